@@ -9,6 +9,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/mudler/LocalAGI/core/agent"
 	"github.com/mudler/LocalAGI/core/state"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/services"
@@ -61,6 +62,34 @@ func runAgent(cmd *cobra.Command, args []string) error {
 // runAgentForeground runs an agent in foreground mode with a single prompt,
 // prints the response, and exits.
 func runAgentForeground(agentName string, agentConfig *state.AgentConfig, promptText string) error {
+	pool, a, err := startForegroundAgent(agentName, agentConfig)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Running agent %q in foreground mode with prompt...\n", agentName)
+
+	// Execute Ask with the prompt using WithText option
+	result := a.Ask(types.WithText(promptText))
+
+	// Print the result
+	if result.Error != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", result.Error.Error())
+		pool.Stop(agentName)
+		return fmt.Errorf("agent error: %s", result.Error.Error())
+	}
+
+	// Print the response
+	fmt.Println(result.Response)
+
+	// Clean up
+	pool.Stop(agentName)
+	return nil
+}
+
+// startForegroundAgent starts an agent without the web server, to be asked
+// directly. The caller stops it through the returned pool.
+func startForegroundAgent(agentName string, agentConfig *state.AgentConfig) (*state.AgentPool, *agent.Agent, error) {
 	// Load all environment variables
 	env := LoadEnv()
 
@@ -75,16 +104,16 @@ func runAgentForeground(agentName string, agentConfig *state.AgentConfig, prompt
 	}
 
 	if env.Model == "" {
-		return fmt.Errorf("model not set: provide 'model' in config or set LOCALAGI_MODEL")
+		return nil, nil, fmt.Errorf("model not set: provide 'model' in config or set LOCALAGI_MODEL")
 	}
 	if env.LLMAPIURL == "" {
-		return fmt.Errorf("API URL not set: provide 'api_url' in config or set LOCALAGI_LLM_API_URL")
+		return nil, nil, fmt.Errorf("API URL not set: provide 'api_url' in config or set LOCALAGI_LLM_API_URL")
 	}
 
 	if env.StateDir == "" {
 		cwd, err := os.Getwd()
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
+			return nil, nil, fmt.Errorf("failed to get working directory: %w", err)
 		}
 		env.StateDir = filepath.Join(cwd, "pool")
 	}
@@ -102,7 +131,7 @@ func runAgentForeground(agentName string, agentConfig *state.AgentConfig, prompt
 	// Initialize skills service
 	skillsService, err := skills.NewService(env.StateDir)
 	if err != nil {
-		return fmt.Errorf("failed to initialize skills service: %w", err)
+		return nil, nil, fmt.Errorf("failed to initialize skills service: %w", err)
 	}
 
 	// Build service factories
@@ -124,41 +153,30 @@ func runAgentForeground(agentName string, agentConfig *state.AgentConfig, prompt
 		env.Timeout, false, skillsService,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create agent pool: %w", err)
+		return nil, nil, fmt.Errorf("failed to create agent pool: %w", err)
 	}
 
 	if env.LocalRAGURL != "" {
 		pool.SetRAGProvider(state.NewHTTPRAGProvider(env.LocalRAGURL, env.LLMAPIKey))
 	}
 
+	secretStore, err := env.SecretStore()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open secrets: %w", err)
+	}
+	pool.SetSecretStore(secretStore)
+
 	// Start the agent
 	if err := pool.StartAgentStandalone(agentName, agentConfig); err != nil {
-		return fmt.Errorf("failed to start agent: %w", err)
+		return nil, nil, fmt.Errorf("failed to start agent: %w", err)
 	}
 
 	a := pool.GetAgent(agentName)
 	if a == nil {
-		return fmt.Errorf("agent %q was not found after starting", agentName)
+		return nil, nil, fmt.Errorf("agent %q was not found after starting", agentName)
 	}
 
-	fmt.Fprintf(os.Stderr, "Running agent %q in foreground mode with prompt...\n", agentName)
-
-	// Execute Ask with the prompt using WithText option
-	result := a.Ask(types.WithText(promptText))
-
-	// Print the result
-	if result.Error != nil {
-		fmt.Fprintf(os.Stderr, "Error: %s\n", result.Error.Error())
-		pool.Stop(agentName)
-		return fmt.Errorf("agent error: %s", result.Error.Error())
-	}
-
-	// Print the response
-	fmt.Println(result.Response)
-
-	// Clean up
-	pool.Stop(agentName)
-	return nil
+	return pool, a, nil
 }
 
 // resolveAgentConfig determines the agent name and config from either
@@ -327,6 +345,12 @@ func startStandaloneAgent(name string, config *state.AgentConfig) error {
 		pool.SetRAGProvider(state.NewHTTPRAGProvider(env.LocalRAGURL, env.LLMAPIKey))
 	}
 
+	secretStore, err := env.SecretStore()
+	if err != nil {
+		return fmt.Errorf("failed to open secrets: %w", err)
+	}
+	pool.SetSecretStore(secretStore)
+
 	// Start the agent via the pool (handles all option building, connectors, etc.)
 	if err := pool.StartAgentStandalone(name, config); err != nil {
 		return fmt.Errorf("failed to start agent: %w", err)
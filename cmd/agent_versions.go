@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	localagi "github.com/mudler/LocalAGI/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serverURL    string
+	serverAPIKey string
+)
+
+var agentVersionsCmd = &cobra.Command{
+	Use:   "versions [agent_name] [version]",
+	Short: "List the configuration versions of an agent, or show one",
+	Long: `List the history of the configuration of an agent of a running LocalAGI
+server, with who changed what and when, or print a version of it:
+  local-agi agent versions my-agent
+  local-agi agent versions my-agent 3`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newServerClient()
+		if len(args) == 2 {
+			version, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid version %q", args[1])
+			}
+			v, err := client.GetConfigVersion(args[0], version)
+			if err != nil {
+				return err
+			}
+			return printJSON(v.Config)
+		}
+
+		history, err := client.ListConfigVersions(args[0])
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tDATE\tAUTHOR\tACTION\tCHANGED")
+		for _, v := range history {
+			changed := ""
+			for i, c := range v.Diff {
+				if i > 0 {
+					changed += ", "
+				}
+				changed += c.Field
+			}
+			if v.Note != "" {
+				changed = v.Note + ": " + changed
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", v.Version, v.CreatedAt.Local().Format(time.DateTime), v.Author, v.Action, changed)
+		}
+		return w.Flush()
+	},
+}
+
+var agentDiffCmd = &cobra.Command{
+	Use:   "diff [agent_name] [from] [to]",
+	Short: "Show the configuration changes between two versions of an agent",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		to, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[2])
+		}
+		changes, err := newServerClient().DiffConfigVersions(args[0], from, to)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			old, _ := json.Marshal(c.Old)
+			updated, _ := json.Marshal(c.New)
+			fmt.Printf("%s:\n  - %s\n  + %s\n", c.Field, old, updated)
+		}
+		return nil
+	},
+}
+
+var agentRollbackCmd = &cobra.Command{
+	Use:   "rollback [agent_name] [version]",
+	Short: "Restart an agent with a previous version of its configuration",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		v, err := newServerClient().RollbackConfigVersion(args[0], version)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Agent %q rolled back to version %d, now at version %d\n", args[0], version, v.Version)
+		return nil
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{agentVersionsCmd, agentDiffCmd, agentRollbackCmd} {
+		c.Flags().StringVar(&serverURL, "url", envOrDefault("LOCALAGI_URL", "http://localhost:3000"), "URL of the LocalAGI server (LOCALAGI_URL)")
+		c.Flags().StringVar(&serverAPIKey, "api-key", os.Getenv("LOCALAGI_API_KEY"), "API key of the LocalAGI server (LOCALAGI_API_KEY)")
+		agentCmd.AddCommand(c)
+	}
+}
+
+func newServerClient() *localagi.Client {
+	return localagi.NewClient(serverURL, serverAPIKey, time.Minute)
+}
+
+func printJSON(data json.RawMessage) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
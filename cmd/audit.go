@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	localagi "github.com/mudler/LocalAGI/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditQuery  localagi.AuditQuery
+	auditFrom   string
+	auditTo     string
+	auditOutput string
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query and export the audit log of a LocalAGI server",
+	Long: `Query and export the audit log of a running LocalAGI server: who created,
+updated, deleted, imported, paused or started agents, who ran actions
+directly, and the actions with side effects the agents ran, with the
+conversation they ran for.`,
+}
+
+// parseAuditQuery reads the time filters of the audit commands
+func parseAuditQuery() (localagi.AuditQuery, error) {
+	q := auditQuery
+	var err error
+	if auditFrom != "" {
+		if q.From, err = parseAuditTime(auditFrom); err != nil {
+			return q, fmt.Errorf("invalid --from: %w", err)
+		}
+	}
+	if auditTo != "" {
+		if q.To, err = parseAuditTime(auditTo); err != nil {
+			return q, fmt.Errorf("invalid --to: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// parseAuditTime accepts RFC3339 times, dates, and durations before now
+func parseAuditTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func formatAuditEntry(e localagi.AuditEntry) string {
+	var parts []string
+	if e.Action != "" {
+		parts = append(parts, e.Action)
+	}
+	details := make([]string, 0, len(e.Details))
+	for k, v := range e.Details {
+		details = append(details, k+"="+v)
+	}
+	sort.Strings(details)
+	parts = append(parts, details...)
+	if e.ConversationID != "" {
+		parts = append(parts, "conversation="+e.ConversationID)
+	}
+	if e.Error != "" {
+		parts = append(parts, "error="+e.Error)
+	}
+	return strings.Join(parts, " ")
+}
+
+var auditListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the entries of the audit log, newest first",
+	Long: `List the entries of the audit log, newest first:
+  local-agi audit list --agent support --from 24h
+  local-agi audit list --operation action.run --conversation slack:C0123`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := parseAuditQuery()
+		if err != nil {
+			return err
+		}
+		entries, err := newServerClient().QueryAuditLog(q)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tACTOR\tOPERATION\tAGENT\tDETAILS")
+		for _, e := range entries {
+			agent := e.Agent
+			if agent == "" {
+				agent = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.DateTime), e.Actor, e.Operation, agent, formatAuditEntry(e))
+		}
+		return w.Flush()
+	},
+}
+
+var auditExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the audit log as JSON lines, oldest first",
+	Long: `Export the matching entries of the audit log as JSON lines, oldest first,
+to stdout or to a file:
+  local-agi audit export --from 2026-01-01 --to 2026-04-01 -o audit-q1.jsonl`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		q, err := parseAuditQuery()
+		if err != nil {
+			return err
+		}
+		out := os.Stdout
+		if auditOutput != "" && auditOutput != "-" {
+			f, err := os.OpenFile(auditOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		return newServerClient().ExportAuditLog(out, q)
+	},
+}
+
+func init() {
+	auditCmd.PersistentFlags().StringVar(&serverURL, "url", envOrDefault("LOCALAGI_URL", "http://localhost:3000"), "URL of the LocalAGI server (LOCALAGI_URL)")
+	auditCmd.PersistentFlags().StringVar(&serverAPIKey, "api-key", os.Getenv("LOCALAGI_API_KEY"), "API key of the LocalAGI server (LOCALAGI_API_KEY)")
+	auditCmd.PersistentFlags().StringVar(&auditFrom, "from", "", "Only entries after this time: RFC3339, a date, or a duration like 24h")
+	auditCmd.PersistentFlags().StringVar(&auditTo, "to", "", "Only entries before this time: RFC3339, a date, or a duration like 1h")
+	auditCmd.PersistentFlags().StringVar(&auditQuery.Actor, "actor", "", "Only entries of this user, or agent:<name> for agents")
+	auditCmd.PersistentFlags().StringVar(&auditQuery.Operation, "operation", "", "Only entries of this operation, e.g. agent.update or action.run")
+	auditCmd.PersistentFlags().StringVar(&auditQuery.Agent, "agent", "", "Only entries about this agent")
+	auditCmd.PersistentFlags().StringVar(&auditQuery.Action, "action", "", "Only entries of this action")
+	auditCmd.PersistentFlags().StringVar(&auditQuery.ConversationID, "conversation", "", "Only entries of this conversation")
+	auditListCmd.Flags().IntVar(&auditQuery.Limit, "limit", 100, "Maximum number of entries")
+	auditExportCmd.Flags().StringVarP(&auditOutput, "output", "o", "", "File to write to, stdout by default")
+	auditCmd.AddCommand(auditListCmd, auditExportCmd)
+	rootCmd.AddCommand(auditCmd)
+}
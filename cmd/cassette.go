@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mudler/LocalAGI/pkg/cassette"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cassetteListen   string
+	cassetteUpstream string
+)
+
+var cassetteCmd = &cobra.Command{
+	Use:   "cassette",
+	Short: "Record and replay LLM requests",
+	Long: `Serve a fake OpenAI compatible API to record the LLM requests of agents
+into a cassette, or to replay a recorded cassette offline.
+
+Point LOCALAGI_LLM_API_URL (or LOCALAI_API_URL for the tests) to the
+listen address:
+  local-agi cassette record session.json --upstream http://localhost:8081
+  local-agi cassette replay session.json`,
+}
+
+var cassetteRecordCmd = &cobra.Command{
+	Use:   "record [cassette]",
+	Short: "Proxy to the LLM API, recording the requests until interrupted",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cassetteUpstream == "" {
+			return fmt.Errorf("--upstream is required to record")
+		}
+		return serveCassette(cassette.ModeRecord, args[0])
+	},
+}
+
+var cassetteReplayCmd = &cobra.Command{
+	Use:   "replay [cassette]",
+	Short: "Answer the LLM requests from a recorded cassette",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return serveCassette(cassette.ModeReplay, args[0])
+	},
+}
+
+func init() {
+	cassetteCmd.PersistentFlags().StringVarP(&cassetteListen, "listen", "l", ":8081", "address to serve the fake LLM API on")
+	cassetteRecordCmd.Flags().StringVarP(&cassetteUpstream, "upstream", "u", "", "URL of the LLM API to record")
+	cassetteCmd.AddCommand(cassetteRecordCmd)
+	cassetteCmd.AddCommand(cassetteReplayCmd)
+	rootCmd.AddCommand(cassetteCmd)
+}
+
+func serveCassette(mode cassette.Mode, path string) error {
+	handler, done, err := cassette.Open(mode, path, cassetteUpstream)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", cassetteListen)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: handler}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+	fmt.Fprintf(os.Stderr, "Serving cassette %s (%s) on %s\n", path, mode, listener.Addr())
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-sigCh:
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	server.Close()
+
+	if err := done(); err != nil {
+		return err
+	}
+	if mode == cassette.ModeRecord {
+		fmt.Fprintf(os.Stderr, "Cassette saved to %s\n", path)
+	}
+	return nil
+}
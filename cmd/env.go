@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/ratelimit"
+	"github.com/mudler/LocalAGI/core/secrets"
 )
 
 // Env contains all environment variables used by LocalAGI
@@ -30,6 +39,33 @@ type Env struct {
 	EnableConversationsLogging bool
 	APIKeys                   []string
 	ConversationDuration      string
+	ConversationMaxMessages   int
+	ConversationMaxCount      int
+	
+	// Usage accounting
+	ModelPricesFile           string
+	
+	// Observability
+	OTLPEndpoint              string
+	
+	// Master key of the encrypted secret store
+	SecretsKey                string
+	
+	// Login to the web UI with an OpenID Connect identity provider
+	OIDCIssuer                string
+	OIDCClientID              string
+	OIDCClientSecret          string
+	OIDCRedirectURL           string
+	OIDCScopes                string
+	OIDCGroupsClaim           string
+	OIDCGroupRoles            string
+	OIDCSessionDuration       string
+	
+	// Rate limits per API key and per connector user
+	APIRateLimit              string
+	APIAgentRateLimit         string
+	ConnectorRateLimit        string
+	ConnectorAgentRateLimit   string
 	
 	// RAG/Vector settings
 	VectorEngine              string
@@ -61,6 +97,21 @@ func LoadEnv() Env {
 		VectorEngine:             os.Getenv("VECTOR_ENGINE"),
 		EmbeddingModel:           os.Getenv("EMBEDDING_MODEL"),
 		DatabaseURL:              os.Getenv("DATABASE_URL"),
+		ModelPricesFile:          os.Getenv("LOCALAGI_MODEL_PRICES"),
+		OTLPEndpoint:             os.Getenv("LOCALAGI_OTLP_ENDPOINT"),
+		SecretsKey:               os.Getenv("LOCALAGI_SECRETS_KEY"),
+		OIDCIssuer:               os.Getenv("LOCALAGI_OIDC_ISSUER"),
+		OIDCClientID:             os.Getenv("LOCALAGI_OIDC_CLIENT_ID"),
+		OIDCClientSecret:         os.Getenv("LOCALAGI_OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:          os.Getenv("LOCALAGI_OIDC_REDIRECT_URL"),
+		OIDCScopes:               os.Getenv("LOCALAGI_OIDC_SCOPES"),
+		OIDCGroupsClaim:          os.Getenv("LOCALAGI_OIDC_GROUPS_CLAIM"),
+		OIDCGroupRoles:           os.Getenv("LOCALAGI_OIDC_GROUP_ROLES"),
+		OIDCSessionDuration:      envOrDefault("LOCALAGI_OIDC_SESSION_DURATION", "12h"),
+		APIRateLimit:             os.Getenv("LOCALAGI_API_RATE_LIMIT"),
+		APIAgentRateLimit:        os.Getenv("LOCALAGI_API_AGENT_RATE_LIMIT"),
+		ConnectorRateLimit:       os.Getenv("LOCALAGI_CONNECTOR_RATE_LIMIT"),
+		ConnectorAgentRateLimit:  os.Getenv("LOCALAGI_CONNECTOR_AGENT_RATE_LIMIT"),
 	}
 	
 	// Parse APIKeys from comma-separated string
@@ -80,6 +131,18 @@ func LoadEnv() Env {
 			env.ChunkOverlap = n
 		}
 	}
+
+	if maxMessagesEnv := os.Getenv("LOCALAGI_CONVERSATION_MAX_MESSAGES"); maxMessagesEnv != "" {
+		if n, err := strconv.Atoi(maxMessagesEnv); err == nil {
+			env.ConversationMaxMessages = n
+		}
+	}
+
+	if maxCountEnv := os.Getenv("LOCALAGI_CONVERSATION_MAX_COUNT"); maxCountEnv != "" {
+		if n, err := strconv.Atoi(maxCountEnv); err == nil {
+			env.ConversationMaxCount = n
+		}
+	}
 	
 	// Set defaults for empty values
 	if env.VectorEngine == "" {
@@ -102,3 +165,77 @@ func envOrDefault(envKey, fallback string) string {
 	}
 	return fallback
 }
+
+// UserStore opens the users of the state directory
+func (e Env) UserStore() (*auth.JSONStore, error) {
+	return auth.NewJSONStore(filepath.Join(e.StateDir, "users.json"))
+}
+
+// AuditLog opens the audit log of the state directory
+func (e Env) AuditLog() (*audit.JSONLog, error) {
+	return audit.NewJSONLog(filepath.Join(e.StateDir, "audit.jsonl"))
+}
+
+// SecretStore opens the secrets of the state directory, encrypted with
+// LOCALAGI_SECRETS_KEY. It returns nil when no key is set.
+func (e Env) SecretStore() (secrets.Store, error) {
+	if e.SecretsKey == "" {
+		return nil, nil
+	}
+	return secrets.NewEncryptedStore(filepath.Join(e.StateDir, "secrets.json"), e.SecretsKey)
+}
+
+// OIDC connects to the identity provider users log in to the web UI with,
+// and returns how long their sessions last. It returns nil when no
+// LOCALAGI_OIDC_ISSUER is set.
+func (e Env) OIDC(ctx context.Context) (*auth.OIDC, time.Duration, error) {
+	if e.OIDCIssuer == "" {
+		return nil, 0, nil
+	}
+	sessionDuration, err := time.ParseDuration(e.OIDCSessionDuration)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid LOCALAGI_OIDC_SESSION_DURATION: %w", err)
+	}
+	groupRoles, err := auth.ParseGroupRoles(e.OIDCGroupRoles)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid LOCALAGI_OIDC_GROUP_ROLES: %w", err)
+	}
+	provider, err := auth.NewOIDC(ctx, auth.OIDCConfig{
+		Issuer:       e.OIDCIssuer,
+		ClientID:     e.OIDCClientID,
+		ClientSecret: e.OIDCClientSecret,
+		RedirectURL:  e.OIDCRedirectURL,
+		Scopes:       strings.Fields(strings.ReplaceAll(e.OIDCScopes, ",", " ")),
+		GroupsClaim:  e.OIDCGroupsClaim,
+		GroupRoles:   groupRoles,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return provider, sessionDuration, nil
+}
+
+// APIRateLimiter returns the rate limits of the API keys, nil when
+// LOCALAGI_API_RATE_LIMIT and LOCALAGI_API_AGENT_RATE_LIMIT are not set
+func (e Env) APIRateLimiter() (*ratelimit.Limiter, error) {
+	return rateLimiter("LOCALAGI_API_RATE_LIMIT", e.APIRateLimit, "LOCALAGI_API_AGENT_RATE_LIMIT", e.APIAgentRateLimit)
+}
+
+// ConnectorRateLimiter returns the rate limits of the connector users, nil
+// when LOCALAGI_CONNECTOR_RATE_LIMIT and LOCALAGI_CONNECTOR_AGENT_RATE_LIMIT
+// are not set
+func (e Env) ConnectorRateLimiter() (*ratelimit.Limiter, error) {
+	return rateLimiter("LOCALAGI_CONNECTOR_RATE_LIMIT", e.ConnectorRateLimit, "LOCALAGI_CONNECTOR_AGENT_RATE_LIMIT", e.ConnectorAgentRateLimit)
+}
+
+func rateLimiter(poolVar, poolRate, agentVar, agentRate string) (*ratelimit.Limiter, error) {
+	var limits ratelimit.Limits
+	var err error
+	if limits.Pool, err = ratelimit.ParseRate(poolRate); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", poolVar, err)
+	}
+	if limits.Agent, err = ratelimit.ParseRate(agentRate); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", agentVar, err)
+	}
+	return ratelimit.New(limits), nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mudler/LocalAGI/core/eval"
+	"github.com/mudler/LocalAGI/core/secrets"
+	"github.com/mudler/LocalAGI/pkg/llm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	evalSuite      string
+	evalFormat     string
+	evalOutput     string
+	evalJudgeModel string
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval [agent_name]",
+	Short: "Run a suite of scripted conversations against an agent",
+	Long: `Run the cases of a YAML or JSON suite against an agent, checking the tools
+it calls, its answers and how long it takes, and write a JSON or JUnit report.
+
+The agent is loaded like with "agent run":
+  local-agi eval my-agent --suite suite.yaml
+  local-agi eval --config agent.json --suite suite.yaml --format junit --output report.xml
+
+The command fails when a case fails, so it can gate CI pipelines.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEval,
+}
+
+func init() {
+	evalCmd.Flags().StringVarP(&configFile, "config", "c", "", "path to agent JSON config file")
+	evalCmd.Flags().StringVarP(&evalSuite, "suite", "s", "", "path to the YAML or JSON suite to run")
+	evalCmd.Flags().StringVarP(&evalFormat, "format", "f", "json", "report format: json or junit")
+	evalCmd.Flags().StringVarP(&evalOutput, "output", "o", "", "path to write the report to (default: stdout)")
+	evalCmd.Flags().StringVar(&evalJudgeModel, "judge-model", "", "model grading the rubrics (default: the model of the agent)")
+	evalCmd.MarkFlagRequired("suite")
+	rootCmd.AddCommand(evalCmd)
+}
+
+func runEval(cmd *cobra.Command, args []string) error {
+	if evalFormat != "json" && evalFormat != "junit" {
+		return fmt.Errorf("unknown report format %q, expected json or junit", evalFormat)
+	}
+
+	suite, err := eval.Load(evalSuite)
+	if err != nil {
+		return err
+	}
+
+	agentName, agentConfig, err := resolveAgentConfig(args)
+	if err != nil {
+		return err
+	}
+
+	pool, a, err := startForegroundAgent(agentName, agentConfig)
+	if err != nil {
+		return err
+	}
+	defer pool.Stop(agentName)
+
+	judgeModel := evalJudgeModel
+	if judgeModel == "" {
+		judgeModel = agentConfig.Model
+	}
+	// The agent config keeps the secret:// references, the judge needs the key
+	judgeKey, err := secrets.Resolve(agentConfig.APIKey, pool.SecretStore())
+	if err != nil {
+		return fmt.Errorf("failed to resolve the API key of the judge: %w", err)
+	}
+	judge := eval.NewLLMJudge(llm.NewClient(judgeKey, agentConfig.APIURL, LoadEnv().Timeout), judgeModel)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Running %d cases against agent %q...\n", len(suite.Cases), agentName)
+	report := eval.Run(ctx, a, judge, suite)
+	report.Agent = agentName
+	for _, c := range report.Cases {
+		status := "PASS"
+		if !c.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(os.Stderr, "%s %s (%.1fs)\n", status, c.Name, c.Latency)
+		for _, f := range c.Failures {
+			fmt.Fprintf(os.Stderr, "    %s\n", f)
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if evalOutput != "" {
+		f, err := os.Create(evalOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create report %q: %w", evalOutput, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if evalFormat == "junit" {
+		err = report.WriteJUnit(out)
+	} else {
+		err = report.WriteJSON(out)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted after %d of %d cases", len(report.Cases), len(suite.Cases))
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d cases failed", report.Failed, len(report.Cases))
+	}
+	return nil
+}
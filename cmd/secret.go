@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage the encrypted secrets of a LocalAGI server",
+	Long: `Manage the secrets of a running LocalAGI server. Agent configurations refer
+to them as secret://<name>, and they are resolved when the agents start.`,
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the secrets, without their values",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := newServerClient().ListSecrets()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tREFERENCE\tUPDATED")
+		for _, s := range list {
+			fmt.Fprintf(w, "%s\tsecret://%s\t%s\n", s.Name, s.Name, s.UpdatedAt.Local().Format(time.DateTime))
+		}
+		return w.Flush()
+	},
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set [name] [value]",
+	Short: "Create or replace a secret",
+	Long: `Create or replace a secret. The value is read from stdin when it is not
+given, to keep it out of the shell history:
+  echo -n "$TOKEN" | local-agi secret set telegram-token`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var value string
+		if len(args) == 2 {
+			value = args[1]
+		} else {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read the value: %w", err)
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+		ref, err := newServerClient().SetSecret(args[0], value)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Secret %q saved, refer to it as %s\n", args[0], ref)
+		return nil
+	},
+}
+
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := newServerClient().DeleteSecret(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Secret %q deleted\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	secretCmd.PersistentFlags().StringVar(&serverURL, "url", envOrDefault("LOCALAGI_URL", "http://localhost:3000"), "URL of the LocalAGI server (LOCALAGI_URL)")
+	secretCmd.PersistentFlags().StringVar(&serverAPIKey, "api-key", os.Getenv("LOCALAGI_API_KEY"), "API key of the LocalAGI server (LOCALAGI_API_KEY)")
+	secretCmd.AddCommand(secretListCmd, secretSetCmd, secretDeleteCmd)
+	rootCmd.AddCommand(secretCmd)
+}
@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/mudler/LocalAGI/core/agent"
 	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/core/tracing"
+	"github.com/mudler/LocalAGI/core/usage"
 	"github.com/mudler/LocalAGI/services"
 	"github.com/mudler/LocalAGI/services/skills"
 	"github.com/mudler/LocalAGI/webui"
@@ -45,6 +48,14 @@ func runServe(cmd *cobra.Command, args []string) error {
 
 	os.MkdirAll(env.StateDir, 0755)
 
+	if env.OTLPEndpoint != "" {
+		shutdown, err := tracing.Setup(context.Background(), env.OTLPEndpoint)
+		if err != nil {
+			return err
+		}
+		defer shutdown(context.Background())
+	}
+
 	if env.CollectionDBPath == "" {
 		env.CollectionDBPath = filepath.Join(env.StateDir, "collections")
 	}
@@ -90,11 +101,35 @@ func runServe(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	users, err := env.UserStore()
+	if err != nil {
+		return err
+	}
+
+	oidc, sessionDuration, err := env.OIDC(context.Background())
+	if err != nil {
+		return err
+	}
+
+	apiRateLimiter, err := env.APIRateLimiter()
+	if err != nil {
+		return err
+	}
+	connectorRateLimiter, err := env.ConnectorRateLimiter()
+	if err != nil {
+		return err
+	}
+	pool.SetRateLimiter(connectorRateLimiter)
+
 	app := webui.NewApp(
 		webui.WithPool(pool),
 		webui.WithSkillsService(skillsService),
 		webui.WithConversationStoreduration(env.ConversationDuration),
+		webui.WithConversationRetention(env.ConversationMaxMessages, env.ConversationMaxCount),
 		webui.WithApiKeys(apiKeys...),
+		webui.WithUsers(users),
+		webui.WithOIDC(oidc, sessionDuration),
+		webui.WithRateLimiter(apiRateLimiter),
 		webui.WithLLMAPIUrl(env.LLMAPIURL),
 		webui.WithLLMAPIKey(env.LLMAPIKey),
 		webui.WithLLMModel(env.Model),
@@ -119,6 +154,27 @@ func runServe(cmd *cobra.Command, args []string) error {
 		})
 	}
 
+	secretStore, err := env.SecretStore()
+	if err != nil {
+		return err
+	}
+	pool.SetSecretStore(secretStore)
+
+	auditLog, err := env.AuditLog()
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+	pool.SetAuditLog(auditLog)
+
+	if env.ModelPricesFile != "" {
+		prices, err := usage.LoadPriceTable(env.ModelPricesFile)
+		if err != nil {
+			return err
+		}
+		pool.SetPriceTable(prices)
+	}
+
 	if err := pool.StartAll(); err != nil {
 		return err
 	}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	localagi "github.com/mudler/LocalAGI/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	userRole   string
+	userGrants []string
+	userKeyTag string
+)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage the users of a LocalAGI server",
+	Long: `Manage the users of a running LocalAGI server, their roles and API keys.
+
+Roles are viewer, operator and admin. The role of a user applies to every
+agent, grants give a user a role on a single agent. While there are no users
+and no LOCALAGI_API_KEYS, the server is open: create an admin and its key
+first.`,
+}
+
+// findUser returns a user of the server
+func findUser(client *localagi.Client, name string) (*localagi.User, error) {
+	users, err := client.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if u.Name == name {
+			return &u, nil
+		}
+	}
+	return nil, fmt.Errorf("user %q not found", name)
+}
+
+// parseGrants parses agent=role pairs
+func parseGrants(grants []string) (map[string]string, error) {
+	agents := map[string]string{}
+	for _, g := range grants {
+		agent, role, ok := strings.Cut(g, "=")
+		if !ok || agent == "" || role == "" {
+			return nil, fmt.Errorf("invalid grant %q, expected agent=role", g)
+		}
+		agents[agent] = role
+	}
+	return agents, nil
+}
+
+func formatGrants(agents map[string]string) string {
+	grants := make([]string, 0, len(agents))
+	for agent, role := range agents {
+		grants = append(grants, agent+"="+role)
+	}
+	sort.Strings(grants)
+	return strings.Join(grants, ",")
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the users and their roles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		users, err := newServerClient().ListUsers()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tROLE\tGRANTS\tKEYS")
+		for _, u := range users {
+			role := u.Role
+			if role == "" {
+				role = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", u.Name, role, formatGrants(u.Agents), len(u.APIKeys))
+		}
+		return w.Flush()
+	},
+}
+
+var userCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a user",
+	Long: `Create a user with a role on every agent and grants on single agents:
+  local-agi user create alice --role viewer --grant support=operator`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		agents, err := parseGrants(userGrants)
+		if err != nil {
+			return err
+		}
+		if err := newServerClient().CreateUser(localagi.User{Name: args[0], Role: userRole, Agents: agents}); err != nil {
+			return err
+		}
+		fmt.Printf("User %q created\n", args[0])
+		return nil
+	},
+}
+
+var userRoleCmd = &cobra.Command{
+	Use:   "role [name] [role]",
+	Short: "Set the role of a user on every agent, none to remove it",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newServerClient()
+		user, err := findUser(client, args[0])
+		if err != nil {
+			return err
+		}
+		user.Role = args[1]
+		if user.Role == "none" {
+			user.Role = ""
+		}
+		if err := client.UpdateUser(*user); err != nil {
+			return err
+		}
+		fmt.Printf("User %q updated\n", args[0])
+		return nil
+	},
+}
+
+var userGrantCmd = &cobra.Command{
+	Use:   "grant [name] [agent] [role]",
+	Short: "Give a user a role on an agent",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newServerClient()
+		user, err := findUser(client, args[0])
+		if err != nil {
+			return err
+		}
+		if user.Agents == nil {
+			user.Agents = map[string]string{}
+		}
+		user.Agents[args[1]] = args[2]
+		if err := client.UpdateUser(*user); err != nil {
+			return err
+		}
+		fmt.Printf("User %q is %s of agent %q\n", args[0], args[2], args[1])
+		return nil
+	},
+}
+
+var userRevokeCmd = &cobra.Command{
+	Use:   "revoke [name] [agent]",
+	Short: "Remove the role of a user on an agent",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := newServerClient()
+		user, err := findUser(client, args[0])
+		if err != nil {
+			return err
+		}
+		delete(user.Agents, args[1])
+		if err := client.UpdateUser(*user); err != nil {
+			return err
+		}
+		fmt.Printf("Grant of user %q on agent %q removed\n", args[0], args[1])
+		return nil
+	},
+}
+
+var userDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a user and revoke its API keys",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := newServerClient().DeleteUser(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("User %q deleted\n", args[0])
+		return nil
+	},
+}
+
+var userKeyCmd = &cobra.Command{
+	Use:   "key [name]",
+	Short: "Generate an API key for a user",
+	Long: `Generate an API key for a user. The key is printed once and can't be
+retrieved again.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, apiKey, err := newServerClient().CreateUserAPIKey(args[0], userKeyTag)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "API key %s created for user %q, store it now, it won't be shown again:\n", apiKey.ID, args[0])
+		fmt.Println(key)
+		return nil
+	},
+}
+
+var userKeysCmd = &cobra.Command{
+	Use:   "keys [name]",
+	Short: "List the API keys of a user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, err := findUser(newServerClient(), args[0])
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tCREATED")
+		for _, k := range user.APIKeys {
+			fmt.Fprintf(w, "%s\t%s\t%s...\t%s\n", k.ID, k.Name, k.Prefix, k.CreatedAt.Local().Format(time.DateTime))
+		}
+		return w.Flush()
+	},
+}
+
+var userRevokeKeyCmd = &cobra.Command{
+	Use:   "revoke-key [name] [id]",
+	Short: "Revoke an API key of a user",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := newServerClient().DeleteUserAPIKey(args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("API key %s of user %q revoked\n", args[1], args[0])
+		return nil
+	},
+}
+
+func init() {
+	userCmd.PersistentFlags().StringVar(&serverURL, "url", envOrDefault("LOCALAGI_URL", "http://localhost:3000"), "URL of the LocalAGI server (LOCALAGI_URL)")
+	userCmd.PersistentFlags().StringVar(&serverAPIKey, "api-key", os.Getenv("LOCALAGI_API_KEY"), "API key of the LocalAGI server (LOCALAGI_API_KEY)")
+	userCreateCmd.Flags().StringVar(&userRole, "role", "", "Role on every agent: viewer, operator or admin")
+	userCreateCmd.Flags().StringArrayVar(&userGrants, "grant", nil, "Role on a single agent, as agent=role (repeatable)")
+	userKeyCmd.Flags().StringVar(&userKeyTag, "name", "", "Name of the key, to tell keys apart")
+	userCmd.AddCommand(userListCmd, userCreateCmd, userRoleCmd, userGrantCmd, userRevokeCmd, userDeleteCmd, userKeyCmd, userKeysCmd, userRevokeKeyCmd)
+	rootCmd.AddCommand(userCmd)
+}
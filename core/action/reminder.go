@@ -14,6 +14,7 @@ import (
 const (
 	RecurringReminderActionName = "set_recurring_task"
 	OneTimeReminderActionName   = "set_onetime_task"
+	TriggeredReminderActionName = "set_triggered_task"
 	ListRemindersName           = "list_tasks"
 	RemoveReminderName          = "remove_task"
 )
@@ -26,6 +27,10 @@ func NewOneTimeReminder() *OneTimeReminderAction {
 	return &OneTimeReminderAction{}
 }
 
+func NewTriggeredReminder() *TriggeredReminderAction {
+	return &TriggeredReminderAction{}
+}
+
 func NewListReminders() *ListRemindersAction {
 	return &ListRemindersAction{}
 }
@@ -36,6 +41,7 @@ func NewRemoveReminder() *RemoveReminderAction {
 
 type RecurringReminderAction struct{}
 type OneTimeReminderAction struct{}
+type TriggeredReminderAction struct{}
 type ListRemindersAction struct{}
 type RemoveReminderAction struct{}
 
@@ -60,7 +66,30 @@ func (a *RecurringReminderAction) Run(ctx context.Context, sharedState *types.Ag
 		return types.ActionResult{}, err
 	}
 
+	if result.Timezone != "" {
+		if err := task.SetTimezone(result.Timezone); err != nil {
+			return types.ActionResult{}, err
+		}
+	}
+
+	if result.Misfire != "" || result.MaxLateness != "" {
+		task.Misfire = &scheduler.MisfirePolicy{Action: scheduler.MisfireAction(result.Misfire)}
+		if err := task.Misfire.Validate(); err != nil {
+			return types.ActionResult{}, err
+		}
+		if result.MaxLateness != "" {
+			maxLateness, err := scheduler.ParseDuration(result.MaxLateness)
+			if err != nil {
+				return types.ActionResult{}, fmt.Errorf("invalid max_lateness, expected a duration like '30m', '2h', '1d': %w", err)
+			}
+			task.Misfire.MaxLatenessMs = maxLateness.Milliseconds()
+		}
+	}
+
 	task.Metadata["reminder_type"] = "user_created"
+	if result.MaxRetries > 0 {
+		task.Retry = &scheduler.RetryPolicy{MaxRetries: result.MaxRetries}
+	}
 
 	err = sharedState.Scheduler.CreateTask(task)
 	if err != nil {
@@ -101,6 +130,9 @@ func (a *OneTimeReminderAction) Run(ctx context.Context, sharedState *types.Agen
 	}
 
 	task.Metadata["reminder_type"] = "user_created"
+	if result.MaxRetries > 0 {
+		task.Retry = &scheduler.RetryPolicy{MaxRetries: result.MaxRetries}
+	}
 
 	err = sharedState.Scheduler.CreateTask(task)
 	if err != nil {
@@ -117,6 +149,50 @@ func (a *OneTimeReminderAction) Run(ctx context.Context, sharedState *types.Agen
 	}, nil
 }
 
+func (a *TriggeredReminderAction) Run(ctx context.Context, sharedState *types.AgentSharedState, params types.ActionParams) (types.ActionResult, error) {
+	result := types.TriggeredReminderParams{}
+	err := params.Unmarshal(&result)
+	if err != nil {
+		return types.ActionResult{}, err
+	}
+
+	if result.On == "" {
+		result.On = string(scheduler.TriggerOnSuccess)
+	}
+
+	task, err := scheduler.NewTriggeredTask(
+		sharedState.AgentName,
+		result.Message,
+		scheduler.TaskTrigger{
+			TaskID: result.AfterTaskID,
+			On:     scheduler.TriggerCondition(result.On),
+			Match:  result.Match,
+		},
+	)
+	if err != nil {
+		return types.ActionResult{}, err
+	}
+
+	task.Metadata["reminder_type"] = "user_created"
+	if result.MaxRetries > 0 {
+		task.Retry = &scheduler.RetryPolicy{MaxRetries: result.MaxRetries}
+	}
+
+	err = sharedState.Scheduler.CreateTask(task)
+	if err != nil {
+		return types.ActionResult{}, err
+	}
+
+	return types.ActionResult{
+		Result: fmt.Sprintf("Task set to run after task %s (%s, ID: %s)", result.AfterTaskID, result.On, task.ID),
+		Metadata: map[string]interface{}{
+			"task_id":       task.ID,
+			"message":       result.Message,
+			"after_task_id": result.AfterTaskID,
+		},
+	}, nil
+}
+
 func (a *ListRemindersAction) Run(ctx context.Context, sharedState *types.AgentSharedState, params types.ActionParams) (types.ActionResult, error) {
 	tasks, err := sharedState.Scheduler.GetAllTasks()
 	if err != nil {
@@ -133,6 +209,16 @@ func (a *ListRemindersAction) Run(ctx context.Context, sharedState *types.AgentS
 	result.WriteString("Current reminders:\n")
 
 	for i, task := range tasks {
+		if task.Trigger != nil {
+			result.WriteString(fmt.Sprintf("%d. %s (Runs after: %s, Trigger: %s, ID: %s)\n",
+				i+1,
+				task.Prompt,
+				task.Trigger.TaskID,
+				task.Trigger.On,
+				task.ID))
+			continue
+		}
+
 		status := "one-time"
 		if task.ScheduleType == scheduler.ScheduleTypeCron || task.ScheduleType == scheduler.ScheduleTypeInterval {
 			status = "recurring"
@@ -200,6 +286,10 @@ func (a *OneTimeReminderAction) Plannable() bool {
 	return true
 }
 
+func (a *TriggeredReminderAction) Plannable() bool {
+	return true
+}
+
 func (a *ListRemindersAction) Plannable() bool {
 	return true
 }
@@ -219,7 +309,24 @@ func (a *RecurringReminderAction) Definition() types.ActionDefinition {
 			},
 			"cron_expr": {
 				Type:        jsonschema.String,
-				Description: "Cron expression for scheduling (e.g. '0 0 * * *' for daily at midnight). Format: 'minute hour day month weekday'",
+				Description: "Cron expression for scheduling (e.g. '0 0 * * *' for daily at midnight). Format: 'minute hour day month weekday', with an optional leading 'second' field",
+			},
+			"timezone": {
+				Type:        jsonschema.String,
+				Description: "Optional IANA timezone the cron expression is evaluated in (e.g. 'America/New_York', 'Europe/Rome'). Defaults to the server timezone",
+			},
+			"misfire": {
+				Type:        jsonschema.String,
+				Enum:        []string{string(scheduler.MisfireSkip), string(scheduler.MisfireRunOnce), string(scheduler.MisfireRunAll)},
+				Description: "Optional, what to do with runs missed while the agent was offline: skip them, run once (default) or run all of them",
+			},
+			"max_lateness": {
+				Type:        jsonschema.String,
+				Description: "Optional, runs later than this duration (e.g. '2h') are skipped, useful for time sensitive tasks like a morning briefing",
+			},
+			"max_retries": {
+				Type:        jsonschema.Integer,
+				Description: "Optional number of times to retry the task if it fails",
 			},
 		},
 		Required: []string{"message", "cron_expr"},
@@ -239,11 +346,46 @@ func (a *OneTimeReminderAction) Definition() types.ActionDefinition {
 				Type:        jsonschema.String,
 				Description: "How long to wait before triggering. Use Go duration format: '30m' (30 minutes), '2h' (2 hours), '1d' (1 day), '1d12h' (1.5 days), '2h30m' (2.5 hours)",
 			},
+			"max_retries": {
+				Type:        jsonschema.Integer,
+				Description: "Optional number of times to retry the task if it fails",
+			},
 		},
 		Required: []string{"message", "delay"},
 	}
 }
 
+func (a *TriggeredReminderAction) Definition() types.ActionDefinition {
+	return types.ActionDefinition{
+		Name:        TriggeredReminderActionName,
+		Description: "Set a task that runs right after another task finishes, to chain tasks together (e.g. summarize the news every morning, then send the summary). The output of the previous task is passed along with the message. Use list_tasks to find the ID of the task to run after.",
+		Properties: map[string]jsonschema.Definition{
+			"message": {
+				Type:        jsonschema.String,
+				Description: "The task to perform with the output of the previous task",
+			},
+			"after_task_id": {
+				Type:        jsonschema.String,
+				Description: "The ID of the task that triggers this one",
+			},
+			"on": {
+				Type:        jsonschema.String,
+				Enum:        []string{string(scheduler.TriggerOnSuccess), string(scheduler.TriggerOnFailure), string(scheduler.TriggerOnComplete)},
+				Description: "When to run: after the previous task succeeds, fails, or in both cases. Defaults to on_success",
+			},
+			"match": {
+				Type:        jsonschema.String,
+				Description: "Optional regular expression the output of the previous task must match for this task to run",
+			},
+			"max_retries": {
+				Type:        jsonschema.Integer,
+				Description: "Optional number of times to retry the task if it fails",
+			},
+		},
+		Required: []string{"message", "after_task_id"},
+	}
+}
+
 func (a *ListRemindersAction) Definition() types.ActionDefinition {
 	return types.ActionDefinition{
 		Name:        ListRemindersName,
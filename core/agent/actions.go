@@ -130,9 +130,9 @@ func (conv Messages) mergeLeadingSystemMessages(prefixBlocks ...string) Messages
 }
 
 // getAvailableActionsForJob returns available actions including user-defined ones for a specific job
-func (a *Agent) getAvailableActionsForJob(job *types.Job) types.Actions {
+func (a *Agent) getAvailableActionsForJob(job *types.Job, live *liveOptions) types.Actions {
 	// Start with regular available actions
-	baseActions := a.availableActions(job)
+	baseActions := a.availableActions(job, live)
 
 	// Add user-defined actions from the job
 	userTools := job.GetUserTools()
@@ -145,10 +145,10 @@ func (a *Agent) getAvailableActionsForJob(job *types.Job) types.Actions {
 	return baseActions
 }
 
-func (a *Agent) availableActions(j *types.Job) types.Actions {
+func (a *Agent) availableActions(j *types.Job, live *liveOptions) types.Actions {
 	//	defaultActions := append(a.options.userActions, action.NewReply())
 
-	defaultActions := slices.Clone(a.options.userActions)
+	defaultActions := slices.Clone(live.userActions)
 	if j.Metadata["type"] == "scheduled" || (a.options.initiateConversations && a.selfEvaluationInProgress) { // && self-evaluation..
 		acts := append(defaultActions, action.NewConversation())
 		if a.options.enableHUD {
@@ -11,19 +11,25 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/mudler/cogito"
-	"github.com/mudler/cogito/clients"
 
 	"github.com/mudler/xlog"
 
 	"github.com/mudler/LocalAGI/core/action"
+	"github.com/mudler/LocalAGI/core/conversations"
+	"github.com/mudler/LocalAGI/core/journal"
+	"github.com/mudler/LocalAGI/core/metrics"
 	"github.com/mudler/LocalAGI/core/scheduler"
+	"github.com/mudler/LocalAGI/core/tracing"
 	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/core/usage"
 	"github.com/mudler/LocalAGI/pkg/llm"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -50,10 +56,21 @@ type Agent struct {
 	sync.Mutex
 	options   *options
 	Character Character
-	client    *openai.Client
 	jobQueue  chan *types.Job
 	context   *types.ActionContext
 
+	// connectorsContext is the context connectors run with, renewed when
+	// they are restarted
+	connectorsContext *types.ActionContext
+
+	// endpoints route the LLM requests, clients holds the OpenAI client of
+	// each of them
+	endpoints *llm.Endpoints
+	clients   []*openai.Client
+
+	// queued counts the jobs waiting to be sent to jobQueue
+	queued atomic.Int64
+
 	currentState *types.AgentInternalState
 
 	selfEvaluationInProgress bool
@@ -61,21 +78,27 @@ type Agent struct {
 
 	newConversations chan *types.ConversationMessage
 
-	mcpSessions []*mcp.ClientSession
-	// only contains the MCP action definitions for observables
-	mcpActionDefinitions types.Actions
+	// liveOptions holds the settings Reconfigure changes, reconfigureMu
+	// serializes the changes
+	liveOptions   atomic.Pointer[liveOptions]
+	reconfigureMu sync.Mutex
 
 	subscriberMutex        sync.Mutex
 	newMessagesSubscribers []func(*types.ConversationMessage)
 
 	observer Observer
 
-	llm         cogito.LLM
 	sharedState *types.AgentSharedState
 
 	// Task scheduler for managing reminders
 	taskScheduler *scheduler.Scheduler
 
+	// journal persists queued jobs so they can be replayed on Run(), nil when disabled
+	journal journal.Journal
+
+	// usage keeps the token usage counters of the agent, nil when disabled
+	usage usage.Store
+
 	// currentJobByConversation tracks the running job per conversation_id for cancel-previous-on-new-message
 	currentJobByConversation map[string]*types.Job
 	currentJobMu             sync.Mutex
@@ -94,28 +117,51 @@ func New(opts ...Option) (*Agent, error) {
 		return nil, fmt.Errorf("failed to set options: %v", err)
 	}
 
-	client := llm.NewClient(options.LLMAPI.APIKey, options.LLMAPI.APIURL, options.timeout)
-	llmClient := clients.NewLocalAILLM(options.LLMAPI.Model, options.LLMAPI.APIKey, options.LLMAPI.APIURL)
+	endpointsOpts := []llm.EndpointsOption{llm.WithLoadBalancing(options.LLMAPI.LoadBalance)}
+	if timeout, err := time.ParseDuration(options.timeout); err == nil {
+		endpointsOpts = append(endpointsOpts, llm.WithRequestTimeout(timeout))
+	}
+	endpoints := llm.NewEndpoints(
+		append([]llm.Endpoint{{URL: options.LLMAPI.APIURL, APIKey: options.LLMAPI.APIKey}}, options.LLMAPI.Endpoints...),
+		endpointsOpts...,
+	)
+	openaiClients := llm.Clients(endpoints, func(e llm.Endpoint) *openai.Client {
+		return llm.NewClient(e.APIKey, e.URL, options.timeout)
+	})
 	c := context.Background()
 	if options.context != nil {
 		c = options.context
 	}
 
+	trackerOpts := []conversations.TrackerOption[string]{
+		conversations.WithRetention[string](options.conversationRetention),
+	}
+	if options.conversationStorePath != "" {
+		conversationStore, err := conversations.NewJSONFileStore[string](options.conversationStorePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create conversation store: %v", err)
+		}
+		trackerOpts = append(trackerOpts, conversations.WithStore[string](conversationStore))
+	}
+
 	ctx, cancel := context.WithCancel(c)
 	a := &Agent{
 		jobQueue:                 make(chan *types.Job),
 		options:                  options,
-		client:                   client,
+		endpoints:                endpoints,
+		clients:                  openaiClients,
 		Character:                options.character,
 		currentState:             &types.AgentInternalState{},
-		llm:                      llmClient,
 		context:                  types.NewActionContext(ctx, cancel),
+		connectorsContext:        types.NewActionContext(context.WithCancel(ctx)),
 		newConversations:         make(chan *types.ConversationMessage),
 		newMessagesSubscribers:   options.newConversationsSubscribers,
-		sharedState:              types.NewAgentSharedState(options.lastMessageDuration),
+		sharedState:              types.NewAgentSharedState(options.lastMessageDuration, trackerOpts...),
 		currentJobByConversation: make(map[string]*types.Job),
 	}
 
+	endpoints.StartHealthChecks(ctx, llm.DefaultHealthCheckInterval)
+
 	// Initialize observer if provided
 	if options.observer != nil {
 		a.observer = options.observer
@@ -139,7 +185,9 @@ func New(opts ...Option) (*Agent, error) {
 	}
 
 	xlog.Info("Populating actions from MCP Servers (if any)")
-	a.initMCPActions()
+	live := a.newLiveOptions(options, nil)
+	live.mcpSessions, live.mcpActionDefinitions = a.connectMCP(options)
+	a.liveOptions.Store(live)
 	xlog.Info("Done populating actions from MCP Servers")
 
 	// Initialize task scheduler for reminders
@@ -148,7 +196,7 @@ func New(opts ...Option) (*Agent, error) {
 		schedulerPath = "scheduled_tasks.json"
 	}
 
-	store, err := scheduler.NewJSONStore(schedulerPath)
+	store, err := scheduler.OpenStore(schedulerPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create scheduler store: %v", err)
 	}
@@ -160,6 +208,24 @@ func New(opts ...Option) (*Agent, error) {
 	}
 
 	a.taskScheduler = scheduler.NewScheduler(store, executor, pollInterval)
+	if options.schedulerDeadLetterHandler != nil {
+		a.taskScheduler.OnDeadLetter(options.schedulerDeadLetterHandler)
+	}
+
+	if options.jobJournalPath != "" {
+		a.journal, err = journal.NewJSONJournal(options.jobJournalPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create job journal: %v", err)
+		}
+		xlog.Info("Job journal initialized", "path", options.jobJournalPath, "interrupted_policy", options.interruptedJobPolicy)
+	}
+
+	if options.usageStorePath != "" {
+		a.usage, err = usage.NewJSONStore(options.usageStorePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create usage store: %v", err)
+		}
+	}
 	a.sharedState.Scheduler = a.taskScheduler
 	a.sharedState.AgentName = a.Character.Name
 	xlog.Info("Task scheduler initialized", "store_path", schedulerPath, "poll_interval", pollInterval)
@@ -186,6 +252,17 @@ func (a *Agent) SetStreamCallback(fn func(cogito.StreamEvent)) {
 	a.options.streamCallback = fn
 }
 
+// forwardStreamEvent sends a streaming event to the agent-wide stream callback
+// and to the job-specific one, if any.
+func (a *Agent) forwardStreamEvent(job *types.Job, ev cogito.StreamEvent) {
+	if a.options.streamCallback != nil {
+		a.options.streamCallback(ev)
+	}
+	if job != nil && job.StreamCallback != nil {
+		job.StreamCallback(ev)
+	}
+}
+
 // StartConversationConsumer starts the goroutine that dispatches new conversation
 // messages to subscribers. This must be called when using AskDirect() without Run(),
 // otherwise the ConversationAction handler will deadlock on the newConversations channel.
@@ -221,14 +298,28 @@ func (a *Agent) AddSubscriber(f func(*types.ConversationMessage)) {
 	a.newMessagesSubscribers = append(a.newMessagesSubscribers, f)
 }
 
+// Context returns the context connectors run with. It is done when the agent
+// stops, or when its connectors are restarted.
 func (a *Agent) Context() context.Context {
-	return a.context.Context
+	a.Lock()
+	defer a.Unlock()
+	return a.connectorsContext.Context
+}
+
+// callbackOptions returns the job options setting the callbacks the agent
+// currently runs with
+func (a *Agent) callbackOptions() []types.JobOption {
+	live := a.live()
+	return []types.JobOption{
+		types.WithReasoningCallback(live.reasoningCallback),
+		types.WithResultCallback(live.resultCallback),
+	}
 }
 
 // Ask is a blocking call that returns the response as soon as it's ready.
 // It discards any other computation.
 func (a *Agent) Ask(opts ...types.JobOption) *types.JobResult {
-	xlog.Debug("Agent Ask()", "agent", a.Character.Name, "model", a.options.LLMAPI.Model)
+	xlog.Debug("Agent Ask()", "agent", a.Character.Name, "model", a.live().model)
 	defer func() {
 		xlog.Debug("Agent has finished being asked", "agent", a.Character.Name)
 	}()
@@ -244,8 +335,7 @@ func (a *Agent) Ask(opts ...types.JobOption) *types.JobResult {
 	return a.Execute(types.NewJob(
 		append(
 			opts,
-			types.WithReasoningCallback(a.options.reasoningCallback),
-			types.WithResultCallback(a.options.resultCallback),
+			a.callbackOptions()...,
 		)...,
 	))
 }
@@ -255,7 +345,7 @@ func (a *Agent) Ask(opts ...types.JobOption) *types.JobResult {
 // AskDirect calls consumeJob directly. This enables stateless execution where
 // the caller manages the event loop
 func (a *Agent) AskDirect(opts ...types.JobOption) *types.JobResult {
-	xlog.Debug("Agent AskDirect()", "agent", a.Character.Name, "model", a.options.LLMAPI.Model)
+	xlog.Debug("Agent AskDirect()", "agent", a.Character.Name, "model", a.live().model)
 	defer func() {
 		xlog.Debug("Agent AskDirect finished", "agent", a.Character.Name)
 	}()
@@ -263,8 +353,7 @@ func (a *Agent) AskDirect(opts ...types.JobOption) *types.JobResult {
 	j := types.NewJob(
 		append(
 			opts,
-			types.WithReasoningCallback(a.options.reasoningCallback),
-			types.WithResultCallback(a.options.resultCallback),
+			a.callbackOptions()...,
 		)...,
 	)
 
@@ -296,7 +385,7 @@ func (a *Agent) AskDirect(opts ...types.JobOption) *types.JobResult {
 		})
 	}
 
-	a.consumeJob(j, UserRole)
+	a.runJob(j, UserRole)
 	return j.Result
 }
 
@@ -311,8 +400,7 @@ func (a *Agent) AskDirectSystem(opts ...types.JobOption) *types.JobResult {
 	j := types.NewJob(
 		append(
 			opts,
-			types.WithReasoningCallback(a.options.reasoningCallback),
-			types.WithResultCallback(a.options.resultCallback),
+			a.callbackOptions()...,
 		)...,
 	)
 
@@ -324,18 +412,30 @@ func (a *Agent) AskDirectSystem(opts ...types.JobOption) *types.JobResult {
 		j.Obs = obs
 	}
 
-	a.consumeJob(j, SystemRole)
+	a.runJob(j, SystemRole)
 	return j.Result
 }
 
 // Ask is a pre-emptive, blocking call that returns the response as soon as it's ready.
 // It discards any other computation.
 func (a *Agent) Execute(j *types.Job) *types.JobResult {
-	xlog.Debug("Agent Execute()", "agent", a.Character.Name, "model", a.options.LLMAPI.Model)
+	xlog.Debug("Agent Execute()", "agent", a.Character.Name, "model", a.live().model)
 	defer func() {
 		xlog.Debug("Agent has finished", "agent", a.Character.Name)
 	}()
 
+	a.observeJob(j)
+
+	a.Enqueue(j)
+	result, err := j.Result.WaitResult(a.context.Context)
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+// observeJob records the job request and its completion in the job's observable
+func (a *Agent) observeJob(j *types.Job) {
 	if j.Obs != nil && a.observer != nil {
 		if len(j.ConversationHistory) > 0 {
 			m := j.ConversationHistory[len(j.ConversationHistory)-1]
@@ -358,22 +458,30 @@ func (a *Agent) Execute(j *types.Job) *types.JobResult {
 			a.observer.Update(*j.Obs)
 		})
 	}
-
-	a.Enqueue(j)
-	result, err := j.Result.WaitResult(a.context.Context)
-	if err != nil {
-		return nil
-	}
-	return result
 }
 
 func (a *Agent) Enqueue(j *types.Job) {
-	j.ReasoningCallback = a.options.reasoningCallback
-	j.ResultCallback = a.options.resultCallback
+	live := a.live()
+	j.ReasoningCallback = live.reasoningCallback
+	j.ResultCallback = live.resultCallback
+
+	// Refuse jobs of senders going over their rate limit before they are
+	// journaled or take a place in the queue
+	if live.rateLimiter != nil {
+		if err := live.rateLimiter(j); err != nil {
+			xlog.Warn("Job refused by the rate limit", "agent", a.Character.Name, "job", j.UUID, "error", err)
+			j.Result.Finish(err)
+			return
+		}
+	}
+
+	if !a.journalJob(j) {
+		return
+	}
 
 	// Cancel previous running job for this conversation if option is enabled
 	cancelPrevious := a.options.cancelPreviousOnNewMessage == nil || *a.options.cancelPreviousOnNewMessage
-	if cancelPrevious && j.Metadata != nil {
+	if cancelPrevious && j.Metadata != nil && !keepsPrevious(j) {
 		if convID, ok := j.Metadata[types.MetadataKeyConversationID].(string); ok && convID != "" {
 			a.currentJobMu.Lock()
 			existing := a.currentJobByConversation[convID]
@@ -384,17 +492,29 @@ func (a *Agent) Enqueue(j *types.Job) {
 		}
 	}
 
-	a.jobQueue <- j
+	a.queueJob(a.context, j)
+}
+
+// keepsPrevious reports whether j runs next to the running job of its
+// conversation, see types.MetadataKeyKeepPrevious
+func keepsPrevious(j *types.Job) bool {
+	keep, _ := j.Metadata[types.MetadataKeyKeepPrevious].(bool)
+	return keep
 }
 
 func (a *Agent) Transcribe(ctx context.Context, file string) (string, error) {
-	resp, err := a.client.CreateTranscription(ctx,
-		openai.AudioRequest{
-			Model:    a.options.LLMAPI.TranscriptionModel,
-			Language: a.options.LLMAPI.TranscriptionLanguage,
-			FilePath: file,
-		},
-	)
+	var resp openai.AudioResponse
+	err := a.withClient(ctx, func(ctx context.Context, client *openai.Client) error {
+		var err error
+		resp, err = client.CreateTranscription(ctx,
+			openai.AudioRequest{
+				Model:    a.options.LLMAPI.TranscriptionModel,
+				Language: a.options.LLMAPI.TranscriptionLanguage,
+				FilePath: file,
+			},
+		)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -405,20 +525,28 @@ func (a *Agent) TTS(ctx context.Context, text string) ([]byte, error) {
 	if a.options.LLMAPI.TTSModel == "" {
 		return nil, fmt.Errorf("TTS model is not set")
 	}
-	resp, err := a.client.CreateSpeech(ctx,
-		openai.CreateSpeechRequest{
-			Model:          openai.SpeechModel(a.options.LLMAPI.TTSModel),
-			Input:          text,
-			ResponseFormat: openai.SpeechResponseFormatMp3,
-		},
-	)
+	buf := bytes.NewBuffer(nil)
+	err := a.withClient(ctx, func(ctx context.Context, client *openai.Client) error {
+		resp, err := client.CreateSpeech(ctx,
+			openai.CreateSpeechRequest{
+				Model:          openai.SpeechModel(a.options.LLMAPI.TTSModel),
+				Input:          text,
+				ResponseFormat: openai.SpeechResponseFormatMp3,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		defer resp.Close()
+
+		// The body is read within the attempt, before its timeout cancels it
+		buf.Reset()
+		_, err = io.Copy(buf, resp)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Close()
-
-	buf := bytes.NewBuffer(nil)
-	io.Copy(buf, resp)
 
 	return buf.Bytes(), nil
 }
@@ -432,11 +560,23 @@ func (a *Agent) Stop() {
 	a.taskScheduler.Stop()
 	xlog.Info("Task scheduler stopped")
 
+	a.reconfigureMu.Lock()
+	defer a.reconfigureMu.Unlock()
 	a.Lock()
 	defer a.Unlock()
 
-	a.closeMCPServers()
+	closeMCPSessions(a.options, a.live().mcpSessions)
 	a.context.Cancel()
+
+	if a.journal != nil {
+		a.journal.Close()
+	}
+	if a.usage != nil {
+		a.usage.Close()
+	}
+	if observer, ok := a.observer.(PersistentObserver); ok && observer.Store() != nil {
+		observer.Store().Close()
+	}
 }
 
 func (a *Agent) Pause() {
@@ -462,9 +602,19 @@ func (a *Agent) Memory() RAGDB {
 	return a.options.ragdb
 }
 
-func (a *Agent) processPrompts(ctx context.Context, conversation Messages) Messages {
+// TaskScheduler returns the scheduler running the agent's tasks
+func (a *Agent) TaskScheduler() *scheduler.Scheduler {
+	return a.taskScheduler
+}
+
+// UsageStore returns the token usage counters of the agent, nil when disabled
+func (a *Agent) UsageStore() usage.Store {
+	return a.usage
+}
+
+func (a *Agent) processPrompts(ctx context.Context, live *liveOptions, conversation Messages) Messages {
 	// Add custom prompts
-	for _, prompt := range a.options.prompts {
+	for _, prompt := range live.prompts {
 		message, err := prompt.Render(a)
 		if err != nil {
 			xlog.Error("Error rendering prompt", "error", err)
@@ -533,11 +683,11 @@ func (a *Agent) processPrompts(ctx context.Context, conversation Messages) Messa
 	}
 
 	// TODO: move to a Promptblock?
-	if a.options.systemPrompt != "" {
-		content := a.options.systemPrompt
+	if live.systemPrompt != "" {
+		content := live.systemPrompt
 
 		if strings.Contains(content, "{{") {
-			promptTemplate, err := templateBase("template", a.options.systemPrompt)
+			promptTemplate, err := templateBase("template", live.systemPrompt)
 			if err != nil {
 				xlog.Error("Error rendering template", "error", err)
 			}
@@ -545,7 +695,7 @@ func (a *Agent) processPrompts(ctx context.Context, conversation Messages) Messa
 			content, err = templateExecute(promptTemplate, CommonTemplateData{AgentName: a.Character.Name})
 			if err != nil {
 				xlog.Error("Error executing template", "error", err)
-				content = a.options.systemPrompt
+				content = live.systemPrompt
 			}
 		}
 
@@ -563,31 +713,46 @@ func (a *Agent) processPrompts(ctx context.Context, conversation Messages) Messa
 
 func (a *Agent) describeImage(ctx context.Context, model, imageURL string) (string, error) {
 	xlog.Debug("Describing image", "model", model)
-	resp, err := a.client.CreateChatCompletion(ctx,
-		openai.ChatCompletionRequest{
-			Model: model,
-			Messages: []openai.ChatCompletionMessage{
-				{
+	ctx, span := tracing.Tracer().Start(ctx, "llm.describe_image",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("llm.model", model)))
+	var resp openai.ChatCompletionResponse
+	err := a.withClient(ctx, func(ctx context.Context, client *openai.Client) error {
+		var err error
+		resp, err = client.CreateChatCompletion(ctx,
+			openai.ChatCompletionRequest{
+				Model: model,
+				Messages: []openai.ChatCompletionMessage{
+					{
 
-					Role: "user",
-					MultiContent: []openai.ChatMessagePart{
-						{
-							Type: openai.ChatMessagePartTypeText,
-							Text: "What is in the image?",
-						},
-						{
-							Type: openai.ChatMessagePartTypeImageURL,
-							ImageURL: &openai.ChatMessageImageURL{
+						Role: "user",
+						MultiContent: []openai.ChatMessagePart{
+							{
+								Type: openai.ChatMessagePartTypeText,
+								Text: "What is in the image?",
+							},
+							{
+								Type: openai.ChatMessagePartTypeImageURL,
+								ImageURL: &openai.ChatMessageImageURL{
 
-								URL: imageURL,
+									URL: imageURL,
+								},
 							},
 						},
 					},
-				},
-			}})
+				}})
+		return err
+	})
+	tracing.End(span, err)
 	if err != nil {
 		return "", err
 	}
+	types.ReportUsage(ctx, model, types.Usage{
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+		Calls:            1,
+	})
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no choices")
 	}
@@ -614,7 +779,7 @@ func extractAllImageContent(message openai.ChatCompletionMessage) (images []stri
 	return
 }
 
-func (a *Agent) processUserInputs(conv Messages) Messages {
+func (a *Agent) processUserInputs(ctx context.Context, conv Messages) Messages {
 
 	// walk conversation history, and check if any message contains images.
 	// If they do, we need to describe the images first with a model that supports image understanding (if the current model doesn't support it)
@@ -640,7 +805,7 @@ func (a *Agent) processUserInputs(conv Messages) Messages {
 			// Process each image in the message
 			var imageDescriptions []string
 			for j, image := range images {
-				imageDescription, err := a.describeImage(a.context.Context, a.options.LLMAPI.MultimodalModel, image)
+				imageDescription, err := a.describeImage(ctx, a.options.LLMAPI.MultimodalModel, image)
 				if err != nil {
 					xlog.Error("Error describing image", "error", err, "messageIndex", i, "imageIndex", j)
 					imageDescriptions = append(imageDescriptions, fmt.Sprintf("Image %d: [Error describing image: %v]", j+1, err))
@@ -680,7 +845,7 @@ func (a *Agent) processUserInputs(conv Messages) Messages {
 	return conv
 }
 
-func (a *Agent) filterJob(job *types.Job) (ok bool, err error) {
+func (a *Agent) filterJob(job *types.Job, live *liveOptions) (ok bool, err error) {
 	hasTriggers := false
 	triggeredBy := ""
 	failedBy := ""
@@ -690,18 +855,24 @@ func (a *Agent) filterJob(job *types.Job) (ok bool, err error) {
 	}
 	job.DoneFilter = true
 
-	if len(a.options.jobFilters) < 1 {
+	if len(live.jobFilters) < 1 {
 		xlog.Debug("No filters")
 		return true, nil
 	}
 
-	for _, filter := range a.options.jobFilters {
+	for _, filter := range live.jobFilters {
 		name := filter.Name()
 		if triggeredBy != "" && filter.IsTrigger() {
 			continue
 		}
 
+		_, span := tracing.Tracer().Start(job.GetContext(), "agent.filter", trace.WithAttributes(
+			attribute.String("agent", a.Character.Name),
+			attribute.String("filter", name),
+		))
 		ok, err = filter.Apply(job)
+		span.SetAttributes(attribute.Bool("filter.ok", ok))
+		tracing.End(span, err)
 		if err != nil {
 			xlog.Error("Error in job filter", "filter", name, "error", err)
 			failedBy = name
@@ -787,14 +958,14 @@ func (a *Agent) replyWithToolCall(job *types.Job, conv []openai.ChatCompletionMe
 }
 
 // validateBuiltinTools checks that builtin tools specified by the user can be matched to available actions
-func (a *Agent) validateBuiltinTools(job *types.Job) {
+func (a *Agent) validateBuiltinTools(job *types.Job, live *liveOptions) {
 	builtinTools := job.GetBuiltinTools()
 	if len(builtinTools) == 0 {
 		return
 	}
 
 	// Get available actions
-	availableActions := a.availableActions(job)
+	availableActions := a.availableActions(job, live)
 
 	for _, tool := range builtinTools {
 		functionName := tool.Name
@@ -896,6 +1067,25 @@ func (a *Agent) addFunctionResultToConversation(ctx context.Context, chosenActio
 }
 
 func (a *Agent) consumeJob(job *types.Job, role string) {
+	ctx, span := tracing.Tracer().Start(job.GetContext(), "agent.job", trace.WithAttributes(
+		attribute.String("agent", a.Character.Name),
+		attribute.String("job.uuid", job.UUID),
+		attribute.String("job.role", role),
+	))
+	job.SetContext(ctx)
+
+	start := time.Now()
+	job.Result.AddFinalizer(func([]openai.ChatCompletionMessage) {
+		tracing.End(span, job.Result.Error)
+
+		status := metrics.StatusCompleted
+		if job.Result.Error != nil {
+			status = metrics.StatusFailed
+		}
+		metrics.JobsFinished.WithLabelValues(a.Character.Name, status).Inc()
+		metrics.JobDuration.WithLabelValues(a.Character.Name).Observe(time.Since(start).Seconds())
+	})
+
 	if err := job.GetContext().Err(); err != nil {
 		job.Result.Finish(fmt.Errorf("expired"))
 		return
@@ -911,17 +1101,21 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 		return
 	}
 
+	// The job runs to its end with the settings it starts with, even if the
+	// agent is reconfigured meanwhile
+	live := a.live()
+
 	// Register this job as the current one for its conversation (for cancel-previous-on-new-message)
 	var conversationID string
 	if job.Metadata != nil {
 		if cid, ok := job.Metadata[types.MetadataKeyConversationID].(string); ok && cid != "" {
 			conversationID = cid
-			a.currentJobMu.Lock()
-			a.currentJobByConversation[conversationID] = job
-			a.currentJobMu.Unlock()
 		}
 	}
-	if conversationID != "" {
+	if conversationID != "" && !keepsPrevious(job) {
+		a.currentJobMu.Lock()
+		a.currentJobByConversation[conversationID] = job
+		a.currentJobMu.Unlock()
 		defer func() {
 			a.currentJobMu.Lock()
 			if a.currentJobByConversation[conversationID] == job {
@@ -930,6 +1124,10 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 			a.currentJobMu.Unlock()
 		}()
 	}
+	if conversationID != "" && job.Obs != nil && a.observer != nil {
+		job.Obs.ConversationID = conversationID
+		a.observer.Update(*job.Obs)
+	}
 
 	// We are self evaluating if we consume the job as a system role
 	selfEvaluation := role == SystemRole
@@ -967,12 +1165,30 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 			if job.Result.Error != nil {
 				job.Obs.Completion.Error = job.Result.Error.Error()
 			}
+			if jobUsage := job.Result.GetUsage(); jobUsage.Calls > 0 {
+				job.Obs.Completion.Usage = &jobUsage
+			}
 			a.observer.Update(*job.Obs)
 		})
 	}
 
-	conv = a.processPrompts(job.GetContext(), conv)
-	if ok, err := a.filterJob(job); !ok || err != nil {
+	// Account the tokens of every LLM call made for this job
+	llmUsage := a.newJobUsage(job, live)
+	job.Result.AddFinalizer(func([]openai.ChatCompletionMessage) {
+		a.recordUsage(llmUsage)
+	})
+	// and of the calls made outside of the agent's LLM, e.g. by job filters
+	job.SetContext(types.WithUsageReporter(job.GetContext(), llmUsage.report))
+
+	// Refuse the job, or downgrade its model, once the token budget is exhausted
+	jobLLM, jobModel, budgetErr := a.budgetedLLM(live, llmUsage)
+	if budgetErr != nil {
+		job.Result.Finish(budgetErr)
+		return
+	}
+
+	conv = a.processPrompts(job.GetContext(), live, conv)
+	if ok, err := a.filterJob(job, live); !ok || err != nil {
 		if err != nil {
 			job.Result.Finish(fmt.Errorf("Error in job filter: %w", err))
 		} else {
@@ -980,13 +1196,13 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 		}
 		return
 	}
-	conv = a.processUserInputs(conv)
+	conv = a.processUserInputs(job.GetContext(), conv)
 
 	// RAG
-	conv = a.knowledgeBaseLookup(job, conv)
+	conv = a.knowledgeBaseLookup(job, live, conv)
 
 	// Validate builtin tools against available actions
-	a.validateBuiltinTools(job)
+	a.validateBuiltinTools(job, live)
 
 	// Merge all leading system messages into one (self-eval, HUD, RAG, system prompt, custom prompts)
 	var selfEvalContent, hudContent string
@@ -994,7 +1210,7 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 		selfEvalContent = pickSelfTemplate
 	}
 	if a.options.enableHUD {
-		prompt, err := renderTemplate(hudTemplate, a.prepareHUD(), a.availableActions(job), "")
+		prompt, err := renderTemplate(hudTemplate, a.prepareHUD(), a.availableActions(job, live), "")
 		if err != nil {
 			job.Result.Finish(fmt.Errorf("error renderTemplate: %w", err))
 			return
@@ -1023,9 +1239,9 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 
 	fragment := cogito.NewFragment(conv...)
 
-	availableActions := a.getAvailableActionsForJob(job)
+	availableActions := a.getAvailableActionsForJob(job, live)
 	cogitoTools := availableActions.ToCogitoTools(job.GetContext(), a.sharedState)
-	allActions := append(availableActions, a.mcpActionDefinitions...)
+	allActions := append(availableActions, live.mcpActionDefinitions...)
 
 	obs := job.Obs
 
@@ -1045,7 +1261,7 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 	var observables = make(map[string]*types.Observable)
 
 	cogitoOpts := []cogito.Option{
-		cogito.WithMCPs(a.mcpSessions...),
+		cogito.WithMCPs(live.mcpSessions...),
 		cogito.WithTools(
 			cogitoTools...,
 		),
@@ -1063,12 +1279,10 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 				return
 			}
 			// Forward reasoning to stream callback
-			if a.options.streamCallback != nil {
-				a.options.streamCallback(cogito.StreamEvent{
-					Type:    cogito.StreamEventReasoning,
-					Content: s,
-				})
-			}
+			a.forwardStreamEvent(job, cogito.StreamEvent{
+				Type:    cogito.StreamEventReasoning,
+				Content: s,
+			})
 			if a.observer != nil && job.Obs != nil {
 				job.Obs.AddProgress(
 					types.Progress{
@@ -1093,6 +1307,15 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 			})
 		}),
 		cogito.WithToolCallResultCallback(func(t cogito.ToolStatus) {
+			if a.options.streamCallback != nil || job.StreamCallback != nil {
+				a.forwardStreamEvent(job, cogito.StreamEvent{
+					Type:       cogito.StreamEventToolResult,
+					ToolName:   t.Name,
+					ToolCallID: t.ToolArguments.ID,
+					ToolResult: t.Result,
+				})
+			}
+
 			toolObs := observables[t.ToolArguments.ID]
 			if a.observer != nil && toolObs != nil {
 				toolObs.Progress = append(toolObs.Progress, types.Progress{
@@ -1161,15 +1384,17 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 				}
 
 				// Forward tool selection to stream callback
-				if a.options.streamCallback != nil {
+				if a.options.streamCallback != nil || job.StreamCallback != nil {
 					toolName := tc.Name
 					if chosenAction != nil {
 						toolName = chosenAction.Definition().Name.String()
 					}
-					a.options.streamCallback(cogito.StreamEvent{
-						Type:     cogito.StreamEventToolCall,
-						ToolName: toolName,
-						ToolArgs: fmt.Sprintf("%v", tc.Arguments),
+					toolArgs, _ := json.Marshal(tc.Arguments)
+					a.forwardStreamEvent(job, cogito.StreamEvent{
+						Type:       cogito.StreamEventToolCall,
+						ToolName:   toolName,
+						ToolArgs:   string(toolArgs),
+						ToolCallID: tc.ID,
 					})
 				}
 
@@ -1180,7 +1405,7 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 					obs.Icon = "brain"
 					obs.Creation = &types.Creation{
 						ChatCompletionRequest: &openai.ChatCompletionRequest{
-							Model:    a.options.LLMAPI.Model,
+							Model:    live.model,
 							Messages: conv,
 						},
 						FunctionDefinition: chosenAction.Definition().ToFunctionDefinition(),
@@ -1318,8 +1543,8 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 			cogitoOpts = append(cogitoOpts, cogito.EnableAutoPlanReEvaluator)
 		}
 		if a.options.LLMAPI.ReviewerModel != "" {
-			llmClient := clients.NewLocalAILLM(a.options.LLMAPI.ReviewerModel, a.options.LLMAPI.APIKey, a.options.LLMAPI.APIURL)
-			cogitoOpts = append(cogitoOpts, cogito.WithReviewerLLM(llmClient))
+			reviewer := a.newLLM(a.options.LLMAPI.ReviewerModel)
+			cogitoOpts = append(cogitoOpts, cogito.WithReviewerLLM(llmUsage.wrap(reviewer, a.options.LLMAPI.ReviewerModel)))
 		}
 	}
 
@@ -1361,12 +1586,22 @@ func (a *Agent) consumeJob(job *types.Job, role string) {
 		cogitoOpts = append(cogitoOpts, cogito.WithMaxRetries(a.options.maxAttempts))
 	}
 
-	if a.options.streamCallback != nil {
-		cogitoOpts = append(cogitoOpts, cogito.WithStreamCallback(a.options.streamCallback))
+	if a.options.streamCallback != nil || job.StreamCallback != nil {
+		cogitoOpts = append(cogitoOpts, cogito.WithStreamCallback(func(ev cogito.StreamEvent) {
+			// Jobs get the actions the agent runs, sent by the tool call
+			// callbacks, rather than the raw tool call deltas of its decisions
+			if ev.Type == cogito.StreamEventToolCall {
+				if a.options.streamCallback != nil {
+					a.options.streamCallback(ev)
+				}
+				return
+			}
+			a.forwardStreamEvent(job, ev)
+		}))
 	}
 
 	fragment, err = cogito.ExecuteTools(
-		a.llm, fragment,
+		llmUsage.wrap(jobLLM, jobModel), fragment,
 		cogitoOpts...,
 	)
 
@@ -1463,9 +1698,10 @@ func (a *Agent) periodicallyRun(timer *time.Timer) {
 		innerMonologue = innerMonologueTemplate
 	}
 	whatNext := types.NewJob(
-		types.WithText(innerMonologue),
-		types.WithReasoningCallback(a.options.reasoningCallback),
-		types.WithResultCallback(a.options.resultCallback),
+		append(
+			[]types.JobOption{types.WithText(innerMonologue)},
+			a.callbackOptions()...,
+		)...,
 	)
 
 	// Attach observable so UI can show standalone job progress (decisions, actions, reasoning)
@@ -1477,7 +1713,7 @@ func (a *Agent) periodicallyRun(timer *time.Timer) {
 		whatNext.Obs = obs
 	}
 
-	a.consumeJob(whatNext, SystemRole)
+	a.runJob(whatNext, SystemRole)
 
 	xlog.Info("STOP -- Periodically run is done", "agent", a.Character.Name)
 }
@@ -1505,6 +1741,10 @@ func (a *Agent) Run() error {
 
 	// we fire the periodicalRunner only once.
 	go a.periodicalRunRunner(timer)
+
+	if a.journal != nil {
+		a.replayJournal()
+	}
 	var errs []error
 	var muErr sync.Mutex
 	var wg sync.WaitGroup
@@ -1540,7 +1780,9 @@ func (a *Agent) run(timer *time.Timer) error {
 				<-timer.C
 			}
 			xlog.Debug("Agent is consuming a job", "agent", a.Character.Name, "job", job)
+			a.journalRunning(job)
 			a.consumeJob(job, UserRole)
+			a.journalFinished(job)
 			timer.Reset(a.options.periodicRuns)
 		case <-a.context.Done():
 			// Agent has been canceled, return error
@@ -1550,6 +1792,34 @@ func (a *Agent) run(timer *time.Timer) error {
 	}
 }
 
+// Await runs wait, which blocks on something outside of the agent such as a
+// human approving an action, while a stand-in worker takes the jobs from the
+// queue, so that a job waiting does not hold up the other ones.
+func (a *Agent) Await(wait func()) {
+	done := make(chan struct{})
+	go a.standIn(done)
+	wait()
+	close(done)
+}
+
+// standIn consumes jobs until done is closed. A job it started is finished
+// before it returns.
+func (a *Agent) standIn(done chan struct{}) {
+	for {
+		select {
+		case job := <-a.jobQueue:
+			xlog.Debug("Stand-in worker is consuming a job", "agent", a.Character.Name, "job", job.UUID)
+			a.journalRunning(job)
+			a.consumeJob(job, UserRole)
+			a.journalFinished(job)
+		case <-done:
+			return
+		case <-a.context.Done():
+			return
+		}
+	}
+}
+
 func (a *Agent) periodicalRunRunner(timer *time.Timer) {
 	for {
 		select {
@@ -1,9 +1,11 @@
 package agent_test
 
 import (
+	"net/http/httptest"
 	"os"
 	"testing"
 
+	"github.com/mudler/LocalAGI/pkg/cassette"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -17,6 +19,11 @@ var testModel = os.Getenv("LOCALAGI_MODEL")
 var apiURL = os.Getenv("LOCALAI_API_URL")
 var apiKeyURL = os.Getenv("LOCALAI_API_KEY")
 
+// LOCALAGI_CASSETTE records the LLM requests of the suite to a cassette, or
+// replays them from it without an API, depending on LOCALAGI_CASSETTE_MODE
+var cassettePath = os.Getenv("LOCALAGI_CASSETTE")
+var cassetteMode = cassette.Mode(os.Getenv("LOCALAGI_CASSETTE_MODE"))
+
 func init() {
 	if testModel == "" {
 		testModel = "hermes-2-pro-mistral"
@@ -25,3 +32,18 @@ func init() {
 		apiURL = "http://192.168.68.113:8080"
 	}
 }
+
+var _ = BeforeSuite(func() {
+	if cassettePath == "" {
+		return
+	}
+	handler, done, err := cassette.Open(cassetteMode, cassettePath, apiURL)
+	Expect(err).ToNot(HaveOccurred())
+	server := httptest.NewServer(handler)
+	apiURL = server.URL
+
+	DeferCleanup(func() {
+		server.Close()
+		Expect(done()).To(Succeed())
+	})
+})
@@ -0,0 +1,100 @@
+package agent_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+
+	. "github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+)
+
+var _ = Describe("Jobs of the same conversation", func() {
+	var (
+		mu       sync.Mutex
+		arrivals int
+		release  chan struct{}
+		agent    *Agent
+	)
+
+	BeforeEach(func() {
+		arrivals = 0
+		release = make(chan struct{})
+		// The LLM answers once released, so that the jobs overlap
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			arrivals++
+			mu.Unlock()
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+			json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{
+					Message:      openai.ChatCompletionMessage{Role: "assistant", Content: "done"},
+					FinishReason: openai.FinishReasonStop,
+				}},
+			})
+		}))
+		DeferCleanup(server.Close)
+
+		var err error
+		agent, err = New(
+			WithLLMAPIURL(server.URL),
+			WithModel("model"),
+			WithSchedulerStorePath(filepath.Join(GinkgoT().TempDir(), "scheduled_tasks.json")),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		go agent.Run()
+		DeferCleanup(agent.Stop)
+	})
+
+	// askBoth asks twice in the conversation, the second time while the first
+	// job is running. It returns whether the first job was cancelled then.
+	askBoth := func(metadata map[string]any) (first, second *types.Job, cancelled bool) {
+		first = types.NewJob(types.WithText("first"), types.WithMetadata(metadata))
+		agent.Enqueue(first)
+		Eventually(func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return arrivals
+		}).Should(Equal(1))
+		second = types.NewJob(types.WithText("second"), types.WithMetadata(metadata))
+		// The agent takes the job once its worker is free
+		go agent.Enqueue(second)
+		Eventually(agent.QueueDepth).Should(Equal(1))
+		cancelled = first.GetContext().Err() != nil
+		close(release)
+		for _, j := range []*types.Job{first, second} {
+			_, err := j.Result.WaitResult(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+		}
+		return first, second, cancelled
+	}
+
+	It("cancels the running job for a new message", func() {
+		_, second, cancelled := askBoth(map[string]any{types.MetadataKeyConversationID: "slack:C1"})
+		Expect(cancelled).To(BeTrue())
+		Expect(second.Result.Error).ToNot(HaveOccurred())
+		Expect(second.Result.Response).To(Equal("done"))
+	})
+
+	It("runs both jobs when the conversation keeps the previous one", func() {
+		first, second, cancelled := askBoth(map[string]any{
+			types.MetadataKeyConversationID: "responses:root",
+			types.MetadataKeyKeepPrevious:   true,
+		})
+		Expect(cancelled).To(BeFalse())
+		Expect(first.Result.Error).ToNot(HaveOccurred())
+		Expect(first.Result.Response).To(Equal("done"))
+		Expect(second.Result.Error).ToNot(HaveOccurred())
+		Expect(second.Result.Response).To(Equal("done"))
+	})
+})
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mudler/LocalAGI/pkg/llm"
+	"github.com/mudler/cogito"
+	"github.com/mudler/cogito/clients"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newLLM returns the LLM answering with model, failing over between the
+// endpoints of the agent
+func (a *Agent) newLLM(model string) cogito.LLM {
+	return traceLLM(&endpointsLLM{
+		endpoints: a.endpoints,
+		clients: llm.Clients(a.endpoints, func(e llm.Endpoint) cogito.LLM {
+			return clients.NewLocalAILLM(model, e.APIKey, e.URL)
+		}),
+	}, model)
+}
+
+// endpointsLLM sends each request to the endpoints in turn until one answers
+type endpointsLLM struct {
+	endpoints *llm.Endpoints
+	clients   []cogito.LLM
+}
+
+func (l *endpointsLLM) Ask(ctx context.Context, f cogito.Fragment) (cogito.Fragment, error) {
+	var result cogito.Fragment
+	err := l.endpoints.Do(ctx, func(ctx context.Context, i int) error {
+		var err error
+		result, err = l.clients[i].Ask(ctx, f)
+		return err
+	})
+	return result, err
+}
+
+func (l *endpointsLLM) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (cogito.LLMReply, cogito.LLMUsage, error) {
+	var reply cogito.LLMReply
+	var usage cogito.LLMUsage
+	err := l.endpoints.Do(ctx, func(ctx context.Context, i int) error {
+		var err error
+		reply, usage, err = l.clients[i].CreateChatCompletion(ctx, request)
+		return err
+	})
+	return reply, usage, err
+}
+
+// CreateChatCompletionStream fails over until a stream is opened, a stream
+// failing midway is not retried
+func (l *endpointsLLM) CreateChatCompletionStream(ctx context.Context, request openai.ChatCompletionRequest) (<-chan cogito.StreamEvent, error) {
+	var events <-chan cogito.StreamEvent
+	err := l.endpoints.Do(ctx, func(_ context.Context, i int) error {
+		streaming, ok := l.clients[i].(cogito.StreamingLLM)
+		if !ok {
+			return fmt.Errorf("LLM client does not support streaming")
+		}
+		var err error
+		// The stream outlives the attempt, so it can't use its timeout
+		events, err = streaming.CreateChatCompletionStream(ctx, request)
+		return err
+	})
+	return events, err
+}
+
+// withClient runs fn with the OpenAI client of each endpoint in turn until
+// one succeeds
+func (a *Agent) withClient(ctx context.Context, fn func(ctx context.Context, client *openai.Client) error) error {
+	return a.endpoints.Do(ctx, func(ctx context.Context, i int) error {
+		return fn(ctx, a.clients[i])
+	})
+}
@@ -1,10 +1,12 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/mudler/LocalAGI/pkg/llm"
+	"github.com/sashabaranov/go-openai"
 )
 
 func (a *Agent) generateIdentity(guidance string) error {
@@ -12,7 +14,9 @@ func (a *Agent) generateIdentity(guidance string) error {
 		guidance = "Generate a random character for roleplaying."
 	}
 
-	err := llm.GenerateTypedJSONWithGuidance(a.context.Context, a.client, "Generate a character as JSON data. "+guidance, a.options.LLMAPI.Model, a.options.character.ToJSONSchema(), &a.options.character)
+	err := a.withClient(a.context.Context, func(ctx context.Context, client *openai.Client) error {
+		return llm.GenerateTypedJSONWithGuidance(ctx, client, "Generate a character as JSON data. "+guidance, a.options.LLMAPI.Model, a.options.character.ToJSONSchema(), &a.options.character)
+	})
 	//err := llm.GenerateJSONFromStruct(a.context.Context, a.client, guidance, a.options.LLMAPI.Model, &a.options.character)
 	a.Character = a.options.character
 	if err != nil {
@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mudler/LocalAGI/core/journal"
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/xlog"
+)
+
+var ErrDuplicateJob = fmt.Errorf("job already submitted")
+
+// journalJob records a job entering the queue. It returns false when the job
+// was already submitted, in which case its result is already finished.
+func (a *Agent) journalJob(j *types.Job) bool {
+	if a.journal == nil {
+		return true
+	}
+
+	existing, err := a.journal.Get(j.UUID)
+	if err == nil {
+		xlog.Info("Job already in journal, not enqueueing it again", "agent", a.Character.Name, "job", j.UUID, "status", existing.Status)
+		if existing.Status != journal.StatusFinished {
+			j.Result.Finish(ErrDuplicateJob)
+			return false
+		}
+		var jobErr error
+		if existing.Error != "" {
+			jobErr = errors.New(existing.Error)
+		}
+		j.Result.SetResponse(existing.Response)
+		j.Result.Finish(jobErr)
+		return false
+	}
+
+	if err := a.journal.Add(journal.NewEntry(j)); err != nil {
+		// A concurrent Enqueue of the same job won the race since the Get above
+		if errors.Is(err, journal.ErrDuplicate) {
+			xlog.Info("Job already in journal, not enqueueing it again", "agent", a.Character.Name, "job", j.UUID)
+			j.Result.Finish(ErrDuplicateJob)
+			return false
+		}
+		xlog.Error("Failed to add job to journal", "agent", a.Character.Name, "job", j.UUID, "error", err)
+	}
+	return true
+}
+
+func (a *Agent) journalRunning(j *types.Job) {
+	if a.journal == nil {
+		return
+	}
+	// Jobs not going through Enqueue (e.g. scheduled tasks) are not journaled
+	if err := a.journal.MarkRunning(j.UUID); err != nil && !errors.Is(err, journal.ErrNotFound) {
+		xlog.Error("Failed to mark job as running", "agent", a.Character.Name, "job", j.UUID, "error", err)
+	}
+}
+
+func (a *Agent) journalFinished(j *types.Job) {
+	if a.journal == nil {
+		return
+	}
+	// The agent is being stopped: leave the job unfinished so it gets recovered on the next Run()
+	if a.context.Err() != nil {
+		return
+	}
+	if err := a.journal.MarkFinished(j.UUID, j.Result.Response, j.Result.Error); err != nil && !errors.Is(err, journal.ErrNotFound) {
+		xlog.Error("Failed to mark job as finished", "agent", a.Character.Name, "job", j.UUID, "error", err)
+	}
+}
+
+// replayJournal enqueues again the jobs that were pending or running when the
+// agent was last stopped, applying the interrupted job policy to the latter.
+func (a *Agent) replayJournal() {
+	entries, err := a.journal.Unfinished()
+	if err != nil {
+		xlog.Error("Failed to read job journal", "agent", a.Character.Name, "error", err)
+		return
+	}
+
+	jobs := []*types.Job{}
+	for _, e := range entries {
+		if e.Status == journal.StatusRunning {
+			if a.options.interruptedJobPolicy == journal.InterruptedFail {
+				xlog.Warn("Job was interrupted, marking it as failed", "agent", a.Character.Name, "job", e.ID)
+				a.journal.MarkFinished(e.ID, "", fmt.Errorf("job interrupted"))
+				continue
+			}
+			if e.Attempts >= journal.MaxAttempts {
+				xlog.Warn("Job was interrupted too many times, giving up", "agent", a.Character.Name, "job", e.ID, "attempts", e.Attempts)
+				a.journal.MarkFinished(e.ID, "", fmt.Errorf("job interrupted %d times", e.Attempts))
+				continue
+			}
+		}
+
+		j := types.NewJob(
+			append(
+				e.JobOptions(),
+				a.callbackOptions()...,
+			)...,
+		)
+		if a.observer != nil {
+			obs := a.observer.NewObservable()
+			obs.Name = "recovered job"
+			obs.Icon = "sync"
+			a.observer.Update(*obs)
+			j.Obs = obs
+			a.observeJob(j)
+		}
+		jobs = append(jobs, j)
+	}
+
+	if len(jobs) == 0 {
+		return
+	}
+
+	xlog.Info("Replaying jobs from journal", "agent", a.Character.Name, "count", len(jobs))
+	handler := a.live().replayedJobHandler
+	go func() {
+		for _, j := range jobs {
+			if !a.queueJob(a.context, j) {
+				return
+			}
+			if handler != nil {
+				go a.deliverReplayedJob(j, handler)
+			}
+		}
+	}()
+}
+
+// deliverReplayedJob hands a replayed job to handler once it finishes. The
+// job is dropped if the agent stops before, it will be replayed again.
+func (a *Agent) deliverReplayedJob(j *types.Job, handler func(job *types.Job)) {
+	if _, err := j.Result.WaitResult(a.context); err != nil {
+		return
+	}
+	handler(j)
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/mudler/LocalAGI/core/journal"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// racingJournal never finds a job, as if a concurrent Enqueue of the same
+// job added it between Get and Add
+type racingJournal struct {
+	journal.Journal
+}
+
+func (racingJournal) Get(id string) (*journal.Entry, error) {
+	return nil, journal.ErrNotFound
+}
+
+var _ = Describe("journalJob", func() {
+	var a *Agent
+
+	BeforeEach(func() {
+		j, err := journal.NewJSONJournal(filepath.Join(GinkgoT().TempDir(), "journal.json"))
+		Expect(err).ToNot(HaveOccurred())
+		a = &Agent{journal: racingJournal{j}}
+		a.Character.Name = "journal-agent"
+	})
+
+	It("does not run a job twice when two enqueues race", func() {
+		first := types.NewJob(types.WithUUID("same-job"))
+		second := types.NewJob(types.WithUUID("same-job"))
+
+		Expect(a.journalJob(first)).To(BeTrue())
+		Expect(a.journalJob(second)).To(BeFalse())
+
+		res, err := second.Result.WaitResult(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Error).To(MatchError(ErrDuplicateJob))
+	})
+})
@@ -7,14 +7,18 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/mudler/LocalAGI/core/tracing"
 	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/core/usage"
 	"github.com/mudler/cogito"
 	"github.com/mudler/xlog"
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (a *Agent) knowledgeBaseLookup(job *types.Job, conv Messages) Messages {
+func (a *Agent) knowledgeBaseLookup(job *types.Job, live *liveOptions, conv Messages) Messages {
 	// Only run KB recall/lookup when KB is explicitly enabled; long-term/summary memory
 	// only affect saving in saveConversation, not this lookup.
 	if !a.options.enableKB || len(conv) <= 0 {
@@ -30,6 +34,14 @@ func (a *Agent) knowledgeBaseLookup(job *types.Job, conv Messages) Messages {
 		return conv
 	}
 
+	ctx := context.Background()
+	if job != nil {
+		ctx = job.GetContext()
+	}
+	_, span := tracing.Tracer().Start(ctx, "agent.knowledge_base_lookup",
+		trace.WithAttributes(attribute.String("agent", a.Character.Name)))
+	defer span.End()
+
 	var obs *types.Observable
 	if job != nil && job.Obs != nil && a.observer != nil {
 		obs = a.observer.NewObservable()
@@ -56,8 +68,10 @@ func (a *Agent) knowledgeBaseLookup(job *types.Job, conv Messages) Messages {
 		return conv
 	}
 
-	results, err := a.options.ragdb.Search(userMessage, a.options.kbResults)
+	results, err := a.options.ragdb.Search(userMessage, live.kbResults)
+	span.SetAttributes(attribute.Int("kb.results", len(results)))
 	if err != nil {
+		span.RecordError(err)
 		xlog.Info("Error finding similar strings inside KB:", "error", err)
 		if obs != nil {
 			obs.AddProgress(types.Progress{
@@ -138,7 +152,11 @@ func (a *Agent) saveCurrentConversation(conv Messages) {
 
 	if a.options.enableSummaryMemory && len(conv) > 0 {
 		fragment := cogito.NewEmptyFragment().AddStartMessage("user", "Summarize the conversation below, keep the highlights as a bullet list:\n"+Messages(conv).String())
-		fragment, err := a.llm.Ask(a.context.Context, fragment)
+		// The summary is accounted as a job of its own
+		live := a.live()
+		summaryUsage := a.newJobUsage(types.NewJob(types.WithMetadata(map[string]interface{}{"type": usage.SourceMemory})), live)
+		fragment, err := summaryUsage.wrap(live.llm, live.model).Ask(a.context.Context, fragment)
+		a.recordUsage(summaryUsage)
 		if err != nil {
 			xlog.Error("Error summarizing conversation", "error", err)
 		}
@@ -98,13 +98,14 @@ func (a *Agent) addTools(client *mcp.ClientSession) (types.Actions, error) {
 			xlog.Error("Failed to unmarshal input schema", "error", err.Error())
 		}
 
-		// Create a new action with Client + tool
-		generatedActions = append(generatedActions, &mcpWrapperAction{
+		// Create a new action with Client + tool. Nothing tells what the
+		// tools of MCP servers do, so their runs are audited.
+		generatedActions = append(generatedActions, types.WithSideEffects(&mcpWrapperAction{
 			mcpClient:       client,
 			toolName:        t.Name,
 			inputSchema:     inputSchema,
 			toolDescription: desc,
-		})
+		}))
 	}
 
 	return generatedActions, nil
@@ -136,19 +137,17 @@ func newBearerTokenRoundTripper(token string, base http.RoundTripper) http.Round
 	}
 }
 
-func (a *Agent) initMCPActions() error {
-	a.closeMCPServers() // Make sure we stop all previous servers if any is active
-
-	a.mcpActionDefinitions = nil
-	var err error
-
+// connectMCP connects to the MCP servers of o and returns their sessions,
+// along with the pre-connected ones, and the actions of their tools
+func (a *Agent) connectMCP(o *options) ([]*mcp.ClientSession, types.Actions) {
+	var sessions []*mcp.ClientSession
 	generatedActions := types.Actions{}
 	client := mcp.NewClient(&mcp.Implementation{Name: "LocalAI", Version: "v1.0.0"}, nil)
 
 	// Connect to a server over stdin/stdout.
 
 	// MCP HTTP Servers
-	for _, mcpServer := range a.options.mcpServers {
+	for _, mcpServer := range o.mcpServers {
 		// Create HTTP client with custom roundtripper for bearer token injection
 		httpclient := &http.Client{
 			Timeout:   360 * time.Second,
@@ -167,7 +166,7 @@ func (a *Agent) initMCPActions() error {
 				continue
 			}
 		}
-		a.mcpSessions = append(a.mcpSessions, session)
+		sessions = append(sessions, session)
 
 		xlog.Debug("Adding tools for MCP server", "server", mcpServer)
 		actions, err := a.addTools(session)
@@ -178,10 +177,10 @@ func (a *Agent) initMCPActions() error {
 	}
 
 	// MCP STDIO Servers
-	if a.options.mcpPrepareScript != "" {
-		xlog.Debug("Preparing MCP", "script", a.options.mcpPrepareScript)
+	if o.mcpPrepareScript != "" {
+		xlog.Debug("Preparing MCP", "script", o.mcpPrepareScript)
 
-		prepareCmd := exec.Command("/bin/bash", "-c", a.options.mcpPrepareScript)
+		prepareCmd := exec.Command("/bin/bash", "-c", o.mcpPrepareScript)
 		output, err := prepareCmd.CombinedOutput()
 		if err != nil {
 			xlog.Error("Failed with error: '%s' - %s", err.Error(), output)
@@ -189,7 +188,7 @@ func (a *Agent) initMCPActions() error {
 		xlog.Debug("Prepared MCP: \n%s", output)
 	}
 
-	for _, mcpStdioServer := range a.options.mcpStdioServers {
+	for _, mcpStdioServer := range o.mcpStdioServers {
 		command := exec.Command(mcpStdioServer.Cmd, mcpStdioServer.Args...)
 		command.Env = os.Environ()
 		command.Env = append(command.Env, mcpStdioServer.Env...)
@@ -201,7 +200,7 @@ func (a *Agent) initMCPActions() error {
 			xlog.Error("Failed to connect to MCP server", "server", mcpStdioServer, "error", err.Error())
 			continue
 		}
-		a.mcpSessions = append(a.mcpSessions, session)
+		sessions = append(sessions, session)
 
 		xlog.Debug("Adding tools for MCP server (stdio)", "server", mcpStdioServer)
 		actions, err := a.addTools(session)
@@ -211,34 +210,30 @@ func (a *Agent) initMCPActions() error {
 		generatedActions = append(generatedActions, actions...)
 	}
 
-	// Pre-connected MCP sessions (e.g. in-process skills server); already in a.mcpSessions after closeMCPServers()
-	for _, session := range a.options.extraMCPSessions {
+	// Pre-connected MCP sessions (e.g. in-process skills server)
+	for _, session := range o.extraMCPSessions {
 		actions, err := a.addTools(session)
 		if err != nil {
 			xlog.Error("Failed to add tools for extra MCP session", "error", err.Error())
 			continue
 		}
-		a.mcpSessions = append(a.mcpSessions, session)
+		sessions = append(sessions, session)
 		generatedActions = append(generatedActions, actions...)
 	}
 
-	a.mcpActionDefinitions = generatedActions
-
-	return err
+	return sessions, generatedActions
 }
 
-func (a *Agent) closeMCPServers() {
+// closeMCPSessions closes the sessions the agent connected, the
+// pre-connected ones of o are left open for their owner
+func closeMCPSessions(o *options, sessions []*mcp.ClientSession) {
 	extraSet := make(map[*mcp.ClientSession]bool)
-	for _, e := range a.options.extraMCPSessions {
+	for _, e := range o.extraMCPSessions {
 		extraSet[e] = true
 	}
-	var keep []*mcp.ClientSession
-	for _, s := range a.mcpSessions {
-		if extraSet[s] {
-			keep = append(keep, s)
-		} else {
+	for _, s := range sessions {
+		if !extraSet[s] {
 			s.Close()
 		}
 	}
-	a.mcpSessions = keep
 }
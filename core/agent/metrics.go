@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/mudler/LocalAGI/core/metrics"
+	"github.com/mudler/LocalAGI/core/types"
+)
+
+// queueJob sends j to the job queue, keeping track of the jobs waiting for the
+// agent loop. It returns false if ctx is done before the job is picked up.
+func (a *Agent) queueJob(ctx context.Context, j *types.Job) bool {
+	metrics.JobsEnqueued.WithLabelValues(a.Character.Name).Inc()
+	a.queued.Add(1)
+	defer a.queued.Add(-1)
+
+	select {
+	case a.jobQueue <- j:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runJob processes j on the calling goroutine. It is counted as enqueued like
+// the jobs going through the queue, so that enqueued and finished jobs add up.
+func (a *Agent) runJob(j *types.Job, role string) {
+	metrics.JobsEnqueued.WithLabelValues(a.Character.Name).Inc()
+	a.consumeJob(j, role)
+}
+
+// QueueDepth returns the number of jobs waiting to be picked up by the agent.
+func (a *Agent) QueueDepth() int {
+	return int(a.queued.Load())
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/mudler/LocalAGI/core/metrics"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("queueJob", func() {
+	var a *Agent
+
+	BeforeEach(func() {
+		a = &Agent{jobQueue: make(chan *types.Job)}
+		a.Character.Name = "queue-agent-" + CurrentSpecReport().LeafNodeText
+	})
+
+	enqueued := func() float64 {
+		return testutil.ToFloat64(metrics.JobsEnqueued.WithLabelValues(a.Character.Name))
+	}
+
+	It("counts the job and tracks the queue depth until it is picked up", func() {
+		queued := make(chan bool)
+		go func() { queued <- a.queueJob(context.Background(), types.NewJob()) }()
+
+		Eventually(a.QueueDepth).Should(Equal(1))
+		Expect(enqueued()).To(Equal(1.0))
+
+		Expect(<-a.jobQueue).NotTo(BeNil())
+		Expect(<-queued).To(BeTrue())
+		Expect(a.QueueDepth()).To(BeZero())
+	})
+
+	It("gives up when the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Expect(a.queueJob(ctx, types.NewJob())).To(BeFalse())
+		Expect(a.QueueDepth()).To(BeZero())
+		Expect(enqueued()).To(Equal(1.0))
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/mudler/LocalAGI/core/history"
 	"github.com/mudler/LocalAGI/core/sse"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/xlog"
@@ -17,6 +18,12 @@ type Observer interface {
 	ClearHistory()
 }
 
+// PersistentObserver is an Observer that also keeps its observables on disk
+type PersistentObserver interface {
+	Observer
+	Store() history.Store
+}
+
 // historyRingSize is the number of observables kept in the ring buffer. When full,
 // the oldest entry is overwritten. The UI builds a tree from parent_id; if a parent
 // is evicted before its children, those children will appear as roots or be omitted.
@@ -30,6 +37,9 @@ type SSEObserver struct {
 	mutex       sync.Mutex
 	history     []types.Observable
 	historyLast int
+
+	// store persists the observables, nil when they are only kept in memory
+	store history.Store
 }
 
 func NewSSEObserver(agent string, manager sse.Manager) *SSEObserver {
@@ -41,6 +51,28 @@ func NewSSEObserver(agent string, manager sse.Manager) *SSEObserver {
 	}
 }
 
+// NewPersistentSSEObserver creates an SSEObserver saving the observables to
+// store. IDs continue after the ones already in the store, and the in-memory
+// history starts with the latest stored observables.
+func NewPersistentSSEObserver(agent string, manager sse.Manager, store history.Store) *SSEObserver {
+	s := NewSSEObserver(agent, manager)
+	s.store = store
+	s.maxID = store.MaxID() + 1
+	for _, obs := range store.Recent(historyRingSize) {
+		s.history[s.historyLast] = obs
+		s.historyLast++
+	}
+	if s.historyLast >= len(s.history) {
+		s.historyLast = 0
+	}
+	return s
+}
+
+// Store returns the store persisting the observables, nil if there is none
+func (s *SSEObserver) Store() history.Store {
+	return s.store
+}
+
 func (s *SSEObserver) NewObservable() *types.Observable {
 	id := atomic.AddInt32(&s.maxID, 1)
 
@@ -59,6 +91,12 @@ func (s *SSEObserver) Update(obs types.Observable) {
 	msg := sse.NewMessage(string(data)).WithEvent("observable_update")
 	s.manager.Send(msg)
 
+	if s.store != nil {
+		if err := s.store.Save(obs); err != nil {
+			xlog.Error("Error saving observable", "agent", s.agent, "error", err)
+		}
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -99,4 +137,10 @@ func (s *SSEObserver) ClearHistory() {
 
 	s.history = make([]types.Observable, historyRingSize)
 	s.historyLast = 0
+
+	if s.store != nil {
+		if err := s.store.Clear(); err != nil {
+			xlog.Error("Error clearing observable history", "agent", s.agent, "error", err)
+		}
+	}
 }
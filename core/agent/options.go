@@ -2,11 +2,17 @@ package agent
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/LocalAGI/core/conversations"
+	"github.com/mudler/LocalAGI/core/journal"
+	"github.com/mudler/LocalAGI/core/scheduler"
 	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/core/usage"
+	"github.com/mudler/LocalAGI/pkg/llm"
 	"github.com/mudler/cogito"
 )
 
@@ -33,6 +39,9 @@ type llmOptions struct {
 	TranscriptionModel    string
 	TranscriptionLanguage string
 	TTSModel              string
+	// Endpoints are tried, in order, after APIURL
+	Endpoints   []llm.Endpoint
+	LoadBalance bool
 }
 
 type options struct {
@@ -56,7 +65,7 @@ type options struct {
 	disableSinkState      bool
 	characterfile         string
 	statefile             string
-	schedulerStorePath    string // Path to scheduler JSON storage file
+	schedulerStorePath    string // Path or URI (json://, sqlite://) of the scheduler storage
 	context               context.Context
 	permanentGoal         string
 	timeout               string
@@ -77,6 +86,9 @@ type options struct {
 	skillPromptTemplate    string
 	schedulerTaskTemplate  string
 
+	// schedulerDeadLetterHandler is notified when a task fails all of its retries
+	schedulerDeadLetterHandler scheduler.DeadLetterHandler
+
 	// callbacks
 	reasoningCallback func(types.ActionCurrentState) bool
 	resultCallback    func(types.ActionState)
@@ -89,13 +101,26 @@ type options struct {
 	extraMCPSessions            []*mcp.ClientSession
 	newConversationsSubscribers []func(*types.ConversationMessage)
 
-	observer             Observer
-	enableAutoCompaction   bool
+	observer                Observer
+	enableAutoCompaction    bool
 	autoCompactionThreshold int
-	parallelJobs int
+	parallelJobs            int
 
 	lastMessageDuration time.Duration
 
+	conversationStorePath string
+	conversationRetention conversations.RetentionPolicy
+
+	jobJournalPath       string
+	interruptedJobPolicy journal.InterruptedPolicy
+	replayedJobHandler   func(job *types.Job)
+
+	usageStorePath string
+	prices         usage.PriceTable
+	budget         usage.Budget
+	budgetNotifier func(job *types.Job, message string)
+	rateLimiter    func(job *types.Job) error
+
 	// cancelPreviousOnNewMessage: when true (or nil), Enqueue cancels the running job for the same conversation_id. When false, jobs are queued.
 	cancelPreviousOnNewMessage *bool
 
@@ -211,6 +236,109 @@ func WithLastMessageDuration(duration string) Option {
 	}
 }
 
+// WithConversationStorePath persists the conversations tracked by the agent
+// (e.g. connector threads) to the given JSON file so they survive restarts
+func WithConversationStorePath(path string) Option {
+	return func(o *options) error {
+		o.conversationStorePath = path
+		return nil
+	}
+}
+
+// WithConversationRetention bounds the number of messages per conversation and
+// the number of conversations kept by the agent. Zero means unlimited.
+func WithConversationRetention(maxMessages, maxConversations int) Option {
+	return func(o *options) error {
+		o.conversationRetention = conversations.RetentionPolicy{
+			MaxMessages:      maxMessages,
+			MaxConversations: maxConversations,
+		}
+		return nil
+	}
+}
+
+// WithJobJournal persists the queued jobs to the given JSON file. Jobs that
+// were pending or running when the agent stopped are replayed on Run().
+func WithJobJournal(path string) Option {
+	return func(o *options) error {
+		o.jobJournalPath = path
+		return nil
+	}
+}
+
+// WithUsageStore persists the token usage counters of the agent to a JSON file
+func WithUsageStore(path string) Option {
+	return func(o *options) error {
+		o.usageStorePath = path
+		return nil
+	}
+}
+
+// WithPriceTable sets the price of the models, used to estimate the cost of the tokens
+func WithPriceTable(prices usage.PriceTable) Option {
+	return func(o *options) error {
+		o.prices = prices
+		return nil
+	}
+}
+
+// WithBudget limits the tokens and the cost the agent can spend. Exhausted
+// budgets refuse new jobs unless the action is "downgrade" and a fallback
+// model is set.
+func WithBudget(budget usage.Budget) Option {
+	return func(o *options) error {
+		if budget.Action != usage.BudgetDowngrade {
+			budget.Action = usage.BudgetRefuse
+		}
+		o.budget = budget
+		return nil
+	}
+}
+
+// WithBudgetNotifier sets the function telling the user a job hit the budget
+func WithBudgetNotifier(notify func(job *types.Job, message string)) Option {
+	return func(o *options) error {
+		o.budgetNotifier = notify
+		return nil
+	}
+}
+
+// WithRateLimiter sets the function checking the rate limit of the sender
+// of a job. Enqueue refuses the job with the error it returns.
+func WithRateLimiter(limit func(job *types.Job) error) Option {
+	return func(o *options) error {
+		o.rateLimiter = limit
+		return nil
+	}
+}
+
+// WithReplayedJobHandler sets the function called with the jobs replayed
+// from the journal once they finish. Nobody waits for their result anymore,
+// so the handler delivers it, e.g. to the connector the job came from.
+func WithReplayedJobHandler(handler func(job *types.Job)) Option {
+	return func(o *options) error {
+		o.replayedJobHandler = handler
+		return nil
+	}
+}
+
+// WithInterruptedJobPolicy sets what happens to journaled jobs that were
+// running when the agent stopped: "retry" (default) runs them again, "fail"
+// marks them as failed.
+func WithInterruptedJobPolicy(policy string) Option {
+	return func(o *options) error {
+		switch journal.InterruptedPolicy(policy) {
+		case journal.InterruptedRetry, journal.InterruptedFail:
+			o.interruptedJobPolicy = journal.InterruptedPolicy(policy)
+		case "":
+			o.interruptedJobPolicy = journal.InterruptedRetry
+		default:
+			return fmt.Errorf("invalid interrupted job policy: %s", policy)
+		}
+		return nil
+	}
+}
+
 func WithParallelJobs(jobs int) Option {
 	return func(o *options) error {
 		o.parallelJobs = jobs
@@ -418,6 +546,24 @@ func WithLLMAPIKey(key string) Option {
 	}
 }
 
+// WithLLMEndpoints adds endpoints serving the same models as the LLM API
+// URL, used when it fails or to spread the load
+func WithLLMEndpoints(endpoints ...llm.Endpoint) Option {
+	return func(o *options) error {
+		o.LLMAPI.Endpoints = append(o.LLMAPI.Endpoints, endpoints...)
+		return nil
+	}
+}
+
+// WithLLMLoadBalancing spreads the LLM requests across all the endpoints
+// instead of only failing over to the next ones
+func WithLLMLoadBalancing(enabled bool) Option {
+	return func(o *options) error {
+		o.LLMAPI.LoadBalance = enabled
+		return nil
+	}
+}
+
 func WithMultimodalModel(model string) Option {
 	return func(o *options) error {
 		o.LLMAPI.MultimodalModel = model
@@ -578,7 +724,8 @@ func WithKBAutoSearch(enabled bool) Option {
 	}
 }
 
-// WithSchedulerStorePath sets the path for the scheduler's JSON storage file
+// WithSchedulerStorePath sets where the scheduler stores its tasks. A plain path
+// (or json://path) uses a JSON file, sqlite://path[?max_runs=N] uses a SQLite database.
 func WithSchedulerStorePath(path string) Option {
 	return func(o *options) error {
 		o.schedulerStorePath = path
@@ -595,6 +742,15 @@ func WithSchedulerTaskTemplate(template string) Option {
 	}
 }
 
+// WithSchedulerDeadLetterHandler sets the handler notified when a scheduled task
+// fails all of its retries and is moved to the dead letter state.
+func WithSchedulerDeadLetterHandler(handler scheduler.DeadLetterHandler) Option {
+	return func(o *options) error {
+		o.schedulerDeadLetterHandler = handler
+		return nil
+	}
+}
+
 var EnableAutoCompaction = func(o *options) error {
 	o.enableAutoCompaction = true
 	return nil
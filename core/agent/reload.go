@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/cogito"
+	"github.com/mudler/xlog"
+)
+
+// liveOptions are the settings Reconfigure changes on a running agent. A
+// published value is never modified, Reconfigure publishes a new one: a job
+// loads it once when it starts and keeps it until it ends.
+type liveOptions struct {
+	llm   cogito.LLM
+	model string
+
+	systemPrompt string
+	prompts      []DynamicPrompt
+	jobFilters   types.JobFilters
+	userActions  types.Actions
+	kbResults    int
+
+	reasoningCallback  func(types.ActionCurrentState) bool
+	resultCallback     func(types.ActionState)
+	budgetNotifier     func(job *types.Job, message string)
+	rateLimiter        func(job *types.Job) error
+	replayedJobHandler func(job *types.Job)
+
+	mcpSessions []*mcp.ClientSession
+	// only contains the MCP action definitions for observables
+	mcpActionDefinitions types.Actions
+}
+
+// newLiveOptions returns the live settings of o, with the MCP sessions of
+// current, or nil when there are none yet
+func (a *Agent) newLiveOptions(o *options, current *liveOptions) *liveOptions {
+	live := &liveOptions{
+		model:              o.LLMAPI.Model,
+		systemPrompt:       o.systemPrompt,
+		prompts:            o.prompts,
+		jobFilters:         o.jobFilters,
+		userActions:        o.userActions,
+		kbResults:          o.kbResults,
+		reasoningCallback:  o.reasoningCallback,
+		resultCallback:     o.resultCallback,
+		budgetNotifier:     o.budgetNotifier,
+		rateLimiter:        o.rateLimiter,
+		replayedJobHandler: o.replayedJobHandler,
+	}
+	if current != nil && current.model == live.model {
+		live.llm = current.llm
+	} else {
+		live.llm = a.newLLM(live.model)
+	}
+	if current != nil {
+		live.mcpSessions = current.mcpSessions
+		live.mcpActionDefinitions = current.mcpActionDefinitions
+	}
+	return live
+}
+
+// live returns the settings the agent currently runs with
+func (a *Agent) live() *liveOptions {
+	return a.liveOptions.Load()
+}
+
+// Reconfigure applies to the running agent the settings of opts that don't
+// need a restart: model, system prompt, dynamic prompts, job filters,
+// actions, knowledge base results and callbacks. The MCP servers are
+// reconnected only if their settings changed. Other settings are ignored.
+//
+// Queued jobs and conversations are kept. Jobs already running finish with
+// the settings they started with, the next ones take the new settings.
+func (a *Agent) Reconfigure(opts ...Option) error {
+	updated, err := newOptions(opts...)
+	if err != nil {
+		return fmt.Errorf("failed to set options: %v", err)
+	}
+
+	a.reconfigureMu.Lock()
+	defer a.reconfigureMu.Unlock()
+
+	current := a.live()
+	live := a.newLiveOptions(updated, current)
+
+	reconnect := !reflect.DeepEqual(updated.mcpServers, a.options.mcpServers) ||
+		!reflect.DeepEqual(updated.mcpStdioServers, a.options.mcpStdioServers) ||
+		updated.mcpPrepareScript != a.options.mcpPrepareScript ||
+		!reflect.DeepEqual(updated.extraMCPSessions, a.options.extraMCPSessions)
+	if reconnect {
+		// Jobs keep using the old sessions until the new ones are published
+		xlog.Info("Reconnecting MCP servers", "agent", a.Character.Name)
+		live.mcpSessions, live.mcpActionDefinitions = a.connectMCP(updated)
+	}
+
+	a.liveOptions.Store(live)
+
+	if reconnect {
+		// Pre-connected sessions are left open for their owner
+		closeMCPSessions(a.options, current.mcpSessions)
+		a.options.mcpServers = updated.mcpServers
+		a.options.mcpStdioServers = updated.mcpStdioServers
+		a.options.mcpPrepareScript = updated.mcpPrepareScript
+		a.options.extraMCPSessions = updated.extraMCPSessions
+	}
+
+	xlog.Info("Agent reconfigured", "agent", a.Character.Name, "model", live.model)
+	return nil
+}
+
+// RestartConnectors ends the context the connectors run with and drops the
+// conversation subscribers they registered, so that new connectors can be
+// started on the agent.
+func (a *Agent) RestartConnectors() {
+	a.Lock()
+	a.connectorsContext.Cancel()
+	a.connectorsContext = types.NewActionContext(context.WithCancel(a.context.Context))
+	a.Unlock()
+
+	a.subscriberMutex.Lock()
+	a.newMessagesSubscribers = slices.Clone(a.options.newConversationsSubscribers)
+	a.subscriberMutex.Unlock()
+}
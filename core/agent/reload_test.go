@@ -0,0 +1,102 @@
+package agent_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+
+	. "github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+)
+
+var _ = Describe("Reconfigure", func() {
+	var (
+		mu sync.Mutex
+		// models maps the system prompt of each request to the models it
+		// was sent with
+		models map[string]map[string]bool
+		server *httptest.Server
+	)
+
+	BeforeEach(func() {
+		models = map[string]map[string]bool{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var request openai.ChatCompletionRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			prompt := ""
+			for _, m := range request.Messages {
+				if m.Role == "system" {
+					prompt = m.Content
+				}
+			}
+			mu.Lock()
+			if models[prompt] == nil {
+				models[prompt] = map[string]bool{}
+			}
+			models[prompt][request.Model] = true
+			mu.Unlock()
+
+			json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+				Model: request.Model,
+				Choices: []openai.ChatCompletionChoice{{
+					Message:      openai.ChatCompletionMessage{Role: "assistant", Content: "done"},
+					FinishReason: openai.FinishReasonStop,
+				}},
+			})
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("keeps the settings a job started with while the agent is reconfigured", func() {
+		dir := GinkgoT().TempDir()
+		settings := func(i int) []Option {
+			return []Option{
+				WithLLMAPIURL(server.URL),
+				WithModel(fmt.Sprintf("model-%d", i)),
+				WithSystemPrompt(fmt.Sprintf("prompt-%d", i)),
+				WithSchedulerStorePath(filepath.Join(dir, "scheduled_tasks.json")),
+			}
+		}
+
+		agent, err := New(settings(0)...)
+		Expect(err).ToNot(HaveOccurred())
+		go agent.Run()
+		defer agent.Stop()
+
+		var jobs sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			jobs.Add(1)
+			go func() {
+				defer jobs.Done()
+				defer GinkgoRecover()
+				for j := 0; j < 5; j++ {
+					result := agent.Ask(types.WithText("hello"))
+					Expect(result).ToNot(BeNil())
+				}
+			}()
+		}
+		for i := 1; i <= 20; i++ {
+			Expect(agent.Reconfigure(settings(i)...)).To(Succeed())
+		}
+		jobs.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(models).ToNot(BeEmpty())
+		for prompt, sent := range models {
+			var i int
+			_, err := fmt.Sscanf(prompt, "prompt-%d", &i)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent).To(Equal(map[string]bool{fmt.Sprintf("model-%d", i): true}), prompt)
+		}
+	})
+})
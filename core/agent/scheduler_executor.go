@@ -36,17 +36,25 @@ func (e *agentSchedulerExecutor) Execute(ctx context.Context, agentName string,
 		}
 	}
 
+	metadata := map[string]any{
+		"message":     prompt,
+		"is_reminder": true,
+		"type":        "scheduled",
+	}
+	if taskID, ok := scheduler.TaskIDFromContext(ctx); ok {
+		metadata["task_id"] = taskID
+	}
+
 	// Create a job for the reminder with the rendered inner monologue
 	reminderJob := types.NewJob(
-		types.WithText(innerMonologue),
-		types.WithReasoningCallback(e.agent.options.reasoningCallback),
-		types.WithResultCallback(e.agent.options.resultCallback),
-		types.WithContext(ctx),
-		types.WithMetadata(map[string]any{
-			"message":     prompt,
-			"is_reminder": true,
-			"type":        "scheduled",
-		}),
+		append(
+			[]types.JobOption{
+				types.WithText(innerMonologue),
+				types.WithContext(ctx),
+				types.WithMetadata(metadata),
+			},
+			e.agent.callbackOptions()...,
+		)...,
 	)
 
 	// Attach observable so UI can show reminder processing state
@@ -59,7 +67,9 @@ func (e *agentSchedulerExecutor) Execute(ctx context.Context, agentName string,
 	}
 
 	// Send the job to be processed
-	e.agent.jobQueue <- reminderJob
+	if !e.agent.queueJob(ctx, reminderJob) {
+		return nil, ctx.Err()
+	}
 
 	// Wait for the job to complete or context to be cancelled
 	select {
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/mudler/LocalAGI/core/tracing"
+	"github.com/mudler/cogito"
+	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceLLM returns an LLM recording a span for each request made with model
+func traceLLM(llm cogito.LLM, model string) cogito.LLM {
+	traced := &tracedLLM{LLM: llm, model: model}
+	if streaming, ok := llm.(cogito.StreamingLLM); ok {
+		return &streamingTracedLLM{tracedLLM: traced, streaming: streaming}
+	}
+	return traced
+}
+
+type tracedLLM struct {
+	cogito.LLM
+	model string
+}
+
+func (l *tracedLLM) start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("llm.model", l.model)),
+	)
+}
+
+func (l *tracedLLM) Ask(ctx context.Context, f cogito.Fragment) (cogito.Fragment, error) {
+	ctx, span := l.start(ctx, "llm.ask")
+	result, err := l.LLM.Ask(ctx, f)
+	if err == nil && result.Status != nil {
+		setUsageAttributes(span, result.Status.LastUsage)
+	}
+	tracing.End(span, err)
+	return result, err
+}
+
+func (l *tracedLLM) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (cogito.LLMReply, cogito.LLMUsage, error) {
+	ctx, span := l.start(ctx, "llm.chat_completion")
+	reply, llmUsage, err := l.LLM.CreateChatCompletion(ctx, request)
+	if err == nil {
+		setUsageAttributes(span, llmUsage)
+	}
+	tracing.End(span, err)
+	return reply, llmUsage, err
+}
+
+type streamingTracedLLM struct {
+	*tracedLLM
+	streaming cogito.StreamingLLM
+}
+
+func (l *streamingTracedLLM) CreateChatCompletionStream(ctx context.Context, request openai.ChatCompletionRequest) (<-chan cogito.StreamEvent, error) {
+	ctx, span := l.start(ctx, "llm.chat_completion_stream")
+	events, err := l.streaming.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	out := make(chan cogito.StreamEvent)
+	go func() {
+		var streamErr error
+		defer close(out)
+		defer func() { tracing.End(span, streamErr) }()
+		for ev := range events {
+			switch {
+			case ev.Error != nil:
+				streamErr = ev.Error
+			case ev.Type == cogito.StreamEventDone:
+				setUsageAttributes(span, ev.Usage)
+			}
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func setUsageAttributes(span trace.Span, u cogito.LLMUsage) {
+	span.SetAttributes(
+		attribute.Int("llm.usage.prompt_tokens", u.PromptTokens),
+		attribute.Int("llm.usage.completion_tokens", u.CompletionTokens),
+		attribute.Int("llm.usage.total_tokens", u.TotalTokens),
+	)
+}
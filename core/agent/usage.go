@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/metrics"
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/core/usage"
+	"github.com/mudler/cogito"
+	"github.com/mudler/xlog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// jobUsage accumulates the token usage of the LLM calls made by a job,
+// per model, into the job result, and stops the job once it exceeds its budget
+type jobUsage struct {
+	agent  string
+	job    *types.Job
+	prices usage.PriceTable
+	budget usage.Budget
+	notify func(message string)
+
+	// usage of the current day and month when the job started
+	day, month types.Usage
+	exceeded   sync.Once
+
+	mu      sync.Mutex
+	byModel map[string]types.Usage
+}
+
+func (a *Agent) newJobUsage(job *types.Job, live *liveOptions) *jobUsage {
+	return &jobUsage{
+		agent:   a.Character.Name,
+		job:     job,
+		prices:  a.options.prices,
+		budget:  a.options.budget,
+		notify:  func(message string) { a.notifyBudget(live, job, message) },
+		byModel: make(map[string]types.Usage),
+	}
+}
+
+// check returns an error once the job used more tokens than allowed, either
+// by itself or added to the usage of the day and month
+func (u *jobUsage) check() error {
+	if !u.budget.Enabled() {
+		return nil
+	}
+
+	total := u.job.Result.GetUsage()
+	err := u.budget.CheckJob(total)
+	// Jobs of downgrading budgets were already switched to the fallback model
+	if err == nil && !u.budget.Downgrades() {
+		day, month := u.day, u.month
+		day.Add(total)
+		month.Add(total)
+		err = u.budget.Check(day, month)
+	}
+	if err != nil {
+		u.exceeded.Do(func() { u.notify(err.Error()) })
+	}
+	return err
+}
+
+func (u *jobUsage) add(model string, llmUsage cogito.LLMUsage) {
+	u.report(model, types.Usage{
+		PromptTokens:     llmUsage.PromptTokens,
+		CompletionTokens: llmUsage.CompletionTokens,
+		TotalTokens:      llmUsage.TotalTokens,
+		Calls:            1,
+	})
+}
+
+// report accounts the usage of a call made with model
+func (u *jobUsage) report(model string, call types.Usage) {
+	call.Cost = u.prices.Cost(model, call)
+
+	u.mu.Lock()
+	m := u.byModel[model]
+	m.Add(call)
+	u.byModel[model] = m
+	u.mu.Unlock()
+
+	u.job.Result.AddUsage(call)
+}
+
+// observe records the latency of an LLM call started at start
+func (u *jobUsage) observe(model string, start time.Time) {
+	metrics.LLMCallDuration.WithLabelValues(u.agent, model).Observe(time.Since(start).Seconds())
+}
+
+// wrap returns an LLM recording the usage of its calls with the given model
+func (u *jobUsage) wrap(llm cogito.LLM, model string) cogito.LLM {
+	wrapped := &usageLLM{LLM: llm, model: model, usage: u}
+	if streaming, ok := llm.(cogito.StreamingLLM); ok {
+		return &streamingUsageLLM{usageLLM: wrapped, streaming: streaming}
+	}
+	return wrapped
+}
+
+// records returns the usage of the job as one record per model
+func (u *jobUsage) records() []usage.Record {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	source := usage.SourceOf(u.job.Metadata)
+	conversationID, _ := u.job.Metadata[types.MetadataKeyConversationID].(string)
+	taskID, _ := u.job.Metadata["task_id"].(string)
+
+	records := make([]usage.Record, 0, len(u.byModel))
+	for model, modelUsage := range u.byModel {
+		records = append(records, usage.Record{
+			Model:          model,
+			Source:         source,
+			ConversationID: conversationID,
+			TaskID:         taskID,
+			Usage:          modelUsage,
+		})
+	}
+	return records
+}
+
+// recordUsage persists the usage of a job once it is done
+func (a *Agent) recordUsage(u *jobUsage) {
+	if a.usage == nil {
+		return
+	}
+	records := u.records()
+	if len(records) == 0 {
+		return
+	}
+	if err := a.usage.Record(records...); err != nil {
+		xlog.Error("Failed to record token usage", "agent", a.Character.Name, "error", err)
+	}
+}
+
+// budgetedLLM returns the LLM and the model a job runs with: the agent's
+// ones, or the fallback model once a downgrading budget is exhausted. It
+// fails with usage.ErrBudgetExceeded when the budget refuses new jobs.
+func (a *Agent) budgetedLLM(live *liveOptions, u *jobUsage) (cogito.LLM, string, error) {
+	budget := a.options.budget
+	if !budget.Enabled() || a.usage == nil {
+		return live.llm, live.model, nil
+	}
+
+	now := time.Now()
+	day, err := a.usage.Since(usage.DayStart(now))
+	if err != nil {
+		xlog.Error("Failed to read token usage", "agent", a.Character.Name, "error", err)
+		return live.llm, live.model, nil
+	}
+	month, err := a.usage.Since(usage.MonthStart(now))
+	if err != nil {
+		xlog.Error("Failed to read token usage", "agent", a.Character.Name, "error", err)
+		return live.llm, live.model, nil
+	}
+	u.day, u.month = day, month
+
+	if err := budget.Check(day, month); err != nil {
+		if budget.Downgrades() {
+			u.exceeded.Do(func() {
+				u.notify(fmt.Sprintf("%s, answering with %s", err, budget.FallbackModel))
+			})
+			return a.newLLM(budget.FallbackModel), budget.FallbackModel, nil
+		}
+		u.exceeded.Do(func() { u.notify(err.Error()) })
+		return nil, "", err
+	}
+	return live.llm, live.model, nil
+}
+
+// notifyBudget tells the originating connector that a job hit a budget
+func (a *Agent) notifyBudget(live *liveOptions, job *types.Job, message string) {
+	xlog.Warn("Token budget reached", "agent", a.Character.Name, "job", job.UUID, "message", message)
+	if live.budgetNotifier != nil {
+		live.budgetNotifier(job, message)
+	}
+}
+
+type usageLLM struct {
+	cogito.LLM
+	model string
+	usage *jobUsage
+}
+
+// Ask sends the fragment as a chat completion through CreateChatCompletion,
+// rather than with the Ask of the LLM, so that each request it makes is
+// checked against the budget and accounted
+func (l *usageLLM) Ask(ctx context.Context, f cogito.Fragment) (cogito.Fragment, error) {
+	reply, llmUsage, err := l.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Messages: f.GetMessages(),
+	})
+	if err != nil {
+		return cogito.Fragment{}, err
+	}
+	if len(reply.ChatCompletionResponse.Choices) == 0 {
+		return cogito.Fragment{}, fmt.Errorf("no choices in response")
+	}
+
+	result := cogito.Fragment{
+		Messages:       append(f.Messages, reply.ChatCompletionResponse.Choices[0].Message),
+		ParentFragment: &f,
+		Status:         f.Status,
+	}
+	if result.Status == nil {
+		result.Status = &cogito.Status{}
+	}
+	result.Status.LastUsage = llmUsage
+	return result, nil
+}
+
+func (l *usageLLM) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (cogito.LLMReply, cogito.LLMUsage, error) {
+	if err := l.usage.check(); err != nil {
+		return cogito.LLMReply{}, cogito.LLMUsage{}, err
+	}
+	start := time.Now()
+	reply, llmUsage, err := l.LLM.CreateChatCompletion(ctx, request)
+	l.usage.observe(l.model, start)
+	if err == nil {
+		l.usage.add(l.model, llmUsage)
+	}
+	return reply, llmUsage, err
+}
+
+type streamingUsageLLM struct {
+	*usageLLM
+	streaming cogito.StreamingLLM
+}
+
+func (l *streamingUsageLLM) CreateChatCompletionStream(ctx context.Context, request openai.ChatCompletionRequest) (<-chan cogito.StreamEvent, error) {
+	if err := l.usage.check(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	events, err := l.streaming.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		l.usage.observe(l.model, start)
+		return nil, err
+	}
+
+	out := make(chan cogito.StreamEvent)
+	go func() {
+		defer close(out)
+		for ev := range events {
+			if ev.Type == cogito.StreamEventDone {
+				l.usage.observe(l.model, start)
+				l.usage.add(l.model, ev.Usage)
+			}
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
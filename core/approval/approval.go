@@ -0,0 +1,204 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAGI/core/types"
+)
+
+// Status represents the state of an approval request
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusDenied   Status = "denied"
+	StatusExpired  Status = "expired"
+)
+
+// DefaultTimeout is how long a request waits for an answer when no timeout is configured
+const DefaultTimeout = 10 * time.Minute
+
+var (
+	ErrNotFound        = errors.New("approval request not found")
+	ErrAlreadyResolved = errors.New("approval request already resolved")
+)
+
+// Request is an action waiting for a human to approve it before running
+type Request struct {
+	ID             string             `json:"id"`
+	Agent          string             `json:"agent"`
+	Action         string             `json:"action"`
+	Params         types.ActionParams `json:"params"`
+	Reasoning      string             `json:"reasoning"`
+	JobID          string             `json:"job_id"`
+	ConversationID string             `json:"conversation_id,omitempty"`
+	Status         Status             `json:"status"`
+	DecidedBy      string             `json:"decided_by,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	ExpiresAt      time.Time          `json:"expires_at"`
+	DecidedAt      time.Time          `json:"decided_at,omitempty"`
+
+	decision chan bool
+}
+
+// Manager keeps track of the approval requests of the agents in a pool
+type Manager struct {
+	mu       sync.Mutex
+	requests map[string]*Request
+	// resolved requests are kept for a while so that they can be listed
+	history time.Duration
+}
+
+// NewManager creates a new approval manager
+func NewManager() *Manager {
+	return &Manager{
+		requests: make(map[string]*Request),
+		history:  time.Hour,
+	}
+}
+
+// NewRequest creates a pending approval request for the action chosen in the given state
+func NewRequest(agentName string, state types.ActionCurrentState, timeout time.Duration) *Request {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	now := time.Now()
+	r := &Request{
+		ID:        uuid.New().String(),
+		Agent:     agentName,
+		Params:    state.Params,
+		Reasoning: state.Reasoning,
+		Status:    StatusPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(timeout),
+	}
+	if state.Action != nil {
+		r.Action = state.Action.Definition().Name.String()
+	}
+	if state.Job != nil {
+		r.JobID = state.Job.UUID
+		if cid, ok := state.Job.Metadata[types.MetadataKeyConversationID].(string); ok {
+			r.ConversationID = cid
+		}
+	}
+	return r
+}
+
+// Add registers a pending request so that it can be listed and resolved
+func (m *Manager) Add(r *Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cleanup()
+	r.decision = make(chan bool, 1)
+	m.requests[r.ID] = r
+}
+
+// Wait blocks until the request is resolved, it expires or ctx is done.
+// It returns true only if the request was approved.
+func (m *Manager) Wait(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	r, exists := m.requests[id]
+	m.mu.Unlock()
+	if !exists {
+		return false, ErrNotFound
+	}
+
+	timer := time.NewTimer(time.Until(r.ExpiresAt))
+	defer timer.Stop()
+
+	select {
+	case approved := <-r.decision:
+		return approved, nil
+	case <-timer.C:
+		m.finish(r, StatusExpired, "")
+		return false, nil
+	case <-ctx.Done():
+		m.finish(r, StatusExpired, "")
+		return false, ctx.Err()
+	}
+}
+
+// Resolve approves or denies a pending request
+func (m *Manager) Resolve(id string, approved bool, by string) error {
+	m.mu.Lock()
+	r, exists := m.requests[id]
+	m.mu.Unlock()
+	if !exists {
+		return ErrNotFound
+	}
+
+	status := StatusDenied
+	if approved {
+		status = StatusApproved
+	}
+	if !m.finish(r, status, by) {
+		return ErrAlreadyResolved
+	}
+	r.decision <- approved
+	return nil
+}
+
+// finish moves a pending request to its final status, returns false if it was already resolved
+func (m *Manager) finish(r *Request, status Status, by string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r.Status != StatusPending {
+		return false
+	}
+	r.Status = status
+	r.DecidedBy = by
+	r.DecidedAt = time.Now()
+	return true
+}
+
+// Get returns a copy of a request
+func (m *Manager) Get(id string) (Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, exists := m.requests[id]
+	if !exists {
+		return Request{}, ErrNotFound
+	}
+	return *r, nil
+}
+
+// List returns the requests of an agent (all agents if empty), newest first.
+// When pendingOnly is set, resolved requests are left out.
+func (m *Manager) List(agentName string, pendingOnly bool) []Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cleanup()
+	requests := []Request{}
+	for _, r := range m.requests {
+		if agentName != "" && r.Agent != agentName {
+			continue
+		}
+		if pendingOnly && r.Status != StatusPending {
+			continue
+		}
+		requests = append(requests, *r)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].CreatedAt.After(requests[j].CreatedAt)
+	})
+	return requests
+}
+
+// cleanup drops resolved requests older than the history window (must be called with lock held)
+func (m *Manager) cleanup() {
+	for id, r := range m.requests {
+		if r.Status != StatusPending && time.Since(r.DecidedAt) > m.history {
+			delete(m.requests, id)
+		}
+	}
+}
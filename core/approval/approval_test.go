@@ -0,0 +1,72 @@
+package approval_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/approval"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manager", func() {
+	var m *approval.Manager
+
+	BeforeEach(func() {
+		m = approval.NewManager()
+	})
+
+	newRequest := func(agentName string, timeout time.Duration) *approval.Request {
+		job := types.NewJob(types.WithText("hello"))
+		return approval.NewRequest(agentName, types.ActionCurrentState{Job: job}, timeout)
+	}
+
+	It("should unblock the waiter when a request is approved", func() {
+		r := newRequest("agent", time.Minute)
+		m.Add(r)
+
+		go func() {
+			defer GinkgoRecover()
+			Expect(m.Resolve(r.ID, true, "tester")).To(Succeed())
+		}()
+
+		approved, err := m.Wait(context.Background(), r.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(approved).To(BeTrue())
+
+		got, err := m.Get(r.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.Status).To(Equal(approval.StatusApproved))
+		Expect(got.DecidedBy).To(Equal("tester"))
+		Expect(got.JobID).To(Equal(r.JobID))
+	})
+
+	It("should expire requests that are not answered in time", func() {
+		r := newRequest("agent", 50*time.Millisecond)
+		m.Add(r)
+
+		approved, err := m.Wait(context.Background(), r.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(approved).To(BeFalse())
+
+		got, _ := m.Get(r.ID)
+		Expect(got.Status).To(Equal(approval.StatusExpired))
+		Expect(m.Resolve(r.ID, true, "late")).To(MatchError(approval.ErrAlreadyResolved))
+	})
+
+	It("should list pending requests by agent", func() {
+		a := newRequest("a", time.Minute)
+		b := newRequest("b", time.Minute)
+		m.Add(a)
+		m.Add(b)
+		Expect(m.Resolve(b.ID, false, "tester")).To(Succeed())
+
+		Expect(m.List("", false)).To(HaveLen(2))
+		Expect(m.List("b", true)).To(BeEmpty())
+		pending := m.List("", true)
+		Expect(pending).To(HaveLen(1))
+		Expect(pending[0].ID).To(Equal(a.ID))
+		Expect(m.Resolve("missing", true, "tester")).To(MatchError(approval.ErrNotFound))
+	})
+})
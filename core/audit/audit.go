@@ -0,0 +1,126 @@
+// Package audit keeps an append-only trail of who changed agents, users and
+// secrets, who ran actions and which actions with side effects the agents
+// ran.
+package audit
+
+import (
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Operation is what an entry of the audit log records
+type Operation string
+
+const (
+	OperationAgentCreate Operation = "agent.create"
+	OperationAgentImport Operation = "agent.import"
+	OperationAgentUpdate Operation = "agent.update"
+	OperationAgentDelete Operation = "agent.delete"
+	OperationAgentPause  Operation = "agent.pause"
+	OperationAgentStart  Operation = "agent.start"
+	// OperationActionExecute is an action run directly by a user
+	OperationActionExecute Operation = "action.execute"
+	// OperationActionRun is an action with side effects run by an agent
+	OperationActionRun Operation = "action.run"
+
+	OperationUserCreate   Operation = "user.create"
+	OperationUserUpdate   Operation = "user.update"
+	OperationUserDelete   Operation = "user.delete"
+	OperationAPIKeyCreate Operation = "apikey.create"
+	OperationAPIKeyDelete Operation = "apikey.delete"
+	// OperationSecretSet is a secret created or replaced, its value is never
+	// recorded
+	OperationSecretSet    Operation = "secret.set"
+	OperationSecretDelete Operation = "secret.delete"
+)
+
+// DefaultLimit is the number of entries returned by a query without a limit
+const DefaultLimit = 100
+
+// MaxResultLength is the length action results are truncated to
+const MaxResultLength = 2000
+
+// AgentActor prefixes the agent name in the actor of the actions run by
+// agents
+const AgentActor = "agent:"
+
+// Entry is a record of the audit log
+type Entry struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Actor is the user who did the operation, or agent:<name> for the
+	// actions run by agents
+	Actor     string    `json:"actor"`
+	Operation Operation `json:"operation"`
+	Agent     string    `json:"agent,omitempty"`
+	Action    string    `json:"action,omitempty"`
+	// Params are the parameters the action ran with
+	Params map[string]any `json:"params,omitempty"`
+	Result string         `json:"result,omitempty"`
+	Error  string         `json:"error,omitempty"`
+	// ConversationID and JobID identify what triggered an action run by an
+	// agent. The job can be replayed from the observable history.
+	ConversationID string `json:"conversation_id,omitempty"`
+	JobID          string `json:"job_id,omitempty"`
+	// Details describe the operation, e.g. the version of an updated agent
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Query selects entries of the audit log. Zero values match everything.
+type Query struct {
+	From           time.Time
+	To             time.Time
+	Actor          string
+	Operation      Operation
+	Agent          string
+	Action         string
+	ConversationID string
+	// Limit is the maximum number of entries returned, DefaultLimit if zero
+	Limit int
+}
+
+// Match reports whether the entry is selected by the query
+func (q Query) Match(e Entry) bool {
+	switch {
+	case !q.From.IsZero() && e.Time.Before(q.From):
+		return false
+	case !q.To.IsZero() && e.Time.After(q.To):
+		return false
+	case q.Actor != "" && e.Actor != q.Actor:
+		return false
+	case q.Operation != "" && e.Operation != q.Operation:
+		return false
+	case q.Agent != "" && e.Agent != q.Agent:
+		return false
+	case q.Action != "" && e.Action != q.Action:
+		return false
+	case q.ConversationID != "" && e.ConversationID != q.ConversationID:
+		return false
+	}
+	return true
+}
+
+// Log is an append-only audit trail
+type Log interface {
+	// Record appends an entry, setting its ID and time when missing
+	Record(entry Entry) error
+	// Query returns the matching entries, newest first
+	Query(q Query) ([]Entry, error)
+	// Export writes every matching entry as JSON lines, oldest first. The
+	// limit of the query is ignored.
+	Export(w io.Writer, q Query) error
+	Close() error
+}
+
+// Truncate shortens action results kept in the log
+func Truncate(s string) string {
+	if len(s) <= MaxResultLength {
+		return s
+	}
+	end := MaxResultLength
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + "... (truncated)"
+}
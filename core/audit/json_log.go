@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/xlog"
+)
+
+// JSONLog implements Log with a JSON lines file. Entries are only ever
+// appended to the file, never rewritten.
+type JSONLog struct {
+	filePath string
+	mu       sync.Mutex
+	file     *os.File
+}
+
+// NewJSONLog opens the audit log of filePath, creating it if needed
+func NewJSONLog(filePath string) (*JSONLog, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	// A crash can leave the last entry half written, start the next one on
+	// its own line so that it stays readable
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		if last[0] != '\n' {
+			if _, err := f.Write([]byte("\n")); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to write audit log: %w", err)
+			}
+		}
+	}
+
+	return &JSONLog{filePath: filePath, file: f}, nil
+}
+
+// Record appends an entry
+func (l *JSONLog) Record(entry Entry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// Query returns the matching entries, newest first
+func (l *JSONLog) Query(q Query) ([]Entry, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	// Only the newest entries are kept while scanning, so that the memory
+	// used doesn't grow with the log
+	var entries []Entry
+	newest := func() {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Time.After(entries[j].Time)
+		})
+		if len(entries) > limit {
+			entries = entries[:limit]
+		}
+	}
+	err := l.scan(q, func(e Entry) error {
+		entries = append(entries, e)
+		if len(entries) >= 2*limit {
+			newest()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newest()
+	return entries, nil
+}
+
+// Export writes every matching entry as JSON lines, oldest first
+func (l *JSONLog) Export(w io.Writer, q Query) error {
+	encoder := json.NewEncoder(w)
+	return l.scan(q, func(e Entry) error {
+		return encoder.Encode(e)
+	})
+}
+
+// scan calls fn with the matching entries, in the order they were recorded
+func (l *JSONLog) scan(q Query, fn func(Entry) error) error {
+	// Entries are written under the lock, its size is where the last
+	// complete entry ends. The ones appended while scanning are left out.
+	l.mu.Lock()
+	info, err := l.file.Stat()
+	l.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	f, err := os.Open(l.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(f, info.Size()))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			xlog.Warn("Skipping unreadable audit log entry", "file", l.filePath, "line", line, "error", err)
+			continue
+		}
+		if !q.Match(e) {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file of the log
+func (l *JSONLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,136 @@
+package audit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/audit"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSONLog", func() {
+	var (
+		path string
+		log  *audit.JSONLog
+		now  time.Time
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "audit.jsonl")
+		var err error
+		log, err = audit.NewJSONLog(path)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() { log.Close() })
+		now = time.Now()
+
+		Expect(log.Record(audit.Entry{Time: now.Add(-3 * time.Hour), Actor: "alice", Operation: audit.OperationAgentCreate, Agent: "support"})).To(Succeed())
+		Expect(log.Record(audit.Entry{
+			Time:           now.Add(-2 * time.Hour),
+			Actor:          "agent:support",
+			Operation:      audit.OperationActionRun,
+			Agent:          "support",
+			Action:         "send_email",
+			Params:         map[string]any{"to": "bob@example.com"},
+			ConversationID: "slack:C123",
+			JobID:          "job-1",
+		})).To(Succeed())
+		Expect(log.Record(audit.Entry{Time: now.Add(-time.Hour), Actor: "bob", Operation: audit.OperationAgentDelete, Agent: "infra"})).To(Succeed())
+	})
+
+	It("returns entries newest first", func() {
+		entries, err := log.Query(audit.Query{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].Operation).To(Equal(audit.OperationAgentDelete))
+		Expect(entries[2].Operation).To(Equal(audit.OperationAgentCreate))
+		Expect(entries[0].ID).ToNot(BeEmpty())
+	})
+
+	It("filters entries", func() {
+		entries, err := log.Query(audit.Query{Agent: "support"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+
+		entries, err = log.Query(audit.Query{ConversationID: "slack:C123"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Action).To(Equal("send_email"))
+		Expect(entries[0].Params).To(HaveKeyWithValue("to", "bob@example.com"))
+		Expect(entries[0].JobID).To(Equal("job-1"))
+
+		entries, err = log.Query(audit.Query{From: now.Add(-150 * time.Minute), Actor: "bob"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+
+		entries, err = log.Query(audit.Query{Limit: 1})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Actor).To(Equal("bob"))
+	})
+
+	It("returns the newest entries of a long log, whatever the order they were recorded in", func() {
+		for i := 0; i < 250; i++ {
+			// Interleave the oldest and the newest entries
+			offset := time.Duration(i/2) * time.Minute
+			if i%2 == 1 {
+				offset = time.Duration(250-i/2) * time.Minute
+			}
+			Expect(log.Record(audit.Entry{Time: now.Add(offset), Actor: "dave", Operation: audit.OperationAgentStart, Agent: "support"})).To(Succeed())
+		}
+
+		entries, err := log.Query(audit.Query{Actor: "dave", Limit: 10})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(10))
+		for i, e := range entries {
+			Expect(e.Time).To(BeTemporally("==", now.Add(time.Duration(250-i)*time.Minute)))
+		}
+
+		entries, err = log.Query(audit.Query{Actor: "dave"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(audit.DefaultLimit))
+	})
+
+	It("exports entries as JSON lines, oldest first", func() {
+		var buf bytes.Buffer
+		Expect(log.Export(&buf, audit.Query{Operation: audit.OperationAgentCreate})).To(Succeed())
+		Expect(strings.Count(buf.String(), "\n")).To(Equal(1))
+
+		buf.Reset()
+		Expect(log.Export(&buf, audit.Query{})).To(Succeed())
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Expect(lines).To(HaveLen(3))
+		var first audit.Entry
+		Expect(json.Unmarshal([]byte(lines[0]), &first)).To(Succeed())
+		Expect(first.Actor).To(Equal("alice"))
+	})
+
+	It("keeps entries across restarts and after a torn write", func() {
+		Expect(log.Close()).To(Succeed())
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.WriteString(`{"id":"torn","actor":`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+
+		log, err = audit.NewJSONLog(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(log.Record(audit.Entry{Actor: "carol", Operation: audit.OperationAgentPause, Agent: "support"})).To(Succeed())
+
+		entries, err := log.Query(audit.Query{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(4))
+		Expect(entries[0].Actor).To(Equal("carol"))
+	})
+
+	It("truncates long results", func() {
+		Expect(audit.Truncate("short")).To(Equal("short"))
+		long := audit.Truncate(strings.Repeat("é", audit.MaxResultLength))
+		Expect(len(long)).To(BeNumerically("<=", audit.MaxResultLength+len("... (truncated)")))
+		Expect(long).To(HaveSuffix("(truncated)"))
+	})
+})
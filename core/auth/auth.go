@@ -0,0 +1,195 @@
+// Package auth holds the users of LocalAGI, their API keys and the roles
+// they have on the agents.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Role is what a user may do. Each role includes the ones below it.
+type Role string
+
+const (
+	// RoleNone gives no access
+	RoleNone Role = ""
+	// RoleViewer can read agents, their configuration, status and history
+	RoleViewer Role = "viewer"
+	// RoleOperator can also chat with agents, pause and start them, run
+	// their tasks and resolve their approvals
+	RoleOperator Role = "operator"
+	// RoleAdmin can also create, configure and delete agents. Admins of
+	// every agent manage users, secrets, skills and actions.
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleNone:     0,
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Allows reports whether r includes required
+func (r Role) Allows(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// ParseRole parses a role name
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if !r.Valid() {
+		return RoleNone, fmt.Errorf("invalid role %q: expected viewer, operator or admin", s)
+	}
+	return r, nil
+}
+
+var (
+	// ErrNotFound is returned for users and API keys that don't exist
+	ErrNotFound = errors.New("not found")
+	// ErrExists is returned when creating a user that already exists
+	ErrExists = errors.New("user already exists")
+
+	validName = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
+)
+
+// APIKey identifies a user. Only the hash of the key is kept.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Prefix    string    `json:"prefix"`
+	Hash      string    `json:"hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// User is someone allowed to use LocalAGI
+type User struct {
+	Name string `json:"name"`
+	// DisplayName is shown instead of Name when set. It is chosen by the
+	// users of the identity provider, so it never tells who they are.
+	DisplayName string `json:"display_name,omitempty"`
+	// Role applies to every agent and to the resources shared by agents
+	Role Role `json:"role,omitempty"`
+	// Agents grants roles on single agents, on top of Role
+	Agents    map[string]Role `json:"agents,omitempty"`
+	APIKeys   []APIKey        `json:"api_keys,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Anonymous is the user of requests when authentication is disabled
+var Anonymous = &User{Name: "anonymous", Role: RoleAdmin}
+
+// AgentRole returns the role of the user on an agent
+func (u *User) AgentRole(agent string) Role {
+	role := u.Role
+	if granted := u.Agents[agent]; !role.Allows(granted) {
+		role = granted
+	}
+	return role
+}
+
+// Can reports whether the user has the role required on an agent
+func (u *User) Can(agent string, required Role) bool {
+	return u.AgentRole(agent).Allows(required)
+}
+
+// IsAdmin reports whether the user administers everything
+func (u *User) IsAdmin() bool {
+	return u.Role.Allows(RoleAdmin)
+}
+
+// Grant raises the roles of u to the ones of other
+func (u *User) Grant(other *User) {
+	if !u.Role.Allows(other.Role) {
+		u.Role = other.Role
+	}
+	for agent, role := range other.Agents {
+		if u.Agents == nil {
+			u.Agents = map[string]Role{}
+		}
+		if !u.Agents[agent].Allows(role) {
+			u.Agents[agent] = role
+		}
+	}
+}
+
+// Validate checks the name and roles of the user
+func (u *User) Validate() error {
+	if !validName.MatchString(u.Name) {
+		return fmt.Errorf("invalid user name %q: only letters, digits, '_', '.', '@' and '-' are allowed", u.Name)
+	}
+	if !u.Role.Valid() {
+		return fmt.Errorf("invalid role %q", u.Role)
+	}
+	for agent, role := range u.Agents {
+		if role == RoleNone || !role.Valid() {
+			return fmt.Errorf("invalid role %q on agent %s", role, agent)
+		}
+	}
+	return nil
+}
+
+// Redacted returns a copy of the user without the hashes of its keys
+func (u User) Redacted() User {
+	keys := make([]APIKey, len(u.APIKeys))
+	for i, k := range u.APIKeys {
+		k.Hash = ""
+		keys[i] = k
+	}
+	u.APIKeys = keys
+	return u
+}
+
+// Store persists the users
+type Store interface {
+	// List returns the users sorted by name
+	List() ([]User, error)
+	// Get returns a user
+	Get(name string) (*User, error)
+	// Create adds a user
+	Create(user User) error
+	// Update replaces the role and grants of a user, keeping its keys
+	Update(user User) error
+	// Delete removes a user and its keys
+	Delete(name string) error
+	// CreateAPIKey generates a key for a user. The key is only returned
+	// here.
+	CreateAPIKey(user, name string) (string, APIKey, error)
+	// DeleteAPIKey revokes a key of a user
+	DeleteAPIKey(user, id string) error
+	// Authenticate returns the user owning key
+	Authenticate(key string) (*User, error)
+	// Empty reports whether there are no users
+	Empty() bool
+}
+
+// keyPrefix starts the API keys of users, to tell them apart
+const keyPrefix = "lagi-"
+
+func generateKey() (key, id string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return keyPrefix + hex.EncodeToString(b), hex.EncodeToString(idBytes), nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
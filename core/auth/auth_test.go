@@ -0,0 +1,123 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/mudler/LocalAGI/core/auth"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("User", func() {
+	It("combines the global role with the grants on agents", func() {
+		user := &User{
+			Name: "alice",
+			Role: RoleViewer,
+			Agents: map[string]Role{
+				"support": RoleOperator,
+				"infra":   RoleAdmin,
+			},
+		}
+		Expect(user.AgentRole("support")).To(Equal(RoleOperator))
+		Expect(user.AgentRole("infra")).To(Equal(RoleAdmin))
+		Expect(user.AgentRole("other")).To(Equal(RoleViewer))
+		Expect(user.Can("support", RoleOperator)).To(BeTrue())
+		Expect(user.Can("support", RoleAdmin)).To(BeFalse())
+		Expect(user.Can("other", RoleOperator)).To(BeFalse())
+		Expect(user.IsAdmin()).To(BeFalse())
+	})
+
+	It("doesn't lower the global role with a grant", func() {
+		user := &User{Name: "bob", Role: RoleOperator, Agents: map[string]Role{"support": RoleViewer}}
+		Expect(user.AgentRole("support")).To(Equal(RoleOperator))
+	})
+
+	It("gives no access without a role", func() {
+		user := &User{Name: "carol"}
+		Expect(user.Can("support", RoleViewer)).To(BeFalse())
+	})
+
+	It("validates names and roles", func() {
+		Expect((&User{Name: "alice", Role: RoleAdmin}).Validate()).To(Succeed())
+		Expect((&User{Name: "a b"}).Validate()).To(HaveOccurred())
+		Expect((&User{Name: "alice", Role: "root"}).Validate()).To(HaveOccurred())
+		Expect((&User{Name: "alice", Agents: map[string]Role{"support": ""}}).Validate()).To(HaveOccurred())
+
+		_, err := ParseRole("owner")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("JSONStore", func() {
+	var (
+		path  string
+		store *JSONStore
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "users.json")
+		var err error
+		store, err = NewJSONStore(path)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("authenticates users by their API keys", func() {
+		Expect(store.Empty()).To(BeTrue())
+		Expect(store.Create(User{Name: "alice", Role: RoleOperator})).To(Succeed())
+		Expect(store.Create(User{Name: "alice"})).To(MatchError(ErrExists))
+		Expect(store.Empty()).To(BeFalse())
+
+		key, apiKey, err := store.CreateAPIKey("alice", "laptop")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.HasPrefix(key, apiKey.Prefix)).To(BeTrue())
+
+		user, err := store.Authenticate(key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(user.Name).To(Equal("alice"))
+		Expect(user.Role).To(Equal(RoleOperator))
+
+		_, err = store.Authenticate("lagi-wrong")
+		Expect(err).To(MatchError(ErrNotFound))
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).ToNot(ContainSubstring(key))
+
+		Expect(store.DeleteAPIKey("alice", apiKey.ID)).To(Succeed())
+		_, err = store.Authenticate(key)
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("keeps the keys when updating the roles of a user", func() {
+		Expect(store.Create(User{Name: "alice", Role: RoleViewer})).To(Succeed())
+		key, _, err := store.CreateAPIKey("alice", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(store.Update(User{Name: "alice", Agents: map[string]Role{"support": RoleAdmin}})).To(Succeed())
+		user, err := store.Authenticate(key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(user.Role).To(Equal(RoleNone))
+		Expect(user.AgentRole("support")).To(Equal(RoleAdmin))
+
+		Expect(store.Update(User{Name: "bob"})).To(MatchError(ErrNotFound))
+	})
+
+	It("sees the changes made by another store on the same file", func() {
+		other, err := NewJSONStore(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other.Create(User{Name: "alice", Role: RoleAdmin})).To(Succeed())
+		key, _, err := other.CreateAPIKey("alice", "")
+		Expect(err).ToNot(HaveOccurred())
+
+		user, err := store.Authenticate(key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(user.IsAdmin()).To(BeTrue())
+
+		Expect(other.Delete("alice")).To(Succeed())
+		users, err := store.List()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(users).To(BeEmpty())
+	})
+})
@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// loginTimeout bounds the time between starting a login and coming back
+// from the identity provider
+const loginTimeout = 10 * time.Minute
+
+// oidcUserPrefix starts the names of the users of the identity provider
+// without a local user. Local user names can't hold it.
+const oidcUserPrefix = "oidc:"
+
+// ErrUnknownLogin is returned when finishing a login that wasn't started,
+// expired or was already finished
+var ErrUnknownLogin = errors.New("unknown or expired login")
+
+// GroupRole gives the members of a group a role on every agent, or on a
+// single agent when Agent is set
+type GroupRole struct {
+	Group string
+	Agent string
+	Role  Role
+}
+
+// ParseGroupRoles parses a comma separated list of group=role or
+// group=agent:role mappings
+func ParseGroupRoles(s string) ([]GroupRole, error) {
+	var mappings []GroupRole
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, value, ok := strings.Cut(entry, "=")
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid group mapping %q, expected group=role or group=agent:role", entry)
+		}
+		mapping := GroupRole{Group: group}
+		if agent, role, ok := strings.Cut(value, ":"); ok {
+			mapping.Agent = agent
+			value = role
+		}
+		role, err := ParseRole(value)
+		if err != nil || role == RoleNone {
+			return nil, fmt.Errorf("invalid group mapping %q: invalid role %q", entry, value)
+		}
+		mapping.Role = role
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// OIDCConfig configures the login with an OpenID Connect identity provider
+type OIDCConfig struct {
+	// Issuer is the URL of the identity provider, its configuration is
+	// discovered from Issuer/.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback of LocalAGI registered at the identity
+	// provider, e.g. https://localagi.example.com/auth/callback
+	RedirectURL string
+	// Scopes requested in addition to openid. Defaults to profile, email
+	// and groups.
+	Scopes []string
+	// GroupsClaim is the claim of the ID token listing the groups of the
+	// user, "groups" by default
+	GroupsClaim string
+	// GroupRoles maps the groups of the users to their roles
+	GroupRoles []GroupRole
+	// HTTPClient is used to talk to the identity provider
+	HTTPClient *http.Client
+}
+
+// Identity is a user as told by the identity provider
+type Identity struct {
+	Subject string
+	// Name is the username of the user, to show. Users choose it, so it
+	// doesn't tell who they are.
+	Name  string
+	Email string
+	// EmailVerified is true when the identity provider checked that the
+	// email belongs to the user
+	EmailVerified bool
+	Groups        []string
+	// IDToken is kept to end the session at the identity provider
+	IDToken string
+}
+
+// OIDC logs users in with the authorization code flow of an OpenID
+// Connect identity provider
+type OIDC struct {
+	config        OIDCConfig
+	oauth         oauth2.Config
+	keys          *jwks
+	endSessionURL string
+
+	mu      sync.Mutex
+	pending map[string]pendingLogin
+}
+
+type pendingLogin struct {
+	nonce     string
+	verifier  string
+	expiresAt time.Time
+}
+
+// NewOIDC discovers the configuration of the identity provider
+func NewOIDC(ctx context.Context, config OIDCConfig) (*OIDC, error) {
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("OIDC issuer, client ID and redirect URL are required")
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"profile", "email", "groups"}
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to discover OIDC provider: status %d", resp.StatusCode)
+	}
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+		EndSessionEndpoint    string `json:"end_session_endpoint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, fmt.Errorf("failed to parse OIDC provider configuration: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != config.Issuer {
+		return nil, fmt.Errorf("OIDC provider issuer %q doesn't match %q", discovery.Issuer, config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC provider configuration is incomplete")
+	}
+	// Tokens carry the issuer exactly as the provider announces it
+	config.Issuer = discovery.Issuer
+
+	return &OIDC{
+		config: config,
+		oauth: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Scopes:       append([]string{"openid"}, config.Scopes...),
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  discovery.AuthorizationEndpoint,
+				TokenURL: discovery.TokenEndpoint,
+			},
+		},
+		keys:          &jwks{url: discovery.JWKSURI, client: config.HTTPClient},
+		endSessionURL: discovery.EndSessionEndpoint,
+		pending:       make(map[string]pendingLogin),
+	}, nil
+}
+
+// Begin starts a login. It returns the state identifying the login, to be
+// bound to the browser, and the URL of the identity provider to send the
+// browser to.
+func (o *OIDC) Begin() (state, authURL string, err error) {
+	state, err = randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	o.mu.Lock()
+	now := time.Now()
+	for s, p := range o.pending {
+		if now.After(p.expiresAt) {
+			delete(o.pending, s)
+		}
+	}
+	o.pending[state] = pendingLogin{nonce: nonce, verifier: verifier, expiresAt: now.Add(loginTimeout)}
+	o.mu.Unlock()
+
+	return state, o.oauth.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Finish completes the login identified by state with the code returned by
+// the identity provider
+func (o *OIDC) Finish(ctx context.Context, state, code string) (*Identity, error) {
+	o.mu.Lock()
+	login, ok := o.pending[state]
+	delete(o.pending, state)
+	o.mu.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		return nil, ErrUnknownLogin
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, o.config.HTTPClient)
+	token, err := o.oauth.Exchange(ctx, code, oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange the authorization code: %w", err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("the identity provider returned no ID token")
+	}
+	claims, err := verifyIDToken(ctx, o.keys, rawIDToken, o.config.Issuer, o.config.ClientID, login.nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject: claims.Subject,
+		Groups:  stringList(claims.Raw[o.config.GroupsClaim]),
+		IDToken: rawIDToken,
+	}
+	identity.Email, _ = claims.Raw["email"].(string)
+	// Some providers send the flag as a string
+	switch verified := claims.Raw["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	for _, claim := range []string{"preferred_username", "email", "name"} {
+		if name, _ := claims.Raw[claim].(string); name != "" {
+			identity.Name = name
+			break
+		}
+	}
+	if identity.Name == "" {
+		identity.Name = claims.Subject
+	}
+	return identity, nil
+}
+
+// User returns the user of an identity, with the roles given to its groups.
+// The local user of users named after the email of the identity keeps its
+// roles on top of these, once the identity provider verified the email.
+// The other claims are chosen by the users, so they never bind them to a
+// local user: the others are named after their subject, unique at the
+// identity provider, and their name is only shown.
+func (o *OIDC) User(identity *Identity, users Store) *User {
+	user := &User{Name: oidcUserPrefix + identity.Subject, DisplayName: identity.Name}
+	for _, mapping := range o.config.GroupRoles {
+		if !containsString(identity.Groups, mapping.Group) {
+			continue
+		}
+		if mapping.Agent == "" {
+			if !user.Role.Allows(mapping.Role) {
+				user.Role = mapping.Role
+			}
+			continue
+		}
+		if user.Agents == nil {
+			user.Agents = map[string]Role{}
+		}
+		if !user.Agents[mapping.Agent].Allows(mapping.Role) {
+			user.Agents[mapping.Agent] = mapping.Role
+		}
+	}
+
+	if users != nil && identity.Email != "" && identity.EmailVerified {
+		if stored, err := users.Get(identity.Email); err == nil {
+			user.Name = stored.Name
+			user.Grant(stored)
+		}
+	}
+	return user
+}
+
+// LogoutURL returns the URL ending the session at the identity provider,
+// which then sends the browser to redirectURL. It is empty when the
+// provider doesn't support it.
+func (o *OIDC) LogoutURL(idToken, redirectURL string) string {
+	if o.endSessionURL == "" {
+		return ""
+	}
+	u, err := url.Parse(o.endSessionURL)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	if idToken != "" {
+		q.Set("id_token_hint", idToken)
+	}
+	q.Set("client_id", o.config.ClientID)
+	if redirectURL != "" {
+		q.Set("post_logout_redirect_uri", redirectURL)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// stringList returns a claim holding a list of strings, or a single string
+func stringList(v any) []string {
+	switch list := v.(type) {
+	case string:
+		return []string{list}
+	case []any:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
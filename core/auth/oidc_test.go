@@ -0,0 +1,240 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	. "github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/auth/oidctest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// login follows the redirect of the provider back to the callback, and
+// returns the state and code it carries
+func login(authURL string) (string, string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	Expect(err).ToNot(HaveOccurred())
+	defer resp.Body.Close()
+	Expect(resp.StatusCode).To(Equal(http.StatusFound))
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(location.Path).To(Equal("/auth/callback"))
+	return location.Query().Get("state"), location.Query().Get("code")
+}
+
+var _ = Describe("OIDC", func() {
+	var (
+		idp      *oidctest.Server
+		provider *OIDC
+		ctx      context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		idp = oidctest.NewServer("localagi", "secret")
+		DeferCleanup(idp.Close)
+		idp.SetUser(oidctest.User{
+			Subject: "42",
+			Name:    "alice",
+			Email:   "alice@example.com",
+			Groups:  []string{"sre", "support-team"},
+		})
+
+		roles, err := ParseGroupRoles("sre=operator, support-team=support:admin, platform=admin")
+		Expect(err).ToNot(HaveOccurred())
+		provider, err = NewOIDC(ctx, OIDCConfig{
+			Issuer:       idp.URL,
+			ClientID:     "localagi",
+			ClientSecret: "secret",
+			RedirectURL:  "http://localagi.test/auth/callback",
+			GroupRoles:   roles,
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("logs users in with the authorization code flow", func() {
+		state, authURL, err := provider.Begin()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(authURL).To(HavePrefix(idp.URL + "/authorize?"))
+		Expect(authURL).To(ContainSubstring("code_challenge_method=S256"))
+
+		returnedState, code := login(authURL)
+		Expect(returnedState).To(Equal(state))
+
+		identity, err := provider.Finish(ctx, state, code)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(identity.Subject).To(Equal("42"))
+		Expect(identity.Name).To(Equal("alice"))
+		Expect(identity.Email).To(Equal("alice@example.com"))
+		Expect(identity.Groups).To(ConsistOf("sre", "support-team"))
+		Expect(identity.IDToken).ToNot(BeEmpty())
+	})
+
+	It("maps the groups of users to roles", func() {
+		user := provider.User(&Identity{Name: "alice", Groups: []string{"sre", "support-team"}}, nil)
+		Expect(user.Role).To(Equal(RoleOperator))
+		Expect(user.AgentRole("support")).To(Equal(RoleAdmin))
+		Expect(user.AgentRole("other")).To(Equal(RoleOperator))
+
+		user = provider.User(&Identity{Name: "bob", Groups: []string{"sales"}}, nil)
+		Expect(user.Can("support", RoleViewer)).To(BeFalse())
+	})
+
+	Describe("local users", func() {
+		var users *JSONStore
+
+		// loginAs logs in as user at the identity provider and returns the
+		// LocalAGI user it becomes
+		loginAs := func(user oidctest.User) *User {
+			idp.SetUser(user)
+			state, authURL, err := provider.Begin()
+			Expect(err).ToNot(HaveOccurred())
+			_, code := login(authURL)
+			identity, err := provider.Finish(ctx, state, code)
+			Expect(err).ToNot(HaveOccurred())
+			return provider.User(identity, users)
+		}
+
+		BeforeEach(func() {
+			var err error
+			users, err = NewJSONStore(filepath.Join(GinkgoT().TempDir(), "users.json"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(users.Create(User{Name: "admin", Role: RoleAdmin})).To(Succeed())
+			Expect(users.Create(User{Name: "carol@example.com", Agents: map[string]Role{"support": RoleAdmin}})).To(Succeed())
+		})
+
+		It("keep their roles when the identity provider verified their email", func() {
+			user := loginAs(oidctest.User{Subject: "7", Name: "carol", Email: "carol@example.com", EmailVerified: true, Groups: []string{"sre"}})
+			Expect(user.Name).To(Equal("carol@example.com"))
+			Expect(user.Role).To(Equal(RoleOperator))
+			Expect(user.AgentRole("support")).To(Equal(RoleAdmin))
+		})
+
+		It("can't be impersonated with the claims users choose", func() {
+			user := loginAs(oidctest.User{Subject: "666", Name: "admin", Email: "admin"})
+			Expect(user.Role).To(Equal(RoleNone))
+			Expect(user.Agents).To(BeEmpty())
+
+			idp.ModifyClaims(func(claims map[string]any) {
+				delete(claims, "preferred_username")
+				claims["name"] = "admin"
+			})
+			user = loginAs(oidctest.User{Subject: "666"})
+			Expect(user.Role).To(Equal(RoleNone))
+			idp.ModifyClaims(nil)
+
+			user = loginAs(oidctest.User{Subject: "666", Name: "mallory", Email: "carol@example.com"})
+			Expect(user.AgentRole("support")).To(Equal(RoleNone))
+		})
+
+		It("don't lend their names to the users of the identity provider", func() {
+			user := loginAs(oidctest.User{Subject: "666", Name: "admin", Email: "admin@example.com", EmailVerified: true, Groups: []string{"sre"}})
+			Expect(user.Name).To(Equal("oidc:666"))
+			Expect(user.DisplayName).To(Equal("admin"))
+			Expect(user.Role).To(Equal(RoleOperator))
+
+			other := loginAs(oidctest.User{Subject: "667", Name: "admin", Groups: []string{"sre"}})
+			Expect(other.Name).To(Equal("oidc:667"))
+			Expect(other.Name).ToNot(Equal(user.Name))
+		})
+	})
+
+	It("doesn't finish a login twice", func() {
+		state, authURL, err := provider.Begin()
+		Expect(err).ToNot(HaveOccurred())
+		_, code := login(authURL)
+		_, err = provider.Finish(ctx, state, code)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = provider.Finish(ctx, state, code)
+		Expect(err).To(MatchError(ErrUnknownLogin))
+		_, err = provider.Finish(ctx, "forged", code)
+		Expect(err).To(MatchError(ErrUnknownLogin))
+	})
+
+	DescribeTable("rejects ID tokens",
+		func(modify func(claims map[string]any), message string) {
+			idp.ModifyClaims(modify)
+			state, authURL, err := provider.Begin()
+			Expect(err).ToNot(HaveOccurred())
+			_, code := login(authURL)
+
+			_, err = provider.Finish(ctx, state, code)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("of another issuer", func(claims map[string]any) { claims["iss"] = "https://evil.example.com" }, "issued by"),
+		Entry("for another client", func(claims map[string]any) { claims["aud"] = []string{"other"} }, "another client"),
+		Entry("of another login", func(claims map[string]any) { claims["nonce"] = "replayed" }, "nonce"),
+		Entry("that expired", func(claims map[string]any) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }, "expired"),
+		Entry("without subject", func(claims map[string]any) { delete(claims, "sub") }, "subject"),
+	)
+
+	It("builds the logout URL of the provider", func() {
+		logout, err := url.Parse(provider.LogoutURL("token", "http://localagi.test/app"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(logout.Path).To(Equal("/logout"))
+		Expect(logout.Query().Get("id_token_hint")).To(Equal("token"))
+		Expect(logout.Query().Get("post_logout_redirect_uri")).To(Equal("http://localagi.test/app"))
+	})
+
+	It("refuses a provider announcing another issuer", func() {
+		_, err := NewOIDC(ctx, OIDCConfig{
+			Issuer:      idp.URL + "/other",
+			ClientID:    "localagi",
+			RedirectURL: "http://localagi.test/auth/callback",
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParseGroupRoles", func() {
+	It("parses global and per agent roles", func() {
+		roles, err := ParseGroupRoles("platform=admin,support-team=support:operator")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(roles).To(Equal([]GroupRole{
+			{Group: "platform", Role: RoleAdmin},
+			{Group: "support-team", Agent: "support", Role: RoleOperator},
+		}))
+	})
+
+	It("rejects invalid mappings", func() {
+		for _, s := range []string{"platform", "platform=root", "=admin", "platform=support:"} {
+			_, err := ParseGroupRoles(s)
+			Expect(err).To(HaveOccurred(), s)
+		}
+	})
+})
+
+var _ = Describe("Sessions", func() {
+	It("expires sessions", func() {
+		sessions := NewSessions(50 * time.Millisecond)
+		session, err := sessions.Create(&User{Name: "alice"}, "token")
+		Expect(err).ToNot(HaveOccurred())
+
+		got, ok := sessions.Get(session.ID)
+		Expect(ok).To(BeTrue())
+		Expect(got.User.Name).To(Equal("alice"))
+
+		Eventually(func() bool {
+			_, ok := sessions.Get(session.ID)
+			return ok
+		}).Should(BeFalse())
+	})
+
+	It("ends sessions", func() {
+		sessions := NewSessions(time.Hour)
+		session, err := sessions.Create(&User{Name: "alice"}, "")
+		Expect(err).ToNot(HaveOccurred())
+		sessions.Delete(session.ID)
+		_, ok := sessions.Get(session.ID)
+		Expect(ok).To(BeFalse())
+	})
+})
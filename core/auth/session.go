@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Session is a user logged in to the web UI through the identity provider
+type Session struct {
+	ID   string
+	User *User
+	// IDToken ends the session at the identity provider on logout
+	IDToken   string
+	ExpiresAt time.Time
+}
+
+// Sessions keeps the sessions of the web UI in memory, so that they end
+// when LocalAGI restarts
+type Sessions struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewSessions keeps sessions for ttl after the login
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{ttl: ttl, sessions: make(map[string]*Session)}
+}
+
+// TTL returns how long sessions last
+func (s *Sessions) TTL() time.Duration {
+	return s.ttl
+}
+
+// Create starts a session for user
+func (s *Sessions) Create(user *User, idToken string) (*Session, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{ID: id, User: user, IDToken: idToken, ExpiresAt: time.Now().Add(s.ttl)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, other := range s.sessions {
+		if now.After(other.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+// Get returns a session that hasn't expired
+func (s *Sessions) Get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(session.ExpiresAt) {
+		delete(s.sessions, id)
+		return nil, false
+	}
+	return session, true
+}
+
+// Delete ends a session
+func (s *Sessions) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAGI/pkg/atomicfile"
+)
+
+// JSONStore implements Store with a JSON file. The file is read again when
+// it changes, so that users edited from the command line apply to a
+// running server.
+type JSONStore struct {
+	filePath string
+	mu       sync.Mutex
+	modTime  time.Time
+	users    map[string]*User
+}
+
+// NewJSONStore opens the users stored in filePath
+func NewJSONStore(filePath string) (*JSONStore, error) {
+	s := &JSONStore{
+		filePath: filePath,
+		users:    make(map[string]*User),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns the users sorted by name
+func (s *JSONStore) List() ([]User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	list := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		list = append(list, *u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Get returns a user
+func (s *JSONStore) Get(name string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	u, ok := s.users[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *u
+	return &copied, nil
+}
+
+// Create adds a user
+func (s *JSONStore) Create(user User) error {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	if _, ok := s.users[user.Name]; ok {
+		return ErrExists
+	}
+	user.APIKeys = nil
+	user.CreatedAt = time.Now()
+	s.users[user.Name] = &user
+	return s.save()
+}
+
+// Update replaces the role and grants of a user, keeping its keys
+func (s *JSONStore) Update(user User) error {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	existing, ok := s.users[user.Name]
+	if !ok {
+		return ErrNotFound
+	}
+	existing.Role = user.Role
+	existing.Agents = user.Agents
+	return s.save()
+}
+
+// Delete removes a user and its keys
+func (s *JSONStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	if _, ok := s.users[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.users, name)
+	return s.save()
+}
+
+// CreateAPIKey generates a key for a user
+func (s *JSONStore) CreateAPIKey(user, name string) (string, APIKey, error) {
+	key, id, err := generateKey()
+	if err != nil {
+		return "", APIKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return "", APIKey{}, err
+	}
+	u, ok := s.users[user]
+	if !ok {
+		return "", APIKey{}, ErrNotFound
+	}
+	apiKey := APIKey{
+		ID:        id,
+		Name:      name,
+		Prefix:    key[:len(keyPrefix)+6],
+		Hash:      hashKey(key),
+		CreatedAt: time.Now(),
+	}
+	u.APIKeys = append(slices.Clone(u.APIKeys), apiKey)
+	if err := s.save(); err != nil {
+		return "", APIKey{}, err
+	}
+	return key, apiKey, nil
+}
+
+// DeleteAPIKey revokes a key of a user
+func (s *JSONStore) DeleteAPIKey(user, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	u, ok := s.users[user]
+	if !ok {
+		return ErrNotFound
+	}
+	for i, k := range u.APIKeys {
+		if k.ID == id {
+			u.APIKeys = slices.Delete(slices.Clone(u.APIKeys), i, i+1)
+			return s.save()
+		}
+	}
+	return ErrNotFound
+}
+
+// Authenticate returns the user owning key
+func (s *JSONStore) Authenticate(key string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	hash := []byte(hashKey(key))
+	for _, u := range s.users {
+		for _, k := range u.APIKeys {
+			if subtle.ConstantTimeCompare(hash, []byte(k.Hash)) == 1 {
+				copied := *u
+				return &copied, nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Empty reports whether there are no users
+func (s *JSONStore) Empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return false
+	}
+	return len(s.users) == 0
+}
+
+// load reads the file again when it changed since it was last read
+func (s *JSONStore) load() error {
+	info, err := os.Stat(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read users: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read users: %w", err)
+	}
+	users := make(map[string]*User)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &users); err != nil {
+			return fmt.Errorf("failed to parse users: %w", err)
+		}
+	}
+	for name, u := range users {
+		u.Name = name
+	}
+	s.users = users
+	s.modTime = info.ModTime()
+	return nil
+}
+
+func (s *JSONStore) save() error {
+	if err := atomicfile.WriteJSON(s.filePath, s.users, 0600); err != nil {
+		return fmt.Errorf("failed to write users: %w", err)
+	}
+
+	if info, err := os.Stat(s.filePath); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package conversations
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,19 +12,88 @@ import (
 
 type TrackerKey interface{ ~int | ~int64 | ~string }
 
+// RetentionPolicy bounds how much conversation history is kept, on top of the
+// inactivity window given by lastMessageDuration. Zero values mean unlimited.
+type RetentionPolicy struct {
+	// MaxMessages is the maximum number of messages kept per conversation;
+	// older messages are dropped first.
+	MaxMessages int
+	// MaxConversations is the maximum number of conversations kept;
+	// the least recently active ones are evicted first.
+	MaxConversations int
+}
+
 type ConversationTracker[K TrackerKey] struct {
 	convMutex           sync.Mutex
 	currentconversation map[K][]openai.ChatCompletionMessage
 	lastMessageTime     map[K]time.Time
 	lastMessageDuration time.Duration
+	// roots maps the conversations continuing another one to the key of
+	// the conversation their thread started with
+	roots map[K]K
+
+	store     Store[K]
+	retention RetentionPolicy
+}
+
+type TrackerOption[K TrackerKey] func(*ConversationTracker[K])
+
+// WithStore persists the tracked conversations to the given store
+func WithStore[K TrackerKey](store Store[K]) TrackerOption[K] {
+	return func(c *ConversationTracker[K]) {
+		c.store = store
+	}
 }
 
-func NewConversationTracker[K TrackerKey](lastMessageDuration time.Duration) *ConversationTracker[K] {
-	return &ConversationTracker[K]{
+// WithRetention sets the retention policy of the tracker
+func WithRetention[K TrackerKey](policy RetentionPolicy) TrackerOption[K] {
+	return func(c *ConversationTracker[K]) {
+		c.retention = policy
+	}
+}
+
+func NewConversationTracker[K TrackerKey](lastMessageDuration time.Duration, opts ...TrackerOption[K]) *ConversationTracker[K] {
+	c := &ConversationTracker[K]{
 		lastMessageDuration: lastMessageDuration,
 		currentconversation: map[K][]openai.ChatCompletionMessage{},
 		lastMessageTime:     map[K]time.Time{},
+		roots:               map[K]K{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.store != nil {
+		c.restore()
 	}
+
+	return c
+}
+
+// restore loads the conversations from the store, dropping the ones that already expired
+func (c *ConversationTracker[K]) restore() {
+	stored, err := c.store.Load()
+	if err != nil {
+		xlog.Error("Failed to load conversations from store", "error", err)
+		return
+	}
+
+	for key, conv := range stored {
+		if conv.LastMessage.Add(c.lastMessageDuration).Before(time.Now()) {
+			c.remove(key)
+			continue
+		}
+		c.currentconversation[key] = conv.Messages
+		c.lastMessageTime[key] = conv.LastMessage
+		var noRoot K
+		if conv.Root != noRoot && conv.Root != key {
+			c.roots[key] = conv.Root
+		}
+	}
+
+	c.enforceMaxConversations()
+	xlog.Debug("Restored conversations from store", "count", len(c.currentconversation))
 }
 
 func (c *ConversationTracker[K]) GetConversation(key K) []openai.ChatCompletionMessage {
@@ -38,7 +108,7 @@ func (c *ConversationTracker[K]) GetConversation(key K) []openai.ChatCompletionM
 		lastMessageTime = time.Now()
 	}
 	if lastMessageTime.Add(c.lastMessageDuration).Before(time.Now()) {
-		currentConv = []openai.ChatCompletionMessage{}
+		c.remove(key)
 		c.lastMessageTime[key] = time.Now()
 		xlog.Debug("Conversation history does not exist for", "key", fmt.Sprintf("%v", key))
 	} else {
@@ -50,14 +120,12 @@ func (c *ConversationTracker[K]) GetConversation(key K) []openai.ChatCompletionM
 	for k := range c.currentconversation {
 		lastMessage, exists := c.lastMessageTime[k]
 		if !exists {
-			delete(c.currentconversation, k)
-			delete(c.lastMessageTime, k)
+			c.remove(k)
 			continue
 		}
 		if lastMessage.Add(c.lastMessageDuration).Before(time.Now()) {
 			xlog.Debug("Cleaning up conversation for", k)
-			delete(c.currentconversation, k)
-			delete(c.lastMessageTime, k)
+			c.remove(k)
 		}
 	}
 
@@ -72,13 +140,94 @@ func (c *ConversationTracker[K]) AddMessage(key K, message openai.ChatCompletion
 
 	c.currentconversation[key] = append(c.currentconversation[key], message)
 	c.lastMessageTime[key] = time.Now()
+	c.update(key)
 }
 
-func (c *ConversationTracker[K]) SetConversation(key K, messages []openai.ChatCompletionMessage) {
+// SetConversation replaces the messages of a conversation. root is the key
+// of the conversation the thread started with, key itself for a new thread,
+// so that a thread stored under a new key at every turn keeps one identity.
+func (c *ConversationTracker[K]) SetConversation(key, root K, messages []openai.ChatCompletionMessage) {
 	// Lock the conversation mutex to update the conversation history
 	c.convMutex.Lock()
 	defer c.convMutex.Unlock()
 
 	c.currentconversation[key] = messages
 	c.lastMessageTime[key] = time.Now()
+	if root != key {
+		c.roots[key] = root
+	} else {
+		delete(c.roots, key)
+	}
+	c.update(key)
+}
+
+// GetRoot returns the key of the conversation the thread of key started
+// with, or key itself when it starts a thread or is not tracked
+func (c *ConversationTracker[K]) GetRoot(key K) K {
+	c.convMutex.Lock()
+	defer c.convMutex.Unlock()
+
+	if root, ok := c.roots[key]; ok {
+		return root
+	}
+	return key
+}
+
+// update applies the retention policy after key changed and persists it (must be called with lock held)
+func (c *ConversationTracker[K]) update(key K) {
+	if limit := c.retention.MaxMessages; limit > 0 && len(c.currentconversation[key]) > limit {
+		messages := c.currentconversation[key]
+		messages = messages[len(messages)-limit:]
+		// Don't start a conversation with tool results whose call was trimmed away
+		for len(messages) > 0 && messages[0].Role == openai.ChatMessageRoleTool {
+			messages = messages[1:]
+		}
+		c.currentconversation[key] = messages
+	}
+
+	c.enforceMaxConversations()
+
+	if _, exists := c.currentconversation[key]; !exists || c.store == nil {
+		return
+	}
+	if err := c.store.Save(key, Conversation[K]{
+		Messages:    c.currentconversation[key],
+		LastMessage: c.lastMessageTime[key],
+		Root:        c.roots[key],
+	}); err != nil {
+		xlog.Error("Failed to persist conversation", "key", fmt.Sprintf("%v", key), "error", err)
+	}
+}
+
+// enforceMaxConversations evicts the least recently active conversations (must be called with lock held)
+func (c *ConversationTracker[K]) enforceMaxConversations() {
+	limit := c.retention.MaxConversations
+	if limit <= 0 || len(c.currentconversation) <= limit {
+		return
+	}
+
+	keys := make([]K, 0, len(c.currentconversation))
+	for k := range c.currentconversation {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.lastMessageTime[keys[i]].Before(c.lastMessageTime[keys[j]])
+	})
+
+	for _, k := range keys[:len(keys)-limit] {
+		xlog.Debug("Evicting conversation due to retention policy", "key", fmt.Sprintf("%v", k))
+		c.remove(k)
+	}
+}
+
+// remove drops a conversation from memory and from the store (must be called with lock held)
+func (c *ConversationTracker[K]) remove(key K) {
+	delete(c.currentconversation, key)
+	delete(c.lastMessageTime, key)
+	delete(c.roots, key)
+	if c.store != nil {
+		if err := c.store.Delete(key); err != nil {
+			xlog.Error("Failed to delete conversation from store", "key", fmt.Sprintf("%v", key), "error", err)
+		}
+	}
 }
@@ -1,6 +1,8 @@
 package conversations_test
 
 import (
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/mudler/LocalAGI/core/conversations"
@@ -109,3 +111,72 @@ var _ = Describe("ConversationTracker", func() {
 		Expect(tracker.GetConversation("key2")).To(BeEmpty())
 	})
 })
+
+var _ = Describe("ConversationTracker with a store", func() {
+	var storePath string
+
+	BeforeEach(func() {
+		storePath = filepath.Join(GinkgoT().TempDir(), "conversations.json")
+	})
+
+	newTracker := func(opts ...conversations.TrackerOption[string]) *conversations.ConversationTracker[string] {
+		store, err := conversations.NewJSONFileStore[string](storePath)
+		Expect(err).ToNot(HaveOccurred())
+		return conversations.NewConversationTracker[string](time.Hour, append(opts, conversations.WithStore[string](store))...)
+	}
+
+	It("should restore conversations after a restart", func() {
+		tracker := newTracker()
+		tracker.AddMessage("slack:C1", openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "Hello"})
+		tracker.AddMessage("slack:C1", openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "Hi"})
+
+		restored := newTracker()
+		conv := restored.GetConversation("slack:C1")
+		Expect(conv).To(HaveLen(2))
+		Expect(conv[1].Content).To(Equal("Hi"))
+	})
+
+	It("should store the conversations in a file only its owner can read", func() {
+		tracker := newTracker()
+		tracker.AddMessage("slack:C1", openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "Hello"})
+
+		info, err := os.Stat(storePath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("should keep the root of threads stored under a new key at every turn", func() {
+		tracker := newTracker()
+		tracker.SetConversation("A", "A", []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "one"}})
+		tracker.SetConversation("B", tracker.GetRoot("A"), []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "two"}})
+		Expect(tracker.GetRoot("A")).To(Equal("A"))
+		Expect(tracker.GetRoot("B")).To(Equal("A"))
+
+		restored := newTracker()
+		restored.SetConversation("C", restored.GetRoot("B"), []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "three"}})
+		Expect(restored.GetRoot("C")).To(Equal("A"))
+		Expect(restored.GetRoot("unknown")).To(Equal("unknown"))
+	})
+
+	It("should keep only the last messages when MaxMessages is set", func() {
+		tracker := newTracker(conversations.WithRetention[string](conversations.RetentionPolicy{MaxMessages: 2}))
+		for _, content := range []string{"one", "two", "three"} {
+			tracker.AddMessage("key", openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: content})
+		}
+
+		conv := newTracker().GetConversation("key")
+		Expect(conv).To(HaveLen(2))
+		Expect(conv[0].Content).To(Equal("two"))
+	})
+
+	It("should evict the least recently active conversations when MaxConversations is set", func() {
+		tracker := newTracker(conversations.WithRetention[string](conversations.RetentionPolicy{MaxConversations: 1}))
+		tracker.AddMessage("old", openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "old"})
+		time.Sleep(10 * time.Millisecond)
+		tracker.AddMessage("new", openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: "new"})
+
+		restored := newTracker()
+		Expect(restored.GetConversation("old")).To(BeEmpty())
+		Expect(restored.GetConversation("new")).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,102 @@
+package conversations
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAGI/pkg/atomicfile"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Conversation is a tracked conversation thread as persisted by a Store
+type Conversation[K TrackerKey] struct {
+	Messages    []openai.ChatCompletionMessage `json:"messages"`
+	LastMessage time.Time                      `json:"last_message"`
+	// Root is the key of the conversation the thread started with, when it
+	// continues another one
+	Root K `json:"root,omitempty"`
+}
+
+// Store persists the conversations of a ConversationTracker so that
+// they survive restarts. The tracker keeps its own in-memory copy and
+// writes through to the store on every change.
+type Store[K TrackerKey] interface {
+	Load() (map[K]Conversation[K], error)
+	Save(key K, conversation Conversation[K]) error
+	Delete(key K) error
+}
+
+// JSONFileStore implements Store using a single JSON file
+type JSONFileStore[K TrackerKey] struct {
+	filePath      string
+	mu            sync.Mutex
+	conversations map[K]Conversation[K]
+}
+
+// NewJSONFileStore creates a new JSON file based conversation store
+func NewJSONFileStore[K TrackerKey](filePath string) (*JSONFileStore[K], error) {
+	store := &JSONFileStore[K]{
+		filePath:      filePath,
+		conversations: map[K]Conversation[K]{},
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read conversation store: %w", err)
+		}
+		return store, nil
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &store.conversations); err != nil {
+			return nil, fmt.Errorf("failed to parse conversation store: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// Load returns all the stored conversations
+func (s *JSONFileStore[K]) Load() (map[K]Conversation[K], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversations := make(map[K]Conversation[K], len(s.conversations))
+	for k, v := range s.conversations {
+		conversations[k] = v
+	}
+	return conversations, nil
+}
+
+// Save stores a conversation
+func (s *JSONFileStore[K]) Save(key K, conversation Conversation[K]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversations[key] = conversation
+	return s.save()
+}
+
+// Delete removes a conversation
+func (s *JSONFileStore[K]) Delete(key K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.conversations[key]; !exists {
+		return nil
+	}
+	delete(s.conversations, key)
+	return s.save()
+}
+
+// save writes the store to disk (must be called with lock held)
+func (s *JSONFileStore[K]) save() error {
+	if err := atomicfile.WriteJSON(s.filePath, s.conversations, 0600); err != nil {
+		return fmt.Errorf("failed to write conversation store: %w", err)
+	}
+	return nil
+}
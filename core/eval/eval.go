@@ -0,0 +1,251 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/pkg/llm"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Asker is the agent under evaluation
+type Asker interface {
+	Ask(opts ...types.JobOption) *types.JobResult
+}
+
+// Verdict is the grade of a response against a rubric
+type Verdict struct {
+	Pass   bool   `json:"pass"`
+	Reason string `json:"reason"`
+}
+
+// Judge grades a response against a rubric
+type Judge interface {
+	Judge(ctx context.Context, rubric string, conversation []openai.ChatCompletionMessage, response string) (Verdict, error)
+}
+
+// LLMJudge asks a model to grade responses
+type LLMJudge struct {
+	client *openai.Client
+	model  string
+}
+
+func NewLLMJudge(client *openai.Client, model string) *LLMJudge {
+	return &LLMJudge{client: client, model: model}
+}
+
+func (j *LLMJudge) Judge(ctx context.Context, rubric string, conversation []openai.ChatCompletionMessage, response string) (Verdict, error) {
+	var transcript strings.Builder
+	for _, m := range conversation {
+		fmt.Fprintf(&transcript, "%s: %s\n", m.Role, m.Content)
+	}
+	prompt := fmt.Sprintf(`You are grading the answer of an AI assistant.
+
+Conversation:
+%s
+Answer of the assistant:
+%s
+
+Rubric:
+%s
+
+Decide whether the answer satisfies the rubric, and explain why in one sentence.`, transcript.String(), response, rubric)
+
+	var verdict Verdict
+	err := llm.GenerateTypedJSONWithGuidance(ctx, j.client, prompt, j.model, jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"pass": {
+				Type:        jsonschema.Boolean,
+				Description: "Whether the answer satisfies the rubric",
+			},
+			"reason": {
+				Type:        jsonschema.String,
+				Description: "Why the answer does or does not satisfy the rubric",
+			},
+		},
+		Required: []string{"pass", "reason"},
+	}, &verdict)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to judge response: %w", err)
+	}
+	return verdict, nil
+}
+
+// Run runs the cases of suite one after the other. judge may be nil when no
+// case has a rubric.
+func Run(ctx context.Context, agent Asker, judge Judge, suite *Suite) *Report {
+	report := &Report{Suite: suite.Name}
+	start := time.Now()
+	for i, c := range suite.Cases {
+		if ctx.Err() != nil {
+			break
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case %d", i+1)
+		}
+		result := runCase(ctx, agent, judge, c)
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Cases = append(report.Cases, result)
+	}
+	report.Duration = time.Since(start).Seconds()
+	return report
+}
+
+func runCase(ctx context.Context, agent Asker, judge Judge, c Case) CaseResult {
+	conversation := c.conversation()
+	start := time.Now()
+	res := agent.Ask(
+		types.WithConversationHistory(conversation),
+		types.WithContext(ctx),
+	)
+	latency := time.Since(start)
+
+	result := CaseResult{
+		Name:     c.Name,
+		Latency:  latency.Seconds(),
+		Response: res.Response,
+	}
+	for _, s := range res.State {
+		if s.Action == nil {
+			continue
+		}
+		result.ToolCalls = append(result.ToolCalls, Call{
+			Name:   s.Action.Definition().Name.String(),
+			Params: s.Params,
+		})
+	}
+
+	fail := func(format string, args ...any) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	}
+
+	if res.Error != nil {
+		fail("agent error: %v", res.Error)
+	}
+	if c.MaxLatency != "" {
+		if max, _ := time.ParseDuration(c.MaxLatency); latency > max {
+			fail("answered in %s, expected at most %s", latency.Round(time.Millisecond), max)
+		}
+	}
+
+	for _, f := range checkToolCalls(c.ToolCalls, result.ToolCalls) {
+		fail("%s", f)
+	}
+	for _, name := range c.ForbiddenTools {
+		for _, call := range result.ToolCalls {
+			if call.Name == name {
+				fail("called forbidden tool %q", name)
+				break
+			}
+		}
+	}
+
+	response := strings.ToLower(res.Response)
+	for _, s := range c.Response.Contains {
+		if !strings.Contains(response, strings.ToLower(s)) {
+			fail("response does not contain %q", s)
+		}
+	}
+	for _, s := range c.Response.NotContains {
+		if strings.Contains(response, strings.ToLower(s)) {
+			fail("response contains %q", s)
+		}
+	}
+	for _, r := range c.Response.Regex {
+		if ok, _ := regexp.MatchString(r, res.Response); !ok {
+			fail("response does not match %q", r)
+		}
+	}
+
+	if c.Response.Rubric != "" && judge == nil {
+		fail("rubric can't be graded without a judge")
+	} else if c.Response.Rubric != "" {
+		verdict, err := judge.Judge(ctx, c.Response.Rubric, conversation, res.Response)
+		switch {
+		case err != nil:
+			fail("%v", err)
+		case !verdict.Pass:
+			fail("response does not satisfy the rubric: %s", verdict.Reason)
+		}
+		result.Judgement = verdict.Reason
+	}
+
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+// checkToolCalls checks that the expected calls happened in order, and
+// returns why they did not
+func checkToolCalls(expected []ToolCall, calls []Call) []string {
+	var failures []string
+	next := 0
+	for _, e := range expected {
+		found := false
+		var mismatches []string
+		for i := next; i < len(calls); i++ {
+			if calls[i].Name != e.Name {
+				continue
+			}
+			mismatch := matchParams(e.Params, calls[i].Params)
+			if mismatch == "" {
+				found = true
+				next = i + 1
+				break
+			}
+			mismatches = append(mismatches, mismatch)
+		}
+		if found {
+			continue
+		}
+		if len(mismatches) > 0 {
+			failures = append(failures, fmt.Sprintf("tool %q was called with other parameters: %s", e.Name, strings.Join(mismatches, "; ")))
+		} else {
+			failures = append(failures, fmt.Sprintf("tool %q was not called (calls: %s)", e.Name, callNames(calls[next:])))
+		}
+	}
+	return failures
+}
+
+func matchParams(expected map[string]Matcher, params types.ActionParams) string {
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var mismatches []string
+	for _, name := range names {
+		m := expected[name]
+		value, ok := params[name]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s is missing, expected %s", name, m))
+			continue
+		}
+		if mismatch := m.Match(value); mismatch != "" {
+			mismatches = append(mismatches, name+" "+mismatch)
+		}
+	}
+	return strings.Join(mismatches, ", ")
+}
+
+func callNames(calls []Call) string {
+	if len(calls) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(calls))
+	for _, c := range calls {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ", ")
+}
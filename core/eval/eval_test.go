@@ -0,0 +1,246 @@
+package eval_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mudler/LocalAGI/core/eval"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+)
+
+type fakeAction struct {
+	name string
+}
+
+func (a *fakeAction) Run(context.Context, *types.AgentSharedState, types.ActionParams) (types.ActionResult, error) {
+	return types.ActionResult{}, nil
+}
+
+func (a *fakeAction) Definition() types.ActionDefinition {
+	return types.ActionDefinition{Name: types.ActionDefinitionName(a.name)}
+}
+
+func (a *fakeAction) Plannable() bool {
+	return true
+}
+
+// fakeAgent answers with the result registered for the last message
+type fakeAgent struct {
+	results map[string]*types.JobResult
+	asked   [][]openai.ChatCompletionMessage
+}
+
+func (f *fakeAgent) Ask(opts ...types.JobOption) *types.JobResult {
+	job := types.NewJob(opts...)
+	f.asked = append(f.asked, job.ConversationHistory)
+	last := job.ConversationHistory[len(job.ConversationHistory)-1].Content
+	if res, ok := f.results[last]; ok {
+		return res
+	}
+	return &types.JobResult{Error: errors.New("unexpected question")}
+}
+
+func called(name string, params types.ActionParams) types.ActionState {
+	return types.ActionState{ActionCurrentState: types.ActionCurrentState{Action: &fakeAction{name: name}, Params: params}}
+}
+
+type fakeJudge struct {
+	verdict eval.Verdict
+	rubrics []string
+}
+
+func (j *fakeJudge) Judge(_ context.Context, rubric string, _ []openai.ChatCompletionMessage, _ string) (eval.Verdict, error) {
+	j.rubrics = append(j.rubrics, rubric)
+	return j.verdict, nil
+}
+
+const suiteYAML = `
+name: weather
+cases:
+  - name: boston
+    messages:
+      - role: system
+        content: be brief
+      - content: weather in boston?
+    tool_calls:
+      - name: search
+        params:
+          term:
+            contains: boston
+      - name: get_weather
+        params:
+          location: Boston
+          days: 2
+    forbidden_tools: [send_email]
+    response:
+      contains: ["30C"]
+      not_contains: ["error"]
+      regex: ["(?i)sunny"]
+      rubric: mentions the temperature
+    max_latency: 1m
+`
+
+var _ = Describe("Eval", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	load := func(name, content string) (*eval.Suite, error) {
+		path := filepath.Join(dir, name)
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return eval.Load(path)
+	}
+
+	It("loads YAML and JSON suites", func() {
+		suite, err := load("suite.yaml", suiteYAML)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(suite.Cases).To(HaveLen(1))
+		c := suite.Cases[0]
+		Expect(c.Messages).To(HaveLen(2))
+		Expect(c.ToolCalls[0].Params["term"].Contains).To(Equal("boston"))
+		Expect(c.ToolCalls[1].Params["location"].Equals).To(Equal("Boston"))
+
+		suite, err = load("suite.json", `{"name":"json","cases":[{"name":"hi","messages":[{"role":"user","content":"hi"}],"tool_calls":[{"name":"reply","params":{"text":{"regex":"^h"}}}]}]}`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(suite.Cases[0].ToolCalls[0].Params["text"].Regex).To(Equal("^h"))
+	})
+
+	It("rejects invalid suites", func() {
+		_, err := load("empty.yaml", "name: empty\n")
+		Expect(err).To(MatchError(ContainSubstring("no cases")))
+		_, err = load("latency.yaml", "cases:\n  - messages: [{content: hi}]\n    max_latency: soon\n")
+		Expect(err).To(MatchError(ContainSubstring("max_latency")))
+		_, err = load("regex.yaml", "cases:\n  - messages: [{content: hi}]\n    response: {regex: ['(']}\n")
+		Expect(err).To(MatchError(ContainSubstring("regex")))
+	})
+
+	It("passes cases meeting every expectation", func() {
+		suite, err := load("suite.yaml", suiteYAML)
+		Expect(err).ToNot(HaveOccurred())
+		agent := &fakeAgent{results: map[string]*types.JobResult{
+			"weather in boston?": {
+				Response: "It's 30C and sunny in Boston",
+				State: []types.ActionState{
+					called("search", types.ActionParams{"term": "Boston weather"}),
+					called("think", nil),
+					// Tool call arguments are decoded from JSON
+					called("get_weather", types.ActionParams{"location": "Boston", "days": float64(2)}),
+				},
+			},
+		}}
+		judge := &fakeJudge{verdict: eval.Verdict{Pass: true, Reason: "it does"}}
+
+		report := eval.Run(context.Background(), agent, judge, suite)
+		Expect(report.Failed).To(Equal(0), "%v", report.Cases)
+		Expect(report.Passed).To(Equal(1))
+		Expect(report.Cases[0].ToolCalls).To(HaveLen(3))
+		Expect(report.Cases[0].Judgement).To(Equal("it does"))
+		Expect(judge.rubrics).To(Equal([]string{"mentions the temperature"}))
+		Expect(agent.asked[0][0].Role).To(Equal("system"))
+		Expect(agent.asked[0][1].Role).To(Equal("user"))
+	})
+
+	It("reports every unmet expectation", func() {
+		suite, err := load("suite.yaml", suiteYAML)
+		Expect(err).ToNot(HaveOccurred())
+		agent := &fakeAgent{results: map[string]*types.JobResult{
+			"weather in boston?": {
+				Response: "There was an error",
+				State: []types.ActionState{
+					called("get_weather", types.ActionParams{"location": "Paris"}),
+					called("send_email", nil),
+				},
+			},
+		}}
+		judge := &fakeJudge{verdict: eval.Verdict{Pass: false, Reason: "no temperature"}}
+
+		report := eval.Run(context.Background(), agent, judge, suite)
+		Expect(report.Failed).To(Equal(1))
+		failures := strings.Join(report.Cases[0].Failures, "\n")
+		Expect(failures).To(ContainSubstring(`tool "search" was not called`))
+		Expect(failures).To(ContainSubstring(`called forbidden tool "send_email"`))
+		Expect(failures).To(ContainSubstring(`response does not contain "30C"`))
+		Expect(failures).To(ContainSubstring(`response contains "error"`))
+		Expect(failures).To(ContainSubstring(`response does not match "(?i)sunny"`))
+		Expect(failures).To(ContainSubstring("no temperature"))
+	})
+
+	It("checks tool call parameters and latency", func() {
+		suite, err := load("suite.yaml", `
+cases:
+  - messages: [{content: paris}]
+    tool_calls:
+      - name: get_weather
+        params: {location: Boston, unit: celsius}
+    max_latency: 1ns
+`)
+		Expect(err).ToNot(HaveOccurred())
+		agent := &fakeAgent{results: map[string]*types.JobResult{
+			"paris": {State: []types.ActionState{called("get_weather", types.ActionParams{"location": "Paris"})}},
+		}}
+
+		report := eval.Run(context.Background(), agent, nil, suite)
+		Expect(report.Cases[0].Name).To(Equal("case 1"))
+		failures := strings.Join(report.Cases[0].Failures, "\n")
+		Expect(failures).To(ContainSubstring(`location is "Paris", expected Boston`))
+		Expect(failures).To(ContainSubstring("unit is missing"))
+		Expect(failures).To(ContainSubstring("expected at most 1ns"))
+	})
+
+	It("writes JSON and JUnit reports", func() {
+		report := &eval.Report{
+			Suite:  "weather",
+			Agent:  "bot",
+			Passed: 1,
+			Failed: 1,
+			Cases: []eval.CaseResult{
+				{Name: "ok", Passed: true, Latency: 1.5, Response: "fine"},
+				{Name: "ko", Failures: []string{"first", "second"}},
+			},
+		}
+
+		var buf bytes.Buffer
+		Expect(report.WriteJSON(&buf)).To(Succeed())
+		var decoded eval.Report
+		Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(Succeed())
+		Expect(decoded.Cases).To(HaveLen(2))
+
+		buf.Reset()
+		Expect(report.WriteJUnit(&buf)).To(Succeed())
+		var junit struct {
+			Suites []struct {
+				Tests    int `xml:"tests,attr"`
+				Failures int `xml:"failures,attr"`
+				Cases    []struct {
+					Name      string `xml:"name,attr"`
+					Classname string `xml:"classname,attr"`
+					Time      string `xml:"time,attr"`
+					Failure   *struct {
+						Message string `xml:"message,attr"`
+						Text    string `xml:",chardata"`
+					} `xml:"failure"`
+				} `xml:"testcase"`
+			} `xml:"testsuite"`
+		}
+		Expect(xml.Unmarshal(buf.Bytes(), &junit)).To(Succeed())
+		Expect(junit.Suites).To(HaveLen(1))
+		Expect(junit.Suites[0].Tests).To(Equal(2))
+		Expect(junit.Suites[0].Failures).To(Equal(1))
+		Expect(junit.Suites[0].Cases[0].Classname).To(Equal("bot.weather"))
+		Expect(junit.Suites[0].Cases[0].Time).To(Equal("1.500"))
+		Expect(junit.Suites[0].Cases[0].Failure).To(BeNil())
+		Expect(junit.Suites[0].Cases[1].Failure.Message).To(Equal("first"))
+		Expect(junit.Suites[0].Cases[1].Failure.Text).To(Equal("first\nsecond"))
+	})
+})
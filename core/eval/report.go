@@ -0,0 +1,115 @@
+package eval
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mudler/LocalAGI/core/types"
+)
+
+// Report is the outcome of a suite. Durations are in seconds.
+type Report struct {
+	Suite    string       `json:"suite"`
+	Agent    string       `json:"agent,omitempty"`
+	Passed   int          `json:"passed"`
+	Failed   int          `json:"failed"`
+	Duration float64      `json:"duration"`
+	Cases    []CaseResult `json:"cases"`
+}
+
+// CaseResult is the outcome of a case
+type CaseResult struct {
+	Name      string   `json:"name"`
+	Passed    bool     `json:"passed"`
+	Failures  []string `json:"failures,omitempty"`
+	Latency   float64  `json:"latency"`
+	Response  string   `json:"response"`
+	ToolCalls []Call   `json:"tool_calls,omitempty"`
+	Judgement string   `json:"judgement,omitempty"`
+}
+
+// Call is a tool called by the agent
+type Call struct {
+	Name   string             `json:"name"`
+	Params types.ActionParams `json:"params,omitempty"`
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML, for CI systems
+func (r *Report) WriteJUnit(w io.Writer) error {
+	classname := r.Suite
+	if r.Agent != "" {
+		classname = r.Agent + "." + r.Suite
+	}
+	suite := junitSuite{
+		Name:     r.Suite,
+		Tests:    len(r.Cases),
+		Failures: r.Failed,
+		Time:     seconds(r.Duration),
+	}
+	for _, c := range r.Cases {
+		tc := junitCase{
+			Name:      c.Name,
+			Classname: classname,
+			Time:      seconds(c.Latency),
+			SystemOut: c.Response,
+		}
+		if !c.Passed {
+			tc.Failure = &junitFailure{
+				Message: c.Failures[0],
+				Text:    strings.Join(c.Failures, "\n"),
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
@@ -0,0 +1,185 @@
+// Package eval runs scripted conversations against an agent and checks the
+// tools it calls and what it answers, to catch regressions when prompts or
+// models change.
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a list of cases run against the same agent
+type Suite struct {
+	Name  string `yaml:"name" json:"name"`
+	Cases []Case `yaml:"cases" json:"cases"`
+}
+
+// Case is a conversation sent to the agent, with what is expected back
+type Case struct {
+	Name     string    `yaml:"name" json:"name"`
+	Messages []Message `yaml:"messages" json:"messages"`
+	// ToolCalls must be called in this order, other calls may happen in between
+	ToolCalls []ToolCall `yaml:"tool_calls,omitempty" json:"tool_calls,omitempty"`
+	// ForbiddenTools must not be called
+	ForbiddenTools []string `yaml:"forbidden_tools,omitempty" json:"forbidden_tools,omitempty"`
+	Response       Response `yaml:"response,omitempty" json:"response,omitempty"`
+	// MaxLatency is the longest the agent may take to answer, e.g. "30s"
+	MaxLatency string `yaml:"max_latency,omitempty" json:"max_latency,omitempty"`
+}
+
+// Message is a message of the conversation of a case
+type Message struct {
+	Role    string `yaml:"role" json:"role"`
+	Content string `yaml:"content" json:"content"`
+}
+
+// ToolCall is a tool the agent is expected to call, and its parameters
+type ToolCall struct {
+	Name   string             `yaml:"name" json:"name"`
+	Params map[string]Matcher `yaml:"params,omitempty" json:"params,omitempty"`
+}
+
+// Response holds the assertions on the final answer of the agent. Contains
+// and NotContains are case insensitive, Rubric is graded by an LLM judge.
+type Response struct {
+	Contains    []string `yaml:"contains,omitempty" json:"contains,omitempty"`
+	NotContains []string `yaml:"not_contains,omitempty" json:"not_contains,omitempty"`
+	Regex       []string `yaml:"regex,omitempty" json:"regex,omitempty"`
+	Rubric      string   `yaml:"rubric,omitempty" json:"rubric,omitempty"`
+}
+
+// Matcher checks a tool call parameter. A plain value is a shorthand for
+// Equals.
+type Matcher struct {
+	Equals   any    `yaml:"equals,omitempty" json:"equals,omitempty"`
+	Contains string `yaml:"contains,omitempty" json:"contains,omitempty"`
+	Regex    string `yaml:"regex,omitempty" json:"regex,omitempty"`
+}
+
+func (m *Matcher) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return node.Decode(&m.Equals)
+	}
+	type plain Matcher
+	return node.Decode((*plain)(m))
+}
+
+// Match reports why value does not match, or an empty string if it does
+func (m Matcher) Match(value any) string {
+	text := fmt.Sprint(value)
+	if m.Equals != nil && !sameValue(m.Equals, value) {
+		return fmt.Sprintf("is %q, expected %v", text, m.Equals)
+	}
+	if m.Contains != "" && !strings.Contains(strings.ToLower(text), strings.ToLower(m.Contains)) {
+		return fmt.Sprintf("is %q, expected it to contain %q", text, m.Contains)
+	}
+	if m.Regex != "" {
+		if ok, _ := regexp.MatchString(m.Regex, text); !ok {
+			return fmt.Sprintf("is %q, expected it to match %q", text, m.Regex)
+		}
+	}
+	return ""
+}
+
+func (m Matcher) String() string {
+	var parts []string
+	if m.Equals != nil {
+		parts = append(parts, fmt.Sprintf("= %v", m.Equals))
+	}
+	if m.Contains != "" {
+		parts = append(parts, fmt.Sprintf("contains %q", m.Contains))
+	}
+	if m.Regex != "" {
+		parts = append(parts, fmt.Sprintf("matches %q", m.Regex))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// sameValue compares values regardless of their Go types, so that the
+// integers of a suite equal the float64 decoded from tool call arguments
+func sameValue(expected, actual any) bool {
+	e, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	a, err := json.Marshal(actual)
+	if err != nil {
+		return false
+	}
+	return string(e) == string(a)
+}
+
+// Load reads a suite from a YAML or JSON file
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite %q: %w", path, err)
+	}
+
+	// JSON is valid YAML, a single decoder handles both
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse suite %q: %w", path, err)
+	}
+	if err := suite.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid suite %q: %w", path, err)
+	}
+	return &suite, nil
+}
+
+// Validate checks that every case can be run
+func (s *Suite) Validate() error {
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("no cases")
+	}
+	for i, c := range s.Cases {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if len(c.Messages) == 0 {
+			return fmt.Errorf("case %s: no messages", name)
+		}
+		if c.MaxLatency != "" {
+			if _, err := time.ParseDuration(c.MaxLatency); err != nil {
+				return fmt.Errorf("case %s: invalid max_latency: %w", name, err)
+			}
+		}
+		for _, r := range c.Response.Regex {
+			if _, err := regexp.Compile(r); err != nil {
+				return fmt.Errorf("case %s: invalid regex: %w", name, err)
+			}
+		}
+		for _, t := range c.ToolCalls {
+			for param, m := range t.Params {
+				if m.Regex == "" {
+					continue
+				}
+				if _, err := regexp.Compile(m.Regex); err != nil {
+					return fmt.Errorf("case %s: invalid regex for %s.%s: %w", name, t.Name, param, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// conversation returns the messages of the case in the format of the agent
+func (c Case) conversation() []openai.ChatCompletionMessage {
+	conv := make([]openai.ChatCompletionMessage, 0, len(c.Messages))
+	for _, m := range c.Messages {
+		role := m.Role
+		if role == "" {
+			role = openai.ChatMessageRoleUser
+		}
+		conv = append(conv, openai.ChatCompletionMessage{Role: role, Content: m.Content})
+	}
+	return conv
+}
@@ -0,0 +1,101 @@
+// Package history keeps the observables of an agent on disk so past jobs
+// can be searched and replayed.
+package history
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/types"
+)
+
+// Status is the state of an observable
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// DefaultLimit is the number of records returned by a query without a limit
+const DefaultLimit = 100
+
+var ErrNotFound = errors.New("observable not found in history")
+
+// Record is an observable as persisted in the history
+type Record struct {
+	types.Observable
+	Status    Status    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Action returns the name of the action run by the observable, if any
+func (r Record) Action() string {
+	if r.Creation == nil || r.Creation.FunctionDefinition == nil {
+		return ""
+	}
+	return r.Creation.FunctionDefinition.Name
+}
+
+// StatusOf returns the status of an observable from its completion
+func StatusOf(obs types.Observable) Status {
+	switch {
+	case obs.Completion == nil:
+		return StatusRunning
+	case obs.Completion.Error != "":
+		return StatusFailed
+	default:
+		return StatusCompleted
+	}
+}
+
+// Query selects records of the history. Zero values match everything.
+type Query struct {
+	From           time.Time
+	To             time.Time
+	Status         Status
+	Action         string
+	ConversationID string
+	// Limit is the maximum number of records returned, DefaultLimit if zero
+	Limit int
+}
+
+// Match reports whether the record is selected by the query
+func (q Query) Match(r Record) bool {
+	if !q.From.IsZero() && r.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && r.CreatedAt.After(q.To) {
+		return false
+	}
+	if q.Status != "" && r.Status != q.Status {
+		return false
+	}
+	if q.Action != "" && r.Action() != q.Action {
+		return false
+	}
+	if q.ConversationID != "" && r.ConversationID != q.ConversationID {
+		return false
+	}
+	return true
+}
+
+// Store persists the observables of an agent
+type Store interface {
+	// Save records the current state of an observable, replacing the
+	// previous one with the same ID
+	Save(obs types.Observable) error
+	// Query returns the matching records, newest first
+	Query(q Query) ([]Record, error)
+	// Timeline returns the job the observable belongs to: its root
+	// observable followed by all of its descendants, in creation order
+	Timeline(id int32) ([]Record, error)
+	// Recent returns the last n observables, oldest first
+	Recent(n int) []types.Observable
+	// MaxID returns the highest observable ID in the history
+	MaxID() int32
+	Clear() error
+	Close() error
+}
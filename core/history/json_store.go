@@ -0,0 +1,370 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/pkg/atomicfile"
+	"github.com/mudler/xlog"
+)
+
+const (
+	// DefaultRetention is how long observables are kept after their last update
+	DefaultRetention = 30 * 24 * time.Hour
+	// DefaultMaxRecords is the number of observables kept per agent
+	DefaultMaxRecords = 10000
+
+	// expireInterval is how often Save drops the observables past the retention
+	expireInterval = time.Minute
+)
+
+// JSONStore implements Store using a JSON lines file. Every update of an
+// observable is appended to the file, which is compacted once it holds
+// mostly stale updates.
+type JSONStore struct {
+	filePath   string
+	retention  time.Duration
+	maxRecords int
+	mu         sync.Mutex
+	records    map[int32]*Record
+	// file is kept open to append the updates, nil until the next one
+	file *os.File
+	// lines is the number of updates in the file
+	lines int
+	// expired is when the observables past the retention were last dropped
+	expired time.Time
+}
+
+// NewJSONStore creates a new JSON-based observable history
+func NewJSONStore(filePath string) (*JSONStore, error) {
+	s := &JSONStore{
+		filePath:   filePath,
+		retention:  DefaultRetention,
+		maxRecords: DefaultMaxRecords,
+		records:    make(map[int32]*Record),
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		return s, nil
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var r Record
+		if err := decoder.Decode(&r); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			// A crash can leave the last update half written, keep what was read so far
+			xlog.Warn("Truncated observable history, dropping the remaining updates", "file", filePath, "error", err)
+			s.lines = -1
+			break
+		}
+		s.records[r.ID] = &r
+		if s.lines >= 0 {
+			s.lines++
+		}
+	}
+
+	if s.lines < 0 {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+	s.prune(time.Now())
+
+	return s, nil
+}
+
+// SetLimits changes how long observables are kept after their last update
+// and how many of them are kept. They are enforced on the next Save.
+func (s *JSONStore) SetLimits(retention time.Duration, maxRecords int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retention = retention
+	s.maxRecords = maxRecords
+	s.expired = time.Time{}
+}
+
+// Save records the current state of an observable
+func (s *JSONStore) Save(obs types.Observable) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	r := &Record{
+		Observable: obs,
+		Status:     StatusOf(obs),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if previous, exists := s.records[obs.ID]; exists {
+		r.CreatedAt = previous.CreatedAt
+	}
+	// Children belong to the conversation of their job
+	if r.ConversationID == "" && r.ParentID != 0 {
+		if parent, exists := s.records[r.ParentID]; exists {
+			r.ConversationID = parent.ConversationID
+		}
+	}
+	s.records[obs.ID] = r
+
+	s.prune(now)
+	if s.lines > 2*len(s.records)+s.maxRecords/10 {
+		return s.compact()
+	}
+	if _, kept := s.records[obs.ID]; !kept {
+		return nil
+	}
+	return s.append(r)
+}
+
+// Query returns the matching records, newest first
+func (s *JSONStore) Query(q Query) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	records := make([]Record, 0)
+	for _, r := range s.sorted() {
+		if q.Match(*r) {
+			records = append(records, *r)
+		}
+	}
+	// sorted is oldest first
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// Timeline returns the root observable of the job id belongs to followed
+// by all of its descendants, in creation order
+func (s *JSONStore) Timeline(id int32) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root, exists := s.records[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	seen := map[int32]bool{root.ID: true}
+	for root.ParentID != 0 {
+		parent, exists := s.records[root.ParentID]
+		if !exists || seen[parent.ID] {
+			break
+		}
+		seen[parent.ID] = true
+		root = parent
+	}
+
+	children := make(map[int32][]*Record)
+	for _, r := range s.records {
+		if r.ParentID != 0 && r.ID != root.ID {
+			children[r.ParentID] = append(children[r.ParentID], r)
+		}
+	}
+
+	timeline := []Record{*root}
+	queue := []int32{root.ID}
+	for len(queue) > 0 {
+		parentID := queue[0]
+		queue = queue[1:]
+		for _, child := range children[parentID] {
+			timeline = append(timeline, *child)
+			queue = append(queue, child.ID)
+		}
+	}
+	sort.Slice(timeline, func(a, b int) bool {
+		return timeline[a].ID < timeline[b].ID
+	})
+	return timeline, nil
+}
+
+// Recent returns the last n observables, oldest first
+func (s *JSONStore) Recent(n int) []types.Observable {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.sorted()
+	if len(records) > n {
+		records = records[len(records)-n:]
+	}
+	observables := make([]types.Observable, 0, len(records))
+	for _, r := range records {
+		observables = append(observables, r.Observable)
+	}
+	return observables
+}
+
+// MaxID returns the highest observable ID in the history
+func (s *JSONStore) MaxID() int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var max int32
+	for id := range s.records {
+		if id > max {
+			max = id
+		}
+	}
+	return max
+}
+
+// Clear removes all the observables from the history
+func (s *JSONStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = make(map[int32]*Record)
+	s.lines = 0
+	if err := s.closeFile(); err != nil {
+		return err
+	}
+	if err := os.Remove(s.filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear history: %w", err)
+	}
+	return nil
+}
+
+// Close closes the history file. It is opened again if an observable is
+// saved afterwards, e.g. by the observer of a restarted agent.
+func (s *JSONStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closeFile()
+}
+
+// prune drops the observables past the retention, checked at most once
+// every expireInterval, and the oldest ones beyond maxRecords. The file
+// keeps them until the next compaction. (must be called with lock held)
+func (s *JSONStore) prune(now time.Time) {
+	if now.Sub(s.expired) >= expireInterval {
+		s.expired = now
+		for id, r := range s.records {
+			if now.Sub(r.UpdatedAt) > s.retention {
+				delete(s.records, id)
+			}
+		}
+	}
+
+	for len(s.records) > s.maxRecords {
+		oldest := int32(math.MaxInt32)
+		for id := range s.records {
+			if id < oldest {
+				oldest = id
+			}
+		}
+		delete(s.records, oldest)
+	}
+}
+
+// sorted returns the records by ID, oldest first (must be called with lock held)
+func (s *JSONStore) sorted() []*Record {
+	records := make([]*Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(a, b int) bool {
+		return records[a].ID < records[b].ID
+	})
+	return records
+}
+
+// append writes an update at the end of the file (must be called with lock held)
+func (s *JSONStore) append(r *Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal observable: %w", err)
+	}
+
+	if s.file == nil {
+		if err := s.mkdir(); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(s.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open history: %w", err)
+		}
+		s.file = f
+	}
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	s.lines++
+	return nil
+}
+
+// closeFile closes the file updates are appended to, if it is open (must
+// be called with lock held)
+func (s *JSONStore) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close history: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the file with the latest state of each observable
+// (must be called with lock held)
+func (s *JSONStore) compact() error {
+	kept := s.sorted()
+
+	// The open file is replaced, append to the new one from now on
+	if err := s.closeFile(); err != nil {
+		return err
+	}
+
+	err := atomicfile.Write(s.filePath, 0644, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		for _, r := range kept {
+			if err := encoder.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	s.lines = len(kept)
+	return nil
+}
+
+func (s *JSONStore) mkdir() error {
+	if dir := filepath.Dir(s.filePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+	return nil
+}
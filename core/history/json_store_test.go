@@ -0,0 +1,221 @@
+package history_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/history"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+)
+
+func action(id, parent int32, name string) types.Observable {
+	return types.Observable{
+		ID:       id,
+		ParentID: parent,
+		Name:     "action",
+		Creation: &types.Creation{FunctionDefinition: &openai.FunctionDefinition{Name: name}},
+	}
+}
+
+func ids(records []history.Record) []int32 {
+	out := make([]int32, 0, len(records))
+	for _, r := range records {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+var _ = Describe("JSONStore", func() {
+	var (
+		path  string
+		store *history.JSONStore
+	)
+
+	BeforeEach(func() {
+		var err error
+		path = filepath.Join(GinkgoT().TempDir(), "observables.jsonl")
+		store, err = history.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(store.Close)
+	})
+
+	// job 1 (conversation a) runs search and fails on fetch, job 4 (conversation b) is still running
+	saveJobs := func() {
+		Expect(store.Save(types.Observable{ID: 1, Name: "job"})).To(Succeed())
+		Expect(store.Save(types.Observable{ID: 1, Name: "job", ConversationID: "a"})).To(Succeed())
+		Expect(store.Save(action(2, 1, "search"))).To(Succeed())
+		Expect(store.Save(types.Observable{ID: 3, ParentID: 2, Name: "decision"})).To(Succeed())
+		Expect(store.Save(types.Observable{ID: 4, Name: "job", ConversationID: "b"})).To(Succeed())
+
+		fetch := action(5, 1, "fetch")
+		fetch.Completion = &types.Completion{Error: "timeout"}
+		Expect(store.Save(fetch)).To(Succeed())
+		Expect(store.Save(types.Observable{ID: 1, Name: "job", ConversationID: "a", Completion: &types.Completion{}})).To(Succeed())
+	}
+
+	It("should keep the latest state of each observable", func() {
+		Expect(store.Save(types.Observable{ID: 1, Name: "job"})).To(Succeed())
+		records, err := store.Query(history.Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		created := records[0].CreatedAt
+		Expect(records[0].Status).To(Equal(history.StatusRunning))
+
+		Expect(store.Save(types.Observable{ID: 1, Name: "job", Completion: &types.Completion{}})).To(Succeed())
+		records, err = store.Query(history.Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Status).To(Equal(history.StatusCompleted))
+		Expect(records[0].CreatedAt).To(Equal(created))
+	})
+
+	It("should filter by status, action and conversation, newest first", func() {
+		saveJobs()
+
+		records, err := store.Query(history.Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(records)).To(Equal([]int32{5, 4, 3, 2, 1}))
+
+		records, err = store.Query(history.Query{Status: history.StatusFailed})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(records)).To(Equal([]int32{5}))
+
+		records, err = store.Query(history.Query{Action: "search"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(records)).To(Equal([]int32{2}))
+
+		// Children inherit the conversation of their job
+		records, err = store.Query(history.Query{ConversationID: "a"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(records)).To(Equal([]int32{5, 3, 2, 1}))
+
+		records, err = store.Query(history.Query{Limit: 2})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(records)).To(Equal([]int32{5, 4}))
+
+		records, err = store.Query(history.Query{From: time.Now().Add(time.Hour)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(BeEmpty())
+	})
+
+	It("should replay the whole job from any of its observables", func() {
+		saveJobs()
+
+		timeline, err := store.Timeline(3)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(timeline)).To(Equal([]int32{1, 2, 3, 5}))
+		Expect(timeline[0].Status).To(Equal(history.StatusCompleted))
+
+		_, err = store.Timeline(42)
+		Expect(err).To(MatchError(history.ErrNotFound))
+	})
+
+	It("should reload the history after a restart", func() {
+		saveJobs()
+
+		reopened, err := history.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reopened.MaxID()).To(Equal(int32(5)))
+		records, err := reopened.Query(history.Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(records)).To(Equal([]int32{5, 4, 3, 2, 1}))
+		Expect(records[4].Status).To(Equal(history.StatusCompleted))
+
+		recent := reopened.Recent(2)
+		Expect(recent).To(HaveLen(2))
+		Expect(recent[0].ID).To(Equal(int32(4)))
+		Expect(recent[1].ID).To(Equal(int32(5)))
+	})
+
+	It("should drop a half written update", func() {
+		saveJobs()
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = f.WriteString(`{"id": 6, "name": "jo`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+
+		reopened, err := history.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reopened.MaxID()).To(Equal(int32(5)))
+		Expect(reopened.Save(types.Observable{ID: 6, Name: "job"})).To(Succeed())
+
+		reopened, err = history.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reopened.MaxID()).To(Equal(int32(6)))
+	})
+
+	It("should compact the file once it holds mostly stale updates", func() {
+		obs := types.Observable{ID: 1, Name: "job"}
+		for i := 0; i < 3000; i++ {
+			obs.AddProgress(types.Progress{ActionResult: "step"})
+			obs.Progress = obs.Progress[len(obs.Progress)-1:]
+			Expect(store.Save(obs)).To(Succeed())
+		}
+
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bytes.Count(data, []byte("\n"))).To(BeNumerically("<", 3000))
+
+		reopened, err := history.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reopened.MaxID()).To(Equal(int32(1)))
+	})
+
+	It("should keep at most maxRecords observables on every save", func() {
+		store.SetLimits(history.DefaultRetention, 3)
+		saveJobs()
+
+		records, err := store.Query(history.Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(records)).To(Equal([]int32{5, 4, 3}))
+
+		reopened, err := history.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		reopened.SetLimits(history.DefaultRetention, 3)
+		Expect(reopened.Save(types.Observable{ID: 6, Name: "job"})).To(Succeed())
+		records, err = reopened.Query(history.Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(records)).To(Equal([]int32{6, 5, 4}))
+	})
+
+	It("should drop the observables past the retention on save", func() {
+		Expect(store.Save(types.Observable{ID: 1, Name: "job"})).To(Succeed())
+		time.Sleep(10 * time.Millisecond)
+
+		store.SetLimits(5*time.Millisecond, history.DefaultMaxRecords)
+		Expect(store.Save(types.Observable{ID: 2, Name: "job"})).To(Succeed())
+
+		records, err := store.Query(history.Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids(records)).To(Equal([]int32{2}))
+	})
+
+	It("should reopen the file when saving after Close", func() {
+		Expect(store.Save(types.Observable{ID: 1, Name: "job"})).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+		Expect(store.Save(types.Observable{ID: 2, Name: "job"})).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		reopened, err := history.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reopened.MaxID()).To(Equal(int32(2)))
+	})
+
+	It("should clear the history", func() {
+		saveJobs()
+		Expect(store.Clear()).To(Succeed())
+		Expect(store.MaxID()).To(BeZero())
+
+		reopened, err := history.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		records, err := reopened.Query(history.Query{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(BeEmpty())
+	})
+})
@@ -0,0 +1,119 @@
+package journal
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Status represents the state of a journaled job
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusRunning  Status = "running"
+	StatusFinished Status = "finished"
+)
+
+// InterruptedPolicy defines what happens on startup to jobs that were
+// running when the process stopped
+type InterruptedPolicy string
+
+const (
+	// InterruptedRetry runs the job again (default)
+	InterruptedRetry InterruptedPolicy = "retry"
+	// InterruptedFail marks the job as failed without running it again
+	InterruptedFail InterruptedPolicy = "fail"
+)
+
+// MaxAttempts is the number of times a job is started before it is
+// considered poisoned and no longer replayed
+const MaxAttempts = 3
+
+var (
+	ErrNotFound  = errors.New("job not found in journal")
+	ErrDuplicate = errors.New("job already in journal")
+)
+
+// Entry is the persisted form of a job. Callbacks and contexts cannot
+// be persisted, only what is needed to run the job again.
+type Entry struct {
+	ID                  string                         `json:"id"` // Job.UUID, used as idempotency key
+	Status              Status                         `json:"status"`
+	ConversationHistory []openai.ChatCompletionMessage `json:"conversation_history"`
+	Metadata            map[string]interface{}         `json:"metadata,omitempty"`
+	IntMetadata         map[string]int64               `json:"int_metadata,omitempty"` // int64 metadata, like chat IDs, that JSON would turn into float64
+	BuiltinTools        []types.ActionDefinition       `json:"builtin_tools,omitempty"`
+	UserTools           []types.ActionDefinition       `json:"user_tools,omitempty"`
+	ToolChoice          string                         `json:"tool_choice,omitempty"`
+	Attempts            int                            `json:"attempts"`
+	Response            string                         `json:"response,omitempty"`
+	Error               string                         `json:"error,omitempty"`
+	CreatedAt           time.Time                      `json:"created_at"`
+	UpdatedAt           time.Time                      `json:"updated_at"`
+}
+
+// Journal records the lifecycle of queued jobs so they can be
+// replayed after a crash or a restart
+type Journal interface {
+	// Add records a new pending job. Returns ErrDuplicate if a job with
+	// the same ID is already known.
+	Add(entry *Entry) error
+	Get(id string) (*Entry, error)
+	MarkRunning(id string) error
+	MarkFinished(id string, response string, jobErr error) error
+	// Unfinished returns the pending and running jobs, oldest first
+	Unfinished() ([]*Entry, error)
+	Close() error
+}
+
+// NewEntry creates a journal entry from a job
+func NewEntry(job *types.Job) *Entry {
+	now := time.Now()
+	metadata := map[string]interface{}{}
+	var intMetadata map[string]int64
+	for k, v := range job.Metadata {
+		switch n := v.(type) {
+		case int64:
+			if intMetadata == nil {
+				intMetadata = map[string]int64{}
+			}
+			intMetadata[k] = n
+		default:
+			metadata[k] = v
+		}
+	}
+	return &Entry{
+		ID:                  job.UUID,
+		Status:              StatusPending,
+		ConversationHistory: job.ConversationHistory,
+		Metadata:            metadata,
+		IntMetadata:         intMetadata,
+		BuiltinTools:        job.BuiltinTools,
+		UserTools:           job.UserTools,
+		ToolChoice:          job.ToolChoice,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+}
+
+// JobOptions returns the options to rebuild the job recorded by the entry
+func (e *Entry) JobOptions() []types.JobOption {
+	metadata := map[string]interface{}{}
+	for k, v := range e.Metadata {
+		metadata[k] = v
+	}
+	for k, v := range e.IntMetadata {
+		metadata[k] = v
+	}
+	return []types.JobOption{
+		types.WithUUID(e.ID),
+		types.WithConversationHistory(e.ConversationHistory),
+		types.WithMetadata(metadata),
+		types.WithBuiltinTools(e.BuiltinTools),
+		types.WithUserTools(e.UserTools),
+		types.WithToolChoice(e.ToolChoice),
+	}
+}
@@ -0,0 +1,160 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAGI/pkg/atomicfile"
+)
+
+// DefaultFinishedRetention is how long finished jobs are kept to
+// detect duplicate submissions
+const DefaultFinishedRetention = 24 * time.Hour
+
+// JSONJournal implements Journal using JSON file storage
+type JSONJournal struct {
+	filePath          string
+	finishedRetention time.Duration
+	mu                sync.Mutex
+	entries           map[string]*Entry
+}
+
+// NewJSONJournal creates a new JSON-based job journal
+func NewJSONJournal(filePath string) (*JSONJournal, error) {
+	j := &JSONJournal{
+		filePath:          filePath,
+		finishedRetention: DefaultFinishedRetention,
+		entries:           make(map[string]*Entry),
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read journal: %w", err)
+		}
+		return j, nil
+	}
+
+	if len(data) > 0 {
+		var entries []*Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse journal: %w", err)
+		}
+		for _, e := range entries {
+			j.entries[e.ID] = e
+		}
+	}
+
+	return j, nil
+}
+
+// Add records a new pending job
+func (j *JSONJournal) Add(entry *Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, exists := j.entries[entry.ID]; exists {
+		return ErrDuplicate
+	}
+
+	j.entries[entry.ID] = entry
+	return j.save()
+}
+
+// Get retrieves a job by ID
+func (j *JSONJournal) Get(id string) (*Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e, exists := j.entries[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	entry := *e
+	return &entry, nil
+}
+
+// MarkRunning marks a job as started
+func (j *JSONJournal) MarkRunning(id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e, exists := j.entries[id]
+	if !exists {
+		return ErrNotFound
+	}
+	e.Status = StatusRunning
+	e.Attempts++
+	e.UpdatedAt = time.Now()
+	return j.save()
+}
+
+// MarkFinished marks a job as done, recording its outcome
+func (j *JSONJournal) MarkFinished(id string, response string, jobErr error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e, exists := j.entries[id]
+	if !exists {
+		return ErrNotFound
+	}
+	e.Status = StatusFinished
+	e.Response = response
+	e.Error = ""
+	if jobErr != nil {
+		e.Error = jobErr.Error()
+	}
+	e.UpdatedAt = time.Now()
+	// Finished jobs don't need to be replayed, drop the payload
+	e.ConversationHistory = nil
+	e.BuiltinTools = nil
+	e.UserTools = nil
+	return j.save()
+}
+
+// Unfinished returns the pending and running jobs, oldest first
+func (j *JSONJournal) Unfinished() ([]*Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]*Entry, 0)
+	for _, e := range j.entries {
+		if e.Status != StatusFinished {
+			entry := *e
+			entries = append(entries, &entry)
+		}
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].CreatedAt.Before(entries[b].CreatedAt)
+	})
+	return entries, nil
+}
+
+// Close closes the journal
+func (j *JSONJournal) Close() error {
+	return nil
+}
+
+// save prunes old finished entries and writes the journal to disk (must be called with lock held)
+func (j *JSONJournal) save() error {
+	entries := make([]*Entry, 0, len(j.entries))
+	for id, e := range j.entries {
+		if e.Status == StatusFinished && time.Since(e.UpdatedAt) > j.finishedRetention {
+			delete(j.entries, id)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].CreatedAt.Before(entries[b].CreatedAt)
+	})
+
+	if err := atomicfile.WriteJSON(j.filePath, entries, 0600); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
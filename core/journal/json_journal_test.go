@@ -0,0 +1,102 @@
+package journal_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAGI/core/journal"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSONJournal", func() {
+	var (
+		path string
+		j    *journal.JSONJournal
+	)
+
+	BeforeEach(func() {
+		var err error
+		path = filepath.Join(GinkgoT().TempDir(), "jobs.json")
+		j, err = journal.NewJSONJournal(path)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject duplicate job IDs", func() {
+		job := types.NewJob(types.WithText("hello"))
+		Expect(j.Add(journal.NewEntry(job))).To(Succeed())
+		Expect(j.Add(journal.NewEntry(job))).To(MatchError(journal.ErrDuplicate))
+	})
+
+	It("should store the journal in a file only its owner can read", func() {
+		Expect(j.Add(journal.NewEntry(types.NewJob(types.WithText("hello"))))).To(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("should return unfinished jobs after a restart", func() {
+		pending := types.NewJob(types.WithText("pending"))
+		running := types.NewJob(types.WithText("running"))
+		finished := types.NewJob(types.WithText("finished"))
+		for _, job := range []*types.Job{pending, running, finished} {
+			Expect(j.Add(journal.NewEntry(job))).To(Succeed())
+		}
+		Expect(j.MarkRunning(running.UUID)).To(Succeed())
+		Expect(j.MarkRunning(finished.UUID)).To(Succeed())
+		Expect(j.MarkFinished(finished.UUID, "done", nil)).To(Succeed())
+
+		reopened, err := journal.NewJSONJournal(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		entries, err := reopened.Unfinished()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].ID).To(Equal(pending.UUID))
+		Expect(entries[0].Status).To(Equal(journal.StatusPending))
+		Expect(entries[1].ID).To(Equal(running.UUID))
+		Expect(entries[1].Status).To(Equal(journal.StatusRunning))
+		Expect(entries[1].Attempts).To(Equal(1))
+		Expect(entries[1].ConversationHistory[0].Content).To(Equal("running"))
+	})
+
+	It("should replay jobs with their ID and the types of their metadata", func() {
+		job := types.NewJob(
+			types.WithText("hello"),
+			types.WithMetadata(map[string]interface{}{
+				"chatID":  int64(42),
+				"channel": "C1",
+			}),
+		)
+		Expect(j.Add(journal.NewEntry(job))).To(Succeed())
+
+		reopened, err := journal.NewJSONJournal(path)
+		Expect(err).NotTo(HaveOccurred())
+		entries, err := reopened.Unfinished()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+
+		replayed := types.NewJob(entries[0].JobOptions()...)
+		Expect(replayed.UUID).To(Equal(job.UUID))
+		Expect(replayed.Metadata["chatID"]).To(Equal(int64(42)))
+		Expect(replayed.Metadata["channel"]).To(Equal("C1"))
+	})
+
+	It("should record the outcome of finished jobs", func() {
+		job := types.NewJob(types.WithText("hello"))
+		Expect(j.Add(journal.NewEntry(job))).To(Succeed())
+		Expect(j.MarkFinished(job.UUID, "", errors.New("boom"))).To(Succeed())
+
+		entry, err := j.Get(job.UUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entry.Status).To(Equal(journal.StatusFinished))
+		Expect(entry.Error).To(Equal("boom"))
+	})
+
+	It("should return ErrNotFound for unknown jobs", func() {
+		Expect(j.MarkRunning("unknown")).To(MatchError(journal.ErrNotFound))
+	})
+})
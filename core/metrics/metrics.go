@@ -0,0 +1,81 @@
+// Package metrics holds the Prometheus collectors shared by the agents,
+// the scheduler and the connectors.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "localagi"
+
+// Job statuses
+const (
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+var (
+	JobsEnqueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_enqueued_total",
+		Help:      "Jobs queued for an agent.",
+	}, []string{"agent"})
+
+	JobsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_finished_total",
+		Help:      "Jobs processed by an agent, by status (completed or failed).",
+	}, []string{"agent", "status"})
+
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Time an agent takes to process a job.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{"agent"})
+
+	ActionInvocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "action_invocations_total",
+		Help:      "Actions run by an agent.",
+	}, []string{"agent", "action"})
+
+	ActionErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "action_errors_total",
+		Help:      "Actions run by an agent that returned an error.",
+	}, []string{"agent", "action"})
+
+	LLMCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_call_duration_seconds",
+		Help:      "Latency of the LLM calls made by an agent.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"agent", "model"})
+
+	SchedulerTaskRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduler_task_runs_total",
+		Help:      "Scheduled task runs, by status.",
+	}, []string{"agent", "status"})
+
+	ConnectorMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "connector_messages_total",
+		Help:      "Messages received by the connectors of an agent.",
+	}, []string{"agent", "connector"})
+)
+
+// Collectors returns the metrics of the agents, to be registered in a registry
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		JobsEnqueued,
+		JobsFinished,
+		JobDuration,
+		ActionInvocations,
+		ActionErrors,
+		LLMCallDuration,
+		SchedulerTaskRuns,
+		ConnectorMessages,
+	}
+}
@@ -0,0 +1,35 @@
+package metrics_test
+
+import (
+	"strings"
+
+	"github.com/mudler/LocalAGI/core/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Collectors", func() {
+	It("registers every collector in a registry", func() {
+		registry := prometheus.NewRegistry()
+		Expect(func() { registry.MustRegister(metrics.Collectors()...) }).NotTo(Panic())
+	})
+
+	It("exposes the job counters under the localagi namespace", func() {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(metrics.Collectors()...)
+
+		metrics.JobsEnqueued.WithLabelValues("collectors-agent").Inc()
+		metrics.JobsFinished.WithLabelValues("collectors-agent", metrics.StatusCompleted).Inc()
+
+		Expect(testutil.ToFloat64(metrics.JobsEnqueued.WithLabelValues("collectors-agent"))).To(Equal(1.0))
+
+		expected := `
+# HELP localagi_jobs_finished_total Jobs processed by an agent, by status (completed or failed).
+# TYPE localagi_jobs_finished_total counter
+localagi_jobs_finished_total{agent="collectors-agent",status="completed"} 1
+`
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(expected), "localagi_jobs_finished_total")).To(Succeed())
+	})
+})
@@ -0,0 +1,255 @@
+// Package ratelimit limits how often an API key or a connector user can
+// hand jobs to the agents, with token buckets.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrLimited = errors.New("rate limit exceeded")
+
+// Rate is the number of requests allowed over a period. Unused requests
+// add up to a burst of at most Requests.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Enabled tells whether the rate limits anything
+func (r Rate) Enabled() bool {
+	return r.Requests > 0 && r.Per > 0
+}
+
+func (r Rate) String() string {
+	switch r.Per {
+	case time.Second:
+		return fmt.Sprintf("%d/s", r.Requests)
+	case time.Minute:
+		return fmt.Sprintf("%d/m", r.Requests)
+	case time.Hour:
+		return fmt.Sprintf("%d/h", r.Requests)
+	case 24 * time.Hour:
+		return fmt.Sprintf("%d/d", r.Requests)
+	}
+	return fmt.Sprintf("%d/%s", r.Requests, r.Per)
+}
+
+// ParseRate reads rates like 30/m: a number of requests, then the period as
+// s, m, h, d or a duration like 90s. An empty string is no limit.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Rate{}, nil
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q, expected <requests>/<period> like 30/m", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("invalid number of requests in rate %q", s)
+	}
+
+	rate := Rate{Requests: n}
+	switch period = strings.TrimSpace(period); period {
+	case "s", "sec", "second":
+		rate.Per = time.Second
+	case "m", "min", "minute":
+		rate.Per = time.Minute
+	case "h", "hour":
+		rate.Per = time.Hour
+	case "d", "day":
+		rate.Per = 24 * time.Hour
+	default:
+		if rate.Per, err = time.ParseDuration(period); err != nil || rate.Per <= 0 {
+			return Rate{}, fmt.Errorf("invalid period in rate %q", s)
+		}
+	}
+	return rate, nil
+}
+
+// Scope is what a limit applies to
+type Scope string
+
+const (
+	// ScopeAgent limits the requests of a key to a single agent
+	ScopeAgent Scope = "agent"
+	// ScopePool limits the requests of a key across all the agents
+	ScopePool Scope = "pool"
+)
+
+// Error is returned for requests over a limit. It wraps ErrLimited.
+type Error struct {
+	Scope      Scope
+	Agent      string
+	Rate       Rate
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	retry := time.Duration(e.RetryAfterSeconds()) * time.Second
+	if e.Scope == ScopeAgent {
+		return fmt.Sprintf("%s: %s for agent %s, retry in %s", ErrLimited, e.Rate, e.Agent, retry)
+	}
+	return fmt.Sprintf("%s: %s, retry in %s", ErrLimited, e.Rate, retry)
+}
+
+func (e *Error) Unwrap() error {
+	return ErrLimited
+}
+
+// RetryAfterSeconds is the number of whole seconds to wait before retrying,
+// as sent in Retry-After headers
+func (e *Error) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Message tells the sender to slow down, for the replies of connectors
+func (e *Error) Message() string {
+	seconds := e.RetryAfterSeconds()
+	unit := "seconds"
+	if seconds == 1 {
+		unit = "second"
+	}
+	return fmt.Sprintf("You are sending messages too quickly, please try again in %d %s.", seconds, unit)
+}
+
+// Limits configures a Limiter. Zero rates mean no limit.
+type Limits struct {
+	// Pool limits the requests of each key across all the agents
+	Pool Rate
+	// Agent limits the requests of each key to each agent
+	Agent Rate
+}
+
+// sweepInterval is how often the buckets that refilled are dropped
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per key, and per key and agent. A nil
+// Limiter allows everything.
+type Limiter struct {
+	limits Limits
+
+	mu        sync.Mutex
+	pool      map[string]*bucket
+	agents    map[string]*bucket
+	lastSweep time.Time
+}
+
+// New returns a Limiter enforcing limits. It returns nil when no limit is
+// set.
+func New(limits Limits) *Limiter {
+	if !limits.Pool.Enabled() && !limits.Agent.Enabled() {
+		return nil
+	}
+	return &Limiter{
+		limits:    limits,
+		pool:      map[string]*bucket{},
+		agents:    map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Limits returns the limits the Limiter enforces
+func (l *Limiter) Limits() Limits {
+	if l == nil {
+		return Limits{}
+	}
+	return l.limits
+}
+
+// Allow takes a request of key to agent from the buckets. It returns an
+// *Error when the key is over the limit of the agent or of the pool, in
+// which case nothing is taken. Empty keys are not limited.
+func (l *Limiter) Allow(agent, key string) error {
+	if l == nil || key == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	var agentBucket, poolBucket *bucket
+	if l.limits.Agent.Enabled() {
+		agentBucket = l.bucket(l.agents, agent+"\x00"+key, l.limits.Agent, now)
+		if agentBucket.tokens < 1 {
+			return &Error{Scope: ScopeAgent, Agent: agent, Rate: l.limits.Agent, RetryAfter: retryAfter(agentBucket, l.limits.Agent)}
+		}
+	}
+	if l.limits.Pool.Enabled() {
+		poolBucket = l.bucket(l.pool, key, l.limits.Pool, now)
+		if poolBucket.tokens < 1 {
+			return &Error{Scope: ScopePool, Agent: agent, Rate: l.limits.Pool, RetryAfter: retryAfter(poolBucket, l.limits.Pool)}
+		}
+	}
+
+	if agentBucket != nil {
+		agentBucket.tokens--
+	}
+	if poolBucket != nil {
+		poolBucket.tokens--
+	}
+	return nil
+}
+
+// bucket returns the bucket of key, refilled up to now
+func (l *Limiter) bucket(buckets map[string]*bucket, key string, rate Rate, now time.Time) *bucket {
+	b, ok := buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Requests), last: now}
+		buckets[key] = b
+		return b
+	}
+	refill(b, rate, now)
+	return b
+}
+
+func refill(b *bucket, rate Rate, now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(float64(rate.Requests), b.tokens+elapsed.Seconds()*perSecond(rate))
+	b.last = now
+}
+
+func perSecond(rate Rate) float64 {
+	return float64(rate.Requests) / rate.Per.Seconds()
+}
+
+// retryAfter is how long until the bucket holds a whole token
+func retryAfter(b *bucket, rate Rate) time.Duration {
+	return time.Duration((1 - b.tokens) / perSecond(rate) * float64(time.Second))
+}
+
+// sweep drops the buckets that refilled, they are the same as new ones
+func (l *Limiter) sweep(now time.Time) {
+	for _, set := range []struct {
+		buckets map[string]*bucket
+		rate    Rate
+	}{{l.pool, l.limits.Pool}, {l.agents, l.limits.Agent}} {
+		for key, b := range set.buckets {
+			refill(b, set.rate, now)
+			if b.tokens >= float64(set.rate.Requests) {
+				delete(set.buckets, key)
+			}
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,120 @@
+package ratelimit_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/ratelimit"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	It("allows a burst of requests, then refuses them until the bucket refills", func() {
+		limiter := ratelimit.New(ratelimit.Limits{Agent: ratelimit.Rate{Requests: 2, Per: 200 * time.Millisecond}})
+
+		Expect(limiter.Allow("support", "slack:U1")).To(Succeed())
+		Expect(limiter.Allow("support", "slack:U1")).To(Succeed())
+
+		err := limiter.Allow("support", "slack:U1")
+		Expect(err).To(MatchError(ratelimit.ErrLimited))
+		var limitErr *ratelimit.Error
+		Expect(errors.As(err, &limitErr)).To(BeTrue())
+		Expect(limitErr.Scope).To(Equal(ratelimit.ScopeAgent))
+		Expect(limitErr.Agent).To(Equal("support"))
+		Expect(limitErr.RetryAfter).To(BeNumerically(">", 0))
+		Expect(limitErr.RetryAfter).To(BeNumerically("<=", 100*time.Millisecond))
+		Expect(limitErr.RetryAfterSeconds()).To(Equal(1))
+		Expect(limitErr.Message()).To(Equal("You are sending messages too quickly, please try again in 1 second."))
+
+		Eventually(func() error {
+			return limiter.Allow("support", "slack:U1")
+		}, time.Second, 10*time.Millisecond).Should(Succeed())
+	})
+
+	It("keeps a bucket per key and per agent", func() {
+		limiter := ratelimit.New(ratelimit.Limits{Agent: ratelimit.Rate{Requests: 1, Per: time.Hour}})
+
+		Expect(limiter.Allow("support", "slack:U1")).To(Succeed())
+		Expect(limiter.Allow("support", "slack:U1")).To(MatchError(ratelimit.ErrLimited))
+		Expect(limiter.Allow("support", "slack:U2")).To(Succeed())
+		Expect(limiter.Allow("sales", "slack:U1")).To(Succeed())
+	})
+
+	It("limits a key across all the agents", func() {
+		limiter := ratelimit.New(ratelimit.Limits{
+			Pool:  ratelimit.Rate{Requests: 2, Per: time.Hour},
+			Agent: ratelimit.Rate{Requests: 5, Per: time.Hour},
+		})
+
+		Expect(limiter.Allow("support", "api:alice")).To(Succeed())
+		Expect(limiter.Allow("sales", "api:alice")).To(Succeed())
+
+		var limitErr *ratelimit.Error
+		Expect(errors.As(limiter.Allow("billing", "api:alice"), &limitErr)).To(BeTrue())
+		Expect(limitErr.Scope).To(Equal(ratelimit.ScopePool))
+		Expect(limitErr.Error()).To(Equal("rate limit exceeded: 2/h, retry in 30m0s"))
+		Expect(limiter.Allow("billing", "api:bob")).To(Succeed())
+	})
+
+	It("takes nothing from the agent bucket when the pool refuses", func() {
+		limiter := ratelimit.New(ratelimit.Limits{
+			Pool:  ratelimit.Rate{Requests: 1, Per: time.Hour},
+			Agent: ratelimit.Rate{Requests: 1, Per: time.Hour},
+		})
+
+		Expect(limiter.Allow("support", "api:alice")).To(Succeed())
+		Expect(limiter.Allow("sales", "api:alice")).To(MatchError(ratelimit.ErrLimited))
+
+		other := ratelimit.New(ratelimit.Limits{Agent: ratelimit.Rate{Requests: 1, Per: time.Hour}})
+		Expect(other.Allow("sales", "api:alice")).To(Succeed())
+	})
+
+	It("does not limit empty keys, nor without limits", func() {
+		limiter := ratelimit.New(ratelimit.Limits{Pool: ratelimit.Rate{Requests: 1, Per: time.Hour}})
+		for i := 0; i < 3; i++ {
+			Expect(limiter.Allow("support", "")).To(Succeed())
+		}
+
+		none := ratelimit.New(ratelimit.Limits{})
+		Expect(none).To(BeNil())
+		Expect(none.Allow("support", "api:alice")).To(Succeed())
+		Expect(none.Limits()).To(Equal(ratelimit.Limits{}))
+	})
+})
+
+var _ = Describe("ParseRate", func() {
+	DescribeTable("reads rates",
+		func(s string, expected ratelimit.Rate) {
+			rate, err := ratelimit.ParseRate(s)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rate).To(Equal(expected))
+		},
+		Entry("empty", "", ratelimit.Rate{}),
+		Entry("per second", "5/s", ratelimit.Rate{Requests: 5, Per: time.Second}),
+		Entry("per minute", "30/m", ratelimit.Rate{Requests: 30, Per: time.Minute}),
+		Entry("per hour", "100/hour", ratelimit.Rate{Requests: 100, Per: time.Hour}),
+		Entry("per day", "1000/d", ratelimit.Rate{Requests: 1000, Per: 24 * time.Hour}),
+		Entry("per duration", " 10 / 90s ", ratelimit.Rate{Requests: 10, Per: 90 * time.Second}),
+	)
+
+	DescribeTable("rejects invalid rates",
+		func(s string) {
+			_, err := ratelimit.ParseRate(s)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("no period", "30"),
+		Entry("no requests", "/m"),
+		Entry("negative requests", "-1/m"),
+		Entry("unknown period", "30/fortnight"),
+		Entry("negative period", "30/-1m"),
+	)
+
+	It("prints rates the way they are parsed", func() {
+		for _, s := range []string{"5/s", "30/m", "100/h", "1000/d", "10/1m30s"} {
+			rate, err := ratelimit.ParseRate(s)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rate.String()).To(Equal(s))
+		}
+	})
+})
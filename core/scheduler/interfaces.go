@@ -47,3 +47,11 @@ type JobResult struct {
 	Response string
 	Error    error
 }
+
+type taskIDKey struct{}
+
+// TaskIDFromContext returns the ID of the task an execution context belongs to
+func TaskIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(taskIDKey{}).(string)
+	return id, ok
+}
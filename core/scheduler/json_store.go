@@ -94,7 +94,7 @@ func (s *JSONStore) GetDue() ([]*Task, error) {
 	dueTasks := make([]*Task, 0)
 
 	for _, task := range s.data.Tasks {
-		if task.Status == TaskStatusActive && now.After(task.NextRun) {
+		if task.Status == TaskStatusActive && task.timeScheduled() && now.After(task.NextRun) {
 			dueTasks = append(dueTasks, task)
 		}
 	}
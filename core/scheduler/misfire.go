@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MisfireAction defines what happens to the occurrences of a task that were
+// missed, e.g. because the server was down when they were due
+type MisfireAction string
+
+const (
+	// MisfireSkip records missed occurrences without running them
+	MisfireSkip MisfireAction = "skip"
+	// MisfireRunOnce runs the task once for all the missed occurrences (default)
+	MisfireRunOnce MisfireAction = "run_once"
+	// MisfireRunAll runs each missed occurrence, up to MaxCatchUp
+	MisfireRunAll MisfireAction = "run_all"
+)
+
+const (
+	// DefaultMaxCatchUp bounds the missed occurrences run by MisfireRunAll
+	DefaultMaxCatchUp = 10
+
+	// maxTrackedMisfires bounds the missed occurrences recorded in the run history
+	maxTrackedMisfires = 100
+
+	// maxCronScan bounds how many missed cron occurrences are walked through
+	maxCronScan = 100000
+)
+
+// MisfirePolicy controls how a task catches up with missed occurrences
+type MisfirePolicy struct {
+	Action MisfireAction `json:"action"`
+	// MaxCatchUp is how many missed occurrences MisfireRunAll runs (default 10)
+	MaxCatchUp int `json:"max_catch_up,omitempty"`
+	// MaxLatenessMs marks occurrences later than this, in milliseconds, as
+	// missed instead of running them, whatever the action (0 for no limit)
+	MaxLatenessMs int64 `json:"max_lateness_ms,omitempty"`
+}
+
+// Validate checks the misfire action
+func (p *MisfirePolicy) Validate() error {
+	switch p.Action {
+	case "", MisfireSkip, MisfireRunOnce, MisfireRunAll:
+		return nil
+	default:
+		return fmt.Errorf("unknown misfire action: %s", p.Action)
+	}
+}
+
+// misfirePlan is the outcome of applying the misfire policy to a due task
+type misfirePlan struct {
+	// runs is how many times the task is executed now
+	runs int
+	// missed are the occurrences recorded as missed instead of running
+	missed []time.Time
+	// next is the first occurrence after now, used when nothing runs
+	next time.Time
+}
+
+// planMisfire decides how many times a due task runs, given the occurrences
+// it missed. An occurrence counts as misfired when it is later than grace.
+func (t *Task) planMisfire(now time.Time, grace time.Duration) (misfirePlan, error) {
+	// Retries and triggered runs are not occurrences of the schedule
+	if t.Attempts > 0 || t.ScheduleType == ScheduleTypeTrigger {
+		return misfirePlan{runs: 1}, nil
+	}
+
+	occurrences, next, err := t.occurrencesUntil(now)
+	if err != nil {
+		return misfirePlan{}, err
+	}
+	if len(occurrences) == 0 {
+		return misfirePlan{runs: 1}, nil
+	}
+
+	policy := MisfirePolicy{Action: MisfireRunOnce}
+	if t.Misfire != nil {
+		policy = *t.Misfire
+	}
+
+	plan := misfirePlan{next: next}
+
+	// Stale occurrences are never run
+	fresh := occurrences
+	if policy.MaxLatenessMs > 0 {
+		maxLateness := time.Duration(policy.MaxLatenessMs) * time.Millisecond
+		fresh = fresh[:0:0]
+		for _, at := range occurrences {
+			if now.Sub(at) > maxLateness {
+				plan.missed = append(plan.missed, at)
+			} else {
+				fresh = append(fresh, at)
+			}
+		}
+	}
+	if len(fresh) == 0 {
+		return plan, nil
+	}
+
+	misfired := len(occurrences) > 1 || now.Sub(occurrences[0]) > grace
+	if !misfired {
+		plan.runs = 1
+		return plan, nil
+	}
+
+	switch policy.Action {
+	case MisfireSkip:
+		plan.missed = append(plan.missed, fresh...)
+	case MisfireRunAll:
+		maxCatchUp := policy.MaxCatchUp
+		if maxCatchUp <= 0 {
+			maxCatchUp = DefaultMaxCatchUp
+		}
+		// Run the most recent occurrences, the older ones are missed
+		if len(fresh) > maxCatchUp {
+			plan.missed = append(plan.missed, fresh[:len(fresh)-maxCatchUp]...)
+			fresh = fresh[len(fresh)-maxCatchUp:]
+		}
+		plan.runs = len(fresh)
+	default:
+		plan.runs = 1
+	}
+
+	return plan, nil
+}
+
+// occurrencesUntil returns the scheduled times from NextRun up to now, in
+// chronological order and limited to the most recent maxTrackedMisfires, and
+// the first scheduled time after now
+func (t *Task) occurrencesUntil(now time.Time) ([]time.Time, time.Time, error) {
+	if t.NextRun.After(now) {
+		return nil, t.NextRun, nil
+	}
+
+	switch t.ScheduleType {
+	case ScheduleTypeCron:
+		schedule, err := cronParser.Parse(t.ScheduleValue)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("invalid cron expression: %w", err)
+		}
+		// NextRun comes back from the store with a fixed offset, the
+		// occurrences are walked in the timezone of the task so that they
+		// follow its daylight saving changes. A CRON_TZ= prefix takes
+		// precedence over the task timezone.
+		loc, err := t.Location()
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if spec, ok := schedule.(*cron.SpecSchedule); ok && spec.Location != time.Local {
+			loc = spec.Location
+		}
+		var occurrences []time.Time
+		at := t.NextRun.In(loc)
+		for i := 0; !at.After(now) && i < maxCronScan; i++ {
+			occurrences = append(occurrences, at)
+			if len(occurrences) > maxTrackedMisfires {
+				occurrences = occurrences[1:]
+			}
+			at = schedule.Next(at)
+		}
+		if !at.After(now) {
+			at = schedule.Next(now.In(loc))
+		}
+		return occurrences, at, nil
+
+	case ScheduleTypeInterval:
+		intervalMs, err := strconv.ParseInt(t.ScheduleValue, 10, 64)
+		if err != nil || intervalMs <= 0 {
+			return nil, time.Time{}, fmt.Errorf("invalid interval: %s", t.ScheduleValue)
+		}
+		interval := time.Duration(intervalMs) * time.Millisecond
+		count := int(now.Sub(t.NextRun)/interval) + 1
+		var occurrences []time.Time
+		for i := max(0, count-maxTrackedMisfires); i < count; i++ {
+			occurrences = append(occurrences, t.NextRun.Add(time.Duration(i)*interval))
+		}
+		return occurrences, t.NextRun.Add(time.Duration(count) * interval), nil
+
+	default:
+		// One-time tasks have a single occurrence
+		return []time.Time{t.NextRun}, time.Time{}, nil
+	}
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    = 30 * time.Second
+	defaultRetryMaxBackoff = time.Hour
+	defaultRetryMultiplier = 2.0
+)
+
+// RetryPolicy controls how a failed task is retried before it is moved to
+// the dead letter state
+type RetryPolicy struct {
+	// MaxRetries is how many times a failed run is retried
+	MaxRetries int `json:"max_retries"`
+	// BackoffMs is the delay before the first retry, in milliseconds (default 30s)
+	BackoffMs int64 `json:"backoff_ms,omitempty"`
+	// MaxBackoffMs caps the delay between retries, in milliseconds (default 1h)
+	MaxBackoffMs int64 `json:"max_backoff_ms,omitempty"`
+	// Multiplier is applied to the delay after each retry (default 2). It
+	// can't be below 1, the delays never shrink.
+	Multiplier float64 `json:"multiplier,omitempty"`
+	// Jitter randomizes each delay by up to this fraction of it (0 to 1)
+	Jitter float64 `json:"jitter,omitempty"`
+}
+
+// Validate checks the multiplier
+func (p *RetryPolicy) Validate() error {
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return fmt.Errorf("invalid retry multiplier %v, it must be at least 1", p.Multiplier)
+	}
+	return nil
+}
+
+// Delay returns how long to wait before the given retry attempt (starting at 1)
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	backoff := defaultRetryBackoff
+	if p.BackoffMs > 0 {
+		backoff = time.Duration(p.BackoffMs) * time.Millisecond
+	}
+	maxBackoff := defaultRetryMaxBackoff
+	if p.MaxBackoffMs > 0 {
+		maxBackoff = time.Duration(p.MaxBackoffMs) * time.Millisecond
+	}
+	multiplier := defaultRetryMultiplier
+	if p.Multiplier != 0 {
+		multiplier = p.Multiplier
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := float64(backoff) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(maxBackoff) {
+		delay = float64(maxBackoff)
+	}
+
+	if jitter := math.Min(p.Jitter, 1); jitter > 0 {
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
@@ -2,13 +2,25 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/mudler/LocalAGI/core/metrics"
 	"github.com/mudler/xlog"
 )
 
+// ErrTaskRunning is returned when starting a task that is already running
+var ErrTaskRunning = errors.New("task already running")
+
+// ErrTaskCycle is returned when a task trigger would make a task depend on itself
+var ErrTaskCycle = errors.New("task trigger creates a cycle")
+
+// DeadLetterHandler is called when a task failed all of its retries.
+// run is the last failed run of the task.
+type DeadLetterHandler func(task *Task, run *TaskRun)
+
 // Scheduler manages scheduled tasks
 type Scheduler struct {
 	store        TaskStore
@@ -19,6 +31,8 @@ type Scheduler struct {
 	wg           sync.WaitGroup
 	mu           sync.RWMutex
 	runningTasks map[string]context.CancelFunc
+
+	deadLetterHandler DeadLetterHandler
 }
 
 // NewScheduler creates a new scheduler with the given store and executor
@@ -32,6 +46,13 @@ func NewScheduler(store TaskStore, executor AgentExecutor, pollInterval time.Dur
 	}
 }
 
+// OnDeadLetter sets the handler notified when a task is moved to the dead letter state
+func (s *Scheduler) OnDeadLetter(handler DeadLetterHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadLetterHandler = handler
+}
+
 // Start begins the scheduler's polling loop
 func (s *Scheduler) Start() {
 	if s.ctx != nil {
@@ -88,27 +109,104 @@ func (s *Scheduler) processDueTasks() {
 	}
 
 	for _, task := range tasks {
-		// Check if task is already running
-		s.mu.RLock()
-		_, running := s.runningTasks[task.ID]
-		s.mu.RUnlock()
-
-		if running {
+		if s.IsTaskRunning(task.ID) {
 			xlog.Warn("Task already running, skipping", "task_id", task.ID)
 			continue
 		}
 
-		// Execute task in goroutine
-		s.wg.Add(1)
-		go s.executeTask(task)
+		runs := s.applyMisfirePolicy(task)
+		if runs == 0 {
+			continue
+		}
+
+		// Triggered tasks are only due when retrying a failed run
+		s.launch(task, task.RetryUpstream, runs)
 	}
 }
 
-// executeTask runs a single task
-func (s *Scheduler) executeTask(task *Task) {
-	defer s.wg.Done()
+// applyMisfirePolicy records the occurrences of a due task that are missed
+// according to its misfire policy, and returns how many times it has to run
+func (s *Scheduler) applyMisfirePolicy(task *Task) int {
+	now := time.Now()
+	plan, err := task.planMisfire(now, s.misfireGrace())
+	if err != nil {
+		xlog.Error("Failed to apply misfire policy", "task_id", task.ID, "error", err)
+		return 1
+	}
+
+	for _, at := range plan.missed {
+		scheduledAt := at
+		run := NewTaskRun(task.ID)
+		run.Status = "missed"
+		run.ScheduledAt = &scheduledAt
+		run.Error = fmt.Sprintf("missed occurrence due at %s (%s late)", at.Format(time.RFC3339), now.Sub(at).Round(time.Second))
+		if err := s.store.LogRun(run); err != nil {
+			xlog.Error("Failed to log missed run", "task_id", task.ID, "error", err)
+		}
+		metrics.SchedulerTaskRuns.WithLabelValues(task.AgentName, run.Status).Inc()
+	}
+	if len(plan.missed) > 0 {
+		xlog.Warn("Task missed scheduled runs", "task_id", task.ID, "missed", len(plan.missed), "runs", plan.runs)
+	}
+
+	if plan.runs > 0 {
+		return plan.runs
+	}
+
+	// Nothing to run: move on to the next occurrence, one-time tasks are done
+	if task.ScheduleType == ScheduleTypeOnce {
+		if err := s.store.Delete(task.ID); err != nil {
+			xlog.Error("Failed to delete task", "task_id", task.ID, "error", err)
+		}
+		return 0
+	}
+	task.NextRun = plan.next
+	if err := s.store.Update(task); err != nil {
+		xlog.Error("Failed to update task", "task_id", task.ID, "error", err)
+	}
+	return 0
+}
+
+// misfireGrace is how late an occurrence can run before it counts as missed.
+// Due tasks are only noticed on the next poll, so it scales with the poll interval.
+func (s *Scheduler) misfireGrace() time.Duration {
+	return max(2*s.pollInterval, time.Minute)
+}
+
+// launch executes a task in its own goroutine unless it is already running.
+// upstream is the run that triggered the task, if any. times is how many runs
+// are executed one after the other, to catch up with missed occurrences.
+func (s *Scheduler) launch(task *Task, upstream *TaskRun, times int) {
+	if s.IsTaskRunning(task.ID) {
+		xlog.Warn("Task already running, skipping", "task_id", task.ID)
+		return
+	}
+
+	// Execute task in goroutine
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for i := 0; i < times; i++ {
+			if i > 0 {
+				// The previous run may have deleted, paused or dead lettered
+				// the task, or failed and be waiting for a retry
+				current, err := s.store.Get(task.ID)
+				if err != nil || current.Status != TaskStatusActive || current.Attempts > 0 || s.ctx.Err() != nil {
+					return
+				}
+				task = current
+			}
+			// Cancelling a run also cancels the catch-up runs after it
+			if run := s.executeTask(task, upstream); run.Status == "cancelled" {
+				return
+			}
+		}
+	}()
+}
 
-	taskCtx, cancel := context.WithCancel(s.ctx)
+// executeTask runs a single task and returns its run
+func (s *Scheduler) executeTask(task *Task, upstream *TaskRun) *TaskRun {
+	taskCtx, cancel := context.WithCancel(context.WithValue(s.ctx, taskIDKey{}, task.ID))
 	defer cancel()
 
 	// Register running task
@@ -126,17 +224,33 @@ func (s *Scheduler) executeTask(task *Task) {
 
 	startTime := time.Now()
 	run := NewTaskRun(task.ID)
+	if upstream != nil {
+		run.TriggeredBy = upstream.ID
+	}
 
 	// Execute the task
-	result, err := s.executor.Execute(taskCtx, task.AgentName, task.Prompt)
+	prompt, err := task.RenderPrompt(upstream)
+	var result *JobResult
+	if err == nil {
+		result, err = s.executor.Execute(taskCtx, task.AgentName, prompt)
+	}
 
 	run.DurationMs = time.Since(startTime).Milliseconds()
 
-	if err != nil {
+	// A cancelled context while the scheduler keeps running means the run was
+	// cancelled through CancelRunningTask, which is not a failure of the task
+	cancelled := err != nil && taskCtx.Err() != nil && s.ctx.Err() == nil
+
+	switch {
+	case cancelled:
+		run.Status = "cancelled"
+		run.Error = err.Error()
+		xlog.Info("Task execution cancelled", "task_id", task.ID, "duration_ms", run.DurationMs)
+	case err != nil:
 		run.Status = "error"
 		run.Error = err.Error()
 		xlog.Error("Task execution failed", "task_id", task.ID, "error", err)
-	} else {
+	default:
 		run.Status = "success"
 		if result != nil {
 			run.Result = result.Response
@@ -144,15 +258,55 @@ func (s *Scheduler) executeTask(task *Task) {
 		xlog.Info("Task executed successfully", "task_id", task.ID, "duration_ms", run.DurationMs)
 	}
 
+	run.Attempt = task.Attempts + 1
+
 	// Log the run
 	if err := s.store.LogRun(run); err != nil {
 		xlog.Error("Failed to log task run", "task_id", task.ID, "error", err)
 	}
+	metrics.SchedulerTaskRuns.WithLabelValues(task.AgentName, run.Status).Inc()
+
+	// The task may have been paused, edited or deleted while it was running:
+	// only update the scheduling state of its latest version
+	current, err := s.store.Get(task.ID)
+	if err != nil {
+		xlog.Info("Task removed while running, not rescheduling it", "task_id", task.ID)
+		return run
+	}
+	task = current
 
 	// Update task for next run
 	now := time.Now()
 	task.LastRun = &now
 
+	if run.Status != "success" && !cancelled && task.Retry != nil {
+		if task.Attempts < task.Retry.MaxRetries {
+			task.Attempts++
+			task.RetryUpstream = upstream
+			task.NextRun = now.Add(task.Retry.Delay(task.Attempts))
+			xlog.Warn("Task failed, retrying", "task_id", task.ID, "attempt", task.Attempts, "max_retries", task.Retry.MaxRetries, "next_run", task.NextRun)
+			if err := s.store.Update(task); err != nil {
+				xlog.Error("Failed to update task", "task_id", task.ID, "error", err)
+			}
+			// Downstream tasks only see the outcome of the last attempt
+			return run
+		}
+
+		xlog.Error("Task failed after all retries, moving it to dead letter", "task_id", task.ID, "attempts", task.Attempts+1)
+		task.Status = TaskStatusDeadLetter
+		task.Attempts = 0
+		task.RetryUpstream = nil
+		if err := s.store.Update(task); err != nil {
+			xlog.Error("Failed to update task", "task_id", task.ID, "error", err)
+		}
+		s.notifyDeadLetter(task, run)
+		s.triggerDownstream(task, run)
+		return run
+	}
+
+	task.Attempts = 0
+	task.RetryUpstream = nil
+
 	// For one-time tasks, mark as deleted
 	if task.ScheduleType == ScheduleTypeOnce {
 		if err := s.store.Delete(task.ID); err != nil {
@@ -164,17 +318,98 @@ func (s *Scheduler) executeTask(task *Task) {
 			xlog.Error("Failed to calculate next run", "task_id", task.ID, "error", err)
 			task.Status = TaskStatusPaused
 		}
+
+		if err := s.store.Update(task); err != nil {
+			xlog.Error("Failed to update task", "task_id", task.ID, "error", err)
+		}
 	}
 
-	if err := s.store.Update(task); err != nil {
-		xlog.Error("Failed to update task", "task_id", task.ID, "error", err)
+	// A cancelled run has no outcome for the downstream tasks to act on
+	if !cancelled {
+		s.triggerDownstream(task, run)
+	}
+	return run
+}
+
+// notifyDeadLetter calls the dead letter handler, if any
+func (s *Scheduler) notifyDeadLetter(task *Task, run *TaskRun) {
+	s.mu.RLock()
+	handler := s.deadLetterHandler
+	s.mu.RUnlock()
+
+	if handler != nil {
+		handler(task, run)
 	}
 }
 
+// triggerDownstream launches the active tasks whose trigger matches the given run
+func (s *Scheduler) triggerDownstream(task *Task, run *TaskRun) {
+	if s.ctx == nil || s.ctx.Err() != nil {
+		return
+	}
+
+	tasks, err := s.store.GetByAgent(task.AgentName)
+	if err != nil {
+		xlog.Error("Failed to get downstream tasks", "task_id", task.ID, "error", err)
+		return
+	}
+
+	for _, downstream := range tasks {
+		if downstream.Status != TaskStatusActive || downstream.Trigger == nil ||
+			downstream.Trigger.TaskID != task.ID || !downstream.Trigger.Matches(run) {
+			continue
+		}
+		xlog.Info("Triggering downstream task", "task_id", downstream.ID, "upstream", task.ID, "on", downstream.Trigger.On)
+		s.launch(downstream, run, 1)
+	}
+}
+
+// validateTrigger checks that the upstream task of a triggered task exists,
+// belongs to the same agent and that following the chain upstream never
+// leads back to the task itself
+func (s *Scheduler) validateTrigger(task *Task) error {
+	if task.Trigger == nil {
+		return nil
+	}
+	if err := task.Trigger.Validate(); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{task.ID: true}
+	upstreamID := task.Trigger.TaskID
+	for upstreamID != "" {
+		if seen[upstreamID] {
+			return fmt.Errorf("%w: %s", ErrTaskCycle, upstreamID)
+		}
+		seen[upstreamID] = true
+
+		upstream, err := s.store.Get(upstreamID)
+		if err != nil {
+			return fmt.Errorf("upstream task: %w", err)
+		}
+		if upstream.AgentName != task.AgentName {
+			return fmt.Errorf("upstream task %s belongs to another agent", upstreamID)
+		}
+		if upstream.Trigger == nil {
+			return nil
+		}
+		upstreamID = upstream.Trigger.TaskID
+	}
+	return nil
+}
+
 // CRUD operations
 
 // CreateTask adds a new task
 func (s *Scheduler) CreateTask(task *Task) error {
+	if err := s.validateTrigger(task); err != nil {
+		return err
+	}
+	if task.Retry != nil {
+		if err := task.Retry.Validate(); err != nil {
+			return err
+		}
+	}
 	return s.store.Create(task)
 }
 
@@ -195,6 +430,14 @@ func (s *Scheduler) GetTasksByAgent(agentName string) ([]*Task, error) {
 
 // UpdateTask updates an existing task
 func (s *Scheduler) UpdateTask(task *Task) error {
+	if err := s.validateTrigger(task); err != nil {
+		return err
+	}
+	if task.Retry != nil {
+		if err := task.Retry.Validate(); err != nil {
+			return err
+		}
+	}
 	return s.store.Update(task)
 }
 
@@ -203,6 +446,60 @@ func (s *Scheduler) DeleteTask(id string) error {
 	return s.store.Delete(id)
 }
 
+// TaskChain is a task together with the tasks it triggers
+type TaskChain struct {
+	Task       *Task        `json:"task"`
+	Downstream []*TaskChain `json:"downstream,omitempty"`
+}
+
+// GetTaskChain returns the whole chain a task belongs to, starting from the
+// task at the root of the chain
+func (s *Scheduler) GetTaskChain(id string) (*TaskChain, error) {
+	task, err := s.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.store.GetByAgent(task.AgentName)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*Task, len(tasks))
+	children := make(map[string][]*Task)
+	for _, t := range tasks {
+		byID[t.ID] = t
+		if t.Trigger != nil {
+			children[t.Trigger.TaskID] = append(children[t.Trigger.TaskID], t)
+		}
+	}
+
+	root := task
+	seen := map[string]bool{root.ID: true}
+	for root.Trigger != nil {
+		parent, ok := byID[root.Trigger.TaskID]
+		if !ok || seen[parent.ID] {
+			break
+		}
+		seen[parent.ID] = true
+		root = parent
+	}
+
+	var build func(t *Task, visited map[string]bool) *TaskChain
+	build = func(t *Task, visited map[string]bool) *TaskChain {
+		visited[t.ID] = true
+		chain := &TaskChain{Task: t}
+		for _, child := range children[t.ID] {
+			if visited[child.ID] {
+				continue
+			}
+			chain.Downstream = append(chain.Downstream, build(child, visited))
+		}
+		return chain
+	}
+
+	return build(root, map[string]bool{}), nil
+}
+
 // GetTaskRuns retrieves execution history for a task
 func (s *Scheduler) GetTaskRuns(taskID string, limit int) ([]*TaskRun, error) {
 	return s.store.GetRuns(taskID, limit)
@@ -219,7 +516,7 @@ func (s *Scheduler) PauseTask(id string) error {
 	return s.store.Update(task)
 }
 
-// ResumeTask resumes a paused task
+// ResumeTask resumes a paused or dead lettered task
 func (s *Scheduler) ResumeTask(id string) error {
 	task, err := s.store.Get(id)
 	if err != nil {
@@ -227,6 +524,8 @@ func (s *Scheduler) ResumeTask(id string) error {
 	}
 
 	task.Status = TaskStatusActive
+	task.Attempts = 0
+	task.RetryUpstream = nil
 	if err := task.CalculateNextRun(); err != nil {
 		return err
 	}
@@ -234,6 +533,33 @@ func (s *Scheduler) ResumeTask(id string) error {
 	return s.store.Update(task)
 }
 
+// RunTaskNow starts a task immediately, outside of its schedule
+func (s *Scheduler) RunTaskNow(id string) error {
+	if s.ctx == nil {
+		return fmt.Errorf("scheduler not started")
+	}
+
+	task, err := s.store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if s.IsTaskRunning(id) {
+		return fmt.Errorf("%w: %s", ErrTaskRunning, id)
+	}
+
+	s.launch(task, nil, 1)
+	return nil
+}
+
+// IsTaskRunning reports whether a task is currently being executed
+func (s *Scheduler) IsTaskRunning(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, running := s.runningTasks[id]
+	return running
+}
+
 // CancelRunningTask cancels a currently running task
 func (s *Scheduler) CancelRunningTask(id string) error {
 	s.mu.Lock()
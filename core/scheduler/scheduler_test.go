@@ -15,10 +15,19 @@ import (
 type MockExecutor struct {
 	executedTasks []string
 	shouldError   bool
+	// block, when set, keeps executions running until it is closed
+	block chan struct{}
 }
 
 func (m *MockExecutor) Execute(ctx context.Context, agentName string, prompt string) (*scheduler.JobResult, error) {
 	m.executedTasks = append(m.executedTasks, agentName+":"+prompt)
+	if m.block != nil {
+		select {
+		case <-m.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 	if m.shouldError {
 		return nil, errors.New("mock execution error")
 	}
@@ -67,6 +76,29 @@ var _ = Describe("Scheduler", func() {
 			Expect(task.NextRun).NotTo(BeZero())
 		})
 
+		It("should evaluate cron expressions in the task timezone", func() {
+			task, err := scheduler.NewTask("test-agent", "standup", scheduler.ScheduleTypeCron, "0 9 * * 1-5")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task.SetTimezone("America/New_York")).To(Succeed())
+			Expect(task.NextRun.Location().String()).To(Equal("America/New_York"))
+			Expect(task.NextRun.Hour()).To(Equal(9))
+			Expect(task.NextRun.Weekday()).NotTo(BeElementOf(time.Saturday, time.Sunday))
+
+			Expect(task.SetTimezone("Not/AZone")).To(HaveOccurred())
+		})
+
+		It("should support CRON_TZ prefixes and seconds", func() {
+			task, err := scheduler.NewTask("test-agent", "tz", scheduler.ScheduleTypeCron, "CRON_TZ=Asia/Tokyo 0 30 8 * * *")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task.NextRun.Location().String()).To(Equal("Asia/Tokyo"))
+			Expect(task.NextRun.Hour()).To(Equal(8))
+			Expect(task.NextRun.Minute()).To(Equal(30))
+
+			task, err = scheduler.NewTask("test-agent", "seconds", scheduler.ScheduleTypeCron, "*/10 * * * * *")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task.NextRun).To(BeTemporally("~", time.Now(), 10*time.Second))
+		})
+
 		It("should return error for invalid cron expression", func() {
 			_, err := scheduler.NewTask("test-agent", "test prompt", scheduler.ScheduleTypeCron, "invalid cron")
 			Expect(err).To(HaveOccurred())
@@ -381,6 +413,19 @@ var _ = Describe("Scheduler", func() {
 			Expect(agent1Tasks).To(HaveLen(2))
 		})
 
+		It("should run a task on demand", func() {
+			task, _ := scheduler.NewTask("test-agent", "on demand", scheduler.ScheduleTypeCron, "0 0 * * *")
+			Expect(sched.CreateTask(task)).To(Succeed())
+
+			Expect(sched.RunTaskNow(task.ID)).To(Succeed())
+			Eventually(func() int {
+				return len(executor.executedTasks)
+			}, "2s", "100ms").Should(Equal(1))
+			Expect(executor.executedTasks[0]).To(Equal("test-agent:on demand"))
+
+			Expect(sched.RunTaskNow("does-not-exist")).To(HaveOccurred())
+		})
+
 		It("should delete a task", func() {
 			task, _ := scheduler.NewTask("test-agent", "test", scheduler.ScheduleTypeCron, "0 0 * * *")
 			sched.CreateTask(task)
@@ -392,4 +437,335 @@ var _ = Describe("Scheduler", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Describe("Running Tasks", func() {
+		var task *scheduler.Task
+
+		BeforeEach(func() {
+			executor.block = make(chan struct{})
+			var err error
+			task, err = scheduler.NewTask("test-agent", "long", scheduler.ScheduleTypeInterval, "3600000")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sched.CreateTask(task)).To(Succeed())
+
+			Expect(sched.RunTaskNow(task.ID)).To(Succeed())
+			Eventually(func() bool {
+				return sched.IsTaskRunning(task.ID)
+			}, "1s", "10ms").Should(BeTrue())
+		})
+
+		runs := func() []*scheduler.TaskRun {
+			runs, _ := sched.GetTaskRuns(task.ID, 10)
+			return runs
+		}
+
+		It("should keep changes made to the task while it runs", func() {
+			Expect(sched.PauseTask(task.ID)).To(Succeed())
+			edited, err := sched.GetTask(task.ID)
+			Expect(err).NotTo(HaveOccurred())
+			edited.Prompt = "edited"
+			Expect(sched.UpdateTask(edited)).To(Succeed())
+
+			close(executor.block)
+			Eventually(runs, "1s", "10ms").Should(HaveLen(1))
+			Eventually(func() bool {
+				return sched.IsTaskRunning(task.ID)
+			}, "1s", "10ms").Should(BeFalse())
+
+			retrieved, err := sched.GetTask(task.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(retrieved.Status).To(Equal(scheduler.TaskStatusPaused))
+			Expect(retrieved.Prompt).To(Equal("edited"))
+			Expect(retrieved.LastRun).NotTo(BeNil())
+		})
+
+		It("should not recreate a task deleted while it runs", func() {
+			Expect(sched.DeleteTask(task.ID)).To(Succeed())
+
+			close(executor.block)
+			Eventually(func() bool {
+				return sched.IsTaskRunning(task.ID)
+			}, "1s", "10ms").Should(BeFalse())
+
+			_, err := sched.GetTask(task.ID)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should record a cancelled run without retrying it or triggering downstream tasks", func() {
+			var deadLetters int
+			sched.OnDeadLetter(func(*scheduler.Task, *scheduler.TaskRun) { deadLetters++ })
+
+			retrying, err := sched.GetTask(task.ID)
+			Expect(err).NotTo(HaveOccurred())
+			retrying.Retry = &scheduler.RetryPolicy{MaxRetries: 2, BackoffMs: 10}
+			Expect(sched.UpdateTask(retrying)).To(Succeed())
+			onFailure, err := scheduler.NewTriggeredTask("test-agent", "alert", scheduler.TaskTrigger{TaskID: task.ID, On: scheduler.TriggerOnComplete})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sched.CreateTask(onFailure)).To(Succeed())
+
+			Expect(sched.CancelRunningTask(task.ID)).To(Succeed())
+			Eventually(runs, "1s", "10ms").Should(HaveLen(1))
+			Expect(runs()[0].Status).To(Equal("cancelled"))
+
+			Consistently(func() int {
+				return len(executor.executedTasks)
+			}, "500ms", "100ms").Should(Equal(1))
+			Expect(deadLetters).To(BeZero())
+
+			retrieved, err := sched.GetTask(task.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(retrieved.Status).To(Equal(scheduler.TaskStatusActive))
+			Expect(retrieved.Attempts).To(BeZero())
+			Expect(retrieved.NextRun).To(BeTemporally(">", time.Now()))
+		})
+	})
+
+	Describe("Task Triggers", func() {
+		newUpstream := func() *scheduler.Task {
+			task, err := scheduler.NewTask("test-agent", "upstream", scheduler.ScheduleTypeInterval, "3600000")
+			Expect(err).NotTo(HaveOccurred())
+			return task
+		}
+
+		It("should run matching downstream tasks with the upstream output", func() {
+			upstream := newUpstream()
+			Expect(sched.CreateTask(upstream)).To(Succeed())
+
+			onSuccess, err := scheduler.NewTriggeredTask("test-agent", "summarize", scheduler.TaskTrigger{TaskID: upstream.ID, On: scheduler.TriggerOnSuccess})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sched.CreateTask(onSuccess)).To(Succeed())
+			onFailure, err := scheduler.NewTriggeredTask("test-agent", "alert", scheduler.TaskTrigger{TaskID: upstream.ID, On: scheduler.TriggerOnFailure})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sched.CreateTask(onFailure)).To(Succeed())
+			noMatch, err := scheduler.NewTriggeredTask("test-agent", "never", scheduler.TaskTrigger{TaskID: upstream.ID, On: scheduler.TriggerOnComplete, Match: "^nothing$"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sched.CreateTask(noMatch)).To(Succeed())
+
+			upstream.NextRun = time.Now().Add(-1 * time.Second)
+			Expect(sched.UpdateTask(upstream)).To(Succeed())
+
+			Eventually(func() int {
+				return len(executor.executedTasks)
+			}, "2s", "100ms").Should(Equal(2))
+			Consistently(func() int {
+				return len(executor.executedTasks)
+			}, "300ms", "100ms").Should(Equal(2))
+
+			Expect(executor.executedTasks[0]).To(Equal("test-agent:upstream"))
+			Expect(executor.executedTasks[1]).To(HavePrefix("test-agent:summarize"))
+			Expect(executor.executedTasks[1]).To(ContainSubstring("test response"))
+
+			upstreamRuns, err := sched.GetTaskRuns(upstream.ID, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(upstreamRuns).To(HaveLen(1))
+			Eventually(func() int {
+				runs, _ := sched.GetTaskRuns(onSuccess.ID, 10)
+				return len(runs)
+			}, "1s", "100ms").Should(Equal(1))
+			runs, _ := sched.GetTaskRuns(onSuccess.ID, 10)
+			Expect(runs[0].TriggeredBy).To(Equal(upstreamRuns[0].ID))
+		})
+
+		It("should render prompt templates with the upstream run", func() {
+			task, err := scheduler.NewTriggeredTask("test-agent", "Send this: {{.Result}}", scheduler.TaskTrigger{TaskID: "upstream", On: scheduler.TriggerOnComplete})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task.IsDue()).To(BeFalse())
+
+			prompt, err := task.RenderPrompt(&scheduler.TaskRun{Status: "success", Result: "all good"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prompt).To(Equal("Send this: all good"))
+		})
+
+		It("should reject triggers that create a cycle", func() {
+			first := newUpstream()
+			Expect(sched.CreateTask(first)).To(Succeed())
+			second, _ := scheduler.NewTriggeredTask("test-agent", "second", scheduler.TaskTrigger{TaskID: first.ID, On: scheduler.TriggerOnSuccess})
+			Expect(sched.CreateTask(second)).To(Succeed())
+			third, _ := scheduler.NewTriggeredTask("test-agent", "third", scheduler.TaskTrigger{TaskID: second.ID, On: scheduler.TriggerOnSuccess})
+			Expect(sched.CreateTask(third)).To(Succeed())
+
+			second.Trigger.TaskID = third.ID
+			Expect(sched.UpdateTask(second)).To(MatchError(scheduler.ErrTaskCycle))
+
+			missing, _ := scheduler.NewTriggeredTask("test-agent", "missing", scheduler.TaskTrigger{TaskID: "does-not-exist", On: scheduler.TriggerOnSuccess})
+			Expect(sched.CreateTask(missing)).To(HaveOccurred())
+
+			_, err := scheduler.NewTriggeredTask("test-agent", "invalid", scheduler.TaskTrigger{TaskID: first.ID, On: "sometimes"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return the chain a task belongs to", func() {
+			root := newUpstream()
+			Expect(sched.CreateTask(root)).To(Succeed())
+			child, _ := scheduler.NewTriggeredTask("test-agent", "child", scheduler.TaskTrigger{TaskID: root.ID, On: scheduler.TriggerOnSuccess})
+			Expect(sched.CreateTask(child)).To(Succeed())
+			grandchild, _ := scheduler.NewTriggeredTask("test-agent", "grandchild", scheduler.TaskTrigger{TaskID: child.ID, On: scheduler.TriggerOnFailure})
+			Expect(sched.CreateTask(grandchild)).To(Succeed())
+
+			chain, err := sched.GetTaskChain(grandchild.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chain.Task.ID).To(Equal(root.ID))
+			Expect(chain.Downstream).To(HaveLen(1))
+			Expect(chain.Downstream[0].Task.ID).To(Equal(child.ID))
+			Expect(chain.Downstream[0].Downstream).To(HaveLen(1))
+			Expect(chain.Downstream[0].Downstream[0].Task.ID).To(Equal(grandchild.ID))
+		})
+	})
+
+	Describe("Retries", func() {
+		It("should grow the backoff exponentially up to the maximum", func() {
+			policy := &scheduler.RetryPolicy{MaxRetries: 5, BackoffMs: 1000, MaxBackoffMs: 5000}
+			Expect(policy.Delay(1)).To(Equal(time.Second))
+			Expect(policy.Delay(2)).To(Equal(2 * time.Second))
+			Expect(policy.Delay(3)).To(Equal(4 * time.Second))
+			Expect(policy.Delay(4)).To(Equal(5 * time.Second))
+
+			policy.Jitter = 0.5
+			Expect(policy.Delay(1)).To(BeNumerically("~", time.Second, 500*time.Millisecond))
+		})
+
+		It("should refuse multipliers that would shrink the backoff", func() {
+			task, _ := scheduler.NewTask("test-agent", "shrinking", scheduler.ScheduleTypeInterval, "60000")
+			task.Retry = &scheduler.RetryPolicy{MaxRetries: 2, Multiplier: 0.5}
+			Expect(sched.CreateTask(task)).To(MatchError(ContainSubstring("multiplier")))
+
+			task.Retry.Multiplier = -2
+			Expect(sched.CreateTask(task)).To(MatchError(ContainSubstring("multiplier")))
+
+			task.Retry.Multiplier = 1
+			Expect(sched.CreateTask(task)).To(Succeed())
+			Expect(task.Retry.Delay(3)).To(Equal(30 * time.Second))
+
+			task.Retry.Multiplier = 0.5
+			Expect(sched.UpdateTask(task)).To(MatchError(ContainSubstring("multiplier")))
+		})
+
+		It("should retry a failing task and move it to dead letter", func() {
+			var deadLetters []*scheduler.TaskRun
+			sched.OnDeadLetter(func(task *scheduler.Task, run *scheduler.TaskRun) {
+				deadLetters = append(deadLetters, run)
+			})
+			executor.shouldError = true
+
+			task, _ := scheduler.NewTask("test-agent", "flaky", scheduler.ScheduleTypeOnce, "0s")
+			task.Retry = &scheduler.RetryPolicy{MaxRetries: 2, BackoffMs: 10}
+			task.NextRun = time.Now().Add(-1 * time.Second)
+			Expect(sched.CreateTask(task)).To(Succeed())
+
+			Eventually(func() int {
+				return len(deadLetters)
+			}, "3s", "100ms").Should(Equal(1))
+			Expect(executor.executedTasks).To(HaveLen(3))
+			Expect(deadLetters[0].Attempt).To(Equal(3))
+
+			retrieved, err := sched.GetTask(task.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(retrieved.Status).To(Equal(scheduler.TaskStatusDeadLetter))
+			Expect(retrieved.Attempts).To(BeZero())
+
+			runs, err := sched.GetTaskRuns(task.ID, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runs).To(HaveLen(3))
+
+			Expect(sched.ResumeTask(task.ID)).To(Succeed())
+			retrieved, _ = sched.GetTask(task.ID)
+			Expect(retrieved.Status).To(Equal(scheduler.TaskStatusActive))
+		})
+	})
+
+	Describe("Misfires", func() {
+		missedRuns := func(taskID string) int {
+			runs, err := sched.GetTaskRuns(taskID, 100)
+			Expect(err).NotTo(HaveOccurred())
+			missed := 0
+			for _, run := range runs {
+				if run.Status == "missed" {
+					missed++
+				}
+			}
+			return missed
+		}
+
+		It("should record missed occurrences without running them when skipping", func() {
+			task, _ := scheduler.NewTask("test-agent", "skipped", scheduler.ScheduleTypeInterval, "60000")
+			task.NextRun = time.Now().Add(-10*time.Minute - 30*time.Second)
+			task.Misfire = &scheduler.MisfirePolicy{Action: scheduler.MisfireSkip}
+			Expect(sched.CreateTask(task)).To(Succeed())
+
+			Eventually(func() int {
+				return missedRuns(task.ID)
+			}, "2s", "100ms").Should(Equal(11))
+			Consistently(func() int {
+				return len(executor.executedTasks)
+			}, "300ms", "100ms").Should(BeZero())
+
+			retrieved, err := sched.GetTask(task.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(retrieved.NextRun).To(BeTemporally(">", time.Now()))
+		})
+
+		It("should catch up with the most recent missed occurrences", func() {
+			task, _ := scheduler.NewTask("test-agent", "catch up", scheduler.ScheduleTypeInterval, "60000")
+			task.NextRun = time.Now().Add(-4*time.Minute - 30*time.Second)
+			task.Misfire = &scheduler.MisfirePolicy{Action: scheduler.MisfireRunAll, MaxCatchUp: 3}
+			Expect(sched.CreateTask(task)).To(Succeed())
+
+			Eventually(func() int {
+				return len(executor.executedTasks)
+			}, "2s", "100ms").Should(Equal(3))
+			Expect(missedRuns(task.ID)).To(Equal(2))
+		})
+
+		It("should catch up with cron occurrences across a daylight saving change", func() {
+			newYork, err := time.LoadLocation("America/New_York")
+			Expect(err).NotTo(HaveOccurred())
+
+			// Start the catch-up at 09:00 on a day whose UTC offset differs
+			// from today's, as stored with a fixed offset
+			now := time.Now().In(newYork)
+			_, offset := now.Zone()
+			start := time.Date(now.Year(), now.Month(), now.Day()-30, 9, 0, 0, 0, newYork)
+			for _, o := start.Zone(); o == offset; _, o = start.Zone() {
+				start = start.AddDate(0, 0, -1)
+			}
+			name, startOffset := start.Zone()
+
+			task, err := scheduler.NewTask("test-agent", "standup", scheduler.ScheduleTypeCron, "0 9 * * *")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(task.SetTimezone("America/New_York")).To(Succeed())
+			task.NextRun = start.In(time.FixedZone(name, startOffset))
+			task.Misfire = &scheduler.MisfirePolicy{Action: scheduler.MisfireSkip}
+			Expect(sched.CreateTask(task)).To(Succeed())
+
+			var retrieved *scheduler.Task
+			Eventually(func() time.Time {
+				retrieved, err = sched.GetTask(task.ID)
+				Expect(err).NotTo(HaveOccurred())
+				return retrieved.NextRun
+			}, "2s", "100ms").Should(BeTemporally(">", time.Now()))
+			Expect(retrieved.NextRun.In(newYork).Hour()).To(Equal(9))
+
+			runs, err := sched.GetTaskRuns(task.ID, 100)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(runs)).To(BeNumerically(">=", 30))
+			for _, run := range runs {
+				Expect(run.ScheduledAt.In(newYork).Hour()).To(Equal(9), "missed occurrence %s", run.ScheduledAt)
+			}
+		})
+
+		It("should not run occurrences later than the max lateness", func() {
+			task, _ := scheduler.NewTask("test-agent", "morning briefing", scheduler.ScheduleTypeOnce, "0s")
+			task.NextRun = time.Now().Add(-2 * time.Hour)
+			task.Misfire = &scheduler.MisfirePolicy{MaxLatenessMs: time.Hour.Milliseconds()}
+			Expect(sched.CreateTask(task)).To(Succeed())
+
+			Eventually(func() int {
+				return missedRuns(task.ID)
+			}, "2s", "100ms").Should(Equal(1))
+			Expect(executor.executedTasks).To(BeEmpty())
+
+			_, err := sched.GetTask(task.ID)
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
@@ -0,0 +1,318 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultMaxRunsPerTask is how many runs are kept per task when no limit is given
+const DefaultMaxRunsPerTask = 100
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS tasks (
+	id         TEXT PRIMARY KEY,
+	agent_name TEXT NOT NULL,
+	status     TEXT NOT NULL,
+	next_run   INTEGER NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_tasks_due ON tasks (status, next_run);
+CREATE INDEX IF NOT EXISTS idx_tasks_agent ON tasks (agent_name);
+
+CREATE TABLE IF NOT EXISTS task_runs (
+	id      TEXT PRIMARY KEY,
+	task_id TEXT NOT NULL,
+	run_at  INTEGER NOT NULL,
+	data    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_task_runs_task ON task_runs (task_id, run_at);
+`
+
+// SQLiteStore implements TaskStore on top of a SQLite database.
+// Tasks and runs are stored as JSON documents next to the indexed columns
+// needed to query them, and only the most recent runs of each task are kept.
+type SQLiteStore struct {
+	db             *sql.DB
+	maxRunsPerTask int
+}
+
+// NewSQLiteStore opens (or creates) a SQLite task store at the given path.
+// maxRunsPerTask bounds the run history kept per task, DefaultMaxRunsPerTask is used if <= 0.
+func NewSQLiteStore(filePath string, maxRunsPerTask int) (*SQLiteStore, error) {
+	if maxRunsPerTask <= 0 {
+		maxRunsPerTask = DefaultMaxRunsPerTask
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", filePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	// SQLite allows a single writer, serialize access instead of hitting SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &SQLiteStore{db: db, maxRunsPerTask: maxRunsPerTask}, nil
+}
+
+// Create adds a new task
+func (s *SQLiteStore) Create(task *Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	_, err = s.db.Exec(
+		`INSERT INTO tasks (id, agent_name, status, next_run, data) VALUES (?, ?, ?, ?, ?)`,
+		task.ID, task.AgentName, string(task.Status), dueAt(task), string(data),
+	)
+	if err != nil {
+		if _, getErr := s.Get(task.ID); getErr == nil {
+			return fmt.Errorf("task with ID %s already exists", task.ID)
+		}
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a task by ID
+func (s *SQLiteStore) Get(id string) (*Task, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM tasks WHERE id = ?`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("task not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	task := &Task{}
+	if err := json.Unmarshal([]byte(data), task); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+	}
+	return task, nil
+}
+
+// GetAll retrieves all tasks
+func (s *SQLiteStore) GetAll() ([]*Task, error) {
+	return s.queryTasks(`SELECT data FROM tasks ORDER BY rowid`)
+}
+
+// GetDue retrieves tasks that are due for execution
+func (s *SQLiteStore) GetDue() ([]*Task, error) {
+	return s.queryTasks(
+		`SELECT data FROM tasks WHERE status = ? AND next_run < ? ORDER BY next_run`,
+		string(TaskStatusActive), time.Now().UnixNano(),
+	)
+}
+
+// GetByAgent retrieves all tasks for a specific agent
+func (s *SQLiteStore) GetByAgent(agentName string) ([]*Task, error) {
+	return s.queryTasks(`SELECT data FROM tasks WHERE agent_name = ? ORDER BY rowid`, agentName)
+}
+
+// Update updates an existing task
+func (s *SQLiteStore) Update(task *Task) error {
+	task.UpdatedAt = time.Now()
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	res, err := s.db.Exec(
+		`UPDATE tasks SET agent_name = ?, status = ?, next_run = ?, data = ? WHERE id = ?`,
+		task.AgentName, string(task.Status), dueAt(task), string(data), task.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("task not found: %s", task.ID)
+	}
+	return nil
+}
+
+// Delete removes a task
+func (s *SQLiteStore) Delete(id string) error {
+	res, err := s.db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	return nil
+}
+
+// LogRun records a task execution and prunes the oldest runs of the task
+// beyond the configured history size
+func (s *SQLiteStore) LogRun(run *TaskRun) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to log run: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`INSERT INTO task_runs (id, task_id, run_at, data) VALUES (?, ?, ?, ?)`,
+		run.ID, run.TaskID, run.RunAt.UnixNano(), string(data),
+	); err != nil {
+		return fmt.Errorf("failed to log run: %w", err)
+	}
+
+	if _, err := tx.Exec(
+		`DELETE FROM task_runs WHERE task_id = ? AND id NOT IN (
+			SELECT id FROM task_runs WHERE task_id = ? ORDER BY run_at DESC LIMIT ?
+		)`,
+		run.TaskID, run.TaskID, s.maxRunsPerTask,
+	); err != nil {
+		return fmt.Errorf("failed to prune runs: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetRuns retrieves execution history for a task, most recent first
+func (s *SQLiteStore) GetRuns(taskID string, limit int) ([]*TaskRun, error) {
+	rows, err := s.db.Query(
+		`SELECT data FROM task_runs WHERE task_id = ? ORDER BY run_at DESC LIMIT ?`,
+		taskID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]*TaskRun, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read run: %w", err)
+		}
+		run := &TaskRun{}
+		if err := json.Unmarshal([]byte(data), run); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Close releases resources
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// ImportJSON migrates the tasks and runs of a JSONStore file into the
+// database. Tasks already present are left untouched, and the JSON file is
+// renamed with a ".migrated" suffix so the import only happens once.
+func (s *SQLiteStore) ImportJSON(jsonPath string) error {
+	if _, err := os.Stat(jsonPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	legacy, err := NewJSONStore(jsonPath)
+	if err != nil {
+		return fmt.Errorf("failed to read JSON store: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to import JSON store: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, task := range legacy.data.Tasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return fmt.Errorf("failed to marshal task: %w", err)
+		}
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO tasks (id, agent_name, status, next_run, data) VALUES (?, ?, ?, ?, ?)`,
+			task.ID, task.AgentName, string(task.Status), dueAt(task), string(data),
+		); err != nil {
+			return fmt.Errorf("failed to import task %s: %w", task.ID, err)
+		}
+	}
+
+	// Only the most recent runs of each task are worth keeping
+	runsPerTask := map[string]int{}
+	for i := len(legacy.data.TaskRuns) - 1; i >= 0; i-- {
+		run := legacy.data.TaskRuns[i]
+		if runsPerTask[run.TaskID] >= s.maxRunsPerTask {
+			continue
+		}
+		runsPerTask[run.TaskID]++
+
+		data, err := json.Marshal(run)
+		if err != nil {
+			return fmt.Errorf("failed to marshal run: %w", err)
+		}
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO task_runs (id, task_id, run_at, data) VALUES (?, ?, ?, ?)`,
+			run.ID, run.TaskID, run.RunAt.UnixNano(), string(data),
+		); err != nil {
+			return fmt.Errorf("failed to import run %s: %w", run.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to import JSON store: %w", err)
+	}
+
+	return os.Rename(jsonPath, jsonPath+".migrated")
+}
+
+// dueAt is the value indexed to find due tasks, triggered tasks are never due
+// by time unless they are waiting for a retry
+func dueAt(task *Task) int64 {
+	if !task.timeScheduled() {
+		return math.MaxInt64
+	}
+	return task.NextRun.UnixNano()
+}
+
+func (s *SQLiteStore) queryTasks(query string, args ...any) ([]*Task, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := make([]*Task, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read task: %w", err)
+		}
+		task := &Task{}
+		if err := json.Unmarshal([]byte(data), task); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
@@ -0,0 +1,114 @@
+package scheduler_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/scheduler"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQLite Store", func() {
+	var (
+		dir   string
+		store *scheduler.SQLiteStore
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir = GinkgoT().TempDir()
+		store, err = scheduler.NewSQLiteStore(filepath.Join(dir, "scheduler.db"), 3)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		store.Close()
+	})
+
+	It("should create, update and delete tasks", func() {
+		task, err := scheduler.NewTask("test-agent", "test prompt", scheduler.ScheduleTypeCron, "0 0 * * *")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Create(task)).To(Succeed())
+		Expect(store.Create(task)).To(HaveOccurred())
+
+		task.Prompt = "updated prompt"
+		Expect(store.Update(task)).To(Succeed())
+
+		retrieved, err := store.Get(task.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(retrieved.Prompt).To(Equal("updated prompt"))
+		Expect(retrieved.NextRun).To(BeTemporally("==", task.NextRun))
+
+		Expect(store.Delete(task.ID)).To(Succeed())
+		_, err = store.Get(task.ID)
+		Expect(err).To(HaveOccurred())
+		Expect(store.Delete(task.ID)).To(HaveOccurred())
+	})
+
+	It("should only return active tasks past their next run as due", func() {
+		due, _ := scheduler.NewTask("agent1", "due", scheduler.ScheduleTypeInterval, "60000")
+		due.NextRun = time.Now().Add(-time.Minute)
+		future, _ := scheduler.NewTask("agent1", "future", scheduler.ScheduleTypeInterval, "60000")
+		paused, _ := scheduler.NewTask("agent2", "paused", scheduler.ScheduleTypeInterval, "60000")
+		paused.NextRun = time.Now().Add(-time.Minute)
+		paused.Status = scheduler.TaskStatusPaused
+		for _, t := range []*scheduler.Task{due, future, paused} {
+			Expect(store.Create(t)).To(Succeed())
+		}
+
+		dueTasks, err := store.GetDue()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dueTasks).To(HaveLen(1))
+		Expect(dueTasks[0].ID).To(Equal(due.ID))
+
+		agentTasks, err := store.GetByAgent("agent1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(agentTasks).To(HaveLen(2))
+	})
+
+	It("should keep only the most recent runs of a task", func() {
+		task, _ := scheduler.NewTask("test-agent", "test prompt", scheduler.ScheduleTypeCron, "0 0 * * *")
+		Expect(store.Create(task)).To(Succeed())
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			run := scheduler.NewTaskRun(task.ID)
+			run.RunAt = start.Add(time.Duration(i) * time.Second)
+			run.Result = string(rune('a' + i))
+			Expect(store.LogRun(run)).To(Succeed())
+		}
+
+		runs, err := store.GetRuns(task.ID, 10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runs).To(HaveLen(3))
+		Expect(runs[0].Result).To(Equal("e"))
+		Expect(runs[2].Result).To(Equal("c"))
+	})
+
+	It("should migrate an existing JSON store when opened through a URI", func() {
+		jsonPath := filepath.Join(dir, "tasks.json")
+		jsonStore, err := scheduler.NewJSONStore(jsonPath)
+		Expect(err).NotTo(HaveOccurred())
+		task, _ := scheduler.NewTask("test-agent", "legacy prompt", scheduler.ScheduleTypeCron, "0 0 * * *")
+		Expect(jsonStore.Create(task)).To(Succeed())
+		Expect(jsonStore.LogRun(scheduler.NewTaskRun(task.ID))).To(Succeed())
+
+		migrated, err := scheduler.OpenStore("sqlite://" + filepath.Join(dir, "tasks.db"))
+		Expect(err).NotTo(HaveOccurred())
+		defer migrated.Close()
+
+		retrieved, err := migrated.Get(task.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(retrieved.Prompt).To(Equal("legacy prompt"))
+		runs, err := migrated.GetRuns(task.ID, 10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runs).To(HaveLen(1))
+
+		_, err = os.Stat(jsonPath)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = os.Stat(jsonPath + ".migrated")
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
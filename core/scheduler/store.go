@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	sqliteScheme = "sqlite://"
+	jsonScheme   = "json://"
+)
+
+// OpenStore opens the task store described by uri:
+//
+//	sqlite:///path/to/tasks.db?max_runs=100  SQLite store, keeping at most max_runs runs per task
+//	json:///path/to/tasks.json               JSON file store
+//	/path/to/tasks.json                      JSON file store (no scheme)
+//
+// When a SQLite store is opened and a JSON store with the same name but a
+// ".json" extension exists next to it, its tasks are migrated into the database.
+func OpenStore(uri string) (TaskStore, error) {
+	switch {
+	case strings.HasPrefix(uri, sqliteScheme):
+		path, rawQuery, _ := strings.Cut(strings.TrimPrefix(uri, sqliteScheme), "?")
+		query, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid store URI %q: %w", uri, err)
+		}
+
+		maxRuns := 0
+		if v := query.Get("max_runs"); v != "" {
+			maxRuns, err = strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max_runs in store URI %q: %w", uri, err)
+			}
+		}
+
+		store, err := NewSQLiteStore(path, maxRuns)
+		if err != nil {
+			return nil, err
+		}
+
+		legacyPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+		if legacyPath != path {
+			if err := store.ImportJSON(legacyPath); err != nil {
+				store.Close()
+				return nil, fmt.Errorf("failed to migrate %s: %w", legacyPath, err)
+			}
+		}
+		return store, nil
+	case strings.HasPrefix(uri, jsonScheme):
+		return NewJSONStore(strings.TrimPrefix(uri, jsonScheme))
+	default:
+		return NewJSONStore(uri)
+	}
+}
@@ -1,9 +1,12 @@
 package scheduler
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
@@ -33,11 +36,17 @@ func ParseDuration(s string) (time.Duration, error) {
 	return time.ParseDuration(s)
 }
 
+// cronParser accepts standard 5-field expressions, an optional leading seconds
+// field, descriptors such as @daily and a CRON_TZ= (or TZ=) timezone prefix
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
 type TaskStatus string
 
 const (
 	TaskStatusActive TaskStatus = "active"
 	TaskStatusPaused TaskStatus = "paused"
+	// TaskStatusDeadLetter is set on tasks that kept failing after all their retries
+	TaskStatusDeadLetter TaskStatus = "dead_letter"
 )
 
 type ScheduleType string
@@ -46,8 +55,73 @@ const (
 	ScheduleTypeCron     ScheduleType = "cron"
 	ScheduleTypeInterval ScheduleType = "interval"
 	ScheduleTypeOnce     ScheduleType = "once"
+	// ScheduleTypeTrigger tasks have no schedule, they run after their upstream task
+	ScheduleTypeTrigger ScheduleType = "trigger"
+)
+
+// TriggerCondition defines which outcomes of the upstream task start a triggered task
+type TriggerCondition string
+
+const (
+	TriggerOnSuccess  TriggerCondition = "on_success"
+	TriggerOnFailure  TriggerCondition = "on_failure"
+	TriggerOnComplete TriggerCondition = "on_complete"
 )
 
+// TaskTrigger links a task to the upstream task whose runs start it
+type TaskTrigger struct {
+	TaskID string           `json:"task_id"`
+	On     TriggerCondition `json:"on"`
+	// Match is an optional regular expression the upstream run result
+	// (or error, for failed runs) must match for the task to start
+	Match string `json:"match,omitempty"`
+}
+
+// Validate checks the trigger condition and match expression
+func (t *TaskTrigger) Validate() error {
+	if t.TaskID == "" {
+		return fmt.Errorf("trigger requires an upstream task ID")
+	}
+	switch t.On {
+	case TriggerOnSuccess, TriggerOnFailure, TriggerOnComplete:
+	default:
+		return fmt.Errorf("unknown trigger condition: %s", t.On)
+	}
+	if t.Match != "" {
+		if _, err := regexp.Compile(t.Match); err != nil {
+			return fmt.Errorf("invalid trigger match expression: %w", err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether an upstream run satisfies the trigger
+func (t *TaskTrigger) Matches(run *TaskRun) bool {
+	success := run.Status == "success"
+	switch t.On {
+	case TriggerOnSuccess:
+		if !success {
+			return false
+		}
+	case TriggerOnFailure:
+		if success {
+			return false
+		}
+	}
+
+	if t.Match == "" {
+		return true
+	}
+	re, err := regexp.Compile(t.Match)
+	if err != nil {
+		return false
+	}
+	if success {
+		return re.MatchString(run.Result)
+	}
+	return re.MatchString(run.Error)
+}
+
 // Task represents a scheduled task
 type Task struct {
 	ID            string                 `json:"id"`
@@ -62,6 +136,15 @@ type Task struct {
 	UpdatedAt     time.Time              `json:"updated_at"`
 	ContextMode   string                 `json:"context_mode"`
 	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	Trigger       *TaskTrigger           `json:"trigger,omitempty"`
+	Retry         *RetryPolicy           `json:"retry,omitempty"`
+	// Timezone is the IANA zone cron expressions are evaluated in (server local time if empty)
+	Timezone string         `json:"timezone,omitempty"`
+	Misfire  *MisfirePolicy `json:"misfire,omitempty"`
+	// Attempts counts the failed runs of the current occurrence while retrying
+	Attempts int `json:"attempts,omitempty"`
+	// RetryUpstream is the upstream run a triggered task is retried with
+	RetryUpstream *TaskRun `json:"retry_upstream,omitempty"`
 }
 
 // TaskRun represents a single execution of a task
@@ -70,9 +153,15 @@ type TaskRun struct {
 	TaskID     string    `json:"task_id"`
 	RunAt      time.Time `json:"run_at"`
 	DurationMs int64     `json:"duration_ms"`
-	Status     string    `json:"status"` // "success", "error", "timeout"
+	Status     string    `json:"status"` // "success", "error", "timeout", "missed", "cancelled"
 	Result     string    `json:"result,omitempty"`
 	Error      string    `json:"error,omitempty"`
+	// TriggeredBy is the ID of the upstream run that started this one, if any
+	TriggeredBy string `json:"triggered_by,omitempty"`
+	// Attempt is 1 for the first run of an occurrence and increases with each retry
+	Attempt int `json:"attempt,omitempty"`
+	// ScheduledAt is when a missed occurrence was due
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
 }
 
 // NewTask creates a new task with the given parameters
@@ -97,18 +186,72 @@ func NewTask(agentName, prompt string, scheduleType ScheduleType, scheduleValue
 	return task, nil
 }
 
+// NewTriggeredTask creates a task that runs after the upstream task described by trigger
+func NewTriggeredTask(agentName, prompt string, trigger TaskTrigger) (*Task, error) {
+	if err := trigger.Validate(); err != nil {
+		return nil, err
+	}
+
+	task := &Task{
+		ID:            uuid.New().String(),
+		AgentName:     agentName,
+		Prompt:        prompt,
+		ScheduleType:  ScheduleTypeTrigger,
+		ScheduleValue: string(trigger.On),
+		Status:        TaskStatusActive,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		ContextMode:   "agent",
+		Metadata:      make(map[string]interface{}),
+		Trigger:       &trigger,
+	}
+
+	return task, nil
+}
+
+// SetTimezone sets the IANA timezone of the task (server local time if empty)
+// and recalculates its next run
+func (t *Task) SetTimezone(timezone string) error {
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+	t.Timezone = timezone
+	return t.CalculateNextRun()
+}
+
+// Location returns the timezone of the task, the server local time if none is set
+func (t *Task) Location() (*time.Location, error) {
+	if t.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", t.Timezone, err)
+	}
+	return loc, nil
+}
+
 // CalculateNextRun calculates the next run time based on schedule type
 func (t *Task) CalculateNextRun() error {
-	now := time.Now()
+	loc, err := t.Location()
+	if err != nil {
+		return err
+	}
+	now := time.Now().In(loc)
 
 	switch t.ScheduleType {
 	case ScheduleTypeCron:
-		parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-		schedule, err := parser.Parse(t.ScheduleValue)
+		schedule, err := cronParser.Parse(t.ScheduleValue)
 		if err != nil {
 			return fmt.Errorf("invalid cron expression: %w", err)
 		}
 		t.NextRun = schedule.Next(now)
+		// A CRON_TZ= prefix takes precedence over the task timezone
+		if spec, ok := schedule.(*cron.SpecSchedule); ok && spec.Location != time.Local {
+			t.NextRun = t.NextRun.In(spec.Location)
+		}
 
 	case ScheduleTypeInterval:
 		intervalMs, err := strconv.ParseInt(t.ScheduleValue, 10, 64)
@@ -119,7 +262,7 @@ func (t *Task) CalculateNextRun() error {
 			return fmt.Errorf("invalid interval: %d", intervalMs)
 		}
 		if t.LastRun != nil {
-			t.NextRun = t.LastRun.Add(time.Duration(intervalMs) * time.Millisecond)
+			t.NextRun = t.LastRun.Add(time.Duration(intervalMs) * time.Millisecond).In(loc)
 		} else {
 			t.NextRun = now.Add(time.Duration(intervalMs) * time.Millisecond)
 		}
@@ -134,6 +277,10 @@ func (t *Task) CalculateNextRun() error {
 		}
 		t.NextRun = now.Add(duration)
 
+	case ScheduleTypeTrigger:
+		// Triggered tasks are started by their upstream task, never by time
+		t.NextRun = time.Time{}
+
 	default:
 		return fmt.Errorf("unknown schedule type: %s", t.ScheduleType)
 	}
@@ -143,7 +290,41 @@ func (t *Task) CalculateNextRun() error {
 
 // IsDue checks if the task should be executed now
 func (t *Task) IsDue() bool {
-	return t.Status == TaskStatusActive && time.Now().After(t.NextRun)
+	return t.Status == TaskStatusActive && t.timeScheduled() && time.Now().After(t.NextRun)
+}
+
+// timeScheduled reports whether NextRun drives the task. Triggered tasks are
+// started by their upstream task, unless a failed run is waiting to be retried.
+func (t *Task) timeScheduled() bool {
+	return t.ScheduleType != ScheduleTypeTrigger || t.Attempts > 0
+}
+
+// RenderPrompt returns the prompt to execute. For tasks started by an upstream
+// run, the prompt is rendered as a template with the upstream TaskRun as data
+// (e.g. {{.Result}}, {{.Error}}, {{.Status}}); prompts that do not use the
+// template syntax get the upstream result appended instead.
+func (t *Task) RenderPrompt(upstream *TaskRun) (string, error) {
+	if upstream == nil {
+		return t.Prompt, nil
+	}
+
+	if !strings.Contains(t.Prompt, "{{") {
+		output := upstream.Result
+		if upstream.Status != "success" {
+			output = upstream.Error
+		}
+		return fmt.Sprintf("%s\n\nOutput of the previous task (%s):\n%s", t.Prompt, upstream.Status, output), nil
+	}
+
+	tmpl, err := template.New("prompt").Parse(t.Prompt)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, upstream); err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+	return buf.String(), nil
 }
 
 // NewTaskRun creates a new task run record
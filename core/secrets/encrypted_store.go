@@ -0,0 +1,203 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAGI/pkg/atomicfile"
+	"golang.org/x/crypto/scrypt"
+)
+
+// EncryptedStore implements Store with a JSON file where every value is
+// encrypted with AES-256-GCM, using a key derived from a master key with
+// scrypt and the random salt stored in the file
+type EncryptedStore struct {
+	filePath string
+	salt     []byte
+	aead     cipher.AEAD
+	mu       sync.Mutex
+	data     map[string]encryptedSecret
+}
+
+// encryptedFile is the content of the file of an EncryptedStore
+type encryptedFile struct {
+	// Salt is the base64 encoded salt the key is derived with
+	Salt    string                     `json:"salt"`
+	Secrets map[string]encryptedSecret `json:"secrets"`
+}
+
+// The scrypt parameters recommended for interactive logins, making every
+// guess of a master key cost tens of milliseconds
+const (
+	scryptN  = 1 << 15
+	scryptR  = 8
+	scryptP  = 1
+	saltSize = 16
+)
+
+type encryptedSecret struct {
+	// Value is the nonce followed by the encrypted value, base64 encoded
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewEncryptedStore opens the secrets stored in filePath, encrypted with
+// masterKey
+func NewEncryptedStore(filePath, masterKey string) (*EncryptedStore, error) {
+	if masterKey == "" {
+		return nil, errors.New("master key is empty")
+	}
+
+	var file encryptedFile
+	data, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse secrets: %w", err)
+		}
+	}
+
+	s := &EncryptedStore{
+		filePath: filePath,
+		data:     file.Secrets,
+	}
+	if s.data == nil {
+		s.data = make(map[string]encryptedSecret)
+	}
+	if file.Salt != "" {
+		if s.salt, err = base64.StdEncoding.DecodeString(file.Salt); err != nil {
+			return nil, fmt.Errorf("failed to parse secrets salt: %w", err)
+		}
+	} else {
+		if len(s.data) > 0 {
+			return nil, errors.New("secrets file has no salt")
+		}
+		s.salt = make([]byte, saltSize)
+		if _, err := rand.Read(s.salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+	}
+
+	key, err := scrypt.Key([]byte(masterKey), s.salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if s.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+
+	// Fail early when the master key changed, rather than when agents start
+	for name := range s.data {
+		if _, err := s.decrypt(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Get returns the value of a secret
+func (s *EncryptedStore) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.decrypt(name)
+}
+
+// Set creates or replaces a secret
+func (s *EncryptedStore) Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// The name is authenticated with the value, so that values can't be
+	// swapped between secrets in the file
+	sealed := s.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	secret, ok := s.data[name]
+	if !ok {
+		secret.CreatedAt = now
+	}
+	secret.Value = base64.StdEncoding.EncodeToString(sealed)
+	secret.UpdatedAt = now
+	s.data[name] = secret
+
+	return s.save()
+}
+
+// Delete removes a secret
+func (s *EncryptedStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.data, name)
+	return s.save()
+}
+
+// List returns the secrets sorted by name
+func (s *EncryptedStore) List() ([]Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Secret, 0, len(s.data))
+	for name, secret := range s.data {
+		list = append(list, Secret{Name: name, CreatedAt: secret.CreatedAt, UpdatedAt: secret.UpdatedAt})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (s *EncryptedStore) decrypt(name string) (string, error) {
+	secret, ok := s.data[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	sealed, err := base64.StdEncoding.DecodeString(secret.Value)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return "", fmt.Errorf("secret %q is corrupted", name)
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	value, err := s.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %q, is the master key right? %w", name, err)
+	}
+	return string(value), nil
+}
+
+func (s *EncryptedStore) save() error {
+	err := atomicfile.WriteJSON(s.filePath, encryptedFile{
+		Salt:    base64.StdEncoding.EncodeToString(s.salt),
+		Secrets: s.data,
+	}, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+	return nil
+}
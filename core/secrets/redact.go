@@ -0,0 +1,245 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the values of sensitive fields
+const Redacted = "********"
+
+// ErrRedacted is returned for documents that still hold redacted values:
+// the credentials they stood for are not known
+var ErrRedacted = errors.New("redacted credential, set its value or a " + Prefix + " reference")
+
+var sensitiveKey = regexp.MustCompile(`(?i)(api[_-]?key|token|secret|password|passwd|passphrase|private[_-]?key|credential)`)
+
+// IsSensitive reports whether a field named key holds a credential
+func IsSensitive(key string) bool {
+	return sensitiveKey.MatchString(key)
+}
+
+// Redact returns a decoded JSON document with the values of its sensitive
+// fields replaced by Redacted. References to secrets and empty values are
+// left as they are, since they reveal nothing.
+func Redact(v any) any {
+	return RedactField("", v)
+}
+
+// RedactField redacts v, stored under the field key
+func RedactField(key string, v any) any {
+	redacted, _ := walkValue(key, v, func(key, s string) (string, error) {
+		return redactString(key, s), nil
+	})
+	return redacted
+}
+
+// RedactJSON redacts a JSON document
+func RedactJSON(data json.RawMessage) (json.RawMessage, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(Redact(doc))
+}
+
+func redactString(key, s string) string {
+	if s == "" || !IsSensitive(key) || reference.FindString(s) == s {
+		return s
+	}
+	return Redacted
+}
+
+// Restore returns updated with the values that were redacted taken from
+// old, so that a redacted document can be edited and saved back without
+// losing the credentials it held. Values it can't tell the origin of are
+// left redacted, for CheckRedactedJSON to reject.
+func Restore(updated, old any) any {
+	switch u := updated.(type) {
+	case map[string]any:
+		o, _ := old.(map[string]any)
+		out := make(map[string]any, len(u))
+		for k, v := range u {
+			out[k] = Restore(v, o[k])
+		}
+		return out
+	case []any:
+		o, _ := old.([]any)
+		return restoreList(u, o)
+	case string:
+		o, ok := old.(string)
+		if !ok || !strings.Contains(u, Redacted) {
+			return u
+		}
+		if u == Redacted {
+			return o
+		}
+		nestedUpdated, ok := decodeObject(u)
+		if !ok {
+			return u
+		}
+		nestedOld, _ := decodeObject(o)
+		data, err := json.Marshal(Restore(nestedUpdated, nestedOld))
+		if err != nil {
+			return u
+		}
+		return string(data)
+	default:
+		return updated
+	}
+}
+
+// restoreList restores the redacted values of the items of a list. Items
+// can be deleted or reordered, so they are matched with the old ones by
+// identity rather than by position: an item takes the values of the old
+// item equal to it apart from its credentials or, once edited, of the only
+// remaining old item of the same kind, e.g. the only Telegram connector.
+func restoreList(updated, old []any) []any {
+	out := make([]any, len(updated))
+	claimed := make([]bool, len(old))
+	edited := []int{}
+	for i, v := range updated {
+		out[i] = v
+		if !hasRedacted(v) {
+			continue
+		}
+		if _, ok := itemObject(v); !ok {
+			// Plain values have no identity, only the position they hold
+			if len(updated) == len(old) {
+				out[i] = Restore(v, old[i])
+			}
+			continue
+		}
+		if j, ok := matchItem(v, old, nil, identity); ok {
+			claimed[j] = true
+			out[i] = Restore(v, old[j])
+			continue
+		}
+		edited = append(edited, i)
+	}
+
+	for _, i := range edited {
+		if j, ok := matchItem(updated[i], old, claimed, kind); ok {
+			out[i] = Restore(updated[i], old[j])
+		}
+	}
+	return out
+}
+
+// matchItem returns the index of the old item that v matches according to
+// key. Skipped items are not considered. Several matches are ambiguous
+// unless they are all the same item.
+func matchItem(v any, old []any, skipped []bool, key func(any) any) (int, bool) {
+	want := key(v)
+	match := -1
+	for j, o := range old {
+		if skipped != nil && skipped[j] {
+			continue
+		}
+		if !reflect.DeepEqual(key(o), want) {
+			continue
+		}
+		if match != -1 && !reflect.DeepEqual(old[match], o) {
+			return -1, false
+		}
+		if match == -1 {
+			match = j
+		}
+	}
+	return match, match != -1
+}
+
+// identity returns an item without the values of its sensitive fields
+func identity(v any) any {
+	o, _ := itemObject(v)
+	masked, _ := walk(o, func(key, s string) (string, error) {
+		if IsSensitive(key) {
+			return "", nil
+		}
+		return s, nil
+	})
+	return masked
+}
+
+// kind returns the plain top-level fields of an item, such as the type of a
+// connector or the name of an action
+func kind(v any) any {
+	o, _ := itemObject(v)
+	fields := map[string]string{}
+	for k, field := range o {
+		s, ok := field.(string)
+		if !ok || IsSensitive(k) {
+			continue
+		}
+		if _, nested := decodeObject(s); nested {
+			continue
+		}
+		fields[k] = s
+	}
+	return fields
+}
+
+// itemObject returns the object a list item holds, directly or as a JSON string
+func itemObject(v any) (map[string]any, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		return v, true
+	case string:
+		return decodeObject(v)
+	default:
+		return nil, false
+	}
+}
+
+// hasRedacted reports whether v holds a redacted value
+func hasRedacted(v any) bool {
+	_, err := walk(v, func(key, s string) (string, error) {
+		if s == Redacted {
+			return s, ErrRedacted
+		}
+		return s, nil
+	})
+	return err != nil
+}
+
+// RestoreJSON restores the redacted values of updated from old
+func RestoreJSON(updated, old []byte) ([]byte, error) {
+	if !strings.Contains(string(updated), Redacted) {
+		return updated, nil
+	}
+	var u, o any
+	if err := json.Unmarshal(updated, &u); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(old, &o); err != nil {
+		return nil, err
+	}
+	return json.Marshal(Restore(u, o))
+}
+
+// CheckRedactedJSON fails with ErrRedacted when a sensitive field of a JSON
+// document holds Redacted, e.g. a configuration exported redacted and
+// imported back
+func CheckRedactedJSON(data []byte) error {
+	if !strings.Contains(string(data), Redacted) {
+		return nil
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	_, err := walk(doc, func(key, s string) (string, error) {
+		if s == Redacted && IsSensitive(key) {
+			return s, fmt.Errorf("%s: %w", key, ErrRedacted)
+		}
+		return s, nil
+	})
+	return err
+}
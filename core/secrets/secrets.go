@@ -0,0 +1,165 @@
+// Package secrets keeps the credentials used by agents encrypted at rest.
+// Agent configurations refer to them as secret://<name>, and the references
+// are resolved only when the agents start.
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Prefix starts the references to secrets
+const Prefix = "secret://"
+
+var (
+	// ErrNotFound is returned for secrets that don't exist
+	ErrNotFound = errors.New("secret not found")
+
+	validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	reference = regexp.MustCompile(regexp.QuoteMeta(Prefix) + `([A-Za-z0-9_.-]+)`)
+)
+
+// Secret describes a stored secret, without its value
+type Secret struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store persists secrets
+type Store interface {
+	// Get returns the value of a secret
+	Get(name string) (string, error)
+	// Set creates or replaces a secret
+	Set(name, value string) error
+	// Delete removes a secret
+	Delete(name string) error
+	// List returns the secrets sorted by name
+	List() ([]Secret, error)
+}
+
+// ValidateName checks that name can be used in a reference
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: only letters, digits, '_', '.' and '-' are allowed", name)
+	}
+	return nil
+}
+
+// Ref returns the reference to the secret name
+func Ref(name string) string {
+	return Prefix + name
+}
+
+// HasReferences reports whether data refers to any secret
+func HasReferences(data []byte) bool {
+	return reference.Match(data)
+}
+
+// ResolveJSON replaces the references to secrets in the strings of a JSON
+// document with their values. Strings holding JSON objects, like the
+// configurations of connectors and actions, are resolved as well.
+func ResolveJSON(data []byte, store Store) ([]byte, error) {
+	if !HasReferences(data) {
+		return data, nil
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	resolved, err := walk(doc, func(_ string, s string) (string, error) {
+		return Resolve(s, store)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resolved)
+}
+
+// Resolve replaces the references to secrets in s with their values
+func Resolve(s string, store Store) (string, error) {
+	if !strings.Contains(s, Prefix) {
+		return s, nil
+	}
+	var err error
+	resolved := reference.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		name := strings.TrimPrefix(ref, Prefix)
+		if store == nil {
+			err = fmt.Errorf("secret %q: no secret store is configured", name)
+			return ref
+		}
+		var value string
+		if value, err = store.Get(name); err != nil {
+			err = fmt.Errorf("secret %q: %w", name, err)
+			return ref
+		}
+		return value
+	})
+	return resolved, err
+}
+
+// walk calls fn on every string of a decoded JSON document with the key it
+// is stored under, and returns the document with the strings fn returned.
+// Strings holding JSON objects are decoded and walked too.
+func walk(v any, fn func(key, s string) (string, error)) (any, error) {
+	return walkValue("", v, fn)
+}
+
+func walkValue(key string, v any, fn func(key, s string) (string, error)) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			w, err := walkValue(k, item, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = w
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			w, err := walkValue(key, item, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = w
+		}
+		return out, nil
+	case string:
+		if nested, ok := decodeObject(v); ok {
+			w, err := walkValue(key, nested, fn)
+			if err != nil {
+				return nil, err
+			}
+			data, err := json.Marshal(w)
+			if err != nil {
+				return nil, err
+			}
+			return string(data), nil
+		}
+		return fn(key, v)
+	default:
+		return v, nil
+	}
+}
+
+// decodeObject decodes s if it holds a JSON object
+func decodeObject(s string) (map[string]any, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(s), "{") {
+		return nil, false
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, false
+	}
+	return m, true
+}
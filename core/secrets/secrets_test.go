@@ -0,0 +1,240 @@
+package secrets_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAGI/core/secrets"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EncryptedStore", func() {
+	var path string
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "secrets.json")
+	})
+
+	It("keeps the values encrypted at rest", func() {
+		store, err := NewEncryptedStore(path, "master")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Set("telegram-token", "123:abc")).To(Succeed())
+		Expect(store.Set("ssh.password", "hunter2")).To(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).ToNot(ContainSubstring("123:abc"))
+		Expect(string(data)).ToNot(ContainSubstring("hunter2"))
+
+		reopened, err := NewEncryptedStore(path, "master")
+		Expect(err).ToNot(HaveOccurred())
+		value, err := reopened.Get("telegram-token")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(value).To(Equal("123:abc"))
+
+		list, err := reopened.List()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(HaveLen(2))
+		Expect(list[0].Name).To(Equal("ssh.password"))
+		Expect(list[1].Name).To(Equal("telegram-token"))
+
+		Expect(reopened.Delete("ssh.password")).To(Succeed())
+		_, err = reopened.Get("ssh.password")
+		Expect(err).To(MatchError(ErrNotFound))
+		Expect(reopened.Delete("ssh.password")).To(MatchError(ErrNotFound))
+	})
+
+	It("derives the key with a random salt of each file", func() {
+		salt := func(path string) string {
+			store, err := NewEncryptedStore(path, "master")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(store.Set("token", "123:abc")).To(Succeed())
+
+			data, err := os.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			var file struct{ Salt string }
+			Expect(json.Unmarshal(data, &file)).To(Succeed())
+			Expect(file.Salt).ToNot(BeEmpty())
+			return file.Salt
+		}
+
+		Expect(salt(path)).ToNot(Equal(salt(filepath.Join(GinkgoT().TempDir(), "secrets.json"))))
+	})
+
+	It("refuses a different master key", func() {
+		store, err := NewEncryptedStore(path, "master")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Set("token", "value")).To(Succeed())
+
+		_, err = NewEncryptedStore(path, "other")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects names that can't be referred to", func() {
+		store, err := NewEncryptedStore(path, "master")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Set("my token", "value")).ToNot(Succeed())
+	})
+})
+
+var _ = Describe("ResolveJSON", func() {
+	It("resolves references in fields and nested configurations", func() {
+		store, err := NewEncryptedStore(filepath.Join(GinkgoT().TempDir(), "secrets.json"), "master")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Set("key", "sk-123")).To(Succeed())
+		Expect(store.Set("token", `to"ken`)).To(Succeed())
+
+		data, err := ResolveJSON([]byte(`{
+			"api_key": "secret://key",
+			"model": "gpt",
+			"connectors": [{"type": "telegram", "config": "{\"token\":\"secret://token\"}"}]
+		}`), store)
+		Expect(err).ToNot(HaveOccurred())
+
+		var resolved struct {
+			APIKey     string `json:"api_key"`
+			Model      string `json:"model"`
+			Connectors []struct {
+				Config string `json:"config"`
+			} `json:"connectors"`
+		}
+		Expect(json.Unmarshal(data, &resolved)).To(Succeed())
+		Expect(resolved.APIKey).To(Equal("sk-123"))
+		Expect(resolved.Model).To(Equal("gpt"))
+		Expect(resolved.Connectors[0].Config).To(MatchJSON(`{"token":"to\"ken"}`))
+	})
+
+	It("fails on missing secrets", func() {
+		store, err := NewEncryptedStore(filepath.Join(GinkgoT().TempDir(), "secrets.json"), "master")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = ResolveJSON([]byte(`{"api_key":"secret://missing"}`), store)
+		Expect(err).To(MatchError(ContainSubstring("missing")))
+		_, err = ResolveJSON([]byte(`{"api_key":"secret://missing"}`), nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Redact", func() {
+	It("redacts credentials but not references", func() {
+		data, err := RedactJSON([]byte(`{
+			"api_key": "sk-123",
+			"local_rag_api_key": "secret://rag",
+			"model": "gpt",
+			"max_tokens_per_job": 100,
+			"llm_endpoints": [{"url": "http://b", "api_key": "sk-456"}],
+			"connectors": [{"type": "telegram", "config": "{\"token\":\"123:abc\",\"group\":\"g\"}"}],
+			"actions": [{"name": "shell", "config": "{\"privateKey\":\"---\",\"password\":\"\"}"}]
+		}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).ToNot(ContainSubstring("sk-"))
+		Expect(string(data)).ToNot(ContainSubstring("123:abc"))
+
+		var redacted map[string]any
+		Expect(json.Unmarshal(data, &redacted)).To(Succeed())
+		Expect(redacted["api_key"]).To(Equal(Redacted))
+		Expect(redacted["local_rag_api_key"]).To(Equal("secret://rag"))
+		Expect(redacted["model"]).To(Equal("gpt"))
+		Expect(redacted["max_tokens_per_job"]).To(BeEquivalentTo(100))
+		connector := redacted["connectors"].([]any)[0].(map[string]any)
+		Expect(connector["config"]).To(MatchJSON(`{"token":"********","group":"g"}`))
+		action := redacted["actions"].([]any)[0].(map[string]any)
+		Expect(action["config"]).To(MatchJSON(`{"privateKey":"********","password":""}`))
+	})
+
+	It("restores the redacted values of an edited document", func() {
+		old := []byte(`{
+			"api_key": "sk-123",
+			"model": "gpt",
+			"connectors": [{"type": "telegram", "config": "{\"token\":\"123:abc\",\"group\":\"g\"}"}]
+		}`)
+		redacted, err := RedactJSON(old)
+		Expect(err).ToNot(HaveOccurred())
+
+		var edited map[string]any
+		Expect(json.Unmarshal(redacted, &edited)).To(Succeed())
+		edited["model"] = "other"
+		edited["connectors"].([]any)[0].(map[string]any)["config"] = `{"token":"********","group":"h"}`
+		updated, err := json.Marshal(edited)
+		Expect(err).ToNot(HaveOccurred())
+
+		restored, err := RestoreJSON(updated, old)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored).To(MatchJSON(`{
+			"api_key": "sk-123",
+			"model": "other",
+			"connectors": [{"type": "telegram", "config": "{\"group\":\"h\",\"token\":\"123:abc\"}"}]
+		}`))
+	})
+
+	Describe("restoring lists", func() {
+		old := []byte(`{"connectors": [
+			{"type": "telegram", "config": "{\"token\":\"BOT-A\",\"group\":\"a\"}"},
+			{"type": "telegram", "config": "{\"token\":\"BOT-B\",\"group\":\"b\"}"}
+		]}`)
+
+		// edit redacts old and applies fn to its connectors
+		edit := func(fn func(connectors []any) []any) []byte {
+			redacted, err := RedactJSON(old)
+			Expect(err).ToNot(HaveOccurred())
+			var doc map[string]any
+			Expect(json.Unmarshal(redacted, &doc)).To(Succeed())
+			doc["connectors"] = fn(doc["connectors"].([]any))
+			updated, err := json.Marshal(doc)
+			Expect(err).ToNot(HaveOccurred())
+			return updated
+		}
+
+		It("keeps the credentials of the remaining items when one is deleted", func() {
+			restored, err := RestoreJSON(edit(func(c []any) []any { return c[1:] }), old)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(restored).To(MatchJSON(`{"connectors": [
+				{"type": "telegram", "config": "{\"group\":\"b\",\"token\":\"BOT-B\"}"}
+			]}`))
+		})
+
+		It("keeps the credentials of reordered items", func() {
+			restored, err := RestoreJSON(edit(func(c []any) []any { return []any{c[1], c[0]} }), old)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(restored).To(MatchJSON(`{"connectors": [
+				{"type": "telegram", "config": "{\"group\":\"b\",\"token\":\"BOT-B\"}"},
+				{"type": "telegram", "config": "{\"group\":\"a\",\"token\":\"BOT-A\"}"}
+			]}`))
+		})
+
+		It("matches an edited item with the only old item of its kind left", func() {
+			restored, err := RestoreJSON(edit(func(c []any) []any {
+				c[0].(map[string]any)["config"] = `{"token":"********","group":"c"}`
+				return []any{c[1], c[0]}
+			}), old)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(restored).To(MatchJSON(`{"connectors": [
+				{"type": "telegram", "config": "{\"group\":\"b\",\"token\":\"BOT-B\"}"},
+				{"type": "telegram", "config": "{\"group\":\"c\",\"token\":\"BOT-A\"}"}
+			]}`))
+		})
+
+		It("leaves redacted the credentials of items it can't tell apart", func() {
+			restored, err := RestoreJSON(edit(func(c []any) []any {
+				c[1].(map[string]any)["config"] = `{"token":"********","group":"c"}`
+				return c[1:]
+			}), old)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(restored)).ToNot(ContainSubstring("BOT-"))
+			Expect(CheckRedactedJSON(restored)).To(MatchError(ErrRedacted))
+		})
+	})
+
+	It("rejects documents that still hold redacted credentials", func() {
+		redacted, err := RedactJSON([]byte(`{
+			"model": "gpt",
+			"connectors": [{"type": "telegram", "config": "{\"token\":\"123:abc\"}"}]
+		}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(CheckRedactedJSON(redacted)).To(MatchError(ErrRedacted))
+
+		Expect(CheckRedactedJSON([]byte(`{"api_key": "secret://openai", "name": "********"}`))).To(Succeed())
+	})
+})
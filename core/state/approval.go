@@ -0,0 +1,101 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/approval"
+	sseLib "github.com/mudler/LocalAGI/core/sse"
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/xlog"
+)
+
+// ApprovalConnector is implemented by connectors that can ask, in the
+// conversation a job originates from, whether an action is allowed to run.
+// RequestApproval returns false if the job does not belong to the connector.
+//
+// ApprovalDone is called on the connector that asked once the request is
+// resolved, wherever it was answered or if it expired, to drop it.
+type ApprovalConnector interface {
+	RequestApproval(job *types.Job, request approval.Request, resolve func(approved bool, by string)) bool
+	ApprovalDone(request approval.Request)
+}
+
+// Approvals returns the manager holding the approval requests of the pool's agents
+func (a *AgentPool) Approvals() *approval.Manager {
+	return a.approvals
+}
+
+// awaitApproval creates an approval request for the chosen action, notifies
+// the web UI and the originating connector, and blocks until it is answered.
+// The agent keeps running its other jobs meanwhile.
+func (a *AgentPool) awaitApproval(name string, config *AgentConfig, manager sseLib.Manager, state types.ActionCurrentState) bool {
+	timeout := approval.DefaultTimeout
+	if config.ApprovalTimeout != "" {
+		if d, err := time.ParseDuration(config.ApprovalTimeout); err == nil {
+			timeout = d
+		} else {
+			xlog.Warn("Invalid approval timeout, using default", "agent", name, "timeout", config.ApprovalTimeout, "error", err)
+		}
+	}
+
+	request := approval.NewRequest(name, state, timeout)
+	a.approvals.Add(request)
+	xlog.Info("Action requires approval", "agent", name, "action", request.Action, "approval", request.ID)
+
+	sendApprovalUpdate(manager, *request)
+
+	var asked ApprovalConnector
+	for _, c := range a.runningConnectors(name) {
+		ac, ok := c.(ApprovalConnector)
+		if !ok {
+			continue
+		}
+		if ac.RequestApproval(state.Job, *request, func(approved bool, by string) {
+			if err := a.approvals.Resolve(request.ID, approved, by); err != nil {
+				xlog.Debug("Could not resolve approval from connector", "approval", request.ID, "error", err)
+			}
+		}) {
+			asked = ac
+			break
+		}
+	}
+
+	ctx := context.Background()
+	if state.Job != nil {
+		ctx = state.Job.GetContext()
+	}
+	var approved bool
+	var err error
+	wait := func() {
+		approved, err = a.approvals.Wait(ctx, request.ID)
+	}
+	if ag := a.GetAgent(name); ag != nil {
+		ag.Await(wait)
+	} else {
+		wait()
+	}
+	if err != nil {
+		xlog.Warn("Stopped waiting for approval", "agent", name, "approval", request.ID, "error", err)
+	}
+
+	if final, err := a.approvals.Get(request.ID); err == nil {
+		sendApprovalUpdate(manager, final)
+		xlog.Info("Approval resolved", "agent", name, "action", final.Action, "approval", final.ID, "status", final.Status, "by", final.DecidedBy)
+		if asked != nil {
+			asked.ApprovalDone(final)
+		}
+	}
+
+	return approved
+}
+
+func sendApprovalUpdate(manager sseLib.Manager, request approval.Request) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		xlog.Error("Error marshalling approval request", "error", err)
+		return
+	}
+	manager.Send(sseLib.NewMessage(string(data)).WithEvent("approval"))
+}
@@ -0,0 +1,44 @@
+package state
+
+import (
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/xlog"
+)
+
+// SetAuditLog sets the log recording the actions with side effects run by
+// the agents. Nothing is recorded when it is nil.
+func (a *AgentPool) SetAuditLog(log audit.Log) {
+	a.Lock()
+	defer a.Unlock()
+	a.audit = log
+}
+
+// AuditLog returns the audit log, nil when auditing is disabled
+func (a *AgentPool) AuditLog() audit.Log {
+	return a.audit
+}
+
+// auditAction records an action run by an agent when it has side effects,
+// along with the job and conversation it was run for
+func (a *AgentPool) auditAction(name string, state types.ActionState) {
+	if a.audit == nil || state.Action == nil || !types.IsActionWithSideEffects(state.Action) {
+		return
+	}
+
+	entry := audit.Entry{
+		Actor:     audit.AgentActor + name,
+		Operation: audit.OperationActionRun,
+		Agent:     name,
+		Action:    state.Action.Definition().Name.String(),
+		Params:    state.Params,
+		Result:    audit.Truncate(state.Result),
+	}
+	if job := state.ActionCurrentState.Job; job != nil {
+		entry.JobID = job.UUID
+		entry.ConversationID, _ = job.Metadata[types.MetadataKeyConversationID].(string)
+	}
+	if err := a.audit.Record(entry); err != nil {
+		xlog.Error("Failed to record action in the audit log", "agent", name, "action", entry.Action, "error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package state
+
+import (
+	"encoding/json"
+
+	sseLib "github.com/mudler/LocalAGI/core/sse"
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/core/usage"
+	"github.com/mudler/xlog"
+)
+
+// NoticeConnector is implemented by connectors that can post a notice in the
+// conversation a job originates from. Notify returns false if the job does
+// not belong to the connector.
+type NoticeConnector interface {
+	Notify(job *types.Job, message string) bool
+}
+
+// BudgetNotification is sent to the web UI when a job hits the token budget
+type BudgetNotification struct {
+	Agent   string `json:"agent"`
+	Job     string `json:"job"`
+	Message string `json:"message"`
+}
+
+// agentBudget returns the token and spend limits set in the agent config
+func agentBudget(config *AgentConfig) usage.Budget {
+	return usage.Budget{
+		DailyTokens:   config.DailyTokenBudget,
+		MonthlyTokens: config.MonthlyTokenBudget,
+		DailyCost:     config.DailyCostBudget,
+		MonthlyCost:   config.MonthlyCostBudget,
+		MaxJobTokens:  config.MaxTokensPerJob,
+		Action:        usage.BudgetAction(config.BudgetAction),
+		FallbackModel: config.BudgetFallbackModel,
+	}
+}
+
+// budgetNotifier tells the web UI and the originating connector that a job
+// was refused, stopped or downgraded because of the budget
+func (a *AgentPool) budgetNotifier(name string, manager sseLib.Manager) func(job *types.Job, message string) {
+	return func(job *types.Job, message string) {
+		data, err := json.Marshal(BudgetNotification{Agent: name, Job: job.UUID, Message: message})
+		if err != nil {
+			xlog.Error("Error marshalling budget notification", "error", err)
+		} else {
+			manager.Send(sseLib.NewMessage(string(data)).WithEvent("budget"))
+		}
+
+		for _, c := range a.runningConnectors(name) {
+			if nc, ok := c.(NoticeConnector); ok && nc.Notify(job, message) {
+				break
+			}
+		}
+	}
+}
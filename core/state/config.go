@@ -9,8 +9,22 @@ import (
 	"github.com/mudler/LocalAGI/core/agent"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/pkg/config"
+	"github.com/mudler/LocalAGI/pkg/llm"
 )
 
+// parseFloatField parses a decimal field that may be received as either a number or a string
+func parseFloatField(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return 0
+}
+
 // parseIntField parses an integer field that may be received as either a number or a string
 func parseIntField(value interface{}) int {
 	switch v := value.(type) {
@@ -34,6 +48,8 @@ type ConnectorConfig struct {
 type ActionsConfig struct {
 	Name   string `json:"name"` // e.g. search
 	Config string `json:"config"`
+	// RequiresApproval pauses the job until a human approves the action
+	RequiresApproval bool `json:"requires_approval,omitempty"`
 }
 
 type DynamicPromptsConfig struct {
@@ -70,9 +86,14 @@ type AgentConfig struct {
 	TTSModel              string `json:"tts_model" form:"tts_model"`
 	APIURL                string `json:"api_url" form:"api_url"`
 	APIKey                string `json:"api_key" form:"api_key"`
-	LocalRAGURL           string `json:"local_rag_url" form:"local_rag_url"`
-	LocalRAGAPIKey        string `json:"local_rag_api_key" form:"local_rag_api_key"`
-	LastMessageDuration   string `json:"last_message_duration" form:"last_message_duration"`
+	// LLMEndpoints are fallback endpoints, tried in order after APIURL
+	LLMEndpoints            []llm.Endpoint `json:"llm_endpoints" form:"llm_endpoints"`
+	LLMLoadBalance          bool           `json:"llm_load_balance" form:"llm_load_balance"`
+	LocalRAGURL             string         `json:"local_rag_url" form:"local_rag_url"`
+	LocalRAGAPIKey          string         `json:"local_rag_api_key" form:"local_rag_api_key"`
+	LastMessageDuration     string         `json:"last_message_duration" form:"last_message_duration"`
+	ConversationMaxMessages int            `json:"conversation_max_messages" form:"conversation_max_messages"`
+	ConversationMaxThreads  int            `json:"conversation_max_threads" form:"conversation_max_threads"`
 
 	Name                       string `json:"name" form:"name"`
 	HUD                        bool   `json:"hud" form:"hud"`
@@ -85,7 +106,7 @@ type AgentConfig struct {
 	IdentityGuidance           string `json:"identity_guidance" form:"identity_guidance"`
 	PeriodicRuns               string `json:"periodic_runs" form:"periodic_runs"`
 	SchedulerPollInterval      string `json:"scheduler_poll_interval" form:"scheduler_poll_interval"`
-	SchedulerTaskTemplate   string `json:"scheduler_task_template" form:"scheduler_task_template"`
+	SchedulerTaskTemplate      string `json:"scheduler_task_template" form:"scheduler_task_template"`
 	PermanentGoal              string `json:"permanent_goal" form:"permanent_goal"`
 	EnableKnowledgeBase        bool   `json:"enable_kb" form:"enable_kb"`
 	EnableKBCompaction         bool   `json:"enable_kb_compaction" form:"enable_kb_compaction"`
@@ -107,6 +128,12 @@ type AgentConfig struct {
 	ConversationStorageMode    string `json:"conversation_storage_mode" form:"conversation_storage_mode"`
 	ParallelJobs               int    `json:"parallel_jobs" form:"parallel_jobs"`
 	CancelPreviousOnNewMessage *bool  `json:"cancel_previous_on_new_message" form:"cancel_previous_on_new_message"`
+	EnableJobJournal           bool   `json:"enable_job_journal" form:"enable_job_journal"`
+	InterruptedJobPolicy       string `json:"interrupted_job_policy" form:"interrupted_job_policy"`
+	ApprovalTimeout            string `json:"approval_timeout" form:"approval_timeout"`
+	SchedulerStore             string `json:"scheduler_store" form:"scheduler_store"`
+	SchedulerRunHistory        int    `json:"scheduler_run_history" form:"scheduler_run_history"`
+	SchedulerDeadLetterWebhook string `json:"scheduler_dead_letter_webhook" form:"scheduler_dead_letter_webhook"`
 	StripThinkingTags          bool   `json:"strip_thinking_tags" form:"strip_thinking_tags"`
 	EnableEvaluation           bool   `json:"enable_evaluation" form:"enable_evaluation"`
 	MaxEvaluationLoops         int    `json:"max_evaluation_loops" form:"max_evaluation_loops"`
@@ -114,6 +141,14 @@ type AgentConfig struct {
 	LoopDetection              int    `json:"loop_detection" form:"loop_detection"`
 	EnableAutoCompaction       bool   `json:"enable_auto_compaction" form:"enable_auto_compaction"`
 	AutoCompactionThreshold    int    `json:"auto_compaction_threshold" form:"auto_compaction_threshold"`
+
+	DailyTokenBudget    int     `json:"daily_token_budget" form:"daily_token_budget"`
+	MonthlyTokenBudget  int     `json:"monthly_token_budget" form:"monthly_token_budget"`
+	DailyCostBudget     float64 `json:"daily_cost_budget" form:"daily_cost_budget"`
+	MonthlyCostBudget   float64 `json:"monthly_cost_budget" form:"monthly_cost_budget"`
+	MaxTokensPerJob     int     `json:"max_tokens_per_job" form:"max_tokens_per_job"`
+	BudgetAction        string  `json:"budget_action" form:"budget_action"`
+	BudgetFallbackModel string  `json:"budget_fallback_model" form:"budget_fallback_model"`
 }
 
 type AgentConfigMeta struct {
@@ -225,6 +260,23 @@ func NewAgentConfigMeta(
 				DefaultValue: "",
 				Tags:         config.Tags{Section: "ModelSettings"},
 			},
+			{
+				Name:         "llm_endpoints",
+				Label:        "Fallback LLM Endpoints",
+				Type:         "textarea",
+				DefaultValue: "",
+				Placeholder:  "http://backup-llm:8080 sk-backup-key",
+				HelpText:     "One endpoint per line: its URL, optionally followed by its API key. They are tried in order when the API URL fails",
+				Tags:         config.Tags{Section: "ModelSettings"},
+			},
+			{
+				Name:         "llm_load_balance",
+				Label:        "Load Balance LLM Endpoints",
+				Type:         "checkbox",
+				DefaultValue: false,
+				HelpText:     "Spread requests across the API URL and the fallback endpoints (llm_endpoints) instead of only failing over to them",
+				Tags:         config.Tags{Section: "ModelSettings"},
+			},
 			{
 				Name:         "local_rag_url",
 				Label:        "Local RAG URL",
@@ -355,14 +407,14 @@ func NewAgentConfigMeta(
 				HelpText:     "Prompt used for periodic/standalone runs when the agent evaluates what to do next. If empty, the default autonomous agent instructions are used.",
 				Tags:         config.Tags{Section: "PromptsGoals"},
 			},
-				{
-					Name:         "scheduler_task_template",
-					Label:        "Scheduler Task Template",
-					Type:         "textarea",
-					DefaultValue: "",
-					HelpText:     "Template for scheduled/recurring tasks. Use {{.Task}} to reference the task. Example: \"Execute: {{.Task}}\". If empty, the default inner monologue template is used with the task injected.",
-					Tags:         config.Tags{Section: "PromptsGoals"},
-				},
+			{
+				Name:         "scheduler_task_template",
+				Label:        "Scheduler Task Template",
+				Type:         "textarea",
+				DefaultValue: "",
+				HelpText:     "Template for scheduled/recurring tasks. Use {{.Task}} to reference the task. Example: \"Execute: {{.Task}}\". If empty, the default inner monologue template is used with the task injected.",
+				Tags:         config.Tags{Section: "PromptsGoals"},
+			},
 			{
 				Name:         "standalone_job",
 				Label:        "Standalone Job",
@@ -395,6 +447,63 @@ func NewAgentConfigMeta(
 				HelpText:     "When a new message arrives for the same conversation, cancel the currently running job and start the new one. If disabled, new messages are queued.",
 				Tags:         config.Tags{Section: "AdvancedSettings"},
 			},
+			{
+				Name:         "enable_job_journal",
+				Label:        "Enable Job Journal",
+				Type:         "checkbox",
+				DefaultValue: false,
+				HelpText:     "Persist queued jobs to disk so that messages received or being processed during a restart are replayed when the agent starts again",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "interrupted_job_policy",
+				Label:        "Interrupted Job Policy",
+				Type:         "select",
+				DefaultValue: "retry",
+				Options: []config.FieldOption{
+					{Value: "retry", Label: "Run again"},
+					{Value: "fail", Label: "Mark as failed"},
+				},
+				HelpText: "What to do with journaled jobs that were running when the agent stopped",
+				Tags:     config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "approval_timeout",
+				Label:        "Approval Timeout",
+				Type:         "text",
+				DefaultValue: "10m",
+				HelpText:     "How long an action requiring approval waits for an answer before being denied",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "scheduler_store",
+				Label:        "Scheduler Storage",
+				Type:         "select",
+				DefaultValue: "json",
+				Options: []config.FieldOption{
+					{Value: "json", Label: "JSON file"},
+					{Value: "sqlite", Label: "SQLite database"},
+				},
+				HelpText: "Where scheduled tasks and their run history are stored. Switching to SQLite migrates the existing JSON file",
+				Tags:     config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "scheduler_run_history",
+				Label:        "Scheduler Run History",
+				Type:         "number",
+				DefaultValue: 0,
+				Min:          0,
+				Step:         1,
+				HelpText:     "Number of runs kept per scheduled task with the SQLite storage (0 for the default of 100)",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:     "scheduler_dead_letter_webhook",
+				Label:    "Scheduler Dead Letter Webhook",
+				Type:     "text",
+				HelpText: "URL notified with a POST request when a scheduled task fails all of its retries",
+				Tags:     config.Tags{Section: "AdvancedSettings"},
+			},
 			{
 				Name:         "loop_detection",
 				Label:        "Loop Detection",
@@ -559,6 +668,95 @@ func NewAgentConfigMeta(
 				HelpText:     "Duration for the last message to be considered in the conversation",
 				Tags:         config.Tags{Section: "AdvancedSettings"},
 			},
+			{
+				Name:         "conversation_max_messages",
+				Label:        "Max Messages per Conversation",
+				Type:         "number",
+				DefaultValue: 0,
+				Min:          0,
+				Step:         1,
+				HelpText:     "Maximum number of messages kept for each conversation (0 for unlimited)",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "conversation_max_threads",
+				Label:        "Max Stored Conversations",
+				Type:         "number",
+				DefaultValue: 0,
+				Min:          0,
+				Step:         1,
+				HelpText:     "Maximum number of conversations kept across restarts, least recently active are dropped first (0 for unlimited)",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "daily_token_budget",
+				Label:        "Daily Token Budget",
+				Type:         "number",
+				DefaultValue: 0,
+				Min:          0,
+				Step:         1,
+				HelpText:     "Maximum number of tokens the agent can use per day (0 for unlimited)",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "monthly_token_budget",
+				Label:        "Monthly Token Budget",
+				Type:         "number",
+				DefaultValue: 0,
+				Min:          0,
+				Step:         1,
+				HelpText:     "Maximum number of tokens the agent can use per month (0 for unlimited)",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "daily_cost_budget",
+				Label:        "Daily Spend Budget",
+				Type:         "number",
+				DefaultValue: 0,
+				Min:          0,
+				Step:         0.01,
+				HelpText:     "Maximum estimated cost per day, requires model prices (0 for unlimited)",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "monthly_cost_budget",
+				Label:        "Monthly Spend Budget",
+				Type:         "number",
+				DefaultValue: 0,
+				Min:          0,
+				Step:         0.01,
+				HelpText:     "Maximum estimated cost per month, requires model prices (0 for unlimited)",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "max_tokens_per_job",
+				Label:        "Max Tokens per Job",
+				Type:         "number",
+				DefaultValue: 0,
+				Min:          0,
+				Step:         1,
+				HelpText:     "Stop a job once it used this many tokens (0 for unlimited)",
+				Tags:         config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:         "budget_action",
+				Label:        "When the Budget Is Exhausted",
+				Type:         "select",
+				DefaultValue: "refuse",
+				Options: []config.FieldOption{
+					{Value: "refuse", Label: "Refuse new jobs"},
+					{Value: "downgrade", Label: "Switch to the fallback model"},
+				},
+				HelpText: "What happens to new jobs once the daily or monthly budget is used up",
+				Tags:     config.Tags{Section: "AdvancedSettings"},
+			},
+			{
+				Name:     "budget_fallback_model",
+				Label:    "Budget Fallback Model",
+				Type:     "text",
+				HelpText: "Cheaper model used once the budget is used up, when the budget action is downgrade",
+				Tags:     config.Tags{Section: "AdvancedSettings"},
+			},
 		},
 		MCPServers: []config.Field{
 			{
@@ -593,11 +791,19 @@ func (a *AgentConfig) UnmarshalJSON(data []byte) error {
 	type Alias AgentConfig
 	aux := &struct {
 		*Alias
-		MCPSTDIOServersConfig interface{} `json:"mcp_stdio_servers"`
-		MaxEvaluationLoops    interface{} `json:"max_evaluation_loops"`
-		MaxAttempts            interface{} `json:"max_attempts"`
-		ParallelJobs           interface{} `json:"parallel_jobs"`
-		KnowledgeBaseResults  interface{} `json:"kb_results"`
+		MCPSTDIOServersConfig   interface{} `json:"mcp_stdio_servers"`
+		MaxEvaluationLoops      interface{} `json:"max_evaluation_loops"`
+		MaxAttempts             interface{} `json:"max_attempts"`
+		ParallelJobs            interface{} `json:"parallel_jobs"`
+		KnowledgeBaseResults    interface{} `json:"kb_results"`
+		ConversationMaxMessages interface{} `json:"conversation_max_messages"`
+		ConversationMaxThreads  interface{} `json:"conversation_max_threads"`
+		SchedulerRunHistory     interface{} `json:"scheduler_run_history"`
+		DailyTokenBudget        interface{} `json:"daily_token_budget"`
+		MonthlyTokenBudget      interface{} `json:"monthly_token_budget"`
+		DailyCostBudget         interface{} `json:"daily_cost_budget"`
+		MonthlyCostBudget       interface{} `json:"monthly_cost_budget"`
+		MaxTokensPerJob         interface{} `json:"max_tokens_per_job"`
 	}{
 		Alias: (*Alias)(a),
 	}
@@ -612,6 +818,14 @@ func (a *AgentConfig) UnmarshalJSON(data []byte) error {
 	a.ParallelJobs = parseIntField(aux.ParallelJobs)
 	a.KnowledgeBaseResults = parseIntField(aux.KnowledgeBaseResults)
 	a.LoopDetection = parseIntField(aux.LoopDetection)
+	a.ConversationMaxMessages = parseIntField(aux.ConversationMaxMessages)
+	a.ConversationMaxThreads = parseIntField(aux.ConversationMaxThreads)
+	a.SchedulerRunHistory = parseIntField(aux.SchedulerRunHistory)
+	a.DailyTokenBudget = parseIntField(aux.DailyTokenBudget)
+	a.MonthlyTokenBudget = parseIntField(aux.MonthlyTokenBudget)
+	a.DailyCostBudget = parseFloatField(aux.DailyCostBudget)
+	a.MonthlyCostBudget = parseFloatField(aux.MonthlyCostBudget)
+	a.MaxTokensPerJob = parseIntField(aux.MaxTokensPerJob)
 
 	// Handle MCP STDIO servers configuration
 	if aux.MCPSTDIOServersConfig != nil {
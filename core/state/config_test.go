@@ -0,0 +1,24 @@
+package state_test
+
+import (
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/pkg/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewAgentConfigMeta", func() {
+	It("lets the fallback LLM endpoints be set in the model settings", func() {
+		meta := state.NewAgentConfigMeta(nil, nil, nil, nil)
+
+		var endpoints *config.Field
+		for i, field := range meta.Fields {
+			if field.Name == "llm_endpoints" {
+				endpoints = &meta.Fields[i]
+			}
+		}
+		Expect(endpoints).NotTo(BeNil())
+		Expect(endpoints.Type).To(Equal(config.FieldTypeTextarea))
+		Expect(endpoints.Tags.Section).To(Equal("ModelSettings"))
+	})
+})
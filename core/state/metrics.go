@@ -0,0 +1,45 @@
+package state
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	queueDepthDesc = prometheus.NewDesc(
+		"localagi_job_queue_depth",
+		"Jobs waiting to be picked up by an agent.",
+		[]string{"agent"}, nil,
+	)
+	sseClientsDesc = prometheus.NewDesc(
+		"localagi_sse_clients",
+		"Clients connected to the event stream of an agent.",
+		[]string{"agent"}, nil,
+	)
+)
+
+// poolCollector reports the gauges read from the pool at scrape time
+type poolCollector struct {
+	pool *AgentPool
+}
+
+// MetricsCollector returns a Prometheus collector for the queue depth and the
+// number of SSE clients of each agent of the pool
+func (a *AgentPool) MetricsCollector() prometheus.Collector {
+	return &poolCollector{pool: a}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+	ch <- sseClientsDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, name := range c.pool.AllAgents() {
+		if agent := c.pool.GetAgent(name); agent != nil {
+			ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(agent.QueueDepth()), name)
+		}
+		if manager := c.pool.GetManager(name); manager != nil {
+			ch <- prometheus.MustNewConstMetric(sseClientsDesc, prometheus.GaugeValue, float64(len(manager.Clients())), name)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package state_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestPool returns a pool whose agents have no actions, connectors,
+// prompts or filters
+func newTestPool() *state.AgentPool {
+	return newTestPoolWithConnectors()
+}
+
+// newTestPoolWithConnectors returns a pool whose agents have connectors and
+// no actions, prompts or filters
+func newTestPoolWithConnectors(connectors ...state.Connector) *state.AgentPool {
+	return newTestPoolIn(GinkgoT().TempDir(), connectors...)
+}
+
+// newTestPoolIn returns a pool keeping its state in directory
+func newTestPoolIn(directory string, connectors ...state.Connector) *state.AgentPool {
+	pool, err := state.NewAgentPool("model", "", "", "", "", "http://127.0.0.1:0", "", directory,
+		func(*state.AgentConfig) func(context.Context, *state.AgentPool) []types.Action {
+			return func(context.Context, *state.AgentPool) []types.Action { return nil }
+		},
+		func(*state.AgentConfig) []state.Connector { return connectors },
+		func(*state.AgentConfig) func(context.Context, *state.AgentPool) []agent.DynamicPrompt {
+			return func(context.Context, *state.AgentPool) []agent.DynamicPrompt { return nil }
+		},
+		func(*state.AgentConfig) types.JobFilters { return nil },
+		"1m", false, nil,
+	)
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(pool.StopAll)
+	return pool
+}
+
+var _ = Describe("MetricsCollector", func() {
+	It("reports the queue depth and SSE clients of each agent", func() {
+		pool := newTestPool()
+		Expect(pool.CreateAgent("metrics-agent", &state.AgentConfig{Name: "metrics-agent"}, "")).To(Succeed())
+
+		expected := `
+# HELP localagi_job_queue_depth Jobs waiting to be picked up by an agent.
+# TYPE localagi_job_queue_depth gauge
+localagi_job_queue_depth{agent="metrics-agent"} 0
+# HELP localagi_sse_clients Clients connected to the event stream of an agent.
+# TYPE localagi_sse_clients gauge
+localagi_sse_clients{agent="metrics-agent"} 0
+`
+		Expect(testutil.CollectAndCompare(pool.MetricsCollector(), strings.NewReader(expected))).To(Succeed())
+	})
+
+	It("reports nothing without agents", func() {
+		Expect(testutil.CollectAndCount(newTestPool().MetricsCollector())).To(BeZero())
+	})
+})
@@ -12,8 +12,15 @@ import (
 	"time"
 
 	. "github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/approval"
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/history"
+	"github.com/mudler/LocalAGI/core/ratelimit"
+	"github.com/mudler/LocalAGI/core/secrets"
 	sseLib "github.com/mudler/LocalAGI/core/sse"
 	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/core/usage"
+	"github.com/mudler/LocalAGI/core/versions"
 	"github.com/mudler/LocalAGI/pkg/localrag"
 	"github.com/mudler/LocalAGI/pkg/utils"
 
@@ -62,11 +69,18 @@ type AgentPool struct {
 	ragProvider                                                   RAGProvider
 	availableActions                                              func(*AgentConfig) func(ctx context.Context, pool *AgentPool) []types.Action
 	connectors                                                    func(*AgentConfig) []Connector
+	agentConnectors                                               map[string][]Connector
 	dynamicPrompt                                                 func(*AgentConfig) func(ctx context.Context, pool *AgentPool) []DynamicPrompt
 	filters                                                       func(*AgentConfig) types.JobFilters
 	timeout                                                       string
 	conversationLogs                                              string
 	skillsService                                                 SkillsProvider
+	approvals                                                     *approval.Manager
+	prices                                                        usage.PriceTable
+	versions                                                      versions.Store
+	secrets                                                       secrets.Store
+	audit                                                         audit.Log
+	rateLimiter                                                   *ratelimit.Limiter
 }
 
 // SetRAGProvider sets the single RAG provider (HTTP or embedded). Must be called after pool creation.
@@ -76,6 +90,14 @@ func (a *AgentPool) SetRAGProvider(fn RAGProvider) {
 	a.ragProvider = fn
 }
 
+// SetPriceTable sets the price of the models, used to estimate the cost of
+// the tokens used by agents started afterwards
+func (a *AgentPool) SetPriceTable(prices usage.PriceTable) {
+	a.Lock()
+	defer a.Unlock()
+	a.prices = prices
+}
+
 type Status struct {
 	ActionResults []types.ActionState
 }
@@ -125,6 +147,14 @@ func NewAgentPool(
 	if withLogs {
 		conversationPath = filepath.Join(directory, "conversations")
 	}
+	versionStore, err := versions.NewJSONStore(filepath.Join(directory, "config-versions.json"))
+	if err != nil {
+		return nil, err
+	}
+	// Versions used to be recorded with their credentials
+	if err := versionStore.Rewrite(redactVersion); err != nil {
+		return nil, err
+	}
 	if _, err := os.Stat(poolfile); err != nil {
 		// file does not exist, create a new pool
 		return &AgentPool{
@@ -141,6 +171,7 @@ func NewAgentPool(
 			pool:                         make(map[string]AgentConfig),
 			agentStatus:                  make(map[string]*Status),
 			managers:                     make(map[string]sseLib.Manager),
+			agentConnectors:              make(map[string][]Connector),
 			connectors:                   connectors,
 			availableActions:             availableActions,
 			dynamicPrompt:                promptBlocks,
@@ -148,6 +179,8 @@ func NewAgentPool(
 			timeout:                      timeout,
 			conversationLogs:             conversationPath,
 			skillsService:                skillsService,
+			approvals:                    approval.NewManager(),
+			versions:                     versionStore,
 		}, nil
 	}
 
@@ -177,6 +210,7 @@ func NewAgentPool(
 		apiKey:                       apiKey,
 		agents:                       make(map[string]*Agent),
 		managers:                     make(map[string]sseLib.Manager),
+		agentConnectors:              make(map[string][]Connector),
 		agentStatus:                  map[string]*Status{},
 		pool:                         *poolData,
 		connectors:                   connectors,
@@ -186,6 +220,8 @@ func NewAgentPool(
 		timeout:                      timeout,
 		conversationLogs:             conversationPath,
 		skillsService:                skillsService,
+		approvals:                    approval.NewManager(),
+		versions:                     versionStore,
 	}, nil
 }
 
@@ -202,10 +238,9 @@ func (a *AgentPool) StartAgentStandalone(name string, agentConfig *AgentConfig)
 	return a.startAgentWithConfig(name, a.pooldir, agentConfig, nil)
 }
 
-// CreateAgent adds a new agent to the pool
-// and starts it.
-// It also saves the state to the file.
-func (a *AgentPool) CreateAgent(name string, agentConfig *AgentConfig) error {
+// CreateAgent adds an agent to the pool and starts it. author is recorded in
+// the history of its configuration.
+func (a *AgentPool) CreateAgent(name string, agentConfig *AgentConfig, author string) error {
 	a.Lock()
 	defer a.Unlock()
 	name = replaceInvalidChars(name)
@@ -213,19 +248,54 @@ func (a *AgentPool) CreateAgent(name string, agentConfig *AgentConfig) error {
 	if _, ok := a.pool[name]; ok {
 		return fmt.Errorf("agent %s already exists", name)
 	}
+	// A redacted export can't be imported as is, there is no agent to take
+	// the credentials from
+	if err := checkRedacted(agentConfig); err != nil {
+		return err
+	}
 	a.pool[name] = *agentConfig
 	if err := a.save(); err != nil {
 		return err
 	}
+	a.recordVersion(name, author, versions.ActionCreate, "", agentConfig)
 
 	return a.startAgentWithConfig(name, a.pooldir, agentConfig, nil)
 }
 
-func (a *AgentPool) RecreateAgent(name string, agentConfig *AgentConfig) error {
+// RecreateAgent restarts an agent with a new configuration. author is
+// recorded in the history of its configuration.
+func (a *AgentPool) RecreateAgent(name string, agentConfig *AgentConfig, author string) error {
 	a.Lock()
 	defer a.Unlock()
 
+	// Credentials read redacted from the API are sent back as they were
+	if old, ok := a.pool[name]; ok {
+		restored, err := restoreRedacted(agentConfig, &old)
+		if err != nil {
+			return err
+		}
+		agentConfig = restored
+	}
+	if err := checkRedacted(agentConfig); err != nil {
+		return err
+	}
+
+	return a.recreateAgent(name, agentConfig, author, versions.ActionUpdate, "")
+}
+
+func (a *AgentPool) recreateAgent(name string, agentConfig *AgentConfig, author, action, note string) error {
 	oldAgent := a.agents[name]
+	var reload *hotReload
+	if old, ok := a.pool[name]; ok {
+		a.recordInitialVersion(name, &old)
+		if oldAgent != nil {
+			var err error
+			if reload, err = planReload(oldAgent, &old, agentConfig); err != nil {
+				xlog.Warn("Failed to compare agent configurations, restarting it", "agent", name, "error", err)
+			}
+		}
+	}
+
 	var o *types.Observable
 	var obs Observer
 	if oldAgent != nil {
@@ -233,18 +303,26 @@ func (a *AgentPool) RecreateAgent(name string, agentConfig *AgentConfig) error {
 		if obs != nil {
 			o = obs.NewObservable()
 			o.Name = "Restarting Agent"
+			if reload != nil {
+				o.Name = "Reloading Agent"
+			}
 			o.Icon = "sync"
 			o.Creation = &types.Creation{}
 			obs.Update(*o)
 		}
-		stateFile, characterFile := a.stateFiles(name)
-		os.Remove(stateFile)
-		os.Remove(characterFile)
-		oldAgent.Stop()
+		if reload == nil {
+			stateFile, characterFile := a.stateFiles(name)
+			os.Remove(stateFile)
+			os.Remove(characterFile)
+			oldAgent.Stop()
+		}
 	}
 
 	a.pool[name] = *agentConfig
-	delete(a.agents, name)
+	if reload == nil {
+		delete(a.agents, name)
+		delete(a.agentConnectors, name)
+	}
 
 	if err := a.save(); err != nil {
 		if obs != nil {
@@ -253,8 +331,9 @@ func (a *AgentPool) RecreateAgent(name string, agentConfig *AgentConfig) error {
 		}
 		return err
 	}
+	a.recordVersion(name, author, action, note, agentConfig)
 
-	if err := a.startAgentWithConfig(name, a.pooldir, agentConfig, obs); err != nil {
+	if err := a.configureAgent(name, a.pooldir, agentConfig, obs, reload); err != nil {
 		if obs != nil {
 			o.Completion = &types.Completion{Error: err.Error()}
 			obs.Update(*o)
@@ -292,6 +371,20 @@ func (a *AgentPool) GetStatusHistory(name string) *Status {
 }
 
 func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConfig, obs Observer) error {
+	return a.configureAgent(name, pooldir, config, obs, nil)
+}
+
+// configureAgent starts an agent with config, or applies config to the
+// running agent when reload is set
+func (a *AgentPool) configureAgent(name, pooldir string, config *AgentConfig, obs Observer, reload *hotReload) error {
+	// The pool and the logs keep the secret:// references, only the agent
+	// gets the values
+	unresolved := config
+	config, err := a.resolveSecrets(config)
+	if err != nil {
+		return err
+	}
+
 	var manager sseLib.Manager
 	if m, ok := a.managers[name]; ok {
 		manager = m
@@ -350,7 +443,10 @@ func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConf
 	effectiveLocalRAGAPI := config.LocalRAGURL
 	effectiveLocalRAGKey := config.LocalRAGAPIKey
 
-	connectors := a.connectors(config)
+	connectors := a.agentConnectors[name]
+	if reload == nil || reload.connectors {
+		connectors = a.connectors(config)
+	}
 	promptBlocks := a.dynamicPrompt(config)(ctx, a)
 	if a.skillsService != nil && config.EnableSkills {
 		if prompt, err := a.skillsService.GetSkillsPrompt(config); err == nil && prompt != nil {
@@ -368,7 +464,8 @@ func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConf
 
 	connectorLog := []string{}
 	for _, connector := range connectors {
-		connectorLog = append(connectorLog, fmt.Sprintf("%+v", connector))
+		// Connectors hold resolved credentials, only their type is logged
+		connectorLog = append(connectorLog, fmt.Sprintf("%T", connector))
 	}
 
 	filtersLog := []string{}
@@ -392,13 +489,19 @@ func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConf
 	// }
 
 	if obs == nil {
-		obs = NewSSEObserver(name, manager)
+		obs = newObserver(name, pooldir, manager)
 	}
 
 	opts := []Option{
-		WithSchedulerStorePath(filepath.Join(pooldir, fmt.Sprintf("scheduler-%s.json", name))),
+		WithSchedulerStorePath(schedulerStoreURI(pooldir, name, config)),
+		WithConversationStorePath(filepath.Join(pooldir, fmt.Sprintf("conversations-%s.json", name))),
+		WithConversationRetention(config.ConversationMaxMessages, config.ConversationMaxThreads),
+		WithUsageStore(filepath.Join(pooldir, fmt.Sprintf("usage-%s.json", name))),
+		WithPriceTable(a.prices),
 		WithModel(model),
 		WithLLMAPIURL(effectiveAPIURL),
+		WithLLMEndpoints(config.LLMEndpoints...),
+		WithLLMLoadBalancing(config.LLMLoadBalance),
 		WithContext(ctx),
 		WithMCPServers(config.MCPServers...),
 		WithTranscriptionModel(transcriptionModel),
@@ -441,16 +544,24 @@ func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConf
 				).WithEvent("status"),
 			)
 
-			for _, c := range connectors {
+			for _, c := range a.runningConnectors(name) {
 				if !c.AgentReasoningCallback()(state) {
 					return false
 				}
 			}
+
+			if state.Action != nil && types.IsActionApprovalRequired(state.Action) {
+				return a.awaitApproval(name, config, manager, state)
+			}
 			return true
 		}),
 		WithSystemPrompt(config.SystemPrompt),
 		WithInnerMonologueTemplate(config.InnerMonologueTemplate),
 		WithSchedulerTaskTemplate(config.SchedulerTaskTemplate),
+		WithSchedulerDeadLetterHandler(deadLetterHandler(name, config, manager)),
+		WithBudget(agentBudget(config)),
+		WithBudgetNotifier(a.budgetNotifier(name, manager)),
+		WithRateLimiter(a.connectorRateLimiter(name)),
 		WithMultimodalModel(multimodalModel),
 		WithLastMessageDuration(config.LastMessageDuration),
 		WithAgentResultCallback(func(state types.ActionState) {
@@ -485,7 +596,9 @@ func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConf
 				).WithEvent("status"),
 			)
 
-			for _, c := range connectors {
+			a.auditAction(name, state)
+
+			for _, c := range a.runningConnectors(name) {
 				c.AgentResultCallback()(state)
 			}
 		}),
@@ -609,6 +722,14 @@ func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConf
 		opts = append(opts, WithCancelPreviousOnNewMessage(true))
 	}
 
+	if config.EnableJobJournal {
+		opts = append(opts,
+			WithJobJournal(filepath.Join(pooldir, fmt.Sprintf("jobs-%s.json", name))),
+			WithInterruptedJobPolicy(config.InterruptedJobPolicy),
+			WithReplayedJobHandler(a.replayedJobHandler(name)),
+		)
+	}
+
 	if config.EnableEvaluation {
 		opts = append(opts, EnableEvaluation())
 	}
@@ -663,7 +784,11 @@ func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConf
 		}
 	}))
 
-	xlog.Info("Starting agent", "name", name, "config", config)
+	if reload != nil {
+		return a.reloadAgent(name, reload, connectors, opts)
+	}
+
+	xlog.Info("Starting agent", "name", name, "config", unresolved)
 
 	agent, err := New(opts...)
 	if err != nil {
@@ -672,6 +797,7 @@ func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConf
 
 	a.agents[name] = agent
 	a.managers[name] = manager
+	a.agentConnectors[name] = connectors
 
 	go func() {
 		if err := agent.Run(); err != nil {
@@ -683,7 +809,7 @@ func (a *AgentPool) startAgentWithConfig(name, pooldir string, config *AgentConf
 		go runCompactionTicker(ctx, compactionClient, config, effectiveAPIURL, effectiveAPIKey, model)
 	}
 
-	xlog.Info("Starting connectors", "name", name, "config", config)
+	xlog.Info("Starting connectors", "name", name, "config", unresolved)
 
 	for _, c := range connectors {
 		go c.Start(agent)
@@ -772,6 +898,11 @@ func (a *AgentPool) CreateOnly(name string) error {
 
 // createAgentWithoutRun is like startAgentWithConfig but skips Run(), connectors, and HUD.
 func (a *AgentPool) createAgentWithoutRun(name, pooldir string, config *AgentConfig) error {
+	config, err := a.resolveSecrets(config)
+	if err != nil {
+		return err
+	}
+
 	var manager sseLib.Manager
 	if m, ok := a.managers[name]; ok {
 		manager = m
@@ -825,12 +956,18 @@ func (a *AgentPool) createAgentWithoutRun(name, pooldir string, config *AgentCon
 	actions := a.availableActions(config)(ctx, a)
 	stateFile, characterFile := a.stateFiles(name)
 
-	obs := NewSSEObserver(name, manager)
+	obs := newObserver(name, pooldir, manager)
 
 	opts := []Option{
-		WithSchedulerStorePath(filepath.Join(pooldir, fmt.Sprintf("scheduler-%s.json", name))),
+		WithSchedulerStorePath(schedulerStoreURI(pooldir, name, config)),
+		WithConversationStorePath(filepath.Join(pooldir, fmt.Sprintf("conversations-%s.json", name))),
+		WithConversationRetention(config.ConversationMaxMessages, config.ConversationMaxThreads),
+		WithUsageStore(filepath.Join(pooldir, fmt.Sprintf("usage-%s.json", name))),
+		WithPriceTable(a.prices),
 		WithModel(model),
 		WithLLMAPIURL(effectiveAPIURL),
+		WithLLMEndpoints(config.LLMEndpoints...),
+		WithLLMLoadBalancing(config.LLMLoadBalance),
 		WithContext(ctx),
 		WithTranscriptionModel(transcriptionModel),
 		WithTranscriptionLanguage(transcriptionLanguage),
@@ -842,6 +979,8 @@ func (a *AgentPool) createAgentWithoutRun(name, pooldir string, config *AgentCon
 		WithCharacterFile(characterFile),
 		WithStateFile(stateFile),
 		WithSystemPrompt(config.SystemPrompt),
+		WithBudget(agentBudget(config)),
+		WithBudgetNotifier(a.budgetNotifier(name, manager)),
 	}
 	if effectiveAPIKey != "" {
 		opts = append(opts, WithLLMAPIKey(effectiveAPIKey))
@@ -864,6 +1003,17 @@ func (a *AgentPool) createAgentWithoutRun(name, pooldir string, config *AgentCon
 	return nil
 }
 
+// newObserver creates the observer of an agent, keeping its observables in
+// the pool directory
+func newObserver(name, pooldir string, manager sseLib.Manager) Observer {
+	store, err := history.NewJSONStore(filepath.Join(pooldir, fmt.Sprintf("observables-%s.jsonl", name)))
+	if err != nil {
+		xlog.Error("Failed to open observable history, keeping it in memory", "agent", name, "error", err)
+		return NewSSEObserver(name, manager)
+	}
+	return NewPersistentSSEObserver(name, manager, store)
+}
+
 func (a *AgentPool) stateFiles(name string) (string, string) {
 	stateFile := filepath.Join(a.pooldir, fmt.Sprintf("%s.state.json", name))
 	characterFile := filepath.Join(a.pooldir, fmt.Sprintf("%s.character.json", name))
@@ -871,6 +1021,19 @@ func (a *AgentPool) stateFiles(name string) (string, string) {
 	return stateFile, characterFile
 }
 
+// schedulerStoreURI returns where the scheduler of an agent keeps its tasks,
+// depending on the storage selected in the agent config
+func schedulerStoreURI(pooldir, name string, config *AgentConfig) string {
+	if config.SchedulerStore == "sqlite" {
+		uri := "sqlite://" + filepath.Join(pooldir, fmt.Sprintf("scheduler-%s.db", name))
+		if config.SchedulerRunHistory > 0 {
+			uri += fmt.Sprintf("?max_runs=%d", config.SchedulerRunHistory)
+		}
+		return uri
+	}
+	return filepath.Join(pooldir, fmt.Sprintf("scheduler-%s.json", name))
+}
+
 func (a *AgentPool) Remove(name string) error {
 	a.Lock()
 	defer a.Unlock()
@@ -879,9 +1042,21 @@ func (a *AgentPool) Remove(name string) error {
 
 	os.Remove(stateFile)
 	os.Remove(characterFile)
+	os.Remove(filepath.Join(a.pooldir, fmt.Sprintf("conversations-%s.json", name)))
+	os.Remove(filepath.Join(a.pooldir, fmt.Sprintf("jobs-%s.json", name)))
+	os.Remove(filepath.Join(a.pooldir, fmt.Sprintf("usage-%s.json", name)))
+	os.Remove(filepath.Join(a.pooldir, fmt.Sprintf("observables-%s.jsonl", name)))
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(filepath.Join(a.pooldir, fmt.Sprintf("scheduler-%s.db%s", name, suffix)))
+	}
+
+	if err := a.versions.Remove(name); err != nil {
+		xlog.Warn("Failed to remove config versions", "agent", name, "error", err)
+	}
 
 	a.stop(name)
 	delete(a.agents, name)
+	delete(a.agentConnectors, name)
 	delete(a.pool, name)
 
 	if err := a.save(); err != nil {
@@ -950,3 +1125,11 @@ func (a *AgentPool) GetManager(name string) sseLib.Manager {
 	return a.managers[name]
 }
 
+// runningConnectors returns the connectors the agent currently runs with.
+// The callbacks of the agent look them up when they are called, so that
+// they reach the new connectors once these are restarted.
+func (a *AgentPool) runningConnectors(name string) []Connector {
+	a.Lock()
+	defer a.Unlock()
+	return a.agentConnectors[name]
+}
@@ -0,0 +1,51 @@
+package state
+
+import (
+	"errors"
+
+	"github.com/mudler/LocalAGI/core/ratelimit"
+	"github.com/mudler/LocalAGI/core/types"
+)
+
+// SetRateLimiter sets the rate limits of the messages connector users send
+// to the agents. Nothing is limited when it is nil.
+func (a *AgentPool) SetRateLimiter(limiter *ratelimit.Limiter) {
+	a.Lock()
+	defer a.Unlock()
+	a.rateLimiter = limiter
+}
+
+// RateLimiter returns the rate limits of the connector users, nil when
+// they are not limited
+func (a *AgentPool) RateLimiter() *ratelimit.Limiter {
+	return a.rateLimiter
+}
+
+// connectorRateLimiter checks the jobs of the connectors against the rate
+// limit of their sender, or of their conversation when the connector does
+// not tell who sent the message. Senders over the limit are told to slow
+// down by the connector the job comes from. Jobs without either, such as
+// the ones of the web UI and the API, are limited by the web server.
+func (a *AgentPool) connectorRateLimiter(name string) func(job *types.Job) error {
+	return func(job *types.Job) error {
+		limiter := a.RateLimiter()
+		if limiter == nil || job.Metadata == nil {
+			return nil
+		}
+		key, _ := job.Metadata[types.MetadataKeySender].(string)
+		if key == "" {
+			key, _ = job.Metadata[types.MetadataKeyConversationID].(string)
+		}
+
+		err := limiter.Allow(name, key)
+		var limitErr *ratelimit.Error
+		if errors.As(err, &limitErr) {
+			for _, c := range a.runningConnectors(name) {
+				if nc, ok := c.(NoticeConnector); ok && nc.Notify(job, limitErr.Message()) {
+					break
+				}
+			}
+		}
+		return err
+	}
+}
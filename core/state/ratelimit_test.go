@@ -0,0 +1,69 @@
+package state_test
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/ratelimit"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// noticeConnector records the notices posted in the conversations
+type noticeConnector struct {
+	mu      sync.Mutex
+	notices map[string][]string
+}
+
+func (n *noticeConnector) AgentResultCallback() func(types.ActionState) {
+	return func(types.ActionState) {}
+}
+
+func (n *noticeConnector) AgentReasoningCallback() func(types.ActionCurrentState) bool {
+	return func(types.ActionCurrentState) bool { return true }
+}
+
+func (n *noticeConnector) Start(*agent.Agent) {}
+
+func (n *noticeConnector) Notify(job *types.Job, message string) bool {
+	conversation, _ := job.Metadata[types.MetadataKeyConversationID].(string)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notices[conversation] = append(n.notices[conversation], message)
+	return true
+}
+
+var _ = Describe("Connector rate limits", func() {
+	It("refuses the messages of a sender over the limit and tells them to slow down", func() {
+		connector := &noticeConnector{notices: map[string][]string{}}
+		pool := newTestPoolWithConnectors(connector)
+		Expect(pool.CreateAgent("limited", &state.AgentConfig{Name: "limited"}, "")).To(Succeed())
+		pool.SetRateLimiter(ratelimit.New(ratelimit.Limits{Agent: ratelimit.Rate{Requests: 1, Per: time.Hour}}))
+
+		// The jobs let through are skipped by the paused agent instead of
+		// waiting for an LLM
+		limited := pool.GetAgent("limited")
+		limited.Pause()
+		ask := func(sender, conversation string) error {
+			return limited.Ask(
+				types.WithText("hello"),
+				types.WithMetadata(map[string]any{
+					types.MetadataKeySender:         sender,
+					types.MetadataKeyConversationID: conversation,
+				}),
+			).Error
+		}
+
+		Expect(ask("discord:1", "discord:general")).NotTo(MatchError(ratelimit.ErrLimited))
+		Expect(ask("discord:1", "discord:random")).To(MatchError(ratelimit.ErrLimited))
+		Expect(ask("discord:2", "discord:general")).NotTo(MatchError(ratelimit.ErrLimited))
+
+		connector.mu.Lock()
+		defer connector.mu.Unlock()
+		Expect(connector.notices).To(HaveLen(1))
+		Expect(connector.notices["discord:random"]).To(ConsistOf(ContainSubstring("sending messages too quickly")))
+	})
+})
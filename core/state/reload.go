@@ -0,0 +1,81 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/versions"
+	"github.com/mudler/xlog"
+)
+
+// liveFields are the configuration fields a running agent takes without
+// being restarted
+var liveFields = map[string]bool{
+	"model":              true,
+	"system_prompt":      true,
+	"dynamic_prompts":    true,
+	"filters":            true,
+	"actions":            true,
+	"kb_results":         true,
+	"mcp_servers":        true,
+	"mcp_stdio_servers":  true,
+	"mcp_prepare_script": true,
+	"connectors":         true,
+}
+
+// hotReload tells how a running agent takes a new configuration
+type hotReload struct {
+	agent *agent.Agent
+	// connectors is true when the connectors have to be restarted
+	connectors bool
+}
+
+// planReload returns how the running agent can take updated instead of
+// old, or nil when it has to be recreated
+func planReload(running *agent.Agent, old, updated *AgentConfig) (*hotReload, error) {
+	from, err := json.Marshal(old)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal agent config: %w", err)
+	}
+	to, err := json.Marshal(updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal agent config: %w", err)
+	}
+	changes, err := versions.Diff(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff agent config: %w", err)
+	}
+
+	reload := &hotReload{agent: running}
+	for _, c := range changes {
+		if !liveFields[c.Field] {
+			return nil, nil
+		}
+		if c.Field == "connectors" {
+			reload.connectors = true
+		}
+	}
+	return reload, nil
+}
+
+// reloadAgent applies opts to the running agent, restarting its connectors
+// if they changed. Its jobs, conversations and state are kept.
+func (a *AgentPool) reloadAgent(name string, reload *hotReload, connectors []Connector, opts []agent.Option) error {
+	xlog.Info("Reloading agent", "name", name, "restart_connectors", reload.connectors)
+
+	if err := reload.agent.Reconfigure(opts...); err != nil {
+		return err
+	}
+
+	if reload.connectors {
+		reload.agent.RestartConnectors()
+		for _, c := range connectors {
+			go c.Start(reload.agent)
+		}
+		a.agentConnectors[name] = connectors
+	}
+
+	xlog.Info("Agent reloaded", "name", name)
+	return nil
+}
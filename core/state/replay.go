@@ -0,0 +1,31 @@
+package state
+
+import (
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/xlog"
+)
+
+// ReplyConnector is implemented by connectors that can post the reply of a
+// job outside of the handler that received the message, using the routing
+// the job metadata carries. Reply returns false if the job does not belong
+// to the connector.
+type ReplyConnector interface {
+	Reply(job *types.Job, response string) bool
+}
+
+// replayedJobHandler posts the reply of the jobs replayed from the journal
+// in the conversation they come from. The connector that received them was
+// restarted and no longer waits for their result.
+func (a *AgentPool) replayedJobHandler(name string) func(job *types.Job) {
+	return func(job *types.Job) {
+		if job.Result.Error != nil || job.Result.Response == "" {
+			return
+		}
+		for _, c := range a.runningConnectors(name) {
+			if rc, ok := c.(ReplyConnector); ok && rc.Reply(job, job.Result.Response) {
+				return
+			}
+		}
+		xlog.Debug("No connector to deliver the reply of a replayed job", "agent", name, "job", job.UUID)
+	}
+}
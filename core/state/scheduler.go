@@ -0,0 +1,67 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/scheduler"
+	sseLib "github.com/mudler/LocalAGI/core/sse"
+	"github.com/mudler/xlog"
+)
+
+const deadLetterWebhookTimeout = 10 * time.Second
+
+// DeadLetterNotification is sent to the web UI and to the dead letter webhook
+// when a scheduled task fails all of its retries
+type DeadLetterNotification struct {
+	Agent string             `json:"agent"`
+	Task  *scheduler.Task    `json:"task"`
+	Run   *scheduler.TaskRun `json:"run"`
+}
+
+// deadLetterHandler notifies the web UI, and the webhook configured for the
+// agent if any, about tasks moved to the dead letter state
+func deadLetterHandler(name string, config *AgentConfig, manager sseLib.Manager) scheduler.DeadLetterHandler {
+	return func(task *scheduler.Task, run *scheduler.TaskRun) {
+		data, err := json.Marshal(DeadLetterNotification{Agent: name, Task: task, Run: run})
+		if err != nil {
+			xlog.Error("Error marshalling dead letter notification", "error", err)
+			return
+		}
+
+		manager.Send(sseLib.NewMessage(string(data)).WithEvent("task_dead_letter"))
+
+		if config.SchedulerDeadLetterWebhook == "" {
+			return
+		}
+		if err := postDeadLetter(config.SchedulerDeadLetterWebhook, data); err != nil {
+			xlog.Error("Failed to notify dead letter webhook", "agent", name, "task_id", task.ID, "error", err)
+		}
+	}
+}
+
+func postDeadLetter(url string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
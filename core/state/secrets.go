@@ -0,0 +1,124 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mudler/LocalAGI/core/secrets"
+	"github.com/mudler/LocalAGI/core/versions"
+)
+
+// SetSecretStore sets the store the secret:// references of the agent
+// configurations are resolved from. Must be called before agents start.
+func (a *AgentPool) SetSecretStore(store secrets.Store) {
+	a.Lock()
+	defer a.Unlock()
+	a.secrets = store
+}
+
+// SecretStore returns the secret store, nil when secrets are disabled
+func (a *AgentPool) SecretStore() secrets.Store {
+	return a.secrets
+}
+
+// resolveSecrets returns config with its secret:// references replaced by
+// the values of the secrets. config is returned as is when it has none.
+func (a *AgentPool) resolveSecrets(config *AgentConfig) (*AgentConfig, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal agent config: %w", err)
+	}
+	if !secrets.HasReferences(data) {
+		return config, nil
+	}
+	if data, err = secrets.ResolveJSON(data, a.secrets); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets of agent %s: %w", config.Name, err)
+	}
+
+	resolved := &AgentConfig{}
+	if err := json.Unmarshal(data, resolved); err != nil {
+		return nil, fmt.Errorf("failed to parse agent config: %w", err)
+	}
+	return resolved, nil
+}
+
+// RedactConfig returns a copy of config with its credentials redacted, to
+// be shown or exported
+func RedactConfig(config *AgentConfig) (*AgentConfig, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal agent config: %w", err)
+	}
+	if data, err = secrets.RedactJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to redact agent config: %w", err)
+	}
+
+	redacted := &AgentConfig{}
+	if err := json.Unmarshal(data, redacted); err != nil {
+		return nil, fmt.Errorf("failed to parse agent config: %w", err)
+	}
+	return redacted, nil
+}
+
+// restoreRedacted returns updated with the credentials that were redacted
+// when it was read taken from old
+func restoreRedacted(updated, old *AgentConfig) (*AgentConfig, error) {
+	updatedData, err := json.Marshal(updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal agent config: %w", err)
+	}
+	oldData, err := json.Marshal(old)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal agent config: %w", err)
+	}
+	data, err := secrets.RestoreJSON(updatedData, oldData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore redacted agent config: %w", err)
+	}
+	if string(data) == string(updatedData) {
+		return updated, nil
+	}
+
+	restored := &AgentConfig{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		return nil, fmt.Errorf("failed to parse agent config: %w", err)
+	}
+	return restored, nil
+}
+
+// checkRedacted fails when config still holds redacted credentials, which
+// would be saved as the literal mask
+func checkRedacted(config *AgentConfig) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal agent config: %w", err)
+	}
+	if err := secrets.CheckRedactedJSON(data); err != nil {
+		return fmt.Errorf("invalid configuration of agent %s: %w", config.Name, err)
+	}
+	return nil
+}
+
+// redactVersion redacts the credentials of the configuration of a version
+// and of its changes
+func redactVersion(v versions.Version) (versions.Version, error) {
+	config, err := secrets.RedactJSON(v.Config)
+	if err != nil {
+		return versions.Version{}, fmt.Errorf("failed to redact version %d: %w", v.Version, err)
+	}
+	v.Config = config
+	v.Diff = redactChanges(v.Diff)
+	return v, nil
+}
+
+func redactChanges(changes []versions.Change) []versions.Change {
+	redacted := make([]versions.Change, len(changes))
+	for i, c := range changes {
+		redacted[i] = versions.Change{
+			Field: c.Field,
+			Old:   secrets.RedactField(c.Field, c.Old),
+			New:   secrets.RedactField(c.Field, c.New),
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,125 @@
+package state_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAGI/core/secrets"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/core/versions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redacted configurations", func() {
+	It("refuses to create an agent from a redacted export", func() {
+		pool := newTestPool()
+		config := &state.AgentConfig{Name: "imported", APIKey: "sk-123"}
+		exported, err := state.RedactConfig(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(pool.CreateAgent("imported", exported, "")).To(MatchError(secrets.ErrRedacted))
+		Expect(pool.GetConfig("imported")).To(BeNil())
+	})
+
+	It("keeps the credentials of an agent updated with its redacted configuration", func() {
+		pool := newTestPool()
+		Expect(pool.CreateAgent("updated", &state.AgentConfig{Name: "updated", APIKey: "sk-123"}, "")).To(Succeed())
+
+		redacted, err := state.RedactConfig(pool.GetConfig("updated"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redacted.APIKey).To(Equal(secrets.Redacted))
+		redacted.SystemPrompt = "You are helpful"
+
+		Expect(pool.RecreateAgent("updated", redacted, "")).To(Succeed())
+		Expect(pool.GetConfig("updated").APIKey).To(Equal("sk-123"))
+		Expect(pool.GetConfig("updated").SystemPrompt).To(Equal("You are helpful"))
+	})
+
+	It("keeps the credentials of the remaining connectors when one is deleted", func() {
+		pool := newTestPool()
+		Expect(pool.CreateAgent("connected", &state.AgentConfig{Name: "connected", Connector: []state.ConnectorConfig{
+			{Type: "telegram", Config: `{"token":"BOT-A","group":"a"}`},
+			{Type: "telegram", Config: `{"token":"BOT-B","group":"b"}`},
+		}}, "")).To(Succeed())
+
+		redacted, err := state.RedactConfig(pool.GetConfig("connected"))
+		Expect(err).NotTo(HaveOccurred())
+		redacted.Connector = redacted.Connector[1:]
+
+		Expect(pool.RecreateAgent("connected", redacted, "")).To(Succeed())
+		Expect(pool.GetConfig("connected").Connector).To(HaveLen(1))
+		Expect(pool.GetConfig("connected").Connector[0].Config).To(MatchJSON(`{"token":"BOT-B","group":"b"}`))
+	})
+
+	It("refuses redacted connectors it can't tell apart", func() {
+		pool := newTestPool()
+		Expect(pool.CreateAgent("twins", &state.AgentConfig{Name: "twins", Connector: []state.ConnectorConfig{
+			{Type: "telegram", Config: `{"token":"BOT-A"}`},
+			{Type: "telegram", Config: `{"token":"BOT-B"}`},
+		}}, "")).To(Succeed())
+
+		redacted, err := state.RedactConfig(pool.GetConfig("twins"))
+		Expect(err).NotTo(HaveOccurred())
+		redacted.Connector = redacted.Connector[1:]
+
+		Expect(pool.RecreateAgent("twins", redacted, "")).To(MatchError(secrets.ErrRedacted))
+		Expect(pool.GetConfig("twins").Connector).To(HaveLen(2))
+	})
+
+	Describe("configuration history", func() {
+		It("keeps no credentials on disk", func() {
+			dir := GinkgoT().TempDir()
+			pool := newTestPoolIn(dir)
+			Expect(pool.CreateAgent("versioned", &state.AgentConfig{Name: "versioned", Model: "a", APIKey: "sk-123"}, "")).To(Succeed())
+			Expect(pool.RecreateAgent("versioned", &state.AgentConfig{Name: "versioned", Model: "b", APIKey: "sk-456"}, "")).To(Succeed())
+
+			path := filepath.Join(dir, "config-versions.json")
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).NotTo(ContainSubstring("sk-"))
+			info, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+
+		It("redacts the credentials recorded before", func() {
+			dir := GinkgoT().TempDir()
+			store, err := versions.NewJSONStore(filepath.Join(dir, "config-versions.json"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = store.Add("old", "", versions.ActionCreate, "", []byte(`{"name":"old","api_key":"sk-123"}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			pool := newTestPoolIn(dir)
+			data, err := os.ReadFile(filepath.Join(dir, "config-versions.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).NotTo(ContainSubstring("sk-123"))
+			v, err := pool.ConfigVersion("old", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v.Config).To(MatchJSON(`{"name":"old","api_key":"********"}`))
+		})
+
+		It("rolls back with the current credentials of the agent", func() {
+			pool := newTestPool()
+			Expect(pool.CreateAgent("rolled", &state.AgentConfig{Name: "rolled", Model: "a", APIKey: "sk-123"}, "")).To(Succeed())
+			Expect(pool.RecreateAgent("rolled", &state.AgentConfig{Name: "rolled", Model: "b", APIKey: "sk-456"}, "")).To(Succeed())
+
+			_, err := pool.RollbackConfig("rolled", 1, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pool.GetConfig("rolled").Model).To(Equal("a"))
+			Expect(pool.GetConfig("rolled").APIKey).To(Equal("sk-456"))
+		})
+
+		It("refuses to roll back to credentials the agent no longer has", func() {
+			pool := newTestPool()
+			Expect(pool.CreateAgent("dropped", &state.AgentConfig{Name: "dropped", Connector: []state.ConnectorConfig{
+				{Type: "telegram", Config: `{"token":"BOT-A"}`},
+			}}, "")).To(Succeed())
+			Expect(pool.RecreateAgent("dropped", &state.AgentConfig{Name: "dropped"}, "")).To(Succeed())
+
+			_, err := pool.RollbackConfig("dropped", 1, "")
+			Expect(err).To(MatchError(secrets.ErrRedacted))
+			Expect(pool.GetConfig("dropped").Connector).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,127 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mudler/LocalAGI/core/secrets"
+	"github.com/mudler/LocalAGI/core/versions"
+	"github.com/mudler/xlog"
+)
+
+// recordVersion adds config to the history of the agent, with credentials
+// redacted so that the history never keeps them. The change is already
+// saved in the pool, so failing to record it is only logged.
+func (a *AgentPool) recordVersion(name, author, action, note string, config *AgentConfig) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		xlog.Error("Failed to marshal agent config version", "agent", name, "error", err)
+		return
+	}
+	if data, err = secrets.RedactJSON(data); err != nil {
+		xlog.Error("Failed to redact agent config version", "agent", name, "error", err)
+		return
+	}
+	if _, err := a.versions.Add(name, author, action, note, data); err != nil {
+		xlog.Error("Failed to record agent config version", "agent", name, "error", err)
+	}
+}
+
+// recordInitialVersion records the configuration of agents created before
+// their history was kept, so that their first change can be rolled back
+func (a *AgentPool) recordInitialVersion(name string, config *AgentConfig) {
+	history, err := a.versions.List(name)
+	if err != nil || len(history) > 0 {
+		return
+	}
+	a.recordVersion(name, "", versions.ActionInitial, "", config)
+}
+
+// ConfigVersions returns the history of the configuration of an agent,
+// oldest first, with credentials redacted
+func (a *AgentPool) ConfigVersions(name string) ([]versions.Version, error) {
+	history, err := a.versions.List(name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range history {
+		if history[i], err = redactVersion(history[i]); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+// ConfigVersion returns a version of the configuration of an agent, with
+// credentials redacted
+func (a *AgentPool) ConfigVersion(name string, version int) (versions.Version, error) {
+	v, err := a.versions.Get(name, version)
+	if err != nil {
+		return versions.Version{}, err
+	}
+	return redactVersion(v)
+}
+
+// DiffConfigVersions returns the changes between two versions of the
+// configuration of an agent, with credentials redacted
+func (a *AgentPool) DiffConfigVersions(name string, from, to int) ([]versions.Change, error) {
+	fromVersion, err := a.versions.Get(name, from)
+	if err != nil {
+		return nil, fmt.Errorf("version %d: %w", from, err)
+	}
+	toVersion, err := a.versions.Get(name, to)
+	if err != nil {
+		return nil, fmt.Errorf("version %d: %w", to, err)
+	}
+	changes, err := versions.Diff(fromVersion.Config, toVersion.Config)
+	if err != nil {
+		return nil, err
+	}
+	return redactChanges(changes), nil
+}
+
+// RollbackConfig applies a previous version of its configuration to an
+// agent, recorded as a new version. The history holds no credentials, so
+// the version takes the current ones of the agent: it fails with
+// secrets.ErrRedacted when the agent no longer has one of them, which has
+// to be set again by updating the agent with the version instead.
+func (a *AgentPool) RollbackConfig(name string, version int, author string) (versions.Version, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if _, ok := a.pool[name]; !ok {
+		return versions.Version{}, fmt.Errorf("agent %s not found", name)
+	}
+	v, err := a.versions.Get(name, version)
+	if err != nil {
+		return versions.Version{}, err
+	}
+
+	var config AgentConfig
+	if err := json.Unmarshal(v.Config, &config); err != nil {
+		return versions.Version{}, fmt.Errorf("failed to parse version %d: %w", version, err)
+	}
+	config.Name = name
+
+	current := a.pool[name]
+	restored, err := restoreRedacted(&config, &current)
+	if err != nil {
+		return versions.Version{}, err
+	}
+	if err := checkRedacted(restored); err != nil {
+		return versions.Version{}, fmt.Errorf("version %d needs credentials the agent no longer has: %w", version, err)
+	}
+
+	if err := a.recreateAgent(name, restored, author, versions.ActionRollback, fmt.Sprintf("rollback to version %d", version)); err != nil {
+		return versions.Version{}, err
+	}
+
+	history, err := a.versions.List(name)
+	if err != nil {
+		return versions.Version{}, err
+	}
+	if len(history) == 0 {
+		return versions.Version{}, versions.ErrNotFound
+	}
+	return redactVersion(history[len(history)-1])
+}
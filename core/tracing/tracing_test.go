@@ -0,0 +1,64 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mudler/LocalAGI/core/tracing"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type failingAction struct{}
+
+func (failingAction) Run(context.Context, *types.AgentSharedState, types.ActionParams) (types.ActionResult, error) {
+	return types.ActionResult{}, errors.New("boom")
+}
+
+func (failingAction) Definition() types.ActionDefinition {
+	return types.ActionDefinition{Name: "fail"}
+}
+
+var _ = Describe("Tracing", func() {
+	var recorder *tracetest.SpanRecorder
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		previous := otel.GetTracerProvider()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		DeferCleanup(func() { otel.SetTracerProvider(previous) })
+	})
+
+	It("should mark spans ended with an error as failed", func() {
+		_, span := tracing.Tracer().Start(context.Background(), "ok")
+		tracing.End(span, nil)
+		_, span = tracing.Tracer().Start(context.Background(), "failed")
+		tracing.End(span, errors.New("boom"))
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Status().Code).To(Equal(codes.Unset))
+		Expect(spans[1].Status().Code).To(Equal(codes.Error))
+		Expect(spans[1].Events()).To(HaveLen(1))
+	})
+
+	It("should record actions as children of the job span", func() {
+		ctx, job := tracing.Tracer().Start(context.Background(), "agent.job")
+		tools := types.Actions{failingAction{}}.ToCogitoTools(ctx, &types.AgentSharedState{AgentName: "test"})
+		_, _, err := tools[0].Execute(map[string]any{})
+		Expect(err).To(HaveOccurred())
+		job.End()
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+		action := spans[0]
+		Expect(action.Name()).To(Equal("action.run"))
+		Expect(action.Parent().SpanID()).To(Equal(job.SpanContext().SpanID()))
+		Expect(action.Status().Code).To(Equal(codes.Error))
+	})
+})
@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/mudler/LocalAGI/core/metrics"
+	"github.com/mudler/LocalAGI/core/tracing"
 	"github.com/mudler/cogito"
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ActionContext struct {
@@ -107,8 +111,21 @@ func (c *cogitoWrapper) Execute(args map[string]any) (string, any, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	agentName := ""
+	if c.sharedState != nil {
+		agentName = c.sharedState.AgentName
+	}
+	actionName := c.action.Definition().Name.String()
+	metrics.ActionInvocations.WithLabelValues(agentName, actionName).Inc()
+
+	ctx, span := tracing.Tracer().Start(ctx, "action.run", trace.WithAttributes(
+		attribute.String("agent", agentName),
+		attribute.String("action", actionName),
+	))
 	result, err := c.action.Run(ctx, c.sharedState, ActionParams(args))
+	tracing.End(span, err)
 	if err != nil {
+		metrics.ActionErrors.WithLabelValues(agentName, actionName).Inc()
 		return "", nil, err
 	}
 
@@ -126,6 +143,84 @@ type UserDefinedChecker interface {
 	IsUserDefined() bool
 }
 
+// ApprovalChecker interface to identify actions that need to be approved
+// by a human before running
+type ApprovalChecker interface {
+	RequiresApproval() bool
+}
+
+type approvalAction struct {
+	Action
+}
+
+func (a *approvalAction) RequiresApproval() bool {
+	return true
+}
+
+func (a *approvalAction) Unwrap() Action {
+	return a.Action
+}
+
+// WithApproval wraps an action so that it requires human approval before running
+func WithApproval(action Action) Action {
+	return &approvalAction{Action: action}
+}
+
+// IsActionApprovalRequired checks if an action needs human approval before running
+func IsActionApprovalRequired(action Action) bool {
+	return checkWrapped(action, func(a Action) bool {
+		checker, ok := a.(ApprovalChecker)
+		return ok && checker.RequiresApproval()
+	})
+}
+
+// SideEffectChecker interface to identify actions changing something outside
+// of LocalAGI, like running commands or sending messages
+type SideEffectChecker interface {
+	HasSideEffects() bool
+}
+
+type sideEffectAction struct {
+	Action
+}
+
+func (a *sideEffectAction) HasSideEffects() bool {
+	return true
+}
+
+func (a *sideEffectAction) Unwrap() Action {
+	return a.Action
+}
+
+// WithSideEffects wraps an action to mark that it has side effects
+func WithSideEffects(action Action) Action {
+	return &sideEffectAction{Action: action}
+}
+
+// IsActionWithSideEffects checks if an action changes something outside of LocalAGI
+func IsActionWithSideEffects(action Action) bool {
+	return checkWrapped(action, func(a Action) bool {
+		checker, ok := a.(SideEffectChecker)
+		return ok && checker.HasSideEffects()
+	})
+}
+
+// checkWrapped reports whether check holds for the action or any action it
+// wraps
+func checkWrapped(action Action, check func(Action) bool) bool {
+	for action != nil {
+		if check(action) {
+			return true
+		}
+		wrapper, ok := action.(interface{ Unwrap() Action })
+		if !ok {
+			return false
+		}
+		action = wrapper.Unwrap()
+	}
+	return false
+}
+
 // BaseAction provides default implementation for Action interface
 // Embed this in action implementations to get the default IsUserDefined behavior
 type BaseAction struct{}
@@ -3,6 +3,7 @@ package types
 import (
 	"context"
 	"log"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/mudler/cogito"
@@ -14,6 +15,16 @@ import (
 // currently running job for that conversation before enqueueing a new one.
 const MetadataKeyConversationID = "conversation_id"
 
+// MetadataKeyKeepPrevious is the job metadata key that, set to true, lets a job run
+// next to the running job of its conversation instead of cancelling it. The web UI
+// and the API set it: their conversation ID is only used to account and audit jobs.
+const MetadataKeyKeepPrevious = "keep_previous"
+
+// MetadataKeySender is the job metadata key for the connector user who sent the
+// message (e.g. "slack:USER_ID"). Connector rate limits are kept per sender, or per
+// conversation when it is not set.
+const MetadataKeySender = "sender"
+
 // Job is a request to the agent to do something
 type Job struct {
 	// The job is a request to the agent to do something
@@ -22,6 +33,7 @@ type Job struct {
 	Result              *JobResult
 	ReasoningCallback   func(ActionCurrentState) bool
 	ResultCallback      func(ActionState)
+	StreamCallback      func(cogito.StreamEvent)
 	ConversationHistory []openai.ChatCompletionMessage
 	UUID                string
 	Metadata            map[string]interface{}
@@ -32,6 +44,8 @@ type Job struct {
 	UserTools    []ActionDefinition // User-defined function tools
 	ToolChoice   string
 
+	// mu guards context, which SetContext may replace while the job runs
+	mu       sync.Mutex
 	context  context.Context
 	fragment *cogito.Fragment
 	cancel   context.CancelFunc
@@ -82,6 +96,14 @@ func WithResultCallback(f func(ActionState)) JobOption {
 	}
 }
 
+// WithStreamCallback registers a callback receiving the streaming events
+// (reasoning, content, tool calls) produced while the job is processed.
+func WithStreamCallback(f func(cogito.StreamEvent)) JobOption {
+	return func(j *Job) {
+		j.StreamCallback = f
+	}
+}
+
 func WithMetadata(metadata map[string]any) JobOption {
 	return func(j *Job) {
 		j.Metadata = metadata
@@ -193,9 +215,19 @@ func (j *Job) Cancel() {
 }
 
 func (j *Job) GetContext() context.Context {
+	j.mu.Lock()
+	defer j.mu.Unlock()
 	return j.context
 }
 
+// SetContext replaces the context of the job, e.g. to carry a trace span.
+// ctx must derive from GetContext so that cancelling the job still applies.
+func (j *Job) SetContext(ctx context.Context) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.context = ctx
+}
+
 func WithObservable(obs *Observable) JobOption {
 	return func(j *Job) {
 		j.Obs = obs
@@ -26,6 +26,7 @@ type Completion struct {
 	ActionResult           string                         `json:"action_result,omitempty"`
 	AgentState             *AgentInternalState            `json:"agent_state,omitempty"`
 	FilterResult           *FilterResult                  `json:"filter_result,omitempty"`
+	Usage                  *Usage                         `json:"usage,omitempty"`
 }
 
 type Observable struct {
@@ -34,6 +35,8 @@ type Observable struct {
 	Agent    string `json:"agent"`
 	Name     string `json:"name"`
 	Icon     string `json:"icon"`
+	// ConversationID is set on the observable of jobs run for a conversation
+	ConversationID string `json:"conversation_id,omitempty"`
 
 	Creation   *Creation   `json:"creation,omitempty"`
 	Progress   []Progress  `json:"progress,omitempty"`
@@ -20,7 +20,9 @@ type JobResult struct {
 
 	Response string
 	Error    error
-	ready    chan bool
+	// Usage is the token usage of the LLM calls made by the job
+	Usage Usage
+	ready chan bool
 }
 
 // SetResult sets the result of a job
@@ -45,6 +47,22 @@ func (j *JobResult) Finish(e error) {
 	j.Finalizers = []func([]openai.ChatCompletionMessage){}
 }
 
+// AddUsage accumulates the token usage of an LLM call made by the job
+func (j *JobResult) AddUsage(u Usage) {
+	j.Lock()
+	defer j.Unlock()
+
+	j.Usage.Add(u)
+}
+
+// GetUsage returns the token usage of the job so far
+func (j *JobResult) GetUsage() Usage {
+	j.Lock()
+	defer j.Unlock()
+
+	return j.Usage
+}
+
 // AddFinalizer adds a finalizer to the job result
 func (j *JobResult) AddFinalizer(f func([]openai.ChatCompletionMessage)) {
 	j.Lock()
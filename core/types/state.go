@@ -42,14 +42,29 @@ const (
 
 // RecurringReminderParams are the parameters the LLM provides for set_recurring_reminder.
 type RecurringReminderParams struct {
-	Message  string `json:"message"`
-	CronExpr string `json:"cron_expr"`
+	Message    string `json:"message"`
+	CronExpr   string `json:"cron_expr"`
+	Timezone   string `json:"timezone,omitempty"` // IANA timezone, e.g. "Europe/Rome"
+	MaxRetries int    `json:"max_retries,omitempty"`
+	// Misfire is what to do with runs missed while the agent was down: skip, run_once or run_all
+	Misfire     string `json:"misfire,omitempty"`
+	MaxLateness string `json:"max_lateness,omitempty"` // e.g. "2h", later runs are skipped
 }
 
 // OneTimeReminderParams are the parameters the LLM provides for set_onetime_reminder.
 type OneTimeReminderParams struct {
-	Message string `json:"message"`
-	Delay   string `json:"delay"` // Go duration format with day support: "30m", "2h", "1d", "1d12h"
+	Message    string `json:"message"`
+	Delay      string `json:"delay"` // Go duration format with day support: "30m", "2h", "1d", "1d12h"
+	MaxRetries int    `json:"max_retries,omitempty"`
+}
+
+// TriggeredReminderParams represents parameters for a task that runs after another task
+type TriggeredReminderParams struct {
+	Message     string `json:"message"`
+	AfterTaskID string `json:"after_task_id"`
+	On          string `json:"on"`              // on_success, on_failure or on_complete
+	Match       string `json:"match,omitempty"` // optional regexp the upstream output must match
+	MaxRetries  int    `json:"max_retries,omitempty"`
 }
 
 // ReminderActionResponse is kept for backward compatibility.
@@ -62,12 +77,12 @@ type AgentSharedState struct {
 	AgentName           string                                     `json:"agent_name"`
 }
 
-func NewAgentSharedState(lastMessageDuration time.Duration) *AgentSharedState {
+func NewAgentSharedState(lastMessageDuration time.Duration, opts ...conversations.TrackerOption[string]) *AgentSharedState {
 	if lastMessageDuration == 0 {
 		lastMessageDuration = DefaultLastMessageDuration
 	}
 	return &AgentSharedState{
-		ConversationTracker: conversations.NewConversationTracker[string](lastMessageDuration),
+		ConversationTracker: conversations.NewConversationTracker[string](lastMessageDuration, opts...),
 	}
 }
 
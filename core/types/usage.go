@@ -0,0 +1,39 @@
+package types
+
+import "context"
+
+// Usage is the token usage of one or more LLM calls
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	Calls            int `json:"calls"`
+	// Cost is the estimated cost of the tokens, zero when the model has no price
+	Cost float64 `json:"cost,omitempty"`
+}
+
+// Add accumulates another usage into u
+func (u *Usage) Add(o Usage) {
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.TotalTokens += o.TotalTokens
+	u.Calls += o.Calls
+	u.Cost += o.Cost
+}
+
+type usageReporterKey struct{}
+
+// WithUsageReporter returns a copy of ctx carrying report. LLM calls that do
+// not go through the agent's LLM, such as the ones of job filters, account
+// their tokens to the job with ReportUsage.
+func WithUsageReporter(ctx context.Context, report func(model string, usage Usage)) context.Context {
+	return context.WithValue(ctx, usageReporterKey{}, report)
+}
+
+// ReportUsage accounts the usage of an LLM call made with model to the job
+// ctx belongs to. It does nothing outside of a job.
+func ReportUsage(ctx context.Context, model string, usage Usage) {
+	if report, ok := ctx.Value(usageReporterKey{}).(func(string, Usage)); ok {
+		report(model, usage)
+	}
+}
@@ -0,0 +1,81 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/types"
+)
+
+// BudgetAction defines what happens to new jobs once a budget is exhausted
+type BudgetAction string
+
+const (
+	// BudgetRefuse fails new jobs until the budget period ends (default)
+	BudgetRefuse BudgetAction = "refuse"
+	// BudgetDowngrade runs new jobs with the fallback model
+	BudgetDowngrade BudgetAction = "downgrade"
+)
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Budget limits the tokens and the cost an agent can spend. Zero values
+// mean no limit.
+type Budget struct {
+	DailyTokens   int
+	MonthlyTokens int
+	DailyCost     float64
+	MonthlyCost   float64
+	// MaxJobTokens stops a single job once it used this many tokens
+	MaxJobTokens  int
+	Action        BudgetAction
+	FallbackModel string
+}
+
+// Enabled tells whether any limit is set
+func (b Budget) Enabled() bool {
+	return b.DailyTokens > 0 || b.MonthlyTokens > 0 || b.DailyCost > 0 || b.MonthlyCost > 0 || b.MaxJobTokens > 0
+}
+
+// Downgrades tells whether exhausted budgets switch to the fallback model
+// instead of refusing jobs
+func (b Budget) Downgrades() bool {
+	return b.Action == BudgetDowngrade && b.FallbackModel != ""
+}
+
+// Check returns an error wrapping ErrBudgetExceeded if the usage of the
+// current day or month reached a limit
+func (b Budget) Check(day, month types.Usage) error {
+	switch {
+	case b.DailyTokens > 0 && day.TotalTokens >= b.DailyTokens:
+		return fmt.Errorf("%w: daily limit of %d tokens reached", ErrBudgetExceeded, b.DailyTokens)
+	case b.MonthlyTokens > 0 && month.TotalTokens >= b.MonthlyTokens:
+		return fmt.Errorf("%w: monthly limit of %d tokens reached", ErrBudgetExceeded, b.MonthlyTokens)
+	case b.DailyCost > 0 && day.Cost >= b.DailyCost:
+		return fmt.Errorf("%w: daily spend limit of %.2f reached", ErrBudgetExceeded, b.DailyCost)
+	case b.MonthlyCost > 0 && month.Cost >= b.MonthlyCost:
+		return fmt.Errorf("%w: monthly spend limit of %.2f reached", ErrBudgetExceeded, b.MonthlyCost)
+	}
+	return nil
+}
+
+// CheckJob returns an error wrapping ErrBudgetExceeded if a job used more
+// tokens than allowed
+func (b Budget) CheckJob(job types.Usage) error {
+	if b.MaxJobTokens > 0 && job.TotalTokens >= b.MaxJobTokens {
+		return fmt.Errorf("%w: job limit of %d tokens reached", ErrBudgetExceeded, b.MaxJobTokens)
+	}
+	return nil
+}
+
+// DayStart returns the beginning of the budget day t falls in
+func DayStart(t time.Time) time.Time {
+	return GranularityDay.Truncate(t)
+}
+
+// MonthStart returns the beginning of the budget month t falls in
+func MonthStart(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
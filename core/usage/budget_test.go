@@ -0,0 +1,58 @@
+package usage_test
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/core/usage"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Budget", func() {
+	It("should be disabled without limits", func() {
+		Expect(usage.Budget{}.Enabled()).To(BeFalse())
+		Expect(usage.Budget{MaxJobTokens: 10}.Enabled()).To(BeTrue())
+	})
+
+	It("should only downgrade with a fallback model", func() {
+		Expect(usage.Budget{Action: usage.BudgetDowngrade}.Downgrades()).To(BeFalse())
+		Expect(usage.Budget{Action: usage.BudgetDowngrade, FallbackModel: "small"}.Downgrades()).To(BeTrue())
+	})
+
+	It("should report the exhausted daily and monthly limits", func() {
+		budget := usage.Budget{DailyTokens: 100, MonthlyTokens: 1000, MonthlyCost: 5}
+
+		Expect(budget.Check(types.Usage{TotalTokens: 99}, types.Usage{TotalTokens: 999})).To(Succeed())
+		Expect(budget.Check(types.Usage{TotalTokens: 100}, types.Usage{TotalTokens: 100})).To(
+			And(MatchError(usage.ErrBudgetExceeded), MatchError(ContainSubstring("daily limit of 100 tokens"))))
+		Expect(budget.Check(types.Usage{}, types.Usage{TotalTokens: 1000})).To(MatchError(ContainSubstring("monthly limit")))
+		Expect(budget.Check(types.Usage{}, types.Usage{Cost: 5.5})).To(MatchError(ContainSubstring("monthly spend limit")))
+	})
+
+	It("should cap the tokens of a single job", func() {
+		budget := usage.Budget{MaxJobTokens: 500}
+		Expect(budget.CheckJob(types.Usage{TotalTokens: 499})).To(Succeed())
+		Expect(budget.CheckJob(types.Usage{TotalTokens: 500})).To(MatchError(usage.ErrBudgetExceeded))
+	})
+
+	It("should sum the usage of the current period", func() {
+		store, err := usage.NewJSONStore(filepath.Join(GinkgoT().TempDir(), "usage.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		now := time.Now()
+		Expect(store.Record(
+			usage.Record{Time: now.Add(-48 * time.Hour), Model: "big", Usage: types.Usage{TotalTokens: 1000}},
+			usage.Record{Time: now, Model: "big", Usage: types.Usage{TotalTokens: 10}},
+		)).To(Succeed())
+
+		day, err := store.Since(usage.DayStart(now))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(day.TotalTokens).To(Equal(10))
+
+		all, err := store.Since(now.Add(-72 * time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(all.TotalTokens).To(Equal(1010))
+	})
+})
@@ -0,0 +1,230 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/pkg/atomicfile"
+)
+
+// DefaultRetention is how long buckets and idle conversation counters are
+// kept
+const DefaultRetention = 90 * 24 * time.Hour
+
+// bucketSize is the time span of the stored buckets. Time zone offsets are
+// multiples of 15 minutes, so local days start on a bucket in any zone.
+const bucketSize = 15 * time.Minute
+
+// jsonData is the on-disk layout of a JSONStore
+type jsonData struct {
+	Buckets       []*Bucket           `json:"buckets"`
+	Conversations map[string]*Counter `json:"conversations"`
+	Tasks         map[string]*Counter `json:"tasks"`
+}
+
+// JSONStore implements Store using JSON file storage, aggregating usage in
+// buckets of 15 minutes
+type JSONStore struct {
+	filePath  string
+	retention time.Duration
+	mu        sync.Mutex
+	data      jsonData
+}
+
+// NewJSONStore creates a new JSON-based usage store
+func NewJSONStore(filePath string) (*JSONStore, error) {
+	s := &JSONStore{
+		filePath:  filePath,
+		retention: DefaultRetention,
+		data: jsonData{
+			Conversations: make(map[string]*Counter),
+			Tasks:         make(map[string]*Counter),
+		},
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read usage: %w", err)
+		}
+		return s, nil
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.data); err != nil {
+			return nil, fmt.Errorf("failed to parse usage: %w", err)
+		}
+		if s.data.Conversations == nil {
+			s.data.Conversations = make(map[string]*Counter)
+		}
+		if s.data.Tasks == nil {
+			s.data.Tasks = make(map[string]*Counter)
+		}
+	}
+
+	return s, nil
+}
+
+// Record adds the usage of a job to the counters
+func (s *JSONStore) Record(records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range records {
+		if r.Time.IsZero() {
+			r.Time = time.Now()
+		}
+		s.bucket(r.Time).add(r.Model, r.Source, r.Usage)
+		if r.ConversationID != "" {
+			addToCounter(s.data.Conversations, r.ConversationID, r.Usage, r.Time)
+		}
+		if r.TaskID != "" {
+			addToCounter(s.data.Tasks, r.TaskID, r.Usage, r.Time)
+		}
+	}
+
+	return s.save()
+}
+
+// Report aggregates the buckets in the range of the query
+func (s *JSONStore) Report(q Query) (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q.Granularity == "" {
+		q.Granularity = GranularityHour
+	}
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+
+	report := &Report{
+		From:          q.From,
+		To:            q.To,
+		Granularity:   q.Granularity,
+		Models:        make(map[string]types.Usage),
+		Sources:       make(map[string]types.Usage),
+		Buckets:       make([]Bucket, 0),
+		Conversations: make(map[string]Counter, len(s.data.Conversations)),
+		Tasks:         make(map[string]Counter, len(s.data.Tasks)),
+	}
+
+	for _, stored := range s.data.Buckets {
+		if stored.Start.Before(q.From.UTC().Truncate(bucketSize)) || stored.Start.After(q.To) {
+			continue
+		}
+
+		start := q.Granularity.Truncate(stored.Start.In(q.To.Location()))
+		if len(report.Buckets) == 0 || !report.Buckets[len(report.Buckets)-1].Start.Equal(start) {
+			report.Buckets = append(report.Buckets, Bucket{Start: start})
+		}
+		b := &report.Buckets[len(report.Buckets)-1]
+		b.merge(stored)
+		report.Total.Add(stored.Usage)
+		for model, u := range stored.Models {
+			report.Models = mergeUsage(report.Models, model, u)
+		}
+		for source, u := range stored.Sources {
+			report.Sources = mergeUsage(report.Sources, source, u)
+		}
+	}
+
+	for id, c := range s.data.Conversations {
+		report.Conversations[id] = *c
+	}
+	for id, c := range s.data.Tasks {
+		report.Tasks[id] = *c
+	}
+
+	return report, nil
+}
+
+// Since returns the usage recorded from the 15 minutes t falls in onward
+func (s *JSONStore) Since(t time.Time) (types.Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total types.Usage
+	start := t.UTC().Truncate(bucketSize)
+	i := sort.Search(len(s.data.Buckets), func(i int) bool {
+		return !s.data.Buckets[i].Start.Before(start)
+	})
+	for _, b := range s.data.Buckets[i:] {
+		total.Add(b.Usage)
+	}
+	return total, nil
+}
+
+// Close closes the store
+func (s *JSONStore) Close() error {
+	return nil
+}
+
+// bucket returns the bucket t falls in, creating it if needed (must be called with lock held)
+func (s *JSONStore) bucket(t time.Time) *Bucket {
+	start := t.UTC().Truncate(bucketSize)
+	i := sort.Search(len(s.data.Buckets), func(i int) bool {
+		return !s.data.Buckets[i].Start.Before(start)
+	})
+	if i < len(s.data.Buckets) && s.data.Buckets[i].Start.Equal(start) {
+		return s.data.Buckets[i]
+	}
+
+	b := &Bucket{Start: start}
+	s.data.Buckets = append(s.data.Buckets, nil)
+	copy(s.data.Buckets[i+1:], s.data.Buckets[i:])
+	s.data.Buckets[i] = b
+	return b
+}
+
+// save prunes expired buckets and counters and writes the store to disk (must be called with lock held)
+func (s *JSONStore) save() error {
+	cutoff := time.Now().Add(-s.retention)
+
+	i := sort.Search(len(s.data.Buckets), func(i int) bool {
+		return !s.data.Buckets[i].Start.Before(cutoff)
+	})
+	s.data.Buckets = s.data.Buckets[i:]
+	for id, c := range s.data.Conversations {
+		if c.LastUsed.Before(cutoff) {
+			delete(s.data.Conversations, id)
+		}
+	}
+	for id, c := range s.data.Tasks {
+		if c.LastUsed.Before(cutoff) {
+			delete(s.data.Tasks, id)
+		}
+	}
+
+	if err := atomicfile.WriteJSON(s.filePath, s.data, 0644); err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	return nil
+}
+
+func addToCounter(counters map[string]*Counter, id string, u types.Usage, t time.Time) {
+	c, ok := counters[id]
+	if !ok {
+		c = &Counter{}
+		counters[id] = c
+	}
+	c.Add(u)
+	if t.After(c.LastUsed) {
+		c.LastUsed = t
+	}
+}
+
+func mergeUsage(m map[string]types.Usage, key string, u types.Usage) map[string]types.Usage {
+	if m == nil {
+		m = make(map[string]types.Usage)
+	}
+	v := m[key]
+	v.Add(u)
+	m[key] = v
+	return m
+}
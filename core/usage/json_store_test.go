@@ -0,0 +1,145 @@
+package usage_test
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/core/usage"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSONStore", func() {
+	var (
+		path  string
+		store *usage.JSONStore
+		now   time.Time
+	)
+
+	tokens := func(prompt, completion int) types.Usage {
+		return types.Usage{
+			PromptTokens:     prompt,
+			CompletionTokens: completion,
+			TotalTokens:      prompt + completion,
+			Calls:            1,
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		path = filepath.Join(GinkgoT().TempDir(), "usage.json")
+		store, err = usage.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+		now = time.Now().UTC().Truncate(24 * time.Hour).Add(12 * time.Hour)
+	})
+
+	It("should break usage down by hour, model and source", func() {
+		Expect(store.Record(
+			usage.Record{Time: now, Model: "big", Source: "slack", Usage: tokens(100, 10)},
+			usage.Record{Time: now.Add(10 * time.Minute), Model: "small", Source: usage.SourceScheduler, Usage: tokens(50, 5)},
+			usage.Record{Time: now.Add(2 * time.Hour), Model: "big", Source: "slack", Usage: tokens(20, 2)},
+		)).To(Succeed())
+
+		report, err := store.Report(usage.Query{From: now.Add(-time.Hour), To: now.Add(3 * time.Hour)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Total.TotalTokens).To(Equal(187))
+		Expect(report.Total.Calls).To(Equal(3))
+		Expect(report.Models["big"].PromptTokens).To(Equal(120))
+		Expect(report.Sources[usage.SourceScheduler].TotalTokens).To(Equal(55))
+		Expect(report.Buckets).To(HaveLen(2))
+		Expect(report.Buckets[0].Usage.TotalTokens).To(Equal(165))
+		Expect(report.Buckets[1].Usage.TotalTokens).To(Equal(22))
+	})
+
+	It("should merge hours into daily buckets and honour the range", func() {
+		Expect(store.Record(
+			usage.Record{Time: now.Add(-48 * time.Hour), Model: "big", Usage: tokens(1, 1)},
+			usage.Record{Time: now, Model: "big", Usage: tokens(10, 0)},
+			usage.Record{Time: now.Add(3 * time.Hour), Model: "big", Usage: tokens(10, 0)},
+		)).To(Succeed())
+
+		report, err := store.Report(usage.Query{
+			From:        now.Add(-time.Hour),
+			To:          now.Add(4 * time.Hour),
+			Granularity: usage.GranularityDay,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Buckets).To(HaveLen(1))
+		Expect(report.Buckets[0].Start).To(Equal(now.Truncate(24 * time.Hour)))
+		Expect(report.Buckets[0].Usage.TotalTokens).To(Equal(20))
+		Expect(report.Total.Calls).To(Equal(2))
+	})
+
+	It("should count usage from the start of the day in half-hour time zones", func() {
+		india := time.FixedZone("IST", 5*3600+1800)
+		midnight := usage.DayStart(time.Now().In(india))
+		Expect(store.Record(
+			usage.Record{Time: midnight.Add(-10 * time.Minute), Model: "big", Usage: tokens(100, 0)},
+			usage.Record{Time: midnight.Add(10 * time.Minute), Model: "big", Usage: tokens(1, 0)},
+		)).To(Succeed())
+
+		total, err := store.Since(usage.DayStart(midnight.Add(time.Hour)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total.TotalTokens).To(Equal(1))
+	})
+
+	It("should report daily usage from the start of the day in half-hour time zones", func() {
+		india := time.FixedZone("IST", 5*3600+1800)
+		midnight := usage.DayStart(now.In(india))
+		Expect(store.Record(
+			usage.Record{Time: midnight.Add(-10 * time.Minute), Model: "big", Usage: tokens(100, 0)},
+			usage.Record{Time: midnight.Add(10 * time.Minute), Model: "big", Usage: tokens(1, 0)},
+		)).To(Succeed())
+
+		report, err := store.Report(usage.Query{
+			From:        midnight,
+			To:          midnight.Add(24 * time.Hour),
+			Granularity: usage.GranularityDay,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Buckets).To(HaveLen(1))
+		Expect(report.Buckets[0].Start.Equal(midnight)).To(BeTrue())
+		Expect(report.Total.TotalTokens).To(Equal(1))
+	})
+
+	It("should keep conversation and task counters across restarts", func() {
+		Expect(store.Record(
+			usage.Record{Time: now, Model: "big", ConversationID: "slack:C1", Usage: tokens(10, 1)},
+			usage.Record{Time: now, Model: "big", ConversationID: "slack:C1", Usage: tokens(10, 1)},
+			usage.Record{Time: now, Model: "big", TaskID: "task-1", Usage: tokens(5, 0)},
+		)).To(Succeed())
+
+		reopened, err := usage.NewJSONStore(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		report, err := reopened.Report(usage.Query{From: now.Add(-time.Hour), To: now.Add(time.Hour)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Conversations["slack:C1"].TotalTokens).To(Equal(22))
+		Expect(report.Conversations["slack:C1"].Calls).To(Equal(2))
+		Expect(report.Tasks["task-1"].PromptTokens).To(Equal(5))
+		Expect(report.Total.TotalTokens).To(Equal(27))
+	})
+})
+
+var _ = Describe("PriceTable", func() {
+	It("should estimate the cost per million tokens", func() {
+		prices := usage.PriceTable{
+			"big": {Prompt: 2, Completion: 10},
+			"*":   {Prompt: 1, Completion: 1},
+		}
+		u := types.Usage{PromptTokens: 1000, CompletionTokens: 500}
+		Expect(prices.Cost("big", u)).To(BeNumerically("~", 0.007))
+		Expect(prices.Cost("other", u)).To(BeNumerically("~", 0.0015))
+		Expect(usage.PriceTable(nil).Cost("big", u)).To(BeZero())
+	})
+})
+
+var _ = Describe("SourceOf", func() {
+	It("should tell scheduled jobs, connectors and API calls apart", func() {
+		Expect(usage.SourceOf(map[string]any{"type": "scheduled"})).To(Equal(usage.SourceScheduler))
+		Expect(usage.SourceOf(map[string]any{types.MetadataKeyConversationID: "telegram:42"})).To(Equal("telegram"))
+		Expect(usage.SourceOf(map[string]any{"type": usage.SourceMemory})).To(Equal(usage.SourceMemory))
+		Expect(usage.SourceOf(nil)).To(Equal(usage.SourceAPI))
+	})
+})
@@ -0,0 +1,47 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mudler/LocalAGI/core/types"
+)
+
+// Price is the cost of a model per million tokens
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// PriceTable maps model names to their price. The "*" entry, if any,
+// applies to models not listed.
+type PriceTable map[string]Price
+
+// LoadPriceTable reads a price table from a JSON file, e.g.
+// {"gpt-4o": {"prompt": 2.5, "completion": 10}}
+func LoadPriceTable(path string) (PriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price table: %w", err)
+	}
+
+	var prices PriceTable
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("failed to parse price table: %w", err)
+	}
+	return prices, nil
+}
+
+// Cost estimates the cost of the tokens used with a model, zero when the
+// model has no price
+func (p PriceTable) Cost(model string, u types.Usage) float64 {
+	price, ok := p[model]
+	if !ok {
+		price, ok = p["*"]
+		if !ok {
+			return 0
+		}
+	}
+	return (float64(u.PromptTokens)*price.Prompt + float64(u.CompletionTokens)*price.Completion) / 1e6
+}
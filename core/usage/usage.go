@@ -0,0 +1,136 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAGI/core/types"
+)
+
+// Granularity is the size of the time buckets of a report
+type Granularity string
+
+const (
+	GranularityHour Granularity = "hour"
+	GranularityDay  Granularity = "day"
+)
+
+// Sources of LLM usage, besides connectors which are named after the
+// prefix of their conversation IDs (e.g. "slack", "telegram")
+const (
+	SourceScheduler = "scheduler"
+	SourceAPI       = "api"
+	// SourceMemory is the summaries of the conversations stored in memory
+	SourceMemory = "memory"
+)
+
+// Record is the token usage of a job with a single model
+type Record struct {
+	Time           time.Time
+	Model          string
+	Source         string
+	ConversationID string
+	TaskID         string
+	Usage          types.Usage
+}
+
+// Bucket aggregates the usage of a time window
+type Bucket struct {
+	Start   time.Time              `json:"start"`
+	Usage   types.Usage            `json:"usage"`
+	Models  map[string]types.Usage `json:"models,omitempty"`
+	Sources map[string]types.Usage `json:"sources,omitempty"`
+}
+
+func (b *Bucket) add(model, source string, u types.Usage) {
+	b.Usage.Add(u)
+	b.Models = mergeUsage(b.Models, model, u)
+	b.Sources = mergeUsage(b.Sources, source, u)
+}
+
+// merge adds the usage of another bucket to b
+func (b *Bucket) merge(o *Bucket) {
+	b.Usage.Add(o.Usage)
+	for model, u := range o.Models {
+		b.Models = mergeUsage(b.Models, model, u)
+	}
+	for source, u := range o.Sources {
+		b.Sources = mergeUsage(b.Sources, source, u)
+	}
+}
+
+// Counter is the lifetime usage of a conversation or a task
+type Counter struct {
+	types.Usage
+	LastUsed time.Time `json:"last_used"`
+}
+
+// Query selects the time range and the bucket size of a report
+type Query struct {
+	From        time.Time
+	To          time.Time
+	Granularity Granularity
+}
+
+// Report is the usage of an agent over a time range, broken down in
+// time buckets. Conversations and tasks are lifetime counters.
+type Report struct {
+	From          time.Time              `json:"from"`
+	To            time.Time              `json:"to"`
+	Granularity   Granularity            `json:"granularity"`
+	Total         types.Usage            `json:"total"`
+	Models        map[string]types.Usage `json:"models"`
+	Sources       map[string]types.Usage `json:"sources"`
+	Buckets       []Bucket               `json:"buckets"`
+	Conversations map[string]Counter     `json:"conversations"`
+	Tasks         map[string]Counter     `json:"tasks"`
+}
+
+// Store keeps the usage counters of an agent
+type Store interface {
+	Record(records ...Record) error
+	Report(q Query) (*Report, error)
+	// Since returns the usage recorded from t onward, t being the start of
+	// a day or a month in any time zone
+	Since(t time.Time) (types.Usage, error)
+	Close() error
+}
+
+// ParseGranularity validates a bucket size, defaulting to hours
+func ParseGranularity(s string) (Granularity, error) {
+	switch Granularity(s) {
+	case "", GranularityHour:
+		return GranularityHour, nil
+	case GranularityDay:
+		return GranularityDay, nil
+	default:
+		return "", fmt.Errorf("invalid granularity %q: must be hour or day", s)
+	}
+}
+
+// Truncate returns the start of the bucket t falls in
+func (g Granularity) Truncate(t time.Time) time.Time {
+	if g == GranularityDay {
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+	return t.Truncate(time.Hour)
+}
+
+// SourceOf tells where a job comes from given its metadata: the scheduler,
+// the memory, a connector (from the prefix of the conversation ID) or the API
+func SourceOf(metadata map[string]any) string {
+	switch metadata["type"] {
+	case "scheduled":
+		return SourceScheduler
+	case SourceMemory:
+		return SourceMemory
+	}
+	if cid, ok := metadata[types.MetadataKeyConversationID].(string); ok {
+		if prefix, _, found := strings.Cut(cid, ":"); found && prefix != "" {
+			return prefix
+		}
+	}
+	return SourceAPI
+}
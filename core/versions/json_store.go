@@ -0,0 +1,158 @@
+package versions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAGI/pkg/atomicfile"
+)
+
+// JSONStore implements Store using JSON file storage, with the versions of
+// all agents in a single file
+type JSONStore struct {
+	filePath    string
+	maxVersions int
+	mu          sync.Mutex
+	data        map[string][]Version
+}
+
+// NewJSONStore creates a new JSON-based version store
+func NewJSONStore(filePath string) (*JSONStore, error) {
+	s := &JSONStore{
+		filePath:    filePath,
+		maxVersions: DefaultMaxVersions,
+		data:        make(map[string][]Version),
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read config versions: %w", err)
+		}
+		return s, nil
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.data); err != nil {
+			return nil, fmt.Errorf("failed to parse config versions: %w", err)
+		}
+		if s.data == nil {
+			s.data = make(map[string][]Version)
+		}
+	}
+
+	return s, nil
+}
+
+// Add records config as the latest version of agent
+func (s *JSONStore) Add(agent, author, action, note string, config json.RawMessage) (Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.data[agent]
+	v := Version{
+		Version:   1,
+		Agent:     agent,
+		Author:    author,
+		Action:    action,
+		Note:      note,
+		CreatedAt: time.Now(),
+		Config:    config,
+	}
+	if len(history) > 0 {
+		latest := history[len(history)-1]
+		diff, err := Diff(latest.Config, config)
+		if err != nil {
+			return Version{}, fmt.Errorf("failed to diff config versions: %w", err)
+		}
+		if len(diff) == 0 {
+			return latest, nil
+		}
+		v.Version = latest.Version + 1
+		v.Diff = diff
+	}
+
+	history = append(history, v)
+	if len(history) > s.maxVersions {
+		history = history[len(history)-s.maxVersions:]
+	}
+	s.data[agent] = history
+
+	if err := s.save(); err != nil {
+		return Version{}, err
+	}
+	return v, nil
+}
+
+// List returns the versions of agent, oldest first
+func (s *JSONStore) List(agent string) ([]Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Version(nil), s.data[agent]...), nil
+}
+
+// Get returns a version of agent
+func (s *JSONStore) Get(agent string, version int) (Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range s.data[agent] {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return Version{}, ErrNotFound
+}
+
+// Remove deletes the history of agent
+func (s *JSONStore) Remove(agent string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data[agent]; !ok {
+		return nil
+	}
+	delete(s.data, agent)
+	return s.save()
+}
+
+// Rewrite replaces every stored version with what fn returns for it
+func (s *JSONStore) Rewrite(fn func(Version) (Version, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for agent, history := range s.data {
+		for i, v := range history {
+			rewritten, err := fn(v)
+			if err != nil {
+				return fmt.Errorf("failed to rewrite version %d of %s: %w", v.Version, agent, err)
+			}
+			// Compare the encoded versions, configurations are reformatted when saved
+			before, _ := json.Marshal(v)
+			after, _ := json.Marshal(rewritten)
+			if !bytes.Equal(before, after) {
+				history[i] = rewritten
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// save writes the store to disk, readable only by its owner since
+// configurations hold endpoints and secret references
+func (s *JSONStore) save() error {
+	if err := atomicfile.WriteJSON(s.filePath, s.data, 0600); err != nil {
+		return fmt.Errorf("failed to write config versions: %w", err)
+	}
+	return nil
+}
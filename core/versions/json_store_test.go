@@ -0,0 +1,119 @@
+package versions_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAGI/core/versions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSONStore", func() {
+	var path string
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "config-versions.json")
+	})
+
+	It("records versions with the changes from the previous one", func() {
+		store, err := NewJSONStore(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		v, err := store.Add("bot", "webui", ActionCreate, "", json.RawMessage(`{"name":"bot","model":"a","hud":true}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.Version).To(Equal(1))
+		Expect(v.Diff).To(BeEmpty())
+
+		v, err = store.Add("bot", "api-key:sk-1...abcd", ActionUpdate, "", json.RawMessage(`{"name":"bot","model":"b","system_prompt":"be nice"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.Version).To(Equal(2))
+		Expect(v.Author).To(Equal("api-key:sk-1...abcd"))
+		Expect(v.Diff).To(Equal([]Change{
+			{Field: "hud", Old: true},
+			{Field: "model", Old: "a", New: "b"},
+			{Field: "system_prompt", New: "be nice"},
+		}))
+
+		// Saving the same configuration again is not a new version
+		v, err = store.Add("bot", "webui", ActionUpdate, "", json.RawMessage(`{"system_prompt":"be nice","model":"b","name":"bot"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.Version).To(Equal(2))
+
+		_, err = store.Add("other", "webui", ActionCreate, "", json.RawMessage(`{"name":"other"}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		reloaded, err := NewJSONStore(path)
+		Expect(err).ToNot(HaveOccurred())
+		history, err := reloaded.List("bot")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(history).To(HaveLen(2))
+		Expect(history[0].Action).To(Equal(ActionCreate))
+
+		first, err := reloaded.Get("bot", 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(first.Config).To(MatchJSON(`{"name":"bot","model":"a","hud":true}`))
+		_, err = reloaded.Get("bot", 3)
+		Expect(err).To(MatchError(ErrNotFound))
+	})
+
+	It("keeps the latest versions", func() {
+		store, err := NewJSONStore(path)
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < DefaultMaxVersions+5; i++ {
+			_, err := store.Add("bot", "webui", ActionUpdate, "", json.RawMessage(fmt.Sprintf(`{"model":"m%d"}`, i)))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		history, err := store.List("bot")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(history).To(HaveLen(DefaultMaxVersions))
+		Expect(history[0].Version).To(Equal(6))
+		Expect(history[len(history)-1].Version).To(Equal(DefaultMaxVersions + 5))
+	})
+
+	It("removes the history of an agent", func() {
+		store, err := NewJSONStore(path)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = store.Add("bot", "webui", ActionCreate, "", json.RawMessage(`{"name":"bot"}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(store.Remove("bot")).To(Succeed())
+		reloaded, err := NewJSONStore(path)
+		Expect(err).ToNot(HaveOccurred())
+		history, err := reloaded.List("bot")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(history).To(BeEmpty())
+	})
+
+	It("rewrites the stored versions in a file only its owner can read", func() {
+		store, err := NewJSONStore(path)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = store.Add("bot", "webui", ActionCreate, "", json.RawMessage(`{"name":"bot","api_key":"sk-1"}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(store.Rewrite(func(v Version) (Version, error) {
+			v.Config = json.RawMessage(`{"name":"bot","api_key":"********"}`)
+			return v, nil
+		})).To(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).ToNot(ContainSubstring("sk-1"))
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("diffs nested fields as a whole", func() {
+		changes, err := Diff(
+			json.RawMessage(`{"actions":[{"name":"search"}],"model":"a"}`),
+			json.RawMessage(`{"actions":[{"name":"search"},{"name":"browse"}],"model":"a"}`),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(HaveLen(1))
+		Expect(changes[0].Field).To(Equal("actions"))
+	})
+})
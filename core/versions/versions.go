@@ -0,0 +1,100 @@
+// Package versions keeps the history of the configuration of each agent, so
+// that changes can be reviewed and rolled back.
+package versions
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Actions that produce a version
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionRollback = "rollback"
+	// ActionInitial records the configuration an agent had before its
+	// history was kept
+	ActionInitial = "initial"
+)
+
+// DefaultMaxVersions is how many versions are kept per agent
+const DefaultMaxVersions = 100
+
+// ErrNotFound is returned for versions that don't exist
+var ErrNotFound = errors.New("version not found")
+
+// Version is the configuration of an agent after a change
+type Version struct {
+	Version   int             `json:"version"`
+	Agent     string          `json:"agent"`
+	Author    string          `json:"author"`
+	Action    string          `json:"action"`
+	Note      string          `json:"note,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Config    json.RawMessage `json:"config,omitempty"`
+	// Diff are the changes from the previous version
+	Diff []Change `json:"diff,omitempty"`
+}
+
+// Change is a configuration field that changed between two versions
+type Change struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// Store persists the versions of the agents
+type Store interface {
+	// Add records config as the latest version of agent, unless it is
+	// identical to it. It returns the latest version.
+	Add(agent, author, action, note string, config json.RawMessage) (Version, error)
+	// List returns the versions of agent, oldest first
+	List(agent string) ([]Version, error)
+	// Get returns a version of agent
+	Get(agent string, version int) (Version, error)
+	// Remove deletes the history of agent
+	Remove(agent string) error
+	// Rewrite replaces every stored version with what fn returns for it
+	Rewrite(fn func(Version) (Version, error)) error
+}
+
+// Diff returns the top level fields that differ between two configurations,
+// sorted by name
+func Diff(from, to json.RawMessage) ([]Change, error) {
+	old, err := fields(from)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := fields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for field, value := range updated {
+		if previous, ok := old[field]; !ok || !reflect.DeepEqual(previous, value) {
+			changes = append(changes, Change{Field: field, Old: old[field], New: value})
+		}
+	}
+	for field, value := range old {
+		if _, ok := updated[field]; !ok {
+			changes = append(changes, Change{Field: field, Old: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func fields(config json.RawMessage) (map[string]any, error) {
+	m := map[string]any{}
+	if len(config) == 0 {
+		return m, nil
+	}
+	if err := json.Unmarshal(config, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,69 @@
+package atomicfile_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAGI/pkg/atomicfile"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("atomicfile", func() {
+	var path string
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "nested", "data.json")
+	})
+
+	It("writes indented JSON and creates the directory", func() {
+		Expect(WriteJSON(path, map[string]int{"a": 1}, 0644)).To(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		var got map[string]int
+		Expect(json.Unmarshal(data, &got)).To(Succeed())
+		Expect(got).To(Equal(map[string]int{"a": 1}))
+		Expect(path + ".tmp").ToNot(BeAnExistingFile())
+	})
+
+	It("creates the file with the given mode", func() {
+		Expect(WriteJSON(path, "secret", 0600)).To(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("tightens the mode of a file written before", func() {
+		Expect(WriteJSON(path, "public", 0644)).To(Succeed())
+		Expect(WriteJSON(path, "secret", 0600)).To(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("keeps the previous content when writing fails", func() {
+		Expect(WriteJSON(path, "old", 0644)).To(Succeed())
+
+		err := Write(path, 0644, func(w io.Writer) error {
+			w.Write([]byte("partial"))
+			return errors.New("boom")
+		})
+		Expect(err).To(MatchError("boom"))
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal(`"old"`))
+		Expect(path + ".tmp").ToNot(BeAnExistingFile())
+	})
+
+	It("does not write values that cannot be marshaled", func() {
+		Expect(WriteJSON(path, make(chan int), 0644)).ToNot(Succeed())
+		Expect(path).ToNot(BeAnExistingFile())
+	})
+})
@@ -0,0 +1,248 @@
+// Package cassette records the requests an agent makes to an OpenAI
+// compatible API, and replays the recorded answers from a fake endpoint, so
+// that agents can be tested offline and deterministically.
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mudler/LocalAGI/pkg/atomicfile"
+)
+
+// Mode is how a cassette is used
+type Mode string
+
+const (
+	// ModeRecord proxies the requests to the real API and records them
+	ModeRecord Mode = "record"
+	// ModeReplay answers the requests from the cassette
+	ModeReplay Mode = "replay"
+)
+
+// Request is a recorded request. JSON bodies are kept as is, multipart forms
+// (transcriptions) as their fields, with files replaced by their checksum.
+type Request struct {
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	Form   map[string]string `json:"form,omitempty"`
+}
+
+// Response is a recorded response. Streamed responses are kept as the raw
+// event stream, binary ones (speech) are base64 encoded.
+type Response struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+	Encoding    string `json:"encoding,omitempty"`
+}
+
+// Interaction is a request with the response it got
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is a list of interactions, in the order they happened
+type Cassette struct {
+	mu           sync.Mutex
+	Interactions []Interaction `json:"interactions"`
+}
+
+// New returns an empty cassette
+func New() *Cassette {
+	return &Cassette{}
+}
+
+// Load reads the cassette at path
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	c := New()
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save writes the cassette to path
+func (c *Cassette) Save(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := atomicfile.WriteJSON(path, c, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// Add appends an interaction
+func (c *Cassette) Add(i Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, i)
+}
+
+// Len returns the number of interactions
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.Interactions)
+}
+
+// Open returns the handler serving the cassette at path. In record mode the
+// requests are proxied to upstream and done saves the cassette, in replay
+// mode the cassette must exist and done reports the requests missing from it.
+func Open(mode Mode, path, upstream string) (handler http.Handler, done func() error, err error) {
+	switch mode {
+	case ModeRecord:
+		c := New()
+		recorder, err := NewRecorder(upstream, c)
+		if err != nil {
+			return nil, nil, err
+		}
+		return recorder, func() error { return c.Save(path) }, nil
+	case ModeReplay, "":
+		c, err := Load(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		player := NewPlayer(c)
+		return player, player.Err, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown cassette mode %q, expected %q or %q", mode, ModeRecord, ModeReplay)
+	}
+}
+
+// readRequest reads the body of r into a Request
+func readRequest(r *http.Request) (Request, []byte, error) {
+	req := Request{Method: r.Method, Path: r.URL.Path}
+	if r.Body == nil {
+		return req, nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return req, nil, err
+	}
+	if len(body) == 0 {
+		return req, body, nil
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "multipart/form-data":
+		form, err := readForm(body, params["boundary"])
+		if err != nil {
+			return req, nil, err
+		}
+		req.Form = form
+	case json.Valid(body):
+		req.Body = body
+	default:
+		req.Form = map[string]string{"body": checksum(body)}
+	}
+	return req, body, nil
+}
+
+func readForm(body []byte, boundary string) (map[string]string, error) {
+	form := map[string]string{}
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read multipart request: %w", err)
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read multipart request: %w", err)
+		}
+		// Files are uploaded from temporary paths, only their content matters
+		if part.FileName() != "" {
+			form[part.FormName()] = checksum(value)
+		} else {
+			form[part.FormName()] = string(value)
+		}
+	}
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// promptTime matches the current time written in the agent prompts
+var promptTime = regexp.MustCompile(`[A-Z][a-z]{2}, \d{2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2} UTC`)
+
+// randomID matches the UUIDs given to the tool calls of the agents, which
+// change on every run
+var randomID = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// key identifies a request regardless of the formatting of its body, of the
+// time it was made at and of the random IDs it holds
+func (r Request) key() string {
+	var b strings.Builder
+	b.WriteString(r.Method + " " + r.Path + "\n")
+	if len(r.Body) > 0 {
+		var v any
+		if err := json.Unmarshal(r.Body, &v); err == nil {
+			canonical, _ := json.Marshal(v)
+			canonical = promptTime.ReplaceAll(canonical, []byte("<time>"))
+			b.Write(randomID.ReplaceAll(canonical, []byte("<id>")))
+		} else {
+			b.Write(r.Body)
+		}
+	}
+	names := make([]string, 0, len(r.Form))
+	for name := range r.Form {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString("\n" + name + "=" + r.Form[name])
+	}
+	return b.String()
+}
+
+func newResponse(status int, contentType string, body []byte) Response {
+	resp := Response{Status: status, ContentType: contentType}
+	if utf8.Valid(body) {
+		resp.Body = string(body)
+	} else {
+		resp.Body = base64.StdEncoding.EncodeToString(body)
+		resp.Encoding = "base64"
+	}
+	return resp
+}
+
+func (r Response) body() ([]byte, error) {
+	if r.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(r.Body)
+	}
+	return []byte(r.Body), nil
+}
+
+// isHealthCheck reports whether path is a readiness probe, which is neither
+// recorded nor needs to be
+func isHealthCheck(path string) bool {
+	return path == "/readyz" || path == "/healthz"
+}
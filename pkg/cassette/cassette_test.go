@@ -0,0 +1,288 @@
+package cassette_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/mudler/LocalAGI/pkg/cassette"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeAPI answers like an OpenAI API, numbering its chat answers
+func fakeAPI() *httptest.Server {
+	calls := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			calls++
+			var req openai.ChatCompletionRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Stream {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"streamed %d\"}}]}\n\n", calls)
+				fmt.Fprint(w, "data: [DONE]\n\n")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{
+					Role:    openai.ChatMessageRoleAssistant,
+					Content: fmt.Sprintf("answer %d to %s", calls, req.Messages[len(req.Messages)-1].Content),
+				}}},
+			})
+		case "/v1/embeddings":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(openai.EmbeddingResponse{Data: []openai.Embedding{{Embedding: []float32{0.1, 0.2}}}})
+		case "/v1/audio/transcriptions":
+			r.ParseMultipartForm(1 << 20)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"text": "transcribed with " + r.FormValue("model")})
+		case "/v1/audio/speech":
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Write([]byte{0xff, 0xfb, 0x90, 0x00})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func newClient(url string) *openai.Client {
+	config := openai.DefaultConfig("sk-test")
+	config.BaseURL = url + "/v1"
+	return openai.NewClientWithConfig(config)
+}
+
+func chat(client *openai.Client, content string) (string, error) {
+	resp, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+		Model:    "test",
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: content}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+var _ = Describe("Cassette", func() {
+	var dir, path, audio string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		path = filepath.Join(dir, "cassettes", "test.json")
+		audio = filepath.Join(dir, "audio.wav")
+		Expect(os.WriteFile(audio, []byte("RIFF fake audio"), 0644)).To(Succeed())
+	})
+
+	// session makes every kind of request an agent makes
+	session := func(client *openai.Client) []string {
+		var results []string
+
+		answer, err := chat(client, "hello")
+		Expect(err).ToNot(HaveOccurred())
+		results = append(results, answer)
+
+		answer, err = chat(client, "how are you?")
+		Expect(err).ToNot(HaveOccurred())
+		results = append(results, answer)
+
+		stream, err := client.CreateChatCompletionStream(context.Background(), openai.ChatCompletionRequest{
+			Model:    "test",
+			Stream:   true,
+			Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "stream"}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			Expect(err).ToNot(HaveOccurred())
+			results = append(results, chunk.Choices[0].Delta.Content)
+		}
+		stream.Close()
+
+		embeddings, err := client.CreateEmbeddings(context.Background(), openai.EmbeddingRequestStrings{
+			Input: []string{"text"},
+			Model: "embeddings",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		results = append(results, fmt.Sprint(embeddings.Data[0].Embedding))
+
+		transcription, err := client.CreateTranscription(context.Background(), openai.AudioRequest{
+			Model:    "whisper",
+			FilePath: audio,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		results = append(results, transcription.Text)
+
+		speech, err := client.CreateSpeech(context.Background(), openai.CreateSpeechRequest{
+			Model: "tts",
+			Input: "hi",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		data, err := io.ReadAll(speech)
+		Expect(err).ToNot(HaveOccurred())
+		speech.Close()
+		results = append(results, fmt.Sprint(data))
+
+		return results
+	}
+
+	record := func() []string {
+		upstream := fakeAPI()
+		defer upstream.Close()
+
+		handler, done, err := Open(ModeRecord, path, upstream.URL)
+		Expect(err).ToNot(HaveOccurred())
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		results := session(newClient(server.URL))
+		Expect(done()).To(Succeed())
+		return results
+	}
+
+	It("replays a recorded session without the API", func() {
+		recorded := record()
+		Expect(recorded).To(ContainElements("answer 1 to hello", "answer 2 to how are you?", "streamed 3", "transcribed with whisper"))
+
+		c, err := Load(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Len()).To(Equal(6))
+
+		player := NewPlayer(c)
+		server := httptest.NewServer(player)
+		defer server.Close()
+
+		Expect(session(newClient(server.URL))).To(Equal(recorded))
+		Expect(player.Unplayed()).To(Equal(0))
+	})
+
+	It("fails on requests that changed since the recording", func() {
+		record()
+		c, err := Load(path)
+		Expect(err).ToNot(HaveOccurred())
+		player := NewPlayer(c)
+		server := httptest.NewServer(player)
+		defer server.Close()
+		client := newClient(server.URL)
+
+		answer, err := chat(client, "how are you?")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(answer).To(Equal("answer 2 to how are you?"))
+		Expect(player.Err()).ToNot(HaveOccurred())
+
+		_, err = chat(client, "hello, changed")
+		Expect(err).To(HaveOccurred())
+		var apiErr *openai.APIError
+		Expect(errors.As(err, &apiErr)).To(BeTrue())
+		Expect(apiErr.HTTPStatusCode).To(Equal(http.StatusNotFound))
+		Expect(player.Err()).To(MatchError(ContainSubstring("POST /v1/chat/completions")))
+
+		// Requests repeated more than when recording get the same answer
+		answer, err = chat(client, "how are you?")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(answer).To(Equal("answer 2 to how are you?"))
+	})
+
+	It("ignores the time in the prompts", func() {
+		upstream := fakeAPI()
+		defer upstream.Close()
+		c := New()
+		recorder, err := NewRecorder(upstream.URL, c)
+		Expect(err).ToNot(HaveOccurred())
+		server := httptest.NewServer(recorder)
+		_, err = chat(newClient(server.URL), "Current Time and Date: Mon, 02 Jan 2006 15:04:05 UTC")
+		server.Close()
+		Expect(err).ToNot(HaveOccurred())
+
+		player := NewPlayer(c)
+		server = httptest.NewServer(player)
+		defer server.Close()
+		answer, err := chat(newClient(server.URL), "Current Time and Date: Fri, 16 Oct 2026 19:46:25 UTC")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(answer).To(Equal("answer 1 to Current Time and Date: Mon, 02 Jan 2006 15:04:05 UTC"))
+		Expect(player.Err()).ToNot(HaveOccurred())
+	})
+
+	It("ignores the IDs of the tool calls", func() {
+		upstream := fakeAPI()
+		defer upstream.Close()
+		c := New()
+		recorder, err := NewRecorder(upstream.URL, c)
+		Expect(err).ToNot(HaveOccurred())
+		server := httptest.NewServer(recorder)
+		_, err = chat(newClient(server.URL), "result of call 0f8fad5b-d9cb-469f-a165-70867728950e")
+		server.Close()
+		Expect(err).ToNot(HaveOccurred())
+
+		player := NewPlayer(c)
+		server = httptest.NewServer(player)
+		defer server.Close()
+		answer, err := chat(newClient(server.URL), "result of call 7c9e6679-7425-40de-944b-e07fc1f90ae7")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(answer).To(Equal("answer 1 to result of call 0f8fad5b-d9cb-469f-a165-70867728950e"))
+		Expect(player.Err()).ToNot(HaveOccurred())
+	})
+
+	It("records compressed responses as plain text", func() {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				fmt.Fprint(w, `{"object":"list"}`)
+				return
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			fmt.Fprint(gz, `{"object":"list"}`)
+			gz.Close()
+		}))
+		defer upstream.Close()
+		c := New()
+		recorder, err := NewRecorder(upstream.URL, c)
+		Expect(err).ToNot(HaveOccurred())
+		server := httptest.NewServer(recorder)
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/models", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+
+		Expect(c.Interactions).To(HaveLen(1))
+		Expect(c.Interactions[0].Response.Body).To(Equal(`{"object":"list"}`))
+	})
+
+	It("answers health checks", func() {
+		c := New()
+		server := httptest.NewServer(NewPlayer(c))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/readyz")
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("rejects unknown modes and missing cassettes", func() {
+		_, _, err := Open("rewind", path, "")
+		Expect(err).To(HaveOccurred())
+		_, _, err = Open(ModeReplay, path, "")
+		Expect(err).To(HaveOccurred())
+		_, _, err = Open(ModeRecord, path, "not a url")
+		Expect(err).To(HaveOccurred())
+	})
+})
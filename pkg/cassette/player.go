@@ -0,0 +1,134 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/mudler/xlog"
+)
+
+// Player is a fake OpenAI compatible API answering from a cassette.
+//
+// A request gets the response of the first interaction not played yet with
+// the same request, or of the last one if all were played. A request that
+// changed since the recording, e.g. because of a prompt change, is answered
+// with an error and reported by Err, so that the regression is not hidden.
+// The current time in the agent prompts is ignored when comparing requests.
+type Player struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	keys     []string
+	played   []bool
+	// misses are the requests that were not in the cassette
+	misses []string
+}
+
+// NewPlayer returns a player answering from c
+func NewPlayer(c *Cassette) *Player {
+	p := &Player{cassette: c}
+	for _, i := range c.Interactions {
+		p.keys = append(p.keys, i.Request.key())
+	}
+	p.played = make([]bool, len(p.keys))
+	return p
+}
+
+// Unplayed returns the number of interactions that were not requested
+func (p *Player) Unplayed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, played := range p.played {
+		if !played {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns an error listing the requests that were not in the cassette,
+// nil if all of them were
+func (p *Player) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.misses) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d requests were not recorded in the cassette, record it again if they changed on purpose: %s",
+		len(p.misses), strings.Join(p.misses, ", "))
+}
+
+func (p *Player) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if isHealthCheck(r.URL.Path) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	request, _, err := readRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	interaction, ok := p.match(request)
+	if !ok {
+		xlog.Warn("No recorded interaction for request", "method", request.Method, "path", request.Path)
+		writeError(w, fmt.Sprintf("no recorded interaction for %s %s", request.Method, request.Path))
+		return
+	}
+
+	body, err := interaction.Response.body()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if interaction.Response.ContentType != "" {
+		w.Header().Set("Content-Type", interaction.Response.ContentType)
+	}
+	w.WriteHeader(interaction.Response.Status)
+	w.Write(body)
+}
+
+func (p *Player) match(request Request) (Interaction, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := request.key()
+	interactions := p.cassette.Interactions
+	found, repeated := -1, -1
+	for i := range interactions {
+		if p.keys[i] != key {
+			continue
+		}
+		if !p.played[i] {
+			found = i
+			break
+		}
+		repeated = i
+	}
+	// Requests repeated more than when recording, e.g. retries
+	if found < 0 && repeated >= 0 {
+		return interactions[repeated], true
+	}
+	if found < 0 {
+		p.misses = append(p.misses, request.Method+" "+request.Path)
+		return Interaction{}, false
+	}
+	p.played[found] = true
+	return interactions[found], true
+}
+
+// writeError answers like an OpenAI API would, so that clients don't retry
+func writeError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"message": message,
+			"type":    "cassette_miss",
+		},
+	})
+}
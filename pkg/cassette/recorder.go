@@ -0,0 +1,98 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mudler/xlog"
+)
+
+// Recorder is a proxy to an OpenAI compatible API recording every request
+// and response in a cassette
+type Recorder struct {
+	upstream *url.URL
+	cassette *Cassette
+	client   *http.Client
+}
+
+// NewRecorder returns a recorder proxying to upstream
+func NewRecorder(upstream string, c *Cassette) (*Recorder, error) {
+	u, err := url.Parse(upstream)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL %q", upstream)
+	}
+	return &Recorder{upstream: u, cassette: c, client: &http.Client{}}, nil
+}
+
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request, body, err := readRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	target := *rec.upstream
+	target.Path = strings.TrimRight(target.Path, "/") + r.URL.Path
+	target.RawQuery = r.URL.RawQuery
+	out, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	out.Header = r.Header.Clone()
+	// Left to the transport, which then decompresses the response, so that
+	// it is recorded and replayed as plain text
+	out.Header.Del("Accept-Encoding")
+
+	resp, err := rec.client.Do(out)
+	if err != nil {
+		xlog.Error("Cassette upstream request failed", "path", r.URL.Path, "error", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for name, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	// Streamed responses are forwarded as they come, and recorded whole
+	var recorded bytes.Buffer
+	if _, err := io.Copy(&flushWriter{w: io.MultiWriter(w, &recorded), flusher: flusherOf(w)}, resp.Body); err != nil {
+		xlog.Error("Cassette upstream response failed", "path", r.URL.Path, "error", err)
+		return
+	}
+
+	if isHealthCheck(r.URL.Path) {
+		return
+	}
+	rec.cassette.Add(Interaction{
+		Request:  request,
+		Response: newResponse(resp.StatusCode, resp.Header.Get("Content-Type"), recorded.Bytes()),
+	})
+}
+
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if f.flusher != nil {
+		f.flusher.Flush()
+	}
+	return n, err
+}
+
+func flusherOf(w http.ResponseWriter) http.Flusher {
+	flusher, _ := w.(http.Flusher)
+	return flusher
+}
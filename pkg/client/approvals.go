@@ -0,0 +1,76 @@
+package localagi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Approval represents an action waiting for a human decision before running
+type Approval struct {
+	ID             string         `json:"id"`
+	Agent          string         `json:"agent"`
+	Action         string         `json:"action"`
+	Params         map[string]any `json:"params"`
+	Reasoning      string         `json:"reasoning"`
+	JobID          string         `json:"job_id"`
+	ConversationID string         `json:"conversation_id,omitempty"`
+	Status         string         `json:"status"`
+	DecidedBy      string         `json:"decided_by,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	DecidedAt      time.Time      `json:"decided_at,omitempty"`
+}
+
+// ListApprovals returns the approval requests of an agent (all agents if empty).
+// When pendingOnly is set, only the requests still waiting for a decision are returned.
+func (c *Client) ListApprovals(agentName string, pendingOnly bool) ([]Approval, error) {
+	query := url.Values{}
+	if agentName != "" {
+		query.Set("agent", agentName)
+	}
+	if pendingOnly {
+		query.Set("status", "pending")
+	}
+	path := "/api/approvals"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := c.doRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Approvals []Approval `json:"approvals"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return response.Approvals, nil
+}
+
+// ResolveApproval approves or denies a pending approval request
+func (c *Client) ResolveApproval(id string, approved bool) error {
+	path := fmt.Sprintf("/api/approvals/%s", id)
+	resp, err := c.doRequest(http.MethodPost, path, map[string]bool{"approved": approved})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if status, ok := response["status"]; ok && status == "ok" {
+		return nil
+	}
+	return fmt.Errorf("failed to resolve approval: %v", response)
+}
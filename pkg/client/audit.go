@@ -0,0 +1,104 @@
+package localagi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// AuditEntry is a record of the audit log: a change to an agent, an action
+// run by a user, or an action with side effects run by an agent
+type AuditEntry struct {
+	ID             string            `json:"id"`
+	Time           time.Time         `json:"time"`
+	Actor          string            `json:"actor"`
+	Operation      string            `json:"operation"`
+	Agent          string            `json:"agent,omitempty"`
+	Action         string            `json:"action,omitempty"`
+	Params         map[string]any    `json:"params,omitempty"`
+	Result         string            `json:"result,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	ConversationID string            `json:"conversation_id,omitempty"`
+	JobID          string            `json:"job_id,omitempty"`
+	Details        map[string]string `json:"details,omitempty"`
+}
+
+// AuditQuery filters the audit log, zero values match everything
+type AuditQuery struct {
+	From           time.Time
+	To             time.Time
+	Actor          string
+	Operation      string
+	Agent          string
+	Action         string
+	ConversationID string
+	// Limit is ignored by ExportAuditLog
+	Limit int
+}
+
+func (q AuditQuery) values() url.Values {
+	query := url.Values{}
+	if !q.From.IsZero() {
+		query.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Actor != "" {
+		query.Set("actor", q.Actor)
+	}
+	if q.Operation != "" {
+		query.Set("operation", q.Operation)
+	}
+	if q.Agent != "" {
+		query.Set("agent", q.Agent)
+	}
+	if q.Action != "" {
+		query.Set("action", q.Action)
+	}
+	if q.ConversationID != "" {
+		query.Set("conversation_id", q.ConversationID)
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return query
+}
+
+// QueryAuditLog returns the entries of the audit log, newest first
+func (c *Client) QueryAuditLog(q AuditQuery) ([]AuditEntry, error) {
+	resp, err := c.doRequest(http.MethodGet, "/api/audit?"+q.values().Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Entries []AuditEntry `json:"Entries"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result.Entries, nil
+}
+
+// ExportAuditLog writes the matching entries of the audit log to w as JSON
+// lines, oldest first
+func (c *Client) ExportAuditLog(w io.Writer, q AuditQuery) error {
+	q.Limit = 0
+	resp, err := c.doRequest(http.MethodGet, "/api/audit/export?"+q.values().Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package localagi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ObservableRecord is an observable stored in the history of an agent.
+// Creation, progress and completion are left as raw JSON.
+type ObservableRecord struct {
+	ID             int32           `json:"id"`
+	ParentID       int32           `json:"parent_id,omitempty"`
+	Agent          string          `json:"agent"`
+	Name           string          `json:"name"`
+	Icon           string          `json:"icon"`
+	ConversationID string          `json:"conversation_id,omitempty"`
+	Creation       json.RawMessage `json:"creation,omitempty"`
+	Progress       json.RawMessage `json:"progress,omitempty"`
+	Completion     json.RawMessage `json:"completion,omitempty"`
+	Status         string          `json:"status"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// ObservableQuery filters the observable history, zero values match everything
+type ObservableQuery struct {
+	From           time.Time
+	To             time.Time
+	Status         string // running, completed or failed
+	Action         string
+	ConversationID string
+	Limit          int
+}
+
+// SearchObservables returns the stored observables of an agent, newest first
+func (c *Client) SearchObservables(agentName string, q ObservableQuery) ([]ObservableRecord, error) {
+	query := url.Values{}
+	if !q.From.IsZero() {
+		query.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		query.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Status != "" {
+		query.Set("status", q.Status)
+	}
+	if q.Action != "" {
+		query.Set("action", q.Action)
+	}
+	if q.ConversationID != "" {
+		query.Set("conversation_id", q.ConversationID)
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	path := fmt.Sprintf("/api/agent/%s/observables/search?%s", agentName, query.Encode())
+
+	resp, err := c.doRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		History []ObservableRecord `json:"History"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result.History, nil
+}
+
+// ReplayObservable returns the timeline of the job the observable belongs
+// to, starting with the job observable
+func (c *Client) ReplayObservable(agentName string, id int32) ([]ObservableRecord, error) {
+	path := fmt.Sprintf("/api/agent/%s/observables/%d/replay", agentName, id)
+
+	resp, err := c.doRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Timeline []ObservableRecord `json:"Timeline"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result.Timeline, nil
+}
@@ -0,0 +1,64 @@
+package localagi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Secret describes a secret stored by the server, its value is never
+// returned
+type Secret struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListSecrets returns the secrets stored by the server
+func (c *Client) ListSecrets() ([]Secret, error) {
+	resp, err := c.doRequest(http.MethodGet, "/api/secrets", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Secrets []Secret `json:"Secrets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result.Secrets, nil
+}
+
+// SetSecret creates or replaces a secret, and returns the reference to use
+// in agent configurations
+func (c *Client) SetSecret(name, value string) (string, error) {
+	resp, err := c.doRequest(http.MethodPut, fmt.Sprintf("/api/secrets/%s", name), map[string]string{"value": value})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Reference string `json:"Reference"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result.Reference, nil
+}
+
+// DeleteSecret removes a secret
+func (c *Client) DeleteSecret(name string) error {
+	resp, err := c.doRequest(http.MethodDelete, fmt.Sprintf("/api/secrets/%s", name), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package localagi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// TaskTrigger makes a task run after another task of the same agent
+type TaskTrigger struct {
+	TaskID string `json:"task_id"`
+	On     string `json:"on"` // on_success, on_failure or on_complete
+	Match  string `json:"match,omitempty"`
+}
+
+// TaskRetryPolicy controls how a failed task is retried
+type TaskRetryPolicy struct {
+	MaxRetries   int     `json:"max_retries"`
+	BackoffMs    int64   `json:"backoff_ms,omitempty"`
+	MaxBackoffMs int64   `json:"max_backoff_ms,omitempty"`
+	Multiplier   float64 `json:"multiplier,omitempty"`
+	Jitter       float64 `json:"jitter,omitempty"`
+}
+
+// TaskMisfirePolicy controls how a task catches up with occurrences missed
+// while the server was down
+type TaskMisfirePolicy struct {
+	Action        string `json:"action"` // skip, run_once or run_all
+	MaxCatchUp    int    `json:"max_catch_up,omitempty"`
+	MaxLatenessMs int64  `json:"max_lateness_ms,omitempty"`
+}
+
+// Task represents a task scheduled for an agent
+type Task struct {
+	ID            string             `json:"id"`
+	AgentName     string             `json:"agent_name"`
+	Prompt        string             `json:"prompt"`
+	ScheduleType  string             `json:"schedule_type"`
+	ScheduleValue string             `json:"schedule_value"`
+	Status        string             `json:"status"`
+	NextRun       time.Time          `json:"next_run"`
+	LastRun       *time.Time         `json:"last_run,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	Metadata      map[string]any     `json:"metadata,omitempty"`
+	Trigger       *TaskTrigger       `json:"trigger,omitempty"`
+	Retry         *TaskRetryPolicy   `json:"retry,omitempty"`
+	Attempts      int                `json:"attempts,omitempty"`
+	Timezone      string             `json:"timezone,omitempty"`
+	Misfire       *TaskMisfirePolicy `json:"misfire,omitempty"`
+}
+
+// TaskRequest is used to create or update a task. Set either a schedule
+// (cron, interval or once) or a trigger. When updating, empty fields are left unchanged.
+type TaskRequest struct {
+	Prompt        string             `json:"prompt,omitempty"`
+	ScheduleType  string             `json:"schedule_type,omitempty"`
+	ScheduleValue string             `json:"schedule_value,omitempty"`
+	Trigger       *TaskTrigger       `json:"trigger,omitempty"`
+	Retry         *TaskRetryPolicy   `json:"retry,omitempty"`
+	Timezone      *string            `json:"timezone,omitempty"` // IANA timezone, empty for server local time
+	Misfire       *TaskMisfirePolicy `json:"misfire,omitempty"`
+	Metadata      map[string]any     `json:"metadata,omitempty"`
+}
+
+// TaskRun represents a single execution of a task
+type TaskRun struct {
+	ID          string     `json:"id"`
+	TaskID      string     `json:"task_id"`
+	RunAt       time.Time  `json:"run_at"`
+	DurationMs  int64      `json:"duration_ms"`
+	Status      string     `json:"status"`
+	Result      string     `json:"result,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	TriggeredBy string     `json:"triggered_by,omitempty"`
+	Attempt     int        `json:"attempt,omitempty"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+}
+
+// ListTasks returns the tasks scheduled for an agent
+func (c *Client) ListTasks(agentName string) ([]Task, error) {
+	path := fmt.Sprintf("/api/agent/%s/tasks", agentName)
+	resp, err := c.doRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Tasks []Task `json:"tasks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return response.Tasks, nil
+}
+
+// GetTask returns a task of an agent
+func (c *Client) GetTask(agentName, id string) (*Task, error) {
+	path := fmt.Sprintf("/api/agent/%s/tasks/%s", agentName, id)
+	return c.decodeTask(http.MethodGet, path, nil)
+}
+
+// CreateTask schedules a new task for an agent
+func (c *Client) CreateTask(agentName string, task *TaskRequest) (*Task, error) {
+	path := fmt.Sprintf("/api/agent/%s/tasks", agentName)
+	return c.decodeTask(http.MethodPost, path, task)
+}
+
+// UpdateTask changes an existing task of an agent
+func (c *Client) UpdateTask(agentName, id string, task *TaskRequest) (*Task, error) {
+	path := fmt.Sprintf("/api/agent/%s/tasks/%s", agentName, id)
+	return c.decodeTask(http.MethodPut, path, task)
+}
+
+// DeleteTask removes a task of an agent
+func (c *Client) DeleteTask(agentName, id string) error {
+	path := fmt.Sprintf("/api/agent/%s/tasks/%s", agentName, id)
+	return c.taskRequest(http.MethodDelete, path, "delete task")
+}
+
+// GetTaskRuns returns a page of the run history of a task, most recent first,
+// and whether older runs are available
+func (c *Client) GetTaskRuns(agentName, id string, offset, limit int) ([]TaskRun, bool, error) {
+	query := url.Values{}
+	query.Set("offset", strconv.Itoa(offset))
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := fmt.Sprintf("/api/agent/%s/tasks/%s/runs?%s", agentName, id, query.Encode())
+
+	resp, err := c.doRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Runs    []TaskRun `json:"runs"`
+		HasMore bool      `json:"has_more"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, false, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return response.Runs, response.HasMore, nil
+}
+
+// RunTask starts a task immediately, outside of its schedule
+func (c *Client) RunTask(agentName, id string) error {
+	path := fmt.Sprintf("/api/agent/%s/tasks/%s/run", agentName, id)
+	return c.taskRequest(http.MethodPost, path, "run task")
+}
+
+// CancelTask cancels the current run of a task
+func (c *Client) CancelTask(agentName, id string) error {
+	path := fmt.Sprintf("/api/agent/%s/tasks/%s/cancel", agentName, id)
+	return c.taskRequest(http.MethodPost, path, "cancel task")
+}
+
+// PauseTask stops a task from being scheduled
+func (c *Client) PauseTask(agentName, id string) error {
+	path := fmt.Sprintf("/api/agent/%s/tasks/%s/pause", agentName, id)
+	return c.taskRequest(http.MethodPut, path, "pause task")
+}
+
+// ResumeTask schedules again a paused or dead lettered task
+func (c *Client) ResumeTask(agentName, id string) error {
+	path := fmt.Sprintf("/api/agent/%s/tasks/%s/resume", agentName, id)
+	return c.taskRequest(http.MethodPut, path, "resume task")
+}
+
+func (c *Client) decodeTask(method, path string, body interface{}) (*Task, error) {
+	resp, err := c.doRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var task Task
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &task, nil
+}
+
+func (c *Client) taskRequest(method, path, what string) error {
+	resp, err := c.doRequest(method, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if status, ok := response["status"]; ok && status == "ok" {
+		return nil
+	}
+	return fmt.Errorf("failed to %s: %v", what, response)
+}
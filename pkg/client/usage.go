@@ -0,0 +1,74 @@
+package localagi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Usage is the token usage of one or more LLM calls
+type Usage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Calls            int     `json:"calls"`
+	Cost             float64 `json:"cost,omitempty"`
+}
+
+// UsageCounter is the lifetime usage of a conversation or a scheduled task
+type UsageCounter struct {
+	Usage
+	LastUsed time.Time `json:"last_used"`
+}
+
+// UsageBucket is the usage of a time window
+type UsageBucket struct {
+	Start   time.Time        `json:"start"`
+	Usage   Usage            `json:"usage"`
+	Models  map[string]Usage `json:"models,omitempty"`
+	Sources map[string]Usage `json:"sources,omitempty"`
+}
+
+// UsageReport is the usage of an agent over a time range
+type UsageReport struct {
+	From          time.Time               `json:"from"`
+	To            time.Time               `json:"to"`
+	Granularity   string                  `json:"granularity"`
+	Total         Usage                   `json:"total"`
+	Models        map[string]Usage        `json:"models"`
+	Sources       map[string]Usage        `json:"sources"`
+	Buckets       []UsageBucket           `json:"buckets"`
+	Conversations map[string]UsageCounter `json:"conversations"`
+	Tasks         map[string]UsageCounter `json:"tasks"`
+}
+
+// GetUsage returns the token usage of an agent in "hour" or "day" buckets.
+// Zero times select the default range of the server.
+func (c *Client) GetUsage(agentName, granularity string, from, to time.Time) (*UsageReport, error) {
+	query := url.Values{}
+	if granularity != "" {
+		query.Set("granularity", granularity)
+	}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+	path := fmt.Sprintf("/api/agent/%s/usage?%s", agentName, query.Encode())
+
+	resp, err := c.doRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var report UsageReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &report, nil
+}
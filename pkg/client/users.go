@@ -0,0 +1,136 @@
+package localagi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// User is someone allowed to use the server. Role applies to every agent,
+// Agents grants roles on single agents on top of it. Roles are viewer,
+// operator and admin.
+type User struct {
+	Name      string            `json:"name"`
+	Role      string            `json:"role,omitempty"`
+	Agents    map[string]string `json:"agents,omitempty"`
+	APIKeys   []UserAPIKey      `json:"api_keys,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitempty"`
+}
+
+// UserAPIKey describes an API key of a user, the key itself is only
+// returned when it is created
+type UserAPIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Prefix    string    `json:"prefix"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CurrentUser describes the user the client is authenticated as
+type CurrentUser struct {
+	Name        string            `json:"Name"`
+	Role        string            `json:"Role"`
+	Agents      map[string]string `json:"Agents"`
+	Admin       bool              `json:"Admin"`
+	AuthEnabled bool              `json:"AuthEnabled"`
+	Session     bool              `json:"Session"`
+}
+
+// Me returns the user the client is authenticated as
+func (c *Client) Me() (*CurrentUser, error) {
+	resp, err := c.doRequest(http.MethodGet, "/api/me", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var user CurrentUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &user, nil
+}
+
+// ListUsers returns the users of the server
+func (c *Client) ListUsers() ([]User, error) {
+	resp, err := c.doRequest(http.MethodGet, "/api/users", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Users []User `json:"Users"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result.Users, nil
+}
+
+// CreateUser adds a user
+func (c *Client) CreateUser(user User) error {
+	resp, err := c.doRequest(http.MethodPost, "/api/users", user)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// UpdateUser replaces the role and grants of a user
+func (c *Client) UpdateUser(user User) error {
+	resp, err := c.doRequest(http.MethodPut, fmt.Sprintf("/api/users/%s", user.Name), user)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// DeleteUser removes a user and revokes its API keys
+func (c *Client) DeleteUser(name string) error {
+	resp, err := c.doRequest(http.MethodDelete, fmt.Sprintf("/api/users/%s", name), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// CreateUserAPIKey generates an API key for a user. The key can't be
+// retrieved again.
+func (c *Client) CreateUserAPIKey(user, name string) (string, *UserAPIKey, error) {
+	resp, err := c.doRequest(http.MethodPost, fmt.Sprintf("/api/users/%s/keys", user), map[string]string{"name": name})
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Key    string     `json:"Key"`
+		APIKey UserAPIKey `json:"APIKey"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result.Key, &result.APIKey, nil
+}
+
+// DeleteUserAPIKey revokes an API key of a user
+func (c *Client) DeleteUserAPIKey(user, id string) error {
+	resp, err := c.doRequest(http.MethodDelete, fmt.Sprintf("/api/users/%s/keys/%s", user, id), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
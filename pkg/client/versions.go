@@ -0,0 +1,103 @@
+package localagi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ConfigVersion is a version in the history of the configuration of an
+// agent. Config is only set when a single version is requested.
+type ConfigVersion struct {
+	Version   int             `json:"version"`
+	Agent     string          `json:"agent"`
+	Author    string          `json:"author"`
+	Action    string          `json:"action"`
+	Note      string          `json:"note,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Config    json.RawMessage `json:"config,omitempty"`
+	Diff      []ConfigChange  `json:"diff,omitempty"`
+}
+
+// ConfigChange is a configuration field that changed between two versions
+type ConfigChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// ListConfigVersions returns the history of the configuration of an agent,
+// oldest first
+func (c *Client) ListConfigVersions(agentName string) ([]ConfigVersion, error) {
+	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf("/api/agent/%s/config/versions", agentName), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Versions []ConfigVersion `json:"Versions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result.Versions, nil
+}
+
+// GetConfigVersion returns a version of the configuration of an agent
+func (c *Client) GetConfigVersion(agentName string, version int) (*ConfigVersion, error) {
+	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf("/api/agent/%s/config/versions/%d", agentName, version), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var v ConfigVersion
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &v, nil
+}
+
+// DiffConfigVersions returns the changes between two versions of the
+// configuration of an agent
+func (c *Client) DiffConfigVersions(agentName string, from, to int) ([]ConfigChange, error) {
+	path := fmt.Sprintf("/api/agent/%s/config/versions/diff?from=%d&to=%d", agentName, from, to)
+
+	resp, err := c.doRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Changes []ConfigChange `json:"Changes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result.Changes, nil
+}
+
+// RollbackConfigVersion restarts an agent with a previous version of its
+// configuration, and returns the version recording the rollback
+func (c *Client) RollbackConfigVersion(agentName string, version int) (*ConfigVersion, error) {
+	path := fmt.Sprintf("/api/agent/%s/config/versions/%d/rollback", agentName, version)
+
+	resp, err := c.doRequest(http.MethodPost, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var v ConfigVersion
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &v, nil
+}
@@ -0,0 +1,298 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mudler/xlog"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failures opening
+	// the circuit of an endpoint
+	DefaultFailureThreshold = 3
+	// DefaultCooldown is how long an endpoint with an open circuit is skipped
+	DefaultCooldown = 30 * time.Second
+	// DefaultHealthCheckInterval is how often the endpoints are probed
+	DefaultHealthCheckInterval = 30 * time.Second
+)
+
+// ErrNoEndpoints is returned when a request is made without endpoints
+var ErrNoEndpoints = errors.New("no LLM endpoints configured")
+
+// Endpoint is an OpenAI compatible API serving the models of an agent
+type Endpoint struct {
+	URL    string `json:"url"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// endpoint is an Endpoint with the state of its circuit breaker
+type endpoint struct {
+	Endpoint
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (e *endpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.openUntil)
+}
+
+func (e *endpoint) succeeded() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = 0
+	e.openUntil = time.Time{}
+}
+
+// failed counts a failure, and opens the circuit once there were threshold
+// failures in a row. After the cooldown a single failure opens it again.
+func (e *endpoint) failed(threshold int, cooldown time.Duration) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if e.failures >= threshold {
+		e.openUntil = time.Now().Add(cooldown)
+		return true
+	}
+	return false
+}
+
+// trip opens the circuit right away
+func (e *endpoint) trip(threshold int, cooldown time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = threshold
+	e.openUntil = time.Now().Add(cooldown)
+}
+
+// Endpoints routes the LLM requests of an agent over an ordered list of
+// endpoints serving the same models. Requests go to the first endpoint and
+// fail over to the next ones on errors and timeouts, or are spread across
+// all of them with load balancing. Endpoints failing repeatedly are skipped
+// for a while, as long as another one is available.
+type Endpoints struct {
+	endpoints []*endpoint
+	balance   bool
+	threshold int
+	cooldown  time.Duration
+	timeout   time.Duration
+	next      atomic.Uint32
+}
+
+type EndpointsOption func(*Endpoints)
+
+// WithLoadBalancing spreads the requests across the endpoints in turn
+// instead of always starting with the first one
+func WithLoadBalancing(enabled bool) EndpointsOption {
+	return func(e *Endpoints) {
+		e.balance = enabled
+	}
+}
+
+// WithCircuitBreaker sets the number of consecutive failures after which an
+// endpoint is skipped, and for how long
+func WithCircuitBreaker(threshold int, cooldown time.Duration) EndpointsOption {
+	return func(e *Endpoints) {
+		if threshold > 0 {
+			e.threshold = threshold
+		}
+		if cooldown > 0 {
+			e.cooldown = cooldown
+		}
+	}
+}
+
+// WithRequestTimeout bounds each attempt, so that a hanging endpoint fails
+// over to the next one. It only applies when there is more than one endpoint.
+func WithRequestTimeout(timeout time.Duration) EndpointsOption {
+	return func(e *Endpoints) {
+		e.timeout = timeout
+	}
+}
+
+// NewEndpoints creates the router for endpoints, ignoring the ones without URL
+func NewEndpoints(endpoints []Endpoint, opts ...EndpointsOption) *Endpoints {
+	e := &Endpoints{
+		threshold: DefaultFailureThreshold,
+		cooldown:  DefaultCooldown,
+	}
+	for _, ep := range endpoints {
+		if ep.URL == "" {
+			continue
+		}
+		e.endpoints = append(e.endpoints, &endpoint{Endpoint: ep})
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// List returns the endpoints, in order
+func (e *Endpoints) List() []Endpoint {
+	list := make([]Endpoint, 0, len(e.endpoints))
+	for _, ep := range e.endpoints {
+		list = append(list, ep.Endpoint)
+	}
+	return list
+}
+
+// order returns the indexes of the endpoints to try for a request
+func (e *Endpoints) order() []int {
+	n := len(e.endpoints)
+	start := 0
+	if e.balance && n > 1 {
+		start = int((e.next.Add(1) - 1) % uint32(n))
+	}
+
+	now := time.Now()
+	available := make([]int, 0, n)
+	skipped := make([]int, 0)
+	for k := 0; k < n; k++ {
+		i := (start + k) % n
+		if e.endpoints[i].available(now) {
+			available = append(available, i)
+		} else {
+			skipped = append(skipped, i)
+		}
+	}
+	// Endpoints with an open circuit are only tried when all of them are
+	if len(available) == 0 {
+		return skipped
+	}
+	return available
+}
+
+// Do calls fn with the index of an endpoint until one succeeds. It stops on
+// errors that another endpoint would return as well, such as invalid
+// requests, and when ctx is done. The context given to fn is only valid
+// until fn returns.
+func (e *Endpoints) Do(ctx context.Context, fn func(ctx context.Context, i int) error) error {
+	if len(e.endpoints) == 0 {
+		return ErrNoEndpoints
+	}
+
+	var err error
+	for _, i := range e.order() {
+		ep := e.endpoints[i]
+		err = e.attempt(ctx, i, fn)
+		if err == nil {
+			ep.succeeded()
+			return nil
+		}
+		if ctx.Err() != nil || !Retryable(err) {
+			return err
+		}
+		if ep.failed(e.threshold, e.cooldown) {
+			xlog.Warn("LLM endpoint is failing, skipping it", "url", ep.URL, "cooldown", e.cooldown, "error", err)
+		} else {
+			xlog.Warn("LLM endpoint request failed", "url", ep.URL, "error", err)
+		}
+	}
+	return err
+}
+
+func (e *Endpoints) attempt(ctx context.Context, i int, fn func(ctx context.Context, i int) error) error {
+	if e.timeout > 0 && len(e.endpoints) > 1 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+	return fn(ctx, i)
+}
+
+// HealthCheck probes every endpoint once: unreachable endpoints, or ones
+// answering with a server error, are skipped until they recover
+func (e *Endpoints) HealthCheck(ctx context.Context, client *http.Client) {
+	for _, ep := range e.endpoints {
+		if err := probe(ctx, client, ep.Endpoint); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			xlog.Warn("LLM endpoint health check failed", "url", ep.URL, "error", err)
+			ep.trip(e.threshold, e.cooldown)
+			continue
+		}
+		ep.succeeded()
+	}
+}
+
+// StartHealthChecks probes the endpoints every interval until ctx is done.
+// It does nothing with a single endpoint, as there is nothing to fail over to.
+func (e *Endpoints) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	if len(e.endpoints) < 2 || interval <= 0 {
+		return
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.HealthCheck(ctx, client)
+			}
+		}
+	}()
+}
+
+func probe(ctx context.Context, client *http.Client, ep Endpoint) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(ep.URL, "/")+"/models", nil)
+	if err != nil {
+		return err
+	}
+	if ep.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+ep.APIKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Retryable reports whether a request failing with err may succeed on
+// another endpoint. Client errors, other than timeouts and rate limits,
+// would fail everywhere.
+func Retryable(err error) bool {
+	code := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		code = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		code = reqErr.HTTPStatusCode
+	}
+	if code >= 400 && code < 500 {
+		return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// Clients creates a client for each endpoint, in the same order, so that the
+// index given by Do selects the client of the endpoint
+func Clients[T any](e *Endpoints, newClient func(Endpoint) T) []T {
+	clients := make([]T, 0, len(e.endpoints))
+	for _, ep := range e.endpoints {
+		clients = append(clients, newClient(ep.Endpoint))
+	}
+	return clients
+}
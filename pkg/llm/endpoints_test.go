@@ -0,0 +1,140 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/mudler/LocalAGI/pkg/llm"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+)
+
+var errUnavailable = errors.New("connection refused")
+
+var _ = Describe("Endpoints", func() {
+	list := []Endpoint{{URL: "http://a"}, {URL: "http://b"}, {URL: "http://c"}}
+
+	// calls records the endpoints tried by a request failing on the ones in fail
+	calls := func(e *Endpoints, fail map[int]error) ([]int, error) {
+		var tried []int
+		err := e.Do(context.Background(), func(_ context.Context, i int) error {
+			tried = append(tried, i)
+			return fail[i]
+		})
+		return tried, err
+	}
+
+	It("ignores endpoints without URL", func() {
+		e := NewEndpoints([]Endpoint{{URL: ""}, {URL: "http://a"}})
+		Expect(e.List()).To(Equal([]Endpoint{{URL: "http://a"}}))
+
+		err := NewEndpoints(nil).Do(context.Background(), func(context.Context, int) error { return nil })
+		Expect(err).To(MatchError(ErrNoEndpoints))
+	})
+
+	It("fails over to the next endpoints in order", func() {
+		e := NewEndpoints(list)
+		tried, err := calls(e, map[int]error{0: errUnavailable})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]int{0, 1}))
+
+		tried, err = calls(e, map[int]error{0: errUnavailable, 1: errUnavailable, 2: errUnavailable})
+		Expect(err).To(MatchError(errUnavailable))
+		Expect(tried).To(Equal([]int{0, 1, 2}))
+	})
+
+	It("does not fail over on client errors", func() {
+		e := NewEndpoints(list)
+		badRequest := &openai.APIError{HTTPStatusCode: http.StatusBadRequest}
+		tried, err := calls(e, map[int]error{0: badRequest})
+		Expect(err).To(HaveOccurred())
+		Expect(tried).To(Equal([]int{0}))
+
+		rateLimited := &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}
+		tried, err = calls(e, map[int]error{0: rateLimited})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]int{0, 1}))
+	})
+
+	It("fails over when an endpoint times out", func() {
+		e := NewEndpoints(list, WithRequestTimeout(10*time.Millisecond))
+		var tried []int
+		err := e.Do(context.Background(), func(ctx context.Context, i int) error {
+			tried = append(tried, i)
+			if i == 0 {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]int{0, 1}))
+	})
+
+	It("skips endpoints with an open circuit until the cooldown", func() {
+		e := NewEndpoints(list, WithCircuitBreaker(2, 50*time.Millisecond))
+		for k := 0; k < 2; k++ {
+			_, err := calls(e, map[int]error{0: errUnavailable})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		tried, err := calls(e, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]int{1}))
+
+		Eventually(func() []int {
+			tried, _ := calls(e, nil)
+			return tried
+		}).Should(Equal([]int{0}))
+	})
+
+	It("tries endpoints with an open circuit when no other is available", func() {
+		e := NewEndpoints(list[:1], WithCircuitBreaker(1, time.Minute))
+		_, err := calls(e, map[int]error{0: errUnavailable})
+		Expect(err).To(HaveOccurred())
+
+		tried, err := calls(e, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]int{0}))
+	})
+
+	It("spreads the requests with load balancing", func() {
+		e := NewEndpoints(list, WithLoadBalancing(true))
+		var first []int
+		for k := 0; k < 4; k++ {
+			tried, err := calls(e, nil)
+			Expect(err).ToNot(HaveOccurred())
+			first = append(first, tried[0])
+		}
+		Expect(first).To(Equal([]int{0, 1, 2, 0}))
+	})
+
+	It("skips endpoints failing the health check", func() {
+		healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer key" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(`{"data":[]}`))
+		}))
+		defer healthy.Close()
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer failing.Close()
+
+		e := NewEndpoints([]Endpoint{
+			{URL: failing.URL + "/v1"},
+			{URL: healthy.URL + "/v1", APIKey: "key"},
+		})
+		e.HealthCheck(context.Background(), http.DefaultClient)
+
+		tried, err := calls(e, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tried).To(Equal([]int{1}))
+	})
+})
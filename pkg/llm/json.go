@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/xlog"
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
@@ -44,6 +45,12 @@ func GenerateTypedJSONWithConversation(ctx context.Context, client *openai.Clien
 	if err != nil {
 		return err
 	}
+	types.ReportUsage(ctx, model, types.Usage{
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+		Calls:            1,
+	})
 
 	if len(resp.Choices) != 1 {
 		return fmt.Errorf("no choices: %d", len(resp.Choices))
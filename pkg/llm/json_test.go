@@ -0,0 +1,45 @@
+package llm_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/mudler/LocalAGI/pkg/llm"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+var _ = Describe("GenerateTypedJSON", func() {
+	It("reports the usage of the call to the job", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{
+				"choices": [{"message": {"role": "assistant", "tool_calls": [
+					{"id": "1", "type": "function", "function": {"name": "json", "arguments": "{\"answer\": true}"}}
+				]}}],
+				"usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}
+			}`))
+		}))
+		defer server.Close()
+
+		var reported []types.Usage
+		ctx := types.WithUsageReporter(context.Background(), func(model string, u types.Usage) {
+			Expect(model).To(Equal("small"))
+			reported = append(reported, u)
+		})
+
+		var result struct {
+			Answer bool `json:"answer"`
+		}
+		err := GenerateTypedJSONWithGuidance(ctx, NewClient("", server.URL, "1m"), "is it?", "small", jsonschema.Definition{
+			Type:       jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{"answer": {Type: jsonschema.Boolean}},
+		}, &result)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Answer).To(BeTrue())
+		Expect(reported).To(Equal([]types.Usage{{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12, Calls: 1}}))
+	})
+})
@@ -54,6 +54,7 @@ const (
 	ActionSetReminder                    = "set_reminder"
 	ActionSetRecurringReminder           = "set_recurring_reminder"
 	ActionSetOneTimeReminder             = "set_onetime_reminder"
+	ActionSetTriggeredReminder           = "set_triggered_reminder"
 	ActionListReminders                  = "list_reminders"
 	ActionRemoveReminder                 = "remove_reminder"
 	ActionAddToMemory                    = "add_to_memory"
@@ -64,6 +65,28 @@ const (
 	ActionWebhook                        = "webhook"
 )
 
+// readOnlyActions only read from the outside of LocalAGI. Every other
+// action, including the custom and the unknown ones, is treated as having
+// side effects and its runs by agents are recorded in the audit log.
+var readOnlyActions = map[string]bool{
+	ActionSearch:                      true,
+	ActionGithubIssueSearcher:         true,
+	ActionGithubRepositoryGet:         true,
+	ActionGithubIssueReader:           true,
+	ActionGithubPRReader:              true,
+	ActionGithubGetAllContent:         true,
+	ActionGithubREADME:                true,
+	ActionGithubRepositorySearchFiles: true,
+	ActionGithubRepositoryListFiles:   true,
+	ActionScraper:                     true,
+	ActionWikipedia:                   true,
+	ActionBrowse:                      true,
+	ActionCounter:                     true,
+	ActionListReminders:               true,
+	ActionListMemory:                  true,
+	ActionSearchMemory:                true,
+}
+
 const (
 	nameField          = "name"
 	descriptionField   = "description"
@@ -299,6 +322,11 @@ var DefaultActions = []config.FieldGroup{
 		Label:  "Set One-Time Reminder",
 		Fields: []config.Field{},
 	},
+	{
+		Name:   "set_triggered_reminder",
+		Label:  "Set Triggered Reminder",
+		Fields: []config.Field{},
+	},
 	{
 		Name:   "list_reminders",
 		Label:  "List Reminders",
@@ -364,7 +392,7 @@ func customActions(customActionsDir string, existingActionConfigs map[string]map
 			xlog.Error("Error creating custom action", "error", err, "file", file.Name())
 			continue
 		}
-		allActions = append(allActions, a)
+		allActions = append(allActions, types.WithSideEffects(a))
 	}
 	return
 }
@@ -385,11 +413,18 @@ func Actions(actionsConfigs map[string]string) func(a *state.AgentConfig) func(c
 				}
 
 				existingActionConfigs[a.Name] = config
+				actionName, requiresApproval := a.Name, a.RequiresApproval
 
 				a, err := Action(a.Name, agentName, config, pool, actionsConfigs)
 				if err != nil {
 					continue
 				}
+				if !readOnlyActions[actionName] {
+					a = types.WithSideEffects(a)
+				}
+				if requiresApproval {
+					a = types.WithApproval(a)
+				}
 				allActions = append(allActions, a)
 			}
 
@@ -483,6 +518,8 @@ func Action(name, agentName string, config map[string]string, pool *state.AgentP
 		a = action.NewRecurringReminder()
 	case ActionSetOneTimeReminder:
 		a = action.NewOneTimeReminder()
+	case ActionSetTriggeredReminder:
+		a = action.NewTriggeredReminder()
 	case ActionListReminders:
 		a = action.NewListReminders()
 	case ActionRemoveReminder:
@@ -11,6 +11,7 @@ import (
 	"github.com/mudler/LocalAGI/pkg/config"
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func trimList(list []string) []string {
@@ -69,7 +70,10 @@ func (a *CallAgentAction) Run(ctx context.Context, sharedState *types.AgentShare
 		return types.ActionResult{}, fmt.Errorf("agent '%s' not found", result.AgentName)
 	}
 
+	// Carry the trace over so that both agents show up in the same trace,
+	// but not the cancellation of the calling job
 	resp := ag.Ask(
+		types.WithContext(trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))),
 		types.WithConversationHistory(
 			[]openai.ChatCompletionMessage{
 				{
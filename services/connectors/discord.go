@@ -7,6 +7,7 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/metrics"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/pkg/config"
 	"github.com/mudler/xlog"
@@ -16,6 +17,7 @@ import (
 type Discord struct {
 	token          string
 	defaultChannel string
+	session        *discordgo.Session
 }
 
 // NewDiscord creates a new Discord connector
@@ -82,6 +84,7 @@ func (d *Discord) Start(a *agent.Agent) {
 	}
 
 	dg.StateEnabled = true
+	d.session = dg
 
 	if d.defaultChannel != "" {
 		// handle new conversations
@@ -154,8 +157,14 @@ func (d *Discord) handleThreadMessage(a *agent.Agent, s *discordgo.Session, m *d
 
 	xlog.Debug("Conversation", "conversation", conv)
 
+	metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "discord").Inc()
 	jobResult := a.Ask(
 		types.WithConversationHistory(conv),
+		types.WithMetadata(map[string]interface{}{
+			"discordChannel":                m.ChannelID,
+			types.MetadataKeyConversationID: "discord:" + m.ChannelID,
+			types.MetadataKeySender:         "discord:" + m.Author.ID,
+		}),
 	)
 
 	if jobResult.Error != nil {
@@ -178,8 +187,14 @@ func (d *Discord) handleChannelMessage(a *agent.Agent, s *discordgo.Session, m *
 
 	conv := a.SharedState().ConversationTracker.GetConversation(fmt.Sprintf("discord:%s", m.ChannelID))
 
+	metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "discord").Inc()
 	jobResult := a.Ask(
 		types.WithConversationHistory(conv),
+		types.WithMetadata(map[string]interface{}{
+			"discordChannel":                m.ChannelID,
+			types.MetadataKeyConversationID: "discord:" + m.ChannelID,
+			types.MetadataKeySender:         "discord:" + m.Author.ID,
+		}),
 	)
 
 	if jobResult.Error != nil {
@@ -212,6 +227,24 @@ func (d *Discord) handleChannelMessage(a *agent.Agent, s *discordgo.Session, m *
 
 }
 
+// Notify posts a notice, such as a budget being exhausted, in the job's
+// channel
+func (d *Discord) Notify(job *types.Job, message string) bool {
+	if job == nil || job.Metadata == nil || d.session == nil {
+		return false
+	}
+	channel, ok := job.Metadata["discordChannel"].(string)
+	if !ok || channel == "" {
+		return false
+	}
+
+	if _, err := d.session.ChannelMessageSend(channel, "⚠️ "+message); err != nil {
+		xlog.Error("Error sending notice", "error", err)
+		return false
+	}
+	return true
+}
+
 func removeBotID(s *discordgo.Session, m string) string {
 	return strings.ReplaceAll(m, "<@"+s.State.User.ID+">", "")
 }
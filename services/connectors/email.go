@@ -2,6 +2,7 @@ package connectors
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"mime"
 	"strings"
@@ -20,7 +21,9 @@ import (
 	"github.com/gomarkdown/markdown/parser"
 
 	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/metrics"
 	"github.com/mudler/LocalAGI/core/types"
+	"github.com/mudler/LocalAGI/core/usage"
 	"github.com/mudler/LocalAGI/pkg/config"
 	"github.com/mudler/xlog"
 	"github.com/sashabaranov/go-openai"
@@ -299,11 +302,28 @@ func imapWorker(done chan bool, e *Email, a *agent.Agent, c *imapclient.Client,
 					conv := []openai.ChatCompletionMessage{}
 					conv = append(conv, openai.ChatCompletionMessage{Role: "user", Content: prompt})
 
+					fromEmail := fmt.Sprintf("%s@%s", fmb.Envelope.From[0].Mailbox, fmb.Envelope.From[0].Host)
+
 					// Send prompt to agent and wait for result
 					xlog.Debug(fmt.Sprintf("Starting conversation:\n\n%v", conv))
-					jobResult := a.Ask(types.WithConversationHistory(conv))
+					metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "email").Inc()
+					jobResult := a.Ask(
+						types.WithConversationHistory(conv),
+						types.WithMetadata(map[string]interface{}{
+							"emailFrom":      fromEmail,
+							"emailSubject":   msg.Header.Get("Subject"),
+							"emailMessageID": msg.Header.Get("Message-ID"),
+							// The conversation is the one with the sender
+							types.MetadataKeyConversationID: "email:" + fromEmail,
+							types.MetadataKeySender:         "email:" + fromEmail,
+						}),
+					)
 					if jobResult.Error != nil {
 						xlog.Error(fmt.Sprintf("Error asking agent: %v", jobResult.Error))
+						if errors.Is(jobResult.Error, usage.ErrBudgetExceeded) {
+							// The sender was already told by Notify
+							return
+						}
 					}
 
 					// Send agent response to user, replying to original email.
@@ -311,7 +331,6 @@ func imapWorker(done chan bool, e *Email, a *agent.Agent, c *imapclient.Client,
 
 					// Get a list of emails to respond to ("Reply All" logic)
 					// This could be done through regex, but it's probably safer to rebuild explicitly
-					fromEmail := fmt.Sprintf("%s@%s", fmb.Envelope.From[0].Mailbox, fmb.Envelope.From[0].Host)
 					emails := []string{}
 					emails = append(emails, fromEmail)
 
@@ -373,6 +392,23 @@ func imapWorker(done chan bool, e *Email, a *agent.Agent, c *imapclient.Client,
 	}
 }
 
+// Notify replies to the sender of the job's email with a notice, such as a
+// budget being exhausted
+func (e *Email) Notify(job *types.Job, message string) bool {
+	if job == nil || job.Metadata == nil {
+		return false
+	}
+	from, ok := job.Metadata["emailFrom"].(string)
+	if !ok || from == "" {
+		return false
+	}
+	subject, _ := job.Metadata["emailSubject"].(string)
+	messageID, _ := job.Metadata["emailMessageID"].(string)
+
+	e.sendMail(from, "Re: "+subject, "System: "+message, messageID, messageID, []string{from}, false)
+	return true
+}
+
 func (e *Email) Start(a *agent.Agent) {
 	go func() {
 		if e.defaultEmail != "" {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/go-github/v69/github"
 	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/metrics"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/pkg/config"
 	"github.com/mudler/xlog"
@@ -176,6 +177,7 @@ func (g *GithubPRs) prService() {
 			continue
 		}
 
+		metrics.ConnectorMessages.WithLabelValues(g.agent.Character.Name, "github-prs").Inc()
 		res := g.agent.Ask(
 			types.WithConversationHistory(messages),
 		)
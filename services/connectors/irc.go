@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/metrics"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/pkg/config"
 	"github.com/mudler/LocalAGI/services/actions"
@@ -63,6 +64,38 @@ func isMentioned(message string, nickname string) bool {
 }
 
 // Start connects to the IRC server and starts listening for messages
+// Notify posts a notice, such as a budget being exhausted, in the job's
+// channel
+func (i *IRC) Notify(job *types.Job, message string) bool {
+	if job == nil || job.Metadata == nil || i.conn == nil {
+		return false
+	}
+	channel, ok := job.Metadata["ircChannel"].(string)
+	if !ok || channel == "" {
+		return false
+	}
+	i.conn.Privmsg(channel, message)
+	return true
+}
+
+// Reply sends the reply of a job, such as one replayed from the journal, in
+// the job's channel
+func (i *IRC) Reply(job *types.Job, response string) bool {
+	if job == nil || job.Metadata == nil || i.conn == nil {
+		return false
+	}
+	channel, ok := job.Metadata["ircChannel"].(string)
+	if !ok || channel == "" {
+		return false
+	}
+	for _, line := range strings.Split(response, "\n") {
+		if line != "" {
+			i.conn.Privmsg(channel, line)
+		}
+	}
+	return true
+}
+
 func (i *IRC) Start(a *agent.Agent) {
 	i.conn = irc.IRC(i.nickname, i.nickname)
 	if i.conn == nil {
@@ -170,8 +203,13 @@ func (i *IRC) Start(a *agent.Agent) {
 				Role:    "user",
 			})
 
+			metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "irc").Inc()
 			res := a.Ask(
 				types.WithConversationHistory(conv),
+				types.WithMetadata(map[string]any{
+					"ircChannel":            channel,
+					types.MetadataKeySender: "irc:" + sender,
+				}),
 			)
 
 			if res.Response == "" {
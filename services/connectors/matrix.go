@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/metrics"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/pkg/config"
 	"github.com/mudler/xlog"
@@ -141,6 +142,7 @@ func (m *Matrix) handleRoomMessage(a *agent.Agent, evt *event.Event) {
 		metadata := map[string]any{
 			"room":                          evt.RoomID.String(),
 			types.MetadataKeyConversationID: "matrix:" + evt.RoomID.String(),
+			types.MetadataKeySender:         "matrix:" + evt.Sender.String(),
 		}
 		agentOptions = append(agentOptions, types.WithMetadata(metadata))
 
@@ -164,6 +166,7 @@ func (m *Matrix) handleRoomMessage(a *agent.Agent, evt *event.Event) {
 			m.activeJobsMutex.Unlock()
 		}()
 
+		metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "matrix").Inc()
 		res := a.Ask(
 			agentOptions...,
 		)
@@ -193,6 +196,41 @@ func (m *Matrix) handleRoomMessage(a *agent.Agent, evt *event.Event) {
 	}()
 }
 
+// Notify posts a notice, such as a budget being exhausted, in the job's room
+func (m *Matrix) Notify(job *types.Job, message string) bool {
+	if job == nil || job.Metadata == nil || m.client == nil {
+		return false
+	}
+	room, ok := job.Metadata["room"].(string)
+	if !ok || room == "" {
+		return false
+	}
+
+	if _, err := m.client.SendText(context.Background(), id.RoomID(room), "⚠️ "+message); err != nil {
+		xlog.Error(fmt.Sprintf("Error posting notice: %v", err))
+		return false
+	}
+	return true
+}
+
+// Reply posts the reply of a job, such as one replayed from the journal, in
+// the job's room
+func (m *Matrix) Reply(job *types.Job, response string) bool {
+	if job == nil || job.Metadata == nil || m.client == nil {
+		return false
+	}
+	room, ok := job.Metadata["room"].(string)
+	if !ok || room == "" {
+		return false
+	}
+
+	if _, err := m.client.SendText(context.Background(), id.RoomID(room), response); err != nil {
+		xlog.Error(fmt.Sprintf("Error posting reply: %v", err))
+		return false
+	}
+	return true
+}
+
 func (m *Matrix) Start(a *agent.Agent) {
 	client, err := mautrix.NewClient(m.homeserverURL, id.UserID(m.userID), m.accessToken)
 	if err != nil {
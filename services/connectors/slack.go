@@ -3,12 +3,14 @@ package connectors
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
@@ -19,6 +21,9 @@ import (
 	"github.com/sashabaranov/go-openai"
 
 	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/approval"
+	"github.com/mudler/LocalAGI/core/metrics"
+	"github.com/mudler/LocalAGI/core/ratelimit"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/services/connectors/common"
 
@@ -45,10 +50,21 @@ type Slack struct {
 	// Track active jobs for cancellation
 	activeJobs      map[string][]*types.Job // map[channelID]bool to track if a channel has active processing
 	activeJobsMutex sync.RWMutex
+
+	// Pending approval requests waiting for a button click
+	approvals      map[string]func(approved bool, by string) // map[approvalID]resolve
+	approvalsMutex sync.Mutex
+	// Slack user IDs allowed to answer approval requests
+	approvers []string
 }
 
 const thinkingMessage = ":hourglass: thinking..."
 
+const (
+	approvalApproveActionID = "approval_approve"
+	approvalDenyActionID    = "approval_deny"
+)
+
 func NewSlack(config map[string]string) *Slack {
 
 	return &Slack{
@@ -59,9 +75,21 @@ func NewSlack(config map[string]string) *Slack {
 		placeholders: make(map[string]string),
 		jobStatus:    make(map[string]*common.StatusAccumulator),
 		activeJobs:   make(map[string][]*types.Job),
+		approvals:    make(map[string]func(approved bool, by string)),
+		approvers:    parseApprovers(config["approvers"]),
 	}
 }
 
+func parseApprovers(list string) []string {
+	approvers := []string{}
+	for _, approver := range strings.Split(list, ",") {
+		if approver = strings.TrimSpace(approver); approver != "" {
+			approvers = append(approvers, approver)
+		}
+	}
+	return approvers
+}
+
 func (t *Slack) AgentResultCallback() func(state types.ActionState) {
 	return func(state types.ActionState) {
 		// Update placeholder with tool result if still in progress
@@ -541,7 +569,8 @@ func (t *Slack) handleChannelMessage(
 		images := scanImagesInMessages(api, ev)
 
 		agentOptions := []types.JobOption{
-			types.WithUUID(ev.ThreadTimeStamp),
+			// The message timestamp makes deliveries of the same message the same job
+			types.WithUUID(fmt.Sprintf("slack:%s:%s", ev.Channel, ev.TimeStamp)),
 		}
 
 		// If the last message has an image, we send it as a multi content message
@@ -564,6 +593,7 @@ func (t *Slack) handleChannelMessage(
 		metadata := map[string]interface{}{
 			"channel":                       ev.Channel,
 			types.MetadataKeyConversationID: "slack:" + ev.Channel,
+			types.MetadataKeySender:         "slack:" + ev.User,
 		}
 		agentOptions = append(agentOptions, types.WithMetadata(metadata))
 
@@ -588,6 +618,7 @@ func (t *Slack) handleChannelMessage(
 			t.activeJobsMutex.Unlock()
 		}()
 
+		metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "slack").Inc()
 		res := a.Ask(
 			agentOptions...,
 		)
@@ -764,9 +795,9 @@ func (t *Slack) handleMention(
 			}
 		}
 
-		// Store the UUID->placeholder message mapping
-		// We'll use the thread timestamp as our UUID
-		jobUUID := msgTs
+		// Store the UUID->placeholder message mapping. The UUID comes from
+		// the mention, so that deliveries of the same event are the same job
+		jobUUID := fmt.Sprintf("slack:%s:%s", ev.Channel, ev.TimeStamp)
 
 		t.placeholderMutex.Lock()
 		t.placeholders[jobUUID] = msgTs
@@ -842,16 +873,29 @@ func (t *Slack) handleMention(
 		// Add channel and conversation_id for callbacks and cancel-previous-on-new-message
 		metadata := map[string]interface{}{
 			"channel":                       ev.Channel,
+			"thread_ts":                     ts,
 			types.MetadataKeyConversationID: "slack:" + ev.Channel,
+			types.MetadataKeySender:         "slack:" + ev.User,
 		}
 
 		// Call the agent with the conversation history
+		metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "slack").Inc()
 		res := a.Ask(
 			types.WithConversationHistory(threadMessages),
 			types.WithUUID(jobUUID),
 			types.WithMetadata(metadata),
 		)
 
+		if res != nil && errors.Is(res.Error, ratelimit.ErrLimited) {
+			// The user was already asked to slow down in the channel
+			if msgTs != "" {
+				if _, _, err := api.DeleteMessage(ev.Channel, msgTs); err != nil {
+					xlog.Error(fmt.Sprintf("Error deleting message: %v", err))
+				}
+			}
+			return
+		}
+
 		if res == nil || res.Response == "" {
 			xlog.Debug(fmt.Sprintf("Empty response from agent"))
 			replyToUpdateMessage("there was an internal error. try again!", api, ev, msgTs, ts, postMessageParams, res)
@@ -887,6 +931,147 @@ func (t *Slack) handleMention(
 	}()
 }
 
+// Notify posts a notice, such as a budget being exhausted, in the job's channel
+func (t *Slack) Notify(job *types.Job, message string) bool {
+	if job == nil || job.Metadata == nil || t.apiClient == nil {
+		return false
+	}
+	channel, ok := job.Metadata["channel"].(string)
+	if !ok || channel == "" {
+		return false
+	}
+
+	if _, _, err := t.apiClient.PostMessage(channel, slack.MsgOptionText(":warning: "+message, false)); err != nil {
+		xlog.Error(fmt.Sprintf("Error posting notice: %v", err))
+		return false
+	}
+	return true
+}
+
+// Reply posts the reply of a job, such as one replayed from the journal,
+// in the job's channel, in its thread when it has one
+func (t *Slack) Reply(job *types.Job, response string) bool {
+	if job == nil || job.Metadata == nil || t.apiClient == nil {
+		return false
+	}
+	channel, ok := job.Metadata["channel"].(string)
+	if !ok || channel == "" {
+		return false
+	}
+
+	opts := []slack.MsgOption{
+		slack.MsgOptionLinkNames(true),
+		slack.MsgOptionText(githubmarkdownconvertergo.Slack(response), false),
+	}
+	if thread, ok := job.Metadata["thread_ts"].(string); ok && thread != "" {
+		opts = append(opts, slack.MsgOptionTS(thread))
+	}
+	if _, _, err := t.apiClient.PostMessage(channel, opts...); err != nil {
+		xlog.Error(fmt.Sprintf("Error posting reply: %v", err))
+		return false
+	}
+	return true
+}
+
+// RequestApproval asks in the job's channel whether the action can run,
+// using buttons that resolve the approval request when clicked. Without
+// approvers configured, requests are only answered in the web UI.
+func (t *Slack) RequestApproval(job *types.Job, request approval.Request, resolve func(approved bool, by string)) bool {
+	if job == nil || job.Metadata == nil || t.apiClient == nil || len(t.approvers) == 0 {
+		return false
+	}
+	channel, ok := job.Metadata["channel"].(string)
+	if !ok || channel == "" {
+		return false
+	}
+
+	text := fmt.Sprintf(":warning: The agent wants to run *%s*", request.Action)
+	if params := request.Params.String(); params != "" && params != "{}" {
+		text += fmt.Sprintf("\n```%s```", params)
+	}
+	if request.Reasoning != "" {
+		text += fmt.Sprintf("\n_%s_", request.Reasoning)
+	}
+
+	t.approvalsMutex.Lock()
+	t.approvals[request.ID] = resolve
+	t.approvalsMutex.Unlock()
+
+	_, _, err := t.apiClient.PostMessage(channel,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+			slack.NewActionBlock(request.ID,
+				slack.NewButtonBlockElement(approvalApproveActionID, request.ID,
+					slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false)).WithStyle(slack.StylePrimary),
+				slack.NewButtonBlockElement(approvalDenyActionID, request.ID,
+					slack.NewTextBlockObject(slack.PlainTextType, "Deny", false, false)).WithStyle(slack.StyleDanger),
+			),
+		),
+	)
+	if err != nil {
+		xlog.Error(fmt.Sprintf("Error posting approval request: %v", err))
+		t.approvalsMutex.Lock()
+		delete(t.approvals, request.ID)
+		t.approvalsMutex.Unlock()
+		return false
+	}
+
+	return true
+}
+
+// ApprovalDone drops a request answered elsewhere, e.g. in the web UI, or
+// that expired
+func (t *Slack) ApprovalDone(request approval.Request) {
+	t.approvalsMutex.Lock()
+	delete(t.approvals, request.ID)
+	t.approvalsMutex.Unlock()
+}
+
+// handleApprovalAction resolves the approval request matching a clicked button
+// and replaces the buttons with the decision.
+func (t *Slack) handleApprovalAction(api *slack.Client, callback slack.InteractionCallback) {
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.ActionID != approvalApproveActionID && action.ActionID != approvalDenyActionID {
+			continue
+		}
+
+		if !slices.Contains(t.approvers, callback.User.ID) {
+			xlog.Info("Unauthorized user tried to answer an approval", "user", callback.User.ID, "approval", action.Value)
+			if _, err := api.PostEphemeral(callback.Channel.ID, callback.User.ID,
+				slack.MsgOptionText("You are not allowed to answer this request.", false)); err != nil {
+				xlog.Error(fmt.Sprintf("Error posting ephemeral message: %v", err))
+			}
+			continue
+		}
+
+		t.approvalsMutex.Lock()
+		resolve, exists := t.approvals[action.Value]
+		delete(t.approvals, action.Value)
+		t.approvalsMutex.Unlock()
+
+		status := "_This request is no longer pending_"
+		if exists {
+			approved := action.ActionID == approvalApproveActionID
+			resolve(approved, "slack:"+callback.User.ID)
+			status = fmt.Sprintf(":x: *Denied* by <@%s>", callback.User.ID)
+			if approved {
+				status = fmt.Sprintf(":white_check_mark: *Approved* by <@%s>", callback.User.ID)
+			}
+		}
+
+		_, _, _, err := api.UpdateMessage(
+			callback.Channel.ID,
+			callback.Message.Timestamp,
+			slack.MsgOptionText(callback.Message.Text+"\n"+status, false),
+			slack.MsgOptionBlocks(),
+		)
+		if err != nil {
+			xlog.Error(fmt.Sprintf("Error updating approval message: %v", err))
+		}
+	}
+}
+
 func (t *Slack) Start(a *agent.Agent) {
 
 	postMessageParams := slack.PostMessageParameters{
@@ -953,6 +1138,18 @@ func (t *Slack) Start(a *agent.Agent) {
 				xlog.Info("Connection failed. Retrying later...")
 			case socketmode.EventTypeConnected:
 				xlog.Info("Connected to Slack with Socket Mode.")
+			case socketmode.EventTypeInteractive:
+				callback, ok := evt.Data.(slack.InteractionCallback)
+				if !ok {
+					xlog.Debug(fmt.Sprintf("Ignored %+v\n", evt))
+					continue
+				}
+
+				client.Ack(*evt.Request)
+
+				if callback.Type == slack.InteractionTypeBlockActions {
+					t.handleApprovalAction(api, callback)
+				}
 			case socketmode.EventTypeEventsAPI:
 				eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
 				if !ok {
@@ -1017,5 +1214,11 @@ func SlackConfigMeta() []config.Field {
 			Label: "Always Reply",
 			Type:  config.FieldTypeCheckbox,
 		},
+		{
+			Name:     "approvers",
+			Label:    "Approvers",
+			Type:     config.FieldTypeText,
+			HelpText: "Comma-separated list of Slack user IDs allowed to answer approval requests in Slack. When empty, actions are approved in the web UI only",
+		},
 	}
 }
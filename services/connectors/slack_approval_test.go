@@ -0,0 +1,76 @@
+package connectors
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/mudler/LocalAGI/core/approval"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/slack-go/slack"
+)
+
+var _ = Describe("Slack approvals", func() {
+	var (
+		server *httptest.Server
+		api    *slack.Client
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok": true}`))
+		}))
+		api = slack.New("token", slack.OptionAPIURL(server.URL+"/"))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	click := func(user string) slack.InteractionCallback {
+		callback := slack.InteractionCallback{}
+		callback.User.ID = user
+		callback.Channel.ID = "C1"
+		callback.ActionCallback.BlockActions = []*slack.BlockAction{
+			{ActionID: approvalApproveActionID, Value: "request"},
+		}
+		return callback
+	}
+
+	It("only lets the approvers answer", func() {
+		s := NewSlack(map[string]string{"approvers": "U1, U2"})
+		var answers []string
+		s.approvals["request"] = func(approved bool, by string) {
+			answers = append(answers, by)
+		}
+
+		s.handleApprovalAction(api, click("U3"))
+		Expect(answers).To(BeEmpty())
+		Expect(s.approvals).To(HaveKey("request"))
+
+		s.handleApprovalAction(api, click("U2"))
+		Expect(answers).To(Equal([]string{"slack:U2"}))
+		Expect(s.approvals).To(BeEmpty())
+	})
+
+	It("does not ask in Slack without approvers", func() {
+		s := NewSlack(map[string]string{})
+		s.apiClient = api
+		job := types.NewJob(types.WithMetadata(map[string]interface{}{"channel": "C1"}))
+		Expect(s.RequestApproval(job, approval.Request{ID: "request"}, func(bool, string) {})).To(BeFalse())
+		Expect(s.approvals).To(BeEmpty())
+	})
+
+	It("drops requests answered elsewhere", func() {
+		s := NewSlack(map[string]string{"approvers": "U1"})
+		s.apiClient = api
+		job := types.NewJob(types.WithMetadata(map[string]interface{}{"channel": "C1"}))
+		Expect(s.RequestApproval(job, approval.Request{ID: "request"}, func(bool, string) {})).To(BeTrue())
+		Expect(s.approvals).To(HaveKey("request"))
+
+		s.ApprovalDone(approval.Request{ID: "request"})
+		Expect(s.approvals).To(BeEmpty())
+	})
+})
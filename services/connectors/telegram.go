@@ -20,11 +20,14 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/approval"
+	"github.com/mudler/LocalAGI/core/metrics"
+	"github.com/mudler/LocalAGI/core/ratelimit"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/pkg/config"
-	"github.com/mudler/LocalAGI/services/connectors/common"
 	"github.com/mudler/LocalAGI/pkg/xstrings"
 	"github.com/mudler/LocalAGI/services/actions"
+	"github.com/mudler/LocalAGI/services/connectors/common"
 	"github.com/mudler/xlog"
 	"github.com/sashabaranov/go-openai"
 )
@@ -32,6 +35,11 @@ import (
 const telegramThinkingMessage = "🤔 thinking..."
 const telegramMaxMessageLength = 3000
 
+const (
+	telegramApprovePrefix = "approve:"
+	telegramDenyPrefix    = "deny:"
+)
+
 type Telegram struct {
 	Token string
 	bot   *bot.Bot
@@ -48,6 +56,10 @@ type Telegram struct {
 	activeJobs      map[int64][]*types.Job // map[chatID]bool to track if a chat has active processing
 	activeJobsMutex sync.RWMutex
 
+	// Pending approval requests waiting for a button press
+	approvals      map[string]func(approved bool, by string) // map[approvalID]resolve
+	approvalsMutex sync.Mutex
+
 	channelID   string
 	groupMode   bool
 	mentionOnly bool
@@ -209,6 +221,16 @@ func (t *Telegram) handleAudioMessage(update *models.Update) (openai.ChatComplet
 }
 
 // handleGroupMessage handles messages in group chats
+// telegramSender identifies who sent a message, for the rate limits. From
+// is not set on the messages sent on behalf of a chat, such as the posts of
+// channels and of anonymous group admins, they count against the chat.
+func telegramSender(message *models.Message) string {
+	if message.From != nil {
+		return fmt.Sprintf("telegram:%d", message.From.ID)
+	}
+	return fmt.Sprintf("telegram:%d", message.Chat.ID)
+}
+
 func (t *Telegram) handleGroupMessage(ctx context.Context, b *bot.Bot, a *agent.Agent, update *models.Update) {
 	xlog.Debug("Handling group message", "update", update)
 	if !t.groupMode {
@@ -224,7 +246,7 @@ func (t *Telegram) handleGroupMessage(ctx context.Context, b *bot.Bot, a *agent.
 	}
 
 	// Skip messages from ourselves
-	if update.Message.From.Username == botInfo.Username {
+	if update.Message.From != nil && update.Message.From.Username == botInfo.Username {
 		return
 	}
 
@@ -252,8 +274,9 @@ func (t *Telegram) handleGroupMessage(ctx context.Context, b *bot.Bot, a *agent.
 		return
 	}
 
-	// Store the UUID->placeholder message mapping
-	jobUUID := fmt.Sprintf("%d", msg.ID)
+	// Store the UUID->placeholder message mapping. The UUID comes from the
+	// message, so that deliveries of the same update are the same job
+	jobUUID := fmt.Sprintf("telegram:%d:%d", update.Message.Chat.ID, update.Message.ID)
 
 	t.placeholderMutex.Lock()
 	t.placeholders[jobUUID] = msg.ID
@@ -261,8 +284,9 @@ func (t *Telegram) handleGroupMessage(ctx context.Context, b *bot.Bot, a *agent.
 
 	// Add chat ID and conversation_id for tracking and cancel-previous-on-new-message
 	metadata := map[string]interface{}{
-		"chatID": update.Message.Chat.ID,
+		"chatID":                        update.Message.Chat.ID,
 		types.MetadataKeyConversationID: fmt.Sprintf("telegram:%d", update.Message.Chat.ID),
+		types.MetadataKeySender:         telegramSender(update.Message),
 	}
 
 	// Track if the original message was audio for TTS response
@@ -313,12 +337,24 @@ func (t *Telegram) handleGroupMessage(ctx context.Context, b *bot.Bot, a *agent.
 		t.placeholderMutex.Unlock()
 	}()
 
+	metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "telegram").Inc()
 	res := a.Ask(
 		types.WithConversationHistory(currentConv),
 		types.WithUUID(jobUUID),
 		types.WithMetadata(metadata),
 	)
 
+	if errors.Is(res.Error, ratelimit.ErrLimited) {
+		// The user was already asked to slow down in the chat
+		if _, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{
+			ChatID:    update.Message.Chat.ID,
+			MessageID: msg.ID,
+		}); err != nil {
+			xlog.Error("Error deleting placeholder message", "error", err)
+		}
+		return
+	}
+
 	if res.Response == "" {
 		xlog.Error("Empty response from agent")
 		_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
@@ -749,8 +785,9 @@ func (t *Telegram) handleUpdate(ctx context.Context, b *bot.Bot, a *agent.Agent,
 		return
 	}
 
-	// Store the UUID->placeholder message mapping
-	jobUUID := fmt.Sprintf("%d", msg.ID)
+	// Store the UUID->placeholder message mapping. The UUID comes from the
+	// message, so that deliveries of the same update are the same job
+	jobUUID := fmt.Sprintf("telegram:%d:%d", update.Message.Chat.ID, update.Message.ID)
 
 	t.placeholderMutex.Lock()
 	t.placeholders[jobUUID] = msg.ID
@@ -758,8 +795,9 @@ func (t *Telegram) handleUpdate(ctx context.Context, b *bot.Bot, a *agent.Agent,
 
 	// Add chat ID and conversation_id for tracking and cancel-previous-on-new-message
 	metadata := map[string]interface{}{
-		"chatID": update.Message.Chat.ID,
+		"chatID":                        update.Message.Chat.ID,
 		types.MetadataKeyConversationID: fmt.Sprintf("telegram:%d", update.Message.Chat.ID),
+		types.MetadataKeySender:         telegramSender(update.Message),
 	}
 
 	// Track if the original message was audio for TTS response
@@ -798,12 +836,24 @@ func (t *Telegram) handleUpdate(ctx context.Context, b *bot.Bot, a *agent.Agent,
 		t.placeholderMutex.Unlock()
 	}()
 
+	metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "telegram").Inc()
 	res := a.Ask(
 		types.WithConversationHistory(currentConv),
 		types.WithUUID(jobUUID),
 		types.WithMetadata(metadata),
 	)
 
+	if errors.Is(res.Error, ratelimit.ErrLimited) {
+		// The user was already asked to slow down in the chat
+		if _, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{
+			ChatID:    update.Message.Chat.ID,
+			MessageID: msg.ID,
+		}); err != nil {
+			xlog.Error("Error deleting placeholder message", "error", err)
+		}
+		return
+	}
+
 	if res.Response == "" {
 		xlog.Error("Empty response from agent")
 		_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
@@ -902,6 +952,160 @@ func (t *Telegram) handleUpdate(ctx context.Context, b *bot.Bot, a *agent.Agent,
 	}
 }
 
+// Notify posts a notice, such as a budget being exhausted, in the job's chat
+func (t *Telegram) Notify(job *types.Job, message string) bool {
+	if job == nil || job.Metadata == nil || t.bot == nil {
+		return false
+	}
+	chatID, ok := job.Metadata["chatID"].(int64)
+	if !ok {
+		return false
+	}
+
+	if _, err := t.bot.SendMessage(t.agent.Context(), &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   "⚠️ " + message,
+	}); err != nil {
+		xlog.Error("Error sending notice", "error", err)
+		return false
+	}
+	return true
+}
+
+// Reply sends the reply of a job, such as one replayed from the journal, in
+// the job's chat
+func (t *Telegram) Reply(job *types.Job, response string) bool {
+	if job == nil || job.Metadata == nil || t.bot == nil {
+		return false
+	}
+	chatID, ok := job.Metadata["chatID"].(int64)
+	if !ok {
+		return false
+	}
+
+	for _, message := range xstrings.SplitParagraph(response, telegramMaxMessageLength) {
+		if _, err := t.bot.SendMessage(t.agent.Context(), &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   message,
+		}); err != nil {
+			xlog.Error("Error sending reply", "error", err)
+			return false
+		}
+	}
+	return true
+}
+
+// RequestApproval asks in the job's chat whether the action can run,
+// using an inline keyboard that resolves the approval request. Only the
+// admins can answer, without admins requests are answered in the web UI.
+func (t *Telegram) RequestApproval(job *types.Job, request approval.Request, resolve func(approved bool, by string)) bool {
+	if job == nil || job.Metadata == nil || t.bot == nil || len(t.admins) == 0 {
+		return false
+	}
+	chatID, ok := job.Metadata["chatID"].(int64)
+	if !ok {
+		return false
+	}
+
+	text := fmt.Sprintf("⚠️ The agent wants to run %s", request.Action)
+	if params := request.Params.String(); params != "" && params != "{}" {
+		text += "\n" + params
+	}
+	if request.Reasoning != "" {
+		text += "\n\n" + request.Reasoning
+	}
+
+	t.approvalsMutex.Lock()
+	t.approvals[request.ID] = resolve
+	t.approvalsMutex.Unlock()
+
+	_, err := t.bot.SendMessage(t.agent.Context(), &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   text,
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{
+					{Text: "✅ Approve", CallbackData: telegramApprovePrefix + request.ID},
+					{Text: "❌ Deny", CallbackData: telegramDenyPrefix + request.ID},
+				},
+			},
+		},
+	})
+	if err != nil {
+		xlog.Error("Error sending approval request", "error", err)
+		t.approvalsMutex.Lock()
+		delete(t.approvals, request.ID)
+		t.approvalsMutex.Unlock()
+		return false
+	}
+
+	return true
+}
+
+// ApprovalDone drops a request answered elsewhere, e.g. in the web UI, or
+// that expired
+func (t *Telegram) ApprovalDone(request approval.Request) {
+	t.approvalsMutex.Lock()
+	delete(t.approvals, request.ID)
+	t.approvalsMutex.Unlock()
+}
+
+// handleApprovalCallback resolves the approval request matching a pressed
+// inline button and replaces the keyboard with the decision.
+func (t *Telegram) handleApprovalCallback(ctx context.Context, b *bot.Bot, query *models.CallbackQuery) {
+	var id string
+	var approved bool
+	switch {
+	case strings.HasPrefix(query.Data, telegramApprovePrefix):
+		id, approved = strings.TrimPrefix(query.Data, telegramApprovePrefix), true
+	case strings.HasPrefix(query.Data, telegramDenyPrefix):
+		id = strings.TrimPrefix(query.Data, telegramDenyPrefix)
+	default:
+		xlog.Debug("Ignoring callback query", "data", query.Data)
+		return
+	}
+
+	username := query.From.Username
+	if !slices.Contains(t.admins, username) {
+		xlog.Info("Unauthorized user tried to answer an approval", "username", username, "approval", id)
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: query.ID,
+			Text:            "you are not authorized to answer this request!",
+		})
+		return
+	}
+
+	t.approvalsMutex.Lock()
+	resolve, exists := t.approvals[id]
+	delete(t.approvals, id)
+	t.approvalsMutex.Unlock()
+
+	status := "This request is no longer pending"
+	if exists {
+		resolve(approved, "telegram:"+username)
+		status = "❌ Denied by @" + username
+		if approved {
+			status = "✅ Approved by @" + username
+		}
+	}
+
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            status,
+	})
+
+	if msg := query.Message.Message; msg != nil {
+		_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    msg.Chat.ID,
+			MessageID: msg.ID,
+			Text:      msg.Text + "\n\n" + status,
+		})
+		if err != nil {
+			xlog.Error("Error updating approval message", "error", err)
+		}
+	}
+}
+
 // func (t *Telegram) handleNewMessage(ctx context.Context, b *bot.Bot, m openai.ChatCompletionMessage) {
 // 	if t.lastChatID == 0 {
 // 		return
@@ -918,6 +1122,10 @@ func (t *Telegram) Start(a *agent.Agent) {
 
 	opts := []bot.Option{
 		bot.WithDefaultHandler(func(ctx context.Context, b *bot.Bot, update *models.Update) {
+			if update.CallbackQuery != nil {
+				go t.handleApprovalCallback(ctx, b, update.CallbackQuery)
+				return
+			}
 			go t.handleUpdate(ctx, b, a, update)
 		}),
 	}
@@ -1028,8 +1236,10 @@ func NewTelegramConnector(config map[string]string) (*Telegram, error) {
 
 	admins := []string{}
 
-	if _, ok := config["admins"]; ok && strings.Contains(config["admins"], ",") {
-		admins = append(admins, strings.Split(config["admins"], ",")...)
+	for _, admin := range strings.Split(config["admins"], ",") {
+		if admin = strings.TrimSpace(admin); admin != "" {
+			admins = append(admins, admin)
+		}
 	}
 
 	return &Telegram{
@@ -1038,6 +1248,7 @@ func NewTelegramConnector(config map[string]string) (*Telegram, error) {
 		placeholders: make(map[string]int),
 		jobStatus:    make(map[string]*common.StatusAccumulator),
 		activeJobs:   make(map[int64][]*types.Job),
+		approvals:    make(map[string]func(approved bool, by string)),
 		channelID:    config["channel_id"],
 		groupMode:    config["group_mode"] == "true",
 		mentionOnly:  config["mention_only"] == "true",
@@ -0,0 +1,81 @@
+package connectors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/mudler/LocalAGI/core/approval"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Telegram approvals", func() {
+	var (
+		server *httptest.Server
+		b      *bot.Bot
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok": true, "result": true}`))
+		}))
+		var err error
+		b, err = bot.New("token", bot.WithServerURL(server.URL), bot.WithSkipGetMe())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	click := func(username string) *models.CallbackQuery {
+		return &models.CallbackQuery{
+			ID:   "query",
+			From: models.User{Username: username},
+			Data: telegramApprovePrefix + "request",
+		}
+	}
+
+	It("only lets the admins answer", func() {
+		t, err := NewTelegramConnector(map[string]string{"token": "token", "admins": "alice, bob"})
+		Expect(err).NotTo(HaveOccurred())
+		var answers []string
+		t.approvals["request"] = func(approved bool, by string) {
+			answers = append(answers, by)
+		}
+
+		t.handleApprovalCallback(context.Background(), b, click("mallory"))
+		Expect(answers).To(BeEmpty())
+		Expect(t.approvals).To(HaveKey("request"))
+
+		t.handleApprovalCallback(context.Background(), b, click("bob"))
+		Expect(answers).To(Equal([]string{"telegram:bob"}))
+		Expect(t.approvals).To(BeEmpty())
+	})
+
+	It("lets nobody answer without admins", func() {
+		t, err := NewTelegramConnector(map[string]string{"token": "token"})
+		Expect(err).NotTo(HaveOccurred())
+		var answers []string
+		t.approvals["request"] = func(approved bool, by string) {
+			answers = append(answers, by)
+		}
+
+		t.handleApprovalCallback(context.Background(), b, click("mallory"))
+		Expect(answers).To(BeEmpty())
+	})
+
+	It("does not ask in Telegram without admins", func() {
+		t, err := NewTelegramConnector(map[string]string{"token": "token"})
+		Expect(err).NotTo(HaveOccurred())
+		t.bot = b
+		job := types.NewJob(types.WithMetadata(map[string]interface{}{"chatID": int64(1)}))
+		Expect(t.RequestApproval(job, approval.Request{ID: "request"}, func(bool, string) {})).To(BeFalse())
+		Expect(t.approvals).To(BeEmpty())
+	})
+})
@@ -0,0 +1,90 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/pkg/cassette"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// telegramCassette holds the LLM requests of the agent answering in
+// Telegram. LOCALAGI_CASSETTE_MODE=record records it again against
+// LOCALAI_API_URL.
+const telegramCassette = "../../tests/cassettes/telegram.json"
+
+var _ = Describe("Telegram conversations", func() {
+	var (
+		server *httptest.Server
+		b      *bot.Bot
+		mu     sync.Mutex
+		sent   []string
+	)
+
+	BeforeEach(func() {
+		sent = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseMultipartForm(1 << 20)
+			method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			mu.Lock()
+			sent = append(sent, method+": "+r.FormValue("text"))
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"ok": true, "result": {"message_id": 2, "date": 0, "chat": {"id": 1, "type": "private"}}}`)
+		}))
+		var err error
+		b, err = bot.New("token", bot.WithServerURL(server.URL), bot.WithSkipGetMe())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("answers a private message with the reply of the agent", func() {
+		handler, done, err := cassette.Open(cassette.Mode(os.Getenv("LOCALAGI_CASSETTE_MODE")), telegramCassette, os.Getenv("LOCALAI_API_URL"))
+		Expect(err).NotTo(HaveOccurred())
+		llm := httptest.NewServer(handler)
+		defer llm.Close()
+
+		a, err := agent.New(
+			agent.WithLLMAPIURL(llm.URL),
+			agent.WithModel("gemma-3-4b-it-qat"),
+			agent.WithTimeout("10m"),
+			agent.WithSchedulerStorePath(filepath.Join(GinkgoT().TempDir(), "scheduled_tasks.json")),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		go a.Run()
+		defer a.Stop()
+
+		t, err := NewTelegramConnector(map[string]string{"token": "token"})
+		Expect(err).NotTo(HaveOccurred())
+		t.bot = b
+		t.agent = a
+
+		t.handleUpdate(context.Background(), b, a, &models.Update{Message: &models.Message{
+			ID:   1,
+			Chat: models.Chat{ID: 1, Type: "private"},
+			From: &models.User{ID: 42, Username: "alice"},
+			Text: "What is the capital of Italy?",
+		}})
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(sent).To(Equal([]string{
+			"sendMessage: " + bot.EscapeMarkdown(telegramThinkingMessage),
+			"editMessageText: " + bot.EscapeMarkdown("The capital of Italy is Rome."),
+		}))
+		Expect(done()).To(Succeed())
+	})
+})
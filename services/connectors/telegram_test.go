@@ -0,0 +1,31 @@
+package connectors
+
+import (
+	"github.com/go-telegram/bot/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Telegram senders", func() {
+	It("identifies the sender of a message by its user", func() {
+		message := &models.Message{Chat: models.Chat{ID: -100}, From: &models.User{ID: 42}}
+		Expect(telegramSender(message)).To(Equal("telegram:42"))
+	})
+
+	It("falls back to the chat for the messages sent on behalf of a chat", func() {
+		message := &models.Message{Chat: models.Chat{ID: -100}}
+		Expect(telegramSender(message)).To(Equal("telegram:-100"))
+	})
+})
+
+var _ = Describe("Telegram connector", func() {
+	It("reads a single admin as well as a list", func() {
+		t, err := NewTelegramConnector(map[string]string{"token": "token", "admins": "alice"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.admins).To(Equal([]string{"alice"}))
+
+		t, err = NewTelegramConnector(map[string]string{"token": "token", "admins": " alice, bob,"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.admins).To(Equal([]string{"alice", "bob"}))
+	})
+})
@@ -7,6 +7,7 @@ import (
 	"os/signal"
 
 	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/metrics"
 	"github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/pkg/config"
 	"github.com/mudler/LocalAGI/services/connectors/twitter"
@@ -99,6 +100,7 @@ func (t *Twitter) run(a *agent.Agent) error {
 		return nil
 	}
 
+	metrics.ConnectorMessages.WithLabelValues(a.Character.Name, "twitter").Inc()
 	res := a.Ask(
 		types.WithConversationHistory(
 			[]openai.ChatCompletionMessage{
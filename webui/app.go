@@ -1,6 +1,7 @@
 package webui
 
 import (
+	"bufio"
 	"context"
 	"embed"
 	"encoding/json"
@@ -12,13 +13,18 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/auth"
 	"github.com/mudler/LocalAGI/core/conversations"
 	coreTypes "github.com/mudler/LocalAGI/core/types"
 	internalTypes "github.com/mudler/LocalAGI/core/types"
 	"github.com/mudler/LocalAGI/pkg/llm"
 	"github.com/mudler/LocalAGI/services"
 	"github.com/mudler/LocalAGI/webui/types"
+	"github.com/mudler/cogito"
 	"github.com/mudler/xlog"
+	"github.com/valyala/fasthttp"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
@@ -107,6 +113,7 @@ func (a *App) Delete(pool *state.AgentPool) func(c *fiber.Ctx) error {
 			xlog.Info("Error removing agent", err)
 			return errorJSONMessage(c, err.Error())
 		}
+		recordAudit(pool, c, audit.Entry{Operation: audit.OperationAgentDelete, Agent: c.Params("name")})
 		return statusJSONMessage(c, "ok")
 	}
 }
@@ -129,6 +136,7 @@ func (a *App) Pause(pool *state.AgentPool) func(c *fiber.Ctx) error {
 		if agent != nil {
 			xlog.Info("Pausing agent", "name", c.Params("name"))
 			agent.Pause()
+			recordAudit(pool, c, audit.Entry{Operation: audit.OperationAgentPause, Agent: c.Params("name")})
 		}
 		return statusJSONMessage(c, "ok")
 	}
@@ -140,6 +148,7 @@ func (a *App) Start(pool *state.AgentPool) func(c *fiber.Ctx) error {
 		if agent != nil {
 			xlog.Info("Starting agent", "name", c.Params("name"))
 			agent.Resume()
+			recordAudit(pool, c, audit.Entry{Operation: audit.OperationAgentStart, Agent: c.Params("name")})
 		}
 		return statusJSONMessage(c, "ok")
 	}
@@ -157,9 +166,10 @@ func (a *App) Create(pool *state.AgentPool) func(c *fiber.Ctx) error {
 		if config.Name == "" {
 			return errorJSONMessage(c, "Name is required")
 		}
-		if err := pool.CreateAgent(config.Name, &config); err != nil {
+		if err := pool.CreateAgent(config.Name, &config, requestAuthor(c)); err != nil {
 			return errorJSONMessage(c, err.Error())
 		}
+		recordAudit(pool, c, audit.Entry{Operation: audit.OperationAgentCreate, Agent: config.Name, Details: latestVersion(pool, config.Name)})
 
 		return statusJSONMessage(c, "ok")
 	}
@@ -172,7 +182,11 @@ func (a *App) GetAgentConfig(pool *state.AgentPool) func(c *fiber.Ctx) error {
 		if config == nil {
 			return errorJSONMessage(c, "Agent not found")
 		}
-		return c.JSON(config)
+		redacted, err := state.RedactConfig(config)
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return c.JSON(redacted)
 	}
 }
 
@@ -194,9 +208,10 @@ func (a *App) UpdateAgentConfig(pool *state.AgentPool) func(c *fiber.Ctx) error
 			return errorJSONMessage(c, err.Error())
 		}
 
-		if err := pool.RecreateAgent(agentName, &newConfig); err != nil {
+		if err := pool.RecreateAgent(agentName, &newConfig, requestAuthor(c)); err != nil {
 			return errorJSONMessage(c, "Error updating agent: "+err.Error())
 		}
+		recordAudit(pool, c, audit.Entry{Operation: audit.OperationAgentUpdate, Agent: agentName, Details: latestVersion(pool, agentName)})
 
 		xlog.Info("Updated agent", "name", agentName, "config", fmt.Sprintf("%+v", newConfig))
 
@@ -210,9 +225,13 @@ func (a *App) ExportAgent(pool *state.AgentPool) func(c *fiber.Ctx) error {
 		if agent == nil {
 			return errorJSONMessage(c, "Agent not found")
 		}
+		redacted, err := state.RedactConfig(agent)
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
 
 		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", agent.Name))
-		return c.JSON(agent)
+		return c.JSON(redacted)
 	}
 }
 
@@ -250,9 +269,10 @@ func (a *App) ImportAgent(pool *state.AgentPool) func(c *fiber.Ctx) error {
 			return errorJSONMessage(c, "Name is required")
 		}
 
-		if err := pool.CreateAgent(config.Name, &config); err != nil {
+		if err := pool.CreateAgent(config.Name, &config, requestAuthor(c)); err != nil {
 			return errorJSONMessage(c, err.Error())
 		}
+		recordAudit(pool, c, audit.Entry{Operation: audit.OperationAgentImport, Agent: config.Name, Details: latestVersion(pool, config.Name)})
 		return statusJSONMessage(c, "ok")
 	}
 }
@@ -381,10 +401,17 @@ func (a *App) Chat(pool *state.AgentPool) func(c *fiber.Ctx) error {
 				sse.NewMessage(string(statusData)).WithEvent("json_message_status"))
 		}
 
+		// Each user has their own conversation with the agent in the web UI.
+		// A new message doesn't cancel the one still being answered.
+		metadata := map[string]any{
+			coreTypes.MetadataKeyConversationID: "web:" + requestAuthor(c),
+			coreTypes.MetadataKeyKeepPrevious:   true,
+		}
+
 		// Process the message asynchronously
 		go func() {
 			// Ask the agent for a response
-			response := agent.Ask(coreTypes.WithText(message))
+			response := agent.Ask(coreTypes.WithText(message), coreTypes.WithMetadata(metadata))
 
 			if response == nil {
 				// Ask returned nil (e.g. context cancelled or WaitResult failed)
@@ -463,7 +490,7 @@ func (a *App) GetActionDefinition(pool *state.AgentPool) func(c *fiber.Ctx) erro
 
 		actionName := c.Params("name")
 
-		xlog.Debug("Executing action", "action", actionName, "config", payload.Config)
+		xlog.Debug("Getting action definition", "action", actionName)
 		a, err := services.Action(actionName, "", payload.Config, pool, map[string]string{})
 		if err != nil {
 			xlog.Error("Error creating action", "error", err)
@@ -488,7 +515,7 @@ func (app *App) ExecuteAction(pool *state.AgentPool) func(c *fiber.Ctx) error {
 
 		actionName := c.Params("name")
 
-		xlog.Debug("Executing action", "action", actionName, "config", payload.Config, "params", payload.Params)
+		xlog.Debug("Executing action", "action", actionName, "params", payload.Params)
 		a, err := services.Action(actionName, "", payload.Config, pool, map[string]string{})
 		if err != nil {
 			xlog.Error("Error creating action", "error", err)
@@ -499,6 +526,16 @@ func (app *App) ExecuteAction(pool *state.AgentPool) func(c *fiber.Ctx) error {
 		defer cancel()
 
 		res, err := a.Run(ctx, app.sharedState, payload.Params)
+		entry := audit.Entry{
+			Operation: audit.OperationActionExecute,
+			Action:    actionName,
+			Params:    payload.Params,
+			Result:    audit.Truncate(res.Result),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		recordAudit(pool, c, entry)
 		if err != nil {
 			xlog.Error("Error running action", "error", err)
 			return errorJSONMessage(c, err.Error())
@@ -516,7 +553,7 @@ func (a *App) ListActions() func(c *fiber.Ctx) error {
 }
 
 // createToolCallResponse generates a proper tool call response for user-defined actions
-func (a *App) createToolCallResponse(id, agentName string, actionState coreTypes.ActionState, conv []openai.ChatCompletionMessage) types.ResponseBody {
+func (a *App) createToolCallResponse(id, agentName string, actionState coreTypes.ActionState, usage coreTypes.Usage) types.ResponseBody {
 	// Create tool call ID
 	toolCallID := fmt.Sprintf("call_%d", time.Now().UnixNano())
 
@@ -564,11 +601,7 @@ func (a *App) createToolCallResponse(id, agentName string, actionState coreTypes
 			messageObj,
 			functionToolCall,
 		},
-		Usage: types.UsageInfo{
-			InputTokens:  0, // TODO: calculate actual usage
-			OutputTokens: 0,
-			TotalTokens:  0,
-		},
+		Usage: types.NewUsageInfo(usage),
 	}
 }
 
@@ -601,15 +634,36 @@ func (a *App) Responses(pool *state.AgentPool, tracker *conversations.Conversati
 			})
 		}
 
+		if !currentUser(c).Can(agentName, auth.RoleOperator) {
+			return c.Status(http.StatusForbidden).JSON(types.ResponseBody{Error: "permission denied"})
+		}
+
 		agent := pool.GetAgent(agentName)
 		if agent == nil {
 			xlog.Info("Agent not found in pool", c.Params("name"))
 			return c.Status(http.StatusInternalServerError).JSON(types.ResponseBody{Error: "Agent not found"})
 		}
+		if err := a.allowRequest(c, agentName); err != nil {
+			return c.Status(http.StatusTooManyRequests).JSON(types.ResponseBody{Error: err.Error()})
+		}
+
+		// A thread of responses is one conversation, identified by the
+		// response that started it
+		id := uuid.New().String()
+		root := id
+		if previousResponseID != "" {
+			root = tracker.GetRoot(previousResponseID)
+		}
+		conversationID := "responses:" + root
 
 		// Prepare job options
 		jobOptions := []coreTypes.JobOption{
 			coreTypes.WithConversationHistory(messages),
+			// Responses of a thread can be requested at once, e.g. to branch it
+			coreTypes.WithMetadata(map[string]any{
+				coreTypes.MetadataKeyConversationID: conversationID,
+				coreTypes.MetadataKeyKeepPrevious:   true,
+			}),
 		}
 
 		// Add tools if present in the request
@@ -632,6 +686,10 @@ func (a *App) Responses(pool *state.AgentPool, tracker *conversations.Conversati
 			}
 		}
 
+		if request.Stream != nil && *request.Stream {
+			return a.streamResponse(c, agent, id, root, agentName, conv, tracker, jobOptions)
+		}
+
 		res := agent.Ask(jobOptions...)
 		if res.Error != nil {
 			xlog.Error("Error asking agent", "agent", agentName, "error", res.Error)
@@ -641,54 +699,198 @@ func (a *App) Responses(pool *state.AgentPool, tracker *conversations.Conversati
 			xlog.Info("we got a response from the agent", "agent", agentName, "response", res.Response)
 		}
 
-		id := uuid.New().String()
+		msgID := fmt.Sprintf("msg_%d", time.Now().UnixNano())
 
-		// Check if this is a user-defined tool call
-		if res.Response == "" && len(res.State) > 0 {
-			// Get the last action from state
-			lastAction := res.State[len(res.State)-1]
-			if coreTypes.IsActionUserDefined(lastAction.Action) {
-				xlog.Debug("Detected user-defined action, creating tool call response", "action", lastAction.Action.Definition().Name)
-
-				// Generate tool call response
-				response := a.createToolCallResponse(id, agentName, lastAction, conv)
-				tracker.SetConversation(id, conv) // Save conversation without adding assistant message
-				return c.JSON(response)
-			}
+		return c.JSON(a.completeResponse(id, root, msgID, agentName, res, conv, tracker))
+	}
+}
+
+// completeResponse builds the final response body for a finished job and
+// stores the resulting conversation in the tracker under the response id,
+// along with root, the id of the response the thread started with.
+func (a *App) completeResponse(id, root, msgID, agentName string, res *coreTypes.JobResult, conv []openai.ChatCompletionMessage, tracker *conversations.ConversationTracker[string]) types.ResponseBody {
+	// Check if this is a user-defined tool call
+	if res.Response == "" && len(res.State) > 0 {
+		// Get the last action from state
+		lastAction := res.State[len(res.State)-1]
+		if coreTypes.IsActionUserDefined(lastAction.Action) {
+			xlog.Debug("Detected user-defined action, creating tool call response", "action", lastAction.Action.Definition().Name)
+
+			// Generate tool call response
+			response := a.createToolCallResponse(id, agentName, lastAction, res.GetUsage())
+			tracker.SetConversation(id, root, conv) // Save conversation without adding assistant message
+			return response
 		}
+	}
 
-		// Regular text response
-		conv = append(conv, openai.ChatCompletionMessage{
-			Role:    "assistant",
-			Content: res.Response,
-		})
+	// Regular text response
+	conv = append(conv, openai.ChatCompletionMessage{
+		Role:    "assistant",
+		Content: res.Response,
+	})
 
-		tracker.SetConversation(id, conv)
-
-		response := types.ResponseBody{
-			ID:        id,
-			Object:    "response",
-			CreatedAt: time.Now().Unix(),
-			Status:    "completed",
-			Model:     agentName,
-			Output: []interface{}{
-				types.ResponseMessage{
-					Type:   "message",
-					ID:     fmt.Sprintf("msg_%d", time.Now().UnixNano()),
-					Status: "completed",
-					Role:   "assistant",
-					Content: []types.MessageContentItem{
-						{
-							Type: "output_text",
-							Text: res.Response,
-						},
+	tracker.SetConversation(id, root, conv)
+
+	return types.ResponseBody{
+		ID:        id,
+		Object:    "response",
+		CreatedAt: time.Now().Unix(),
+		Status:    "completed",
+		Model:     agentName,
+		Output: []interface{}{
+			types.ResponseMessage{
+				Type:   "message",
+				ID:     msgID,
+				Status: "completed",
+				Role:   "assistant",
+				Content: []types.MessageContentItem{
+					{
+						Type: "output_text",
+						Text: res.Response,
 					},
 				},
 			},
+		},
+		Usage: types.NewUsageInfo(res.GetUsage()),
+	}
+}
+
+// streamResponse runs the job and streams its progress to the client as
+// Responses API server-sent events, ending with response.completed.
+func (a *App) streamResponse(c *fiber.Ctx, agent *agent.Agent, id, root, agentName string, conv []openai.ChatCompletionMessage, tracker *conversations.ConversationTracker[string], jobOptions []coreTypes.JobOption) error {
+	msgID := fmt.Sprintf("msg_%d", time.Now().UnixNano())
+	createdAt := time.Now().Unix()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan cogito.StreamEvent, 100)
+	result := make(chan *coreTypes.JobResult, 1)
+	done := make(chan struct{})
+
+	jobOptions = append(jobOptions,
+		coreTypes.WithContext(ctx),
+		coreTypes.WithStreamCallback(func(ev cogito.StreamEvent) {
+			select {
+			case events <- ev:
+			case <-done:
+			}
+		}),
+	)
+
+	go func() {
+		result <- agent.Ask(jobOptions...)
+	}()
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		defer close(done)
+
+		sequence := 0
+		send := func(event types.ResponseStreamEvent) error {
+			event.SequenceNumber = sequence
+			sequence++
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprint(w, sse.NewMessage(string(data)).WithEvent(event.Type).String()); err != nil {
+				return err
+			}
+			return w.Flush()
+		}
+
+		if err := send(types.ResponseStreamEvent{
+			Type: "response.created",
+			Response: &types.ResponseBody{
+				ID:        id,
+				Object:    "response",
+				CreatedAt: createdAt,
+				Status:    "in_progress",
+				Model:     agentName,
+				Output:    []interface{}{},
+			},
+		}); err != nil {
+			xlog.Debug("Client disconnected from response stream", "agent", agentName, "error", err)
+			return
+		}
+		send(types.ResponseStreamEvent{
+			Type: "response.in_progress",
+			Response: &types.ResponseBody{
+				ID:        id,
+				Object:    "response",
+				CreatedAt: createdAt,
+				Status:    "in_progress",
+				Model:     agentName,
+				Output:    []interface{}{},
+			},
+		})
+
+		stream := newResponseStream(agentName, msgID, send)
+		for {
+			select {
+			case ev := <-events:
+				if err := stream.event(ev); err != nil {
+					xlog.Debug("Client disconnected from response stream", "agent", agentName, "error", err)
+					return
+				}
+			case res := <-result:
+				// Ask returns once the job sent all its events, deliver the
+				// ones still buffered before the result
+				for drained := false; !drained; {
+					select {
+					case ev := <-events:
+						if err := stream.event(ev); err != nil {
+							xlog.Debug("Client disconnected from response stream", "agent", agentName, "error", err)
+							return
+						}
+					default:
+						drained = true
+					}
+				}
+
+				if res == nil || res.Error != nil {
+					errMsg := "the agent stopped before answering"
+					if res != nil {
+						errMsg = res.Error.Error()
+					}
+					xlog.Error("Error asking agent", "agent", agentName, "error", errMsg)
+					send(types.ResponseStreamEvent{
+						Type: "response.failed",
+						Response: &types.ResponseBody{
+							ID:        id,
+							Object:    "response",
+							CreatedAt: createdAt,
+							Status:    "failed",
+							Model:     agentName,
+							Error:     errMsg,
+							Output:    stream.output,
+						},
+					})
+					return
+				}
+
+				response := a.completeResponse(id, root, msgID, agentName, res, conv, tracker)
+				response.CreatedAt = createdAt
+				output, err := stream.finish(response.Output)
+				if err != nil {
+					xlog.Debug("Client disconnected from response stream", "agent", agentName, "error", err)
+					return
+				}
+				response.Output = output
+
+				send(types.ResponseStreamEvent{
+					Type:     "response.completed",
+					Response: &response,
+				})
+				return
+			}
 		}
+	}))
 
-		return c.JSON(response)
-	}
+	return nil
 }
 
 type AgentRole struct {
@@ -764,7 +966,7 @@ func (a *App) CreateGroup(pool *state.AgentPool) func(c *fiber.Ctx) error {
 			agentConfig.Name = agent.Name
 			agentConfig.Description = agent.Description
 			agentConfig.SystemPrompt = agent.SystemPrompt
-			if err := pool.CreateAgent(agent.Name, agentConfig); err != nil {
+			if err := pool.CreateAgent(agent.Name, agentConfig, requestAuthor(c)); err != nil {
 				return errorJSONMessage(c, err.Error())
 			}
 		}
@@ -0,0 +1,86 @@
+package webui
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mudler/LocalAGI/core/approval"
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/state"
+)
+
+type resolveApprovalRequest struct {
+	Approved bool   `json:"approved"`
+	By       string `json:"by"`
+}
+
+// ListApprovals returns the approval requests, optionally filtered by agent
+// (path parameter or ?agent=) and restricted to pending ones with ?status=pending.
+func (a *App) ListApprovals(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		agentName := c.Params("name")
+		if agentName == "" {
+			agentName = c.Query("agent")
+		}
+		pendingOnly := c.Query("status") == string(approval.StatusPending)
+
+		user := currentUser(c)
+		approvals := slices.DeleteFunc(pool.Approvals().List(agentName, pendingOnly), func(r approval.Request) bool {
+			return !user.Can(r.Agent, auth.RoleViewer)
+		})
+
+		return c.JSON(fiber.Map{
+			"approvals": approvals,
+		})
+	}
+}
+
+// GetApproval returns a single approval request
+func (a *App) GetApproval(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request, err := pool.Approvals().Get(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !currentUser(c).Can(request.Agent, auth.RoleViewer) {
+			return forbidden(c)
+		}
+		return c.JSON(request)
+	}
+}
+
+// ResolveApproval approves or denies a pending approval request
+func (a *App) ResolveApproval(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var payload resolveApprovalRequest
+		if err := c.BodyParser(&payload); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		// Authenticated users resolve requests under their own name
+		if _, ok := c.Locals(userLocal).(*auth.User); ok || payload.By == "" {
+			payload.By = requestAuthor(c)
+		}
+
+		request, err := pool.Approvals().Get(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !currentUser(c).Can(request.Agent, auth.RoleOperator) {
+			return forbidden(c)
+		}
+
+		err = pool.Approvals().Resolve(c.Params("id"), payload.Approved, payload.By)
+		switch {
+		case errors.Is(err, approval.ErrNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, approval.ErrAlreadyResolved):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return errorJSONMessage(c, err.Error())
+		}
+
+		return statusJSONMessage(c, "ok")
+	}
+}
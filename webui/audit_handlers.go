@@ -0,0 +1,107 @@
+package webui
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/xlog"
+
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/state"
+)
+
+// recordAudit records an operation done by the user of the request. Errors
+// are logged, the operation already happened.
+func recordAudit(pool *state.AgentPool, c *fiber.Ctx, entry audit.Entry) {
+	log := pool.AuditLog()
+	if log == nil {
+		return
+	}
+	entry.Actor = requestAuthor(c)
+	if err := log.Record(entry); err != nil {
+		xlog.Error("Failed to record operation in the audit log", "operation", entry.Operation, "agent", entry.Agent, "error", err)
+	}
+}
+
+// latestVersion returns the number of the latest configuration version of
+// an agent, for the audit log
+func latestVersion(pool *state.AgentPool, name string) map[string]string {
+	history, err := pool.ConfigVersions(name)
+	if err != nil || len(history) == 0 {
+		return nil
+	}
+	return map[string]string{"version": strconv.Itoa(history[len(history)-1].Version)}
+}
+
+// auditQuery reads the filters of the audit log from the query string:
+// ?from= and ?to= (RFC3339), ?actor=, ?operation=, ?agent=, ?action=,
+// ?conversation_id= and ?limit=
+func auditQuery(c *fiber.Ctx) (audit.Query, error) {
+	query := audit.Query{
+		Actor:          c.Query("actor"),
+		Operation:      audit.Operation(c.Query("operation")),
+		Agent:          c.Query("agent"),
+		Action:         c.Query("action"),
+		ConversationID: c.Query("conversation_id"),
+		Limit:          c.QueryInt("limit", audit.DefaultLimit),
+	}
+	var err error
+	if v := c.Query("from"); v != "" {
+		if query.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return query, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if query.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return query, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	return query, nil
+}
+
+// QueryAuditLog returns the entries of the audit log, newest first
+func (a *App) QueryAuditLog(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		log := pool.AuditLog()
+		if log == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "the audit log is disabled, a state directory is required"})
+		}
+		query, err := auditQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		entries, err := log.Query(query)
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"Entries": entries})
+	}
+}
+
+// ExportAuditLog downloads the matching entries of the audit log as JSON
+// lines, oldest first
+func (a *App) ExportAuditLog(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		log := pool.AuditLog()
+		if log == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "the audit log is disabled, a state directory is required"})
+		}
+		query, err := auditQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		c.Set("Content-Type", "application/x-ndjson")
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=audit-%s.jsonl", time.Now().UTC().Format("20060102-150405")))
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			if err := log.Export(w, query); err != nil {
+				xlog.Error("Failed to export the audit log", "error", err)
+			}
+			w.Flush()
+		})
+		return nil
+	}
+}
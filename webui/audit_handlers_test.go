@@ -0,0 +1,98 @@
+package webui_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/secrets"
+	"github.com/mudler/LocalAGI/webui"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audit of the admin operations", func() {
+	var (
+		app     *webui.App
+		log     *audit.JSONLog
+		logPath string
+		key     string
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		pool := newTestPool()
+
+		var err error
+		logPath = filepath.Join(dir, "audit.jsonl")
+		log, err = audit.NewJSONLog(logPath)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(log.Close)
+		pool.SetAuditLog(log)
+
+		store, err := secrets.NewEncryptedStore(filepath.Join(dir, "secrets.json"), "master-key")
+		Expect(err).NotTo(HaveOccurred())
+		pool.SetSecretStore(store)
+
+		users, err := auth.NewJSONStore(filepath.Join(dir, "users.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(users.Create(auth.User{Name: "root", Role: auth.RoleAdmin})).To(Succeed())
+		key, _, err = users.CreateAPIKey("root", "test")
+		Expect(err).NotTo(HaveOccurred())
+
+		app = webui.NewApp(
+			webui.WithPool(pool),
+			webui.WithUsers(users),
+			webui.WithStateDir(dir),
+			webui.WithLocalRAGURL("http://127.0.0.1:0"),
+		)
+	})
+
+	request := func(method, path, body string) []byte {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := app.Test(req, -1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(fiber.StatusOK), "%s %s", method, path)
+		data, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return data
+	}
+
+	It("records who changed the users, their API keys and the secrets", func() {
+		request("POST", "/api/users", `{"name":"alice","role":"viewer"}`)
+		request("PUT", "/api/users/alice", `{"role":"operator"}`)
+		var created struct{ APIKey auth.APIKey }
+		Expect(json.Unmarshal(request("POST", "/api/users/alice/keys", `{"name":"ci"}`), &created)).To(Succeed())
+		id := created.APIKey.ID
+		request("DELETE", "/api/users/alice/keys/"+id, "")
+		request("DELETE", "/api/users/alice", "")
+		request("PUT", "/api/secrets/OPENAI", `{"value":"sk-top-secret"}`)
+		request("DELETE", "/api/secrets/OPENAI", "")
+
+		entries, err := log.Query(audit.Query{Actor: "root"})
+		Expect(err).NotTo(HaveOccurred())
+		operations := map[audit.Operation]map[string]string{}
+		for _, e := range entries {
+			operations[e.Operation] = e.Details
+		}
+		Expect(operations).To(HaveKeyWithValue(audit.OperationUserCreate, map[string]string{"user": "alice", "role": "viewer"}))
+		Expect(operations).To(HaveKeyWithValue(audit.OperationUserUpdate, map[string]string{"user": "alice", "role": "operator"}))
+		Expect(operations).To(HaveKeyWithValue(audit.OperationAPIKeyCreate, map[string]string{"user": "alice", "key": id, "name": "ci"}))
+		Expect(operations).To(HaveKeyWithValue(audit.OperationAPIKeyDelete, map[string]string{"user": "alice", "key": id}))
+		Expect(operations).To(HaveKeyWithValue(audit.OperationUserDelete, map[string]string{"user": "alice"}))
+		Expect(operations).To(HaveKeyWithValue(audit.OperationSecretSet, map[string]string{"secret": "OPENAI"}))
+		Expect(operations).To(HaveKeyWithValue(audit.OperationSecretDelete, map[string]string{"secret": "OPENAI"}))
+
+		data, err := os.ReadFile(logPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("sk-top-secret"))
+	})
+})
@@ -0,0 +1,122 @@
+package webui
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+
+	"github.com/dave-gray101/v2keyauth"
+	fiber "github.com/gofiber/fiber/v2"
+
+	"github.com/mudler/LocalAGI/core/auth"
+)
+
+// userLocal is where the authenticated user of a request is kept
+const userLocal = "user"
+
+// apiKeyLocal is where the hash of the API key of a request is kept, so that
+// requests can be told apart by key without keeping the key around
+const apiKeyLocal = "apiKey"
+
+// authEnabled reports whether requests must carry an API key or a session,
+// which is when API keys are configured, users exist or users log in with
+// an identity provider
+func (app *App) authEnabled() bool {
+	return len(app.config.ApiKeys) > 0 || app.config.OIDC != nil || (app.config.Users != nil && !app.config.Users.Empty())
+}
+
+// authenticate returns the user owning key
+func (app *App) authenticate(key string) (*auth.User, bool) {
+	for _, validKey := range app.config.ApiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(validKey)) == 1 {
+			// API keys from the configuration administer everything
+			return &auth.User{Name: maskAPIKey(key), Role: auth.RoleAdmin}, true
+		}
+	}
+	if app.config.Users != nil {
+		if user, err := app.config.Users.Authenticate(key); err == nil {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+// authConfig is the configuration of the API key middleware. The key is
+// looked up in the same places as GetKeyAuthConfig, and the user it
+// belongs to is kept for the permission checks of the routes. Requests of
+// a web UI session need no key.
+func (app *App) authConfig() (*v2keyauth.Config, error) {
+	config, err := GetKeyAuthConfig(app.config.ApiKeys)
+	if err != nil {
+		return nil, err
+	}
+	config.Validator = func(c *fiber.Ctx, key string) (bool, error) {
+		if !app.authEnabled() {
+			return true, nil
+		}
+		user, ok := app.authenticate(key)
+		if !ok {
+			return false, v2keyauth.ErrMissingOrMalformedAPIKey
+		}
+		c.Locals(userLocal, user)
+		sum := sha256.Sum256([]byte(key))
+		c.Locals(apiKeyLocal, hex.EncodeToString(sum[:]))
+		return true, nil
+	}
+	config.Next = app.sessionUser
+	config.ErrorHandler = getApiKeyErrorHandler(false, app.authEnabled, app.config.OIDC != nil)
+	return config, nil
+}
+
+// currentUser returns the user of a request. When authentication is
+// disabled everyone is an anonymous admin.
+func currentUser(c *fiber.Ctx) *auth.User {
+	if user, ok := c.Locals(userLocal).(*auth.User); ok {
+		return user
+	}
+	return auth.Anonymous
+}
+
+func forbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "permission denied"})
+}
+
+// requireRole lets requests through when the user has role on every agent
+func requireRole(role auth.Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !currentUser(c).Role.Allows(role) {
+			return forbidden(c)
+		}
+		return c.Next()
+	}
+}
+
+// requireAgentRole lets requests through when the user has role on the
+// agent of the :name parameter
+func requireAgentRole(role auth.Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !currentUser(c).Can(c.Params("name"), role) {
+			return forbidden(c)
+		}
+		return c.Next()
+	}
+}
+
+// requireWriteRole lets every authenticated user read, and requires role
+// on every agent to change anything
+func requireWriteRole(role auth.Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			return c.Next()
+		}
+		return requireRole(role)(c)
+	}
+}
+
+// maskAPIKey shortens an API key so it can be logged or shown
+func maskAPIKey(key string) string {
+	if len(key) <= 8 {
+		return "api-key:****"
+	}
+	return "api-key:" + key[:4] + "..." + key[len(key)-4:]
+}
@@ -3,10 +3,12 @@ package webui
 import (
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAGI/core/auth"
 	"github.com/mudler/xlog"
 )
 
@@ -69,18 +71,22 @@ func collectionsAPIKeyFromRequest(c *fiber.Ctx) string {
 }
 
 // RegisterCollectionRoutes mounts /api/collections* routes. backend is either from NewInProcessCollectionsBackend or NewCollectionsBackendHTTP.
+// The knowledge base of an agent is the collection named after it, so the roles on agents apply to collections.
 func (app *App) RegisterCollectionRoutes(webapp *fiber.App, cfg *Config, backend CollectionsBackend) {
-	webapp.Post("/api/collections", app.createCollection(backend))
+	reader := requireAgentRole(auth.RoleViewer)
+	writer := requireAgentRole(auth.RoleOperator)
+
+	webapp.Post("/api/collections", requireRole(auth.RoleOperator), app.createCollection(backend))
 	webapp.Get("/api/collections", app.listCollections(backend))
-	webapp.Post("/api/collections/:name/upload", app.uploadFile(backend))
-	webapp.Get("/api/collections/:name/entries", app.listFiles(backend))
-	webapp.Get("/api/collections/:name/entries/*", app.getEntryContent(backend))
-	webapp.Post("/api/collections/:name/search", app.searchCollection(backend))
-	webapp.Post("/api/collections/:name/reset", app.resetCollection(backend))
-	webapp.Delete("/api/collections/:name/entry/delete", app.deleteEntryFromCollection(backend))
-	webapp.Post("/api/collections/:name/sources", app.registerExternalSource(backend))
-	webapp.Delete("/api/collections/:name/sources", app.removeExternalSource(backend))
-	webapp.Get("/api/collections/:name/sources", app.listSources(backend))
+	webapp.Post("/api/collections/:name/upload", writer, app.uploadFile(backend))
+	webapp.Get("/api/collections/:name/entries", reader, app.listFiles(backend))
+	webapp.Get("/api/collections/:name/entries/*", reader, app.getEntryContent(backend))
+	webapp.Post("/api/collections/:name/search", reader, app.searchCollection(backend))
+	webapp.Post("/api/collections/:name/reset", writer, app.resetCollection(backend))
+	webapp.Delete("/api/collections/:name/entry/delete", writer, app.deleteEntryFromCollection(backend))
+	webapp.Post("/api/collections/:name/sources", writer, app.registerExternalSource(backend))
+	webapp.Delete("/api/collections/:name/sources", writer, app.removeExternalSource(backend))
+	webapp.Get("/api/collections/:name/sources", reader, app.listSources(backend))
 }
 
 func collectionErrStatus(err error, collection string) int {
@@ -120,6 +126,10 @@ func (app *App) listCollections(backend CollectionsBackend) func(c *fiber.Ctx) e
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(collectionsErrorResponse(errCodeInternalError, "Failed to list collections", err.Error()))
 		}
+		user := currentUser(c)
+		collectionsList = slices.DeleteFunc(collectionsList, func(name string) bool {
+			return !user.Can(name, auth.RoleViewer)
+		})
 		return c.JSON(collectionsSuccessResponse("Collections retrieved successfully", map[string]interface{}{
 			"collections": collectionsList,
 			"count":       len(collectionsList),
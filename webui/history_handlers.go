@@ -0,0 +1,95 @@
+package webui
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/history"
+	"github.com/mudler/LocalAGI/core/state"
+)
+
+// observableHistory returns the persisted observables of the agent in the request
+func observableHistory(pool *state.AgentPool, c *fiber.Ctx) history.Store {
+	a := pool.GetAgent(c.Params("name"))
+	if a == nil {
+		return nil
+	}
+	observer, ok := a.Observer().(agent.PersistentObserver)
+	if !ok {
+		return nil
+	}
+	return observer.Store()
+}
+
+// SearchObservables returns the stored observables of an agent, newest
+// first. They are filtered with ?from= and ?to= (RFC3339),
+// ?status=running|completed|failed, ?action= and ?conversation_id=, and
+// ?limit= caps the number of results.
+func (a *App) SearchObservables(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store := observableHistory(pool, c)
+		if store == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Agent not found"})
+		}
+
+		query := history.Query{
+			Status:         history.Status(c.Query("status")),
+			Action:         c.Query("action"),
+			ConversationID: c.Query("conversation_id"),
+			Limit:          c.QueryInt("limit", history.DefaultLimit),
+		}
+		switch query.Status {
+		case "", history.StatusRunning, history.StatusCompleted, history.StatusFailed:
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid status: " + string(query.Status)})
+		}
+
+		var err error
+		if v := c.Query("from"); v != "" {
+			if query.From, err = time.Parse(time.RFC3339, v); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from: " + err.Error()})
+			}
+		}
+		if v := c.Query("to"); v != "" {
+			if query.To, err = time.Parse(time.RFC3339, v); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to: " + err.Error()})
+			}
+		}
+
+		records, err := store.Query(query)
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"Name": c.Params("name"), "History": records})
+	}
+}
+
+// ReplayObservable returns the full timeline of the job an observable
+// belongs to: the job observable and everything that happened under it,
+// in order
+func (a *App) ReplayObservable(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store := observableHistory(pool, c)
+		if store == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Agent not found"})
+		}
+
+		id, err := strconv.ParseInt(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid observable id"})
+		}
+
+		timeline, err := store.Timeline(int32(id))
+		if errors.Is(err, history.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"Name": c.Params("name"), "Timeline": timeline})
+	}
+}
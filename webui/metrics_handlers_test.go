@@ -0,0 +1,31 @@
+package webui_test
+
+import (
+	"io"
+	"net/http/httptest"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/webui"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	It("serves the agent metrics in the Prometheus text format", func() {
+		pool := newTestPool()
+		Expect(pool.CreateAgent("webui-metrics", &state.AgentConfig{Name: "webui-metrics"}, "")).To(Succeed())
+
+		app := fiber.New()
+		app.Get("/metrics", (&webui.App{}).Metrics(pool))
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(fiber.StatusOK))
+
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring("# TYPE go_goroutines gauge"))
+		Expect(string(body)).To(ContainSubstring(`localagi_job_queue_depth{agent="webui-metrics"} 0`))
+	})
+})
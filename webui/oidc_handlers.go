@@ -0,0 +1,142 @@
+package webui
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+	"github.com/mudler/xlog"
+
+	"github.com/mudler/LocalAGI/core/auth"
+)
+
+const (
+	// sessionCookie holds the session of users logged in with the identity
+	// provider
+	sessionCookie = "localagi_session"
+	// loginCookie binds a login in progress to the browser that started it
+	loginCookie = "localagi_login"
+	// sessionLocal is where the session of a request is kept
+	sessionLocal = "session"
+)
+
+// sessionUser looks up the session of the request. When there is one its
+// user is kept for the permission checks and no API key is needed.
+func (app *App) sessionUser(c *fiber.Ctx) bool {
+	if app.config.Sessions == nil {
+		return false
+	}
+	id := c.Cookies(sessionCookie)
+	if id == "" {
+		return false
+	}
+	session, ok := app.config.Sessions.Get(id)
+	if !ok {
+		return false
+	}
+	c.Locals(userLocal, session.User)
+	c.Locals(sessionLocal, session)
+	return true
+}
+
+// OIDCLogin sends the browser to the identity provider
+func (app *App) OIDCLogin() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		state, authURL, err := app.config.OIDC.Begin()
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		c.Cookie(&fiber.Cookie{
+			Name:     loginCookie,
+			Value:    state,
+			Path:     "/auth",
+			Expires:  time.Now().Add(10 * time.Minute),
+			Secure:   c.Protocol() == "https",
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+		return c.Redirect(authURL)
+	}
+}
+
+// OIDCCallback finishes the login when the identity provider sends the
+// browser back, and starts a session
+func (app *App) OIDCCallback() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if reason := c.Query("error"); reason != "" {
+			if description := c.Query("error_description"); description != "" {
+				reason = description
+			}
+			xlog.Warn("Login refused by the identity provider", "error", reason)
+			return app.loginFailed(c, "Login refused by the identity provider: "+reason)
+		}
+
+		state := c.Query("state")
+		c.Cookie(&fiber.Cookie{Name: loginCookie, Path: "/auth", Expires: time.Unix(0, 0), HTTPOnly: true})
+		if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Cookies(loginCookie))) != 1 {
+			return app.loginFailed(c, "The login expired, please try again")
+		}
+		identity, err := app.config.OIDC.Finish(c.UserContext(), state, c.Query("code"))
+		if err != nil {
+			xlog.Warn("Login failed", "error", err)
+			return app.loginFailed(c, "Login failed, please try again")
+		}
+
+		// Users known to LocalAGI keep the roles they were given, on top of
+		// the ones of their groups
+		user := app.config.OIDC.User(identity, app.config.Users)
+		if user.Role == auth.RoleNone && len(user.Agents) == 0 {
+			xlog.Warn("Login of a user without roles", "user", user.Name, "name", identity.Name, "groups", identity.Groups)
+			return app.loginFailed(c, fmt.Sprintf("%s has no access to LocalAGI", identity.Name))
+		}
+
+		session, err := app.config.Sessions.Create(user, identity.IDToken)
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		c.Cookie(&fiber.Cookie{
+			Name:     sessionCookie,
+			Value:    session.ID,
+			Path:     "/",
+			Expires:  session.ExpiresAt,
+			Secure:   c.Protocol() == "https",
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+		xlog.Info("User logged in", "user", user.Name, "name", identity.Name, "role", user.Role)
+		return c.Redirect("/app")
+	}
+}
+
+// OIDCLogout ends the session, and the one at the identity provider when
+// it supports it
+func (app *App) OIDCLogout() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var idToken string
+		if id := c.Cookies(sessionCookie); id != "" {
+			if session, ok := app.config.Sessions.Get(id); ok {
+				idToken = session.IDToken
+				xlog.Info("User logged out", "user", session.User.Name)
+			}
+			app.config.Sessions.Delete(id)
+		}
+		// The API key given on the login page is forgotten as well
+		c.ClearCookie(sessionCookie, "token")
+
+		if idToken != "" {
+			if logoutURL := app.config.OIDC.LogoutURL(idToken, c.BaseURL()+"/app"); logoutURL != "" {
+				return c.Redirect(logoutURL)
+			}
+		}
+		return c.Redirect("/app")
+	}
+}
+
+func (app *App) loginFailed(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusUnauthorized).Render("public/views/login", fiber.Map{
+		"Title":      "Login Required",
+		"SSO":        true,
+		"LoginError": message,
+	})
+}
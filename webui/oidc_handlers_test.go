@@ -0,0 +1,127 @@
+package webui_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/auth/oidctest"
+	"github.com/mudler/LocalAGI/core/ratelimit"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/webui"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Users of the identity provider", func() {
+	var (
+		app *webui.App
+		idp *oidctest.Server
+		log *audit.JSONLog
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		pool := newTestPool()
+		Expect(pool.CreateAgent("limited", &state.AgentConfig{Name: "limited"}, "")).To(Succeed())
+
+		var err error
+		log, err = audit.NewJSONLog(filepath.Join(dir, "audit.jsonl"))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(log.Close)
+		pool.SetAuditLog(log)
+
+		users, err := auth.NewJSONStore(filepath.Join(dir, "users.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(users.Create(auth.User{Name: "admin", Role: auth.RoleAdmin})).To(Succeed())
+
+		idp = oidctest.NewServer("localagi", "secret")
+		DeferCleanup(idp.Close)
+		roles, err := auth.ParseGroupRoles("platform=admin")
+		Expect(err).NotTo(HaveOccurred())
+		provider, err := auth.NewOIDC(context.Background(), auth.OIDCConfig{
+			Issuer:       idp.URL,
+			ClientID:     "localagi",
+			ClientSecret: "secret",
+			RedirectURL:  "http://localagi.test/auth/callback",
+			GroupRoles:   roles,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		app = webui.NewApp(
+			webui.WithPool(pool),
+			webui.WithUsers(users),
+			webui.WithStateDir(dir),
+			webui.WithLocalRAGURL("http://127.0.0.1:0"),
+			webui.WithOIDC(provider, time.Hour),
+			webui.WithRateLimiter(ratelimit.New(ratelimit.Limits{Agent: ratelimit.Rate{Requests: 1, Per: time.Hour}})),
+		)
+	})
+
+	// loginAs logs in as user at the identity provider and returns the
+	// session cookie
+	loginAs := func(user oidctest.User) *http.Cookie {
+		idp.SetUser(user)
+		resp, err := app.Test(httptest.NewRequest("GET", "/auth/login", nil), -1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(fiber.StatusFound))
+
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		back, err := client.Get(resp.Header.Get("Location"))
+		Expect(err).NotTo(HaveOccurred())
+		back.Body.Close()
+		callback, err := url.Parse(back.Header.Get("Location"))
+		Expect(err).NotTo(HaveOccurred())
+
+		req := httptest.NewRequest("GET", "/auth/callback?"+callback.RawQuery, nil)
+		for _, cookie := range resp.Cookies() {
+			req.AddCookie(cookie)
+		}
+		resp, err = app.Test(req, -1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Header.Get("Location")).To(Equal("/app"))
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == "localagi_session" {
+				return cookie
+			}
+		}
+		Fail("no session cookie")
+		return nil
+	}
+
+	request := func(session *http.Cookie, method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(session)
+		resp, err := app.Test(req, -1)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode
+	}
+
+	It("are not mistaken for the users with the same name", func() {
+		mallory := loginAs(oidctest.User{Subject: "666", Name: "admin", Groups: []string{"platform"}})
+		Expect(request(mallory, "POST", "/api/users", `{"name":"bob","role":"viewer"}`)).To(Equal(fiber.StatusOK))
+		Expect(request(mallory, "POST", "/api/chat/limited", `{"message":"hello"}`)).To(Equal(fiber.StatusAccepted))
+
+		entries, err := log.Query(audit.Query{Actor: "admin"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+		entries, err = log.Query(audit.Query{Actor: "oidc:666"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).NotTo(BeEmpty())
+
+		// Another user calling themselves admin has their own rate limit
+		other := loginAs(oidctest.User{Subject: "667", Name: "admin", Groups: []string{"platform"}})
+		Expect(request(other, "POST", "/api/chat/limited", `{"message":"hello"}`)).To(Equal(fiber.StatusAccepted))
+		Expect(request(mallory, "POST", "/api/chat/limited", `{"message":"hello"}`)).To(Equal(fiber.StatusTooManyRequests))
+	})
+})
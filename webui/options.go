@@ -3,6 +3,8 @@ package webui
 import (
 	"time"
 
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/ratelimit"
 	"github.com/mudler/LocalAGI/core/state"
 	"github.com/mudler/LocalAGI/services/skills"
 )
@@ -12,12 +14,18 @@ type Config struct {
 	Pool                      *state.AgentPool
 	SkillsService             *skills.Service
 	ApiKeys                   []string
+	Users                     auth.Store
+	OIDC                      *auth.OIDC
+	Sessions                  *auth.Sessions
+	RateLimiter               *ratelimit.Limiter
 	LLMAPIURL                 string
 	LLMAPIKey                 string
 	LLMModel                  string
 	StateDir                  string
 	CustomActionsDir          string
 	ConversationStoreDuration time.Duration
+	ConversationMaxMessages   int
+	ConversationMaxCount      int
 
 	// Collections / knowledge base (LocalRecall)
 	CollectionDBPath string
@@ -49,6 +57,15 @@ func WithConversationStoreduration(duration string) Option {
 	}
 }
 
+// WithConversationRetention bounds the conversations kept for /v1/responses.
+// Zero means unlimited.
+func WithConversationRetention(maxMessages, maxConversations int) Option {
+	return func(c *Config) {
+		c.ConversationMaxMessages = maxMessages
+		c.ConversationMaxCount = maxConversations
+	}
+}
+
 func WithStateDir(dir string) Option {
 	return func(c *Config) {
 		c.StateDir = dir
@@ -97,6 +114,34 @@ func WithApiKeys(keys ...string) Option {
 	}
 }
 
+// WithUsers sets the users allowed to use the API, on top of the API keys
+func WithUsers(store auth.Store) Option {
+	return func(c *Config) {
+		c.Users = store
+	}
+}
+
+// WithOIDC lets users log in to the web UI with an OpenID Connect identity
+// provider. Their sessions last sessionDuration. A nil provider disables
+// the login.
+func WithOIDC(provider *auth.OIDC, sessionDuration time.Duration) Option {
+	return func(c *Config) {
+		if provider == nil {
+			return
+		}
+		c.OIDC = provider
+		c.Sessions = auth.NewSessions(sessionDuration)
+	}
+}
+
+// WithRateLimiter limits how often each API key or user can send jobs to
+// the agents through the API
+func WithRateLimiter(limiter *ratelimit.Limiter) Option {
+	return func(c *Config) {
+		c.RateLimiter = limiter
+	}
+}
+
 func WithCollectionDBPath(path string) Option {
 	return func(c *Config) {
 		c.CollectionDBPath = path
@@ -0,0 +1,43 @@
+package webui
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/ratelimit"
+)
+
+// rateLimitKey is who a request counts against: its API key, the user of
+// its session, or the address of the client when authentication is disabled
+func rateLimitKey(c *fiber.Ctx) string {
+	if key, ok := c.Locals(apiKeyLocal).(string); ok {
+		return "key:" + key
+	}
+	if user, ok := c.Locals(userLocal).(*auth.User); ok {
+		return user.Name
+	}
+	return "ip:" + c.IP()
+}
+
+// allowRequest takes a request to agent from the rate limit of the caller.
+// Over the limit, it sets the Retry-After header and returns the error.
+func (app *App) allowRequest(c *fiber.Ctx, agent string) *ratelimit.Error {
+	var limitErr *ratelimit.Error
+	if errors.As(app.config.RateLimiter.Allow(agent, rateLimitKey(c)), &limitErr) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(limitErr.RetryAfterSeconds()))
+		return limitErr
+	}
+	return nil
+}
+
+// rateLimit answers 429 to the requests over the rate limit of the agent of
+// the :name parameter
+func (app *App) rateLimit(c *fiber.Ctx) error {
+	if err := app.allowRequest(c, c.Params("name")); err != nil {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Next()
+}
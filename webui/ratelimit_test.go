@@ -0,0 +1,71 @@
+package webui_test
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/ratelimit"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/webui"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rate limits", func() {
+	var (
+		app          *webui.App
+		first, other string
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		pool := newTestPool()
+		Expect(pool.CreateAgent("limited", &state.AgentConfig{Name: "limited"}, "")).To(Succeed())
+
+		users, err := auth.NewJSONStore(filepath.Join(dir, "users.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(users.Create(auth.User{Name: "alice", Role: auth.RoleOperator})).To(Succeed())
+		first, _, err = users.CreateAPIKey("alice", "laptop")
+		Expect(err).NotTo(HaveOccurred())
+		other, _, err = users.CreateAPIKey("alice", "ci")
+		Expect(err).NotTo(HaveOccurred())
+
+		app = webui.NewApp(
+			webui.WithPool(pool),
+			webui.WithUsers(users),
+			webui.WithStateDir(dir),
+			webui.WithLocalRAGURL("http://127.0.0.1:0"),
+			webui.WithRateLimiter(ratelimit.New(ratelimit.Limits{Agent: ratelimit.Rate{Requests: 1, Per: time.Hour}})),
+		)
+	})
+
+	chat := func(key string) (int, string) {
+		req := httptest.NewRequest("POST", "/api/chat/limited", strings.NewReader(`{"message":"hello"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := app.Test(req, -1)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter)
+	}
+
+	It("answers 429 with Retry-After to the API key over the limit", func() {
+		status, _ := chat(first)
+		Expect(status).To(Equal(fiber.StatusAccepted))
+
+		status, retryAfter := chat(first)
+		Expect(status).To(Equal(fiber.StatusTooManyRequests))
+		Expect(retryAfter).NotTo(BeEmpty())
+	})
+
+	It("limits each API key of a user separately", func() {
+		status, _ := chat(first)
+		Expect(status).To(Equal(fiber.StatusAccepted))
+
+		status, _ = chat(other)
+		Expect(status).To(Equal(fiber.StatusAccepted))
+	})
+})
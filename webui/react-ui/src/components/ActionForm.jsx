@@ -1,5 +1,6 @@
 import React from 'react';
 import ConfigForm from './ConfigForm';
+import FormField from './common/FormField';
 
 /**
  * ActionForm component for configuring an action
@@ -9,6 +10,19 @@ const ActionForm = ({ actions = [], onChange, onRemove, onAdd, onPlay, fieldGrou
   const handleActionChange = (index, updatedAction) => {
     onChange(index, updatedAction);
   };
+
+  // Gated actions wait for a human to approve them before running
+  const renderApprovalToggle = (action, index) => (
+    <FormField
+      id={`action-${index}-requires_approval`}
+      name="requires_approval"
+      label="Require approval"
+      type="checkbox"
+      value={action.requires_approval === true}
+      onChange={(e) => handleActionChange(index, { ...action, requires_approval: e.target.checked })}
+      helpText="Pause the job until someone approves or denies the action from the web UI or the connector"
+    />
+  );
   
   return (
     <ConfigForm
@@ -21,6 +35,7 @@ const ActionForm = ({ actions = [], onChange, onRemove, onAdd, onPlay, fieldGrou
       itemType="action"
       typeField="name"
       addButtonText="Add Action"
+      renderItemExtras={renderApprovalToggle}
     />
   );
 };
@@ -15,6 +15,7 @@ import FormFieldDefinition from './common/FormFieldDefinition';
  * @param {String} props.typeField - The field name that determines the item's type (e.g., 'name' for actions, 'type' for connectors)
  * @param {String} props.addButtonText - Text for the add button
  * @param {String} props.saveAllFieldsAsString - Whether to save all fields as string or the appropriate JSON type
+ * @param {Function} props.renderItemExtras - Optional renderer for item-level settings shown below the type-specific fields
  */
 const ConfigForm = ({ 
   items = [], 
@@ -27,6 +28,7 @@ const ConfigForm = ({
   typeField = 'type',
   addButtonText = 'Add Item',
   saveAllFieldsAsString = true,
+  renderItemExtras,
 }) => {
   // Generate options from fieldGroups
   const typeOptions = [
@@ -137,6 +139,8 @@ const ConfigForm = ({
             idPrefix={`${itemType}-${index}-`}
           />
         )}
+
+        {renderItemExtras && renderItemExtras(safeItem, index)}
       </div>
     );
   };
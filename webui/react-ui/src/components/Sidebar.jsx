@@ -1,17 +1,19 @@
 import { useState } from 'react';
 import { Outlet, Link, useLocation } from 'react-router-dom';
+import { usePermissions } from '../hooks/usePermissions';
 
 const Sidebar = ({ children }) => {
   const [collapsed, setCollapsed] = useState(false);
   const [mobileOpen, setMobileOpen] = useState(false);
   const location = useLocation();
+  const { me, isAdmin } = usePermissions();
 
   const navItems = [
     { path: '/', icon: 'fas fa-home', label: 'Home' },
     { path: '/agents', icon: 'fas fa-users', label: 'Agents' },
-    { path: '/actions-playground', icon: 'fas fa-bolt', label: 'Actions' },
-    { path: '/group-create', icon: 'fas fa-users-cog', label: 'Groups' },
-  ];
+    { path: '/actions-playground', icon: 'fas fa-bolt', label: 'Actions', adminOnly: true },
+    { path: '/group-create', icon: 'fas fa-users-cog', label: 'Groups', adminOnly: true },
+  ].filter((item) => !item.adminOnly || isAdmin);
 
   const isActive = (path) => {
     if (path === '/') return location.pathname === '/';
@@ -74,6 +76,16 @@ const Sidebar = ({ children }) => {
               </span>
             )}
           </div>
+          {me?.Session && (
+            <a
+              href="/auth/logout"
+              className="nav-link"
+              title={collapsed ? `Log out ${me.DisplayName || me.Name}` : ''}
+            >
+              <i className="fas fa-sign-out-alt" />
+              {!collapsed && <span className="nav-label">Log out {me.DisplayName || me.Name}</span>}
+            </a>
+          )}
         </div>
       </aside>
 
@@ -1,9 +1,29 @@
-import React from 'react';
+import React, { useState, useEffect } from 'react';
 import FormFieldDefinition from '../common/FormFieldDefinition';
 
+// Format the llm_endpoints array as one "url [api_key]" line per endpoint
+function formatEndpoints(endpoints) {
+  if (!Array.isArray(endpoints)) return '';
+  return endpoints
+    .map(e => [e?.url || '', e?.api_key || ''].filter(Boolean).join(' '))
+    .join('\n');
+}
+
+// Parse "url [api_key]" lines to the llm_endpoints array, skipping blank lines
+function parseEndpoints(text) {
+  return (text || '')
+    .split('\n')
+    .map(line => line.trim())
+    .filter(Boolean)
+    .map(line => {
+      const [url, apiKey] = line.split(/\s+/);
+      return apiKey ? { url, api_key: apiKey } : { url };
+    });
+}
+
 /**
  * Model Settings section of the agent form
- * 
+ *
  * @param {Object} props Component props
  * @param {Object} props.formData Current form data values
  * @param {Function} props.handleInputChange Handler for input changes
@@ -13,6 +33,16 @@ const ModelSettingsSection = ({ formData, handleInputChange, metadata }) => {
   // Get fields from metadata
   const fields = metadata?.ModelSettingsSection || [];
 
+  // llm_endpoints is an array in the config, edited as text
+  const [endpointsText, setEndpointsText] = useState(() => formatEndpoints(formData.llm_endpoints));
+  // Follow the changes made outside this section, e.g. the config being loaded
+  useEffect(() => {
+    const current = JSON.stringify(formData.llm_endpoints || []);
+    if (current !== JSON.stringify(parseEndpoints(endpointsText))) {
+      setEndpointsText(formatEndpoints(formData.llm_endpoints));
+    }
+  }, [formData.llm_endpoints]);
+
   // Handle field value changes (FormField passes the event)
   const handleFieldChange = (e) => {
     const { name, value, type, checked } = e.target;
@@ -25,6 +55,15 @@ const ModelSettingsSection = ({ formData, handleInputChange, metadata }) => {
           checked
         }
       });
+    } else if (name === 'llm_endpoints') {
+      setEndpointsText(value);
+      handleInputChange({
+        target: {
+          name,
+          type,
+          value: parseEndpoints(value)
+        }
+      });
     } else {
       handleInputChange({
         target: {
@@ -39,10 +78,10 @@ const ModelSettingsSection = ({ formData, handleInputChange, metadata }) => {
   return (
     <div id="model-section">
       <h3 className="section-title">Model Settings</h3>
-      
+
       <FormFieldDefinition
         fields={fields}
-        values={formData}
+        values={{ ...formData, llm_endpoints: endpointsText }}
         onChange={handleFieldChange}
         idPrefix="model_"
       />
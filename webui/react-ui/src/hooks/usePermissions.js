@@ -0,0 +1,54 @@
+import { useState, useEffect } from 'react';
+import { userApi } from '../utils/api';
+
+const ROLE_RANKS = { '': 0, viewer: 1, operator: 2, admin: 3 };
+
+// The current user doesn't change while the page is open, fetch it once
+let mePromise = null;
+
+const fetchMe = () => {
+  if (!mePromise) {
+    mePromise = userApi.getMe().catch((err) => {
+      mePromise = null;
+      throw err;
+    });
+  }
+  return mePromise;
+};
+
+const allows = (role, required) => (ROLE_RANKS[role || ''] || 0) >= ROLE_RANKS[required];
+
+/**
+ * Custom hook for the roles of the current user, to hide what it can't do.
+ * Until the user is loaded nothing is allowed.
+ * @returns {Object} - The user and permission checks
+ */
+export function usePermissions() {
+  const [me, setMe] = useState(null);
+
+  useEffect(() => {
+    let cancelled = false;
+    fetchMe()
+      .then((user) => { if (!cancelled) setMe(user); })
+      .catch((err) => console.error('Error fetching current user:', err));
+    return () => { cancelled = true; };
+  }, []);
+
+  // Role of the user on an agent: its global role or its grant on the agent
+  const agentRole = (agent) => {
+    if (!me) return '';
+    const granted = me.Agents?.[agent] || '';
+    return allows(me.Role, granted) ? me.Role : granted;
+  };
+
+  return {
+    me,
+    loaded: me !== null,
+    isAdmin: !!me?.Admin,
+    // Whether the user has role on every agent
+    hasRole: (role) => !!me && allows(me.Role, role),
+    // Whether the user has role on an agent
+    can: (agent, role) => allows(agentRole(agent), role),
+    agentRole,
+  };
+}
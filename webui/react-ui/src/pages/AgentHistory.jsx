@@ -0,0 +1,260 @@
+import { useState, useEffect, useCallback } from 'react';
+import { useParams, useSearchParams, Link } from 'react-router-dom';
+import { historyApi } from '../utils/api';
+import { ObservableCard } from './AgentStatus';
+
+const STATUSES = ['running', 'completed', 'failed'];
+
+// toISO converts a datetime-local input value to an RFC3339 date
+function toISO(value) {
+  return value ? new Date(value).toISOString() : '';
+}
+
+function formatTime(value) {
+  return value ? new Date(value).toLocaleString() : '';
+}
+
+// formatOffset returns the time elapsed between the start of the job and an observable
+function formatOffset(start, value) {
+  const ms = new Date(value) - new Date(start);
+  if (!Number.isFinite(ms) || ms < 0) return '';
+  return ms < 1000 ? `+${ms}ms` : `+${(ms / 1000).toFixed(1)}s`;
+}
+
+function StatusBadge({ status }) {
+  return <span className={`status-badge history-status ${status}`}>{status}</span>;
+}
+
+// ReplayTimeline shows the observables of a past job in the order they
+// happened, indented under the observable that started them
+function ReplayTimeline({ timeline }) {
+  if (timeline.length === 0) {
+    return <p className="status-section-description">No observables recorded for this job.</p>;
+  }
+
+  const depths = {};
+  timeline.forEach(record => {
+    depths[record.id] = record.parent_id && depths[record.parent_id] !== undefined
+      ? depths[record.parent_id] + 1
+      : 0;
+  });
+  const start = timeline[0].created_at;
+
+  return (
+    <div className="history-timeline">
+      {timeline.map(record => (
+        <div
+          key={record.id}
+          className="history-timeline-item"
+          style={{ marginLeft: `${depths[record.id] * 1.5}rem` }}
+        >
+          <div className="history-timeline-meta">
+            <span title={formatTime(record.created_at)}>{formatOffset(start, record.created_at)}</span>
+            <StatusBadge status={record.status} />
+          </div>
+          <ObservableCard observable={record} isNested={depths[record.id] > 0} />
+        </div>
+      ))}
+    </div>
+  );
+}
+
+function AgentHistory() {
+  const { name } = useParams();
+  const [searchParams, setSearchParams] = useSearchParams();
+  const replayID = searchParams.get('replay');
+
+  const [filters, setFilters] = useState({
+    from: '',
+    to: '',
+    status: '',
+    action: '',
+    conversation_id: '',
+  });
+  const [records, setRecords] = useState([]);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState(null);
+  const [timeline, setTimeline] = useState(null);
+  const [replayError, setReplayError] = useState(null);
+
+  useEffect(() => {
+    if (name) {
+      document.title = `${name} - History - LocalAGI`;
+    }
+    return () => {
+      document.title = 'LocalAGI';
+    };
+  }, [name]);
+
+  const search = useCallback(async (current) => {
+    setLoading(true);
+    setError(null);
+    try {
+      const results = await historyApi.search(name, {
+        ...current,
+        from: toISO(current.from),
+        to: toISO(current.to),
+      });
+      setRecords(results);
+    } catch (err) {
+      setError(`Failed to search the history of "${name}": ${err.message}`);
+    } finally {
+      setLoading(false);
+    }
+  }, [name]);
+
+  // Show the latest jobs when the page opens
+  useEffect(() => {
+    search({});
+  }, [search]);
+
+  useEffect(() => {
+    if (!replayID) {
+      setTimeline(null);
+      return;
+    }
+    let cancelled = false;
+    setReplayError(null);
+    historyApi.replay(name, replayID)
+      .then(result => { if (!cancelled) setTimeline(result); })
+      .catch(err => { if (!cancelled) setReplayError(`Failed to replay #${replayID}: ${err.message}`); });
+    return () => { cancelled = true; };
+  }, [name, replayID]);
+
+  const handleChange = (e) => {
+    const { name: field, value } = e.target;
+    setFilters(prev => ({ ...prev, [field]: value }));
+  };
+
+  const handleSubmit = (e) => {
+    e.preventDefault();
+    search(filters);
+  };
+
+  const replay = (id) => {
+    setSearchParams({ replay: String(id) });
+  };
+
+  return (
+    <div className="agent-status-container">
+      <header className="page-header">
+        <div className="header-title-section">
+          <div style={{ display: 'flex', alignItems: 'center', gap: '0.75rem' }}>
+            <Link to={`/status/${name}`} className="back-link" title="Back to status">
+              <i className="fas fa-arrow-left" />
+            </Link>
+            <h1>{name}</h1>
+          </div>
+          <p className="agent-subtitle">Search past jobs and replay their timeline</p>
+        </div>
+      </header>
+
+      {/* Search Section */}
+      <div className="status-section">
+        <div className="status-section-header">
+          <h2>
+            <i className="fas fa-search" />
+            Search History
+          </h2>
+        </div>
+        <form className="history-filters" onSubmit={handleSubmit}>
+          <div className="form-group">
+            <label htmlFor="from">From</label>
+            <input type="datetime-local" id="from" name="from" value={filters.from} onChange={handleChange} />
+          </div>
+          <div className="form-group">
+            <label htmlFor="to">To</label>
+            <input type="datetime-local" id="to" name="to" value={filters.to} onChange={handleChange} />
+          </div>
+          <div className="form-group">
+            <label htmlFor="status">Status</label>
+            <select id="status" name="status" value={filters.status} onChange={handleChange}>
+              <option value="">Any</option>
+              {STATUSES.map(status => (
+                <option key={status} value={status}>{status}</option>
+              ))}
+            </select>
+          </div>
+          <div className="form-group">
+            <label htmlFor="action">Action</label>
+            <input type="text" id="action" name="action" value={filters.action} onChange={handleChange} placeholder="e.g. search" />
+          </div>
+          <div className="form-group">
+            <label htmlFor="conversation_id">Conversation</label>
+            <input type="text" id="conversation_id" name="conversation_id" value={filters.conversation_id} onChange={handleChange} placeholder="e.g. slack:C0123" />
+          </div>
+          <button type="submit" className="action-btn status-btn" disabled={loading}>
+            {loading ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-search" />} Search
+          </button>
+        </form>
+
+        {error && <p className="history-error">{error}</p>}
+
+        {!loading && !error && records.length === 0 && (
+          <p className="status-section-description">No observables match the search.</p>
+        )}
+
+        {records.length > 0 && (
+          <table className="history-table">
+            <thead>
+              <tr>
+                <th>Observable</th>
+                <th>Action</th>
+                <th>Conversation</th>
+                <th>Status</th>
+                <th>Started</th>
+                <th />
+              </tr>
+            </thead>
+            <tbody>
+              {records.map(record => (
+                <tr key={record.id} className={String(record.id) === replayID ? 'selected' : ''}>
+                  <td>
+                    <i className={`fas fa-${record.icon || 'robot'}`} /> {record.name}
+                    <span className="observable-id">#{record.id}</span>
+                  </td>
+                  <td>{record.creation?.function_definition?.name || ''}</td>
+                  <td>{record.conversation_id || ''}</td>
+                  <td><StatusBadge status={record.status} /></td>
+                  <td>{formatTime(record.created_at)}</td>
+                  <td>
+                    <button className="action-btn status-btn" onClick={() => replay(record.id)} title="Replay the job">
+                      <i className="fas fa-play" /> Replay
+                    </button>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+      </div>
+
+      {/* Replay Section */}
+      {replayID && (
+        <div className="status-section">
+          <div className="status-section-header">
+            <h2>
+              <i className="fas fa-history" />
+              Replay of #{replayID}
+            </h2>
+            <button className="action-btn" onClick={() => setSearchParams({})} title="Close the replay">
+              <i className="fas fa-times" /> Close
+            </button>
+          </div>
+          <p className="status-section-description">
+            Everything that happened in the job, in order, with the time elapsed since it started
+          </p>
+          {replayError && <p className="history-error">{replayError}</p>}
+          {!replayError && timeline === null && (
+            <div className="loading-container">
+              <div className="loader" />
+            </div>
+          )}
+          {!replayError && timeline !== null && <ReplayTimeline timeline={timeline} />}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default AgentHistory;
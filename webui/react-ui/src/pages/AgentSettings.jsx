@@ -1,6 +1,7 @@
 import { useState, useEffect } from 'react';
 import { useParams, useOutletContext, useNavigate } from 'react-router-dom';
 import { useAgent } from '../hooks/useAgent';
+import { usePermissions } from '../hooks/usePermissions';
 import { agentApi } from '../utils/api';
 import AgentForm from '../components/AgentForm';
 
@@ -10,6 +11,7 @@ function AgentSettings() {
   const navigate = useNavigate();
   const [metadata, setMetadata] = useState(null);
   const [formData, setFormData] = useState({});
+  const { can } = usePermissions();
 
   // Update document title
   useEffect(() => {
@@ -140,20 +142,24 @@ function AgentSettings() {
         </div>
         
         <div className="header-actions">
-          <button 
-            className={`action-btn ${agent?.active ? 'warning' : 'success'}`}
-            onClick={handleToggleStatus}
-          >
-            <i className={`fas ${agent?.active ? 'fa-pause' : 'fa-play'}`} />
-            {agent?.active ? 'Pause Agent' : 'Start Agent'}
-          </button>
-          <button 
-            className="action-btn delete-btn"
-            onClick={handleDelete}
-          >
-            <i className="fas fa-trash" />
-            Delete
-          </button>
+          {can(name, 'operator') && (
+            <button 
+              className={`action-btn ${agent?.active ? 'warning' : 'success'}`}
+              onClick={handleToggleStatus}
+            >
+              <i className={`fas ${agent?.active ? 'fa-pause' : 'fa-play'}`} />
+              {agent?.active ? 'Pause Agent' : 'Start Agent'}
+            </button>
+          )}
+          {can(name, 'admin') && (
+            <button 
+              className="action-btn delete-btn"
+              onClick={handleDelete}
+            >
+              <i className="fas fa-trash" />
+              Delete
+            </button>
+          )}
         </div>
       </header>
       
@@ -4,10 +4,11 @@ import hljs from 'highlight.js/lib/core';
 import json from 'highlight.js/lib/languages/json';
 import 'highlight.js/styles/monokai.css';
 import CollapsibleRawSections from '../components/CollapsibleRawSections';
+import { usePermissions } from '../hooks/usePermissions';
 
 hljs.registerLanguage('json', json);
 
-function ObservableSummary({ observable }) {
+export function ObservableSummary({ observable }) {
   const creation = observable?.creation || {};
   const completion = observable?.completion || {};
   
@@ -172,7 +173,7 @@ function ObservableSummary({ observable }) {
   );
 }
 
-function ObservableCard({ observable, isNested = false }) {
+export function ObservableCard({ observable, isNested = false }) {
   const [isExpanded, setIsExpanded] = useState(false);
   const [expandedChildren, setExpandedChildren] = useState(new Map());
   const childKey = isNested ? `child-${observable.id}` : observable.id;
@@ -258,6 +259,7 @@ function ObservableCard({ observable, isNested = false }) {
 
 function AgentStatus() {
   const { name } = useParams();
+  const { can } = usePermissions();
   const [showStatus, setShowStatus] = useState(false);
   const [statusData, setStatusData] = useState(null);
   const [loading, setLoading] = useState(true);
@@ -426,6 +428,9 @@ function AgentStatus() {
           </div>
           <p className="agent-subtitle">Monitor agent activity and observables in real-time</p>
         </div>
+        <Link to={`/history/${name}`} className="action-btn status-btn" title="Search and replay past jobs">
+          <i className="fas fa-history" /> History
+        </Link>
       </header>
 
       {/* Current Status Section */}
@@ -472,18 +477,20 @@ function AgentStatus() {
               <i className="fas fa-eye" />
               Observable Updates
             </h2>
-            <button
-              className="action-btn delete-btn"
-              onClick={handleClearObservables}
-              disabled={clearLoading}
-              style={{ fontSize: '0.85rem', padding: '0.4rem 0.75rem' }}
-            >
-              {clearLoading ? (
-                <><i className="fas fa-spinner fa-spin" /> Clearing...</>
-              ) : (
-                <><i className="fas fa-trash" /> Clear History</>
-              )}
-            </button>
+            {can(name, 'operator') && (
+              <button
+                className="action-btn delete-btn"
+                onClick={handleClearObservables}
+                disabled={clearLoading}
+                style={{ fontSize: '0.85rem', padding: '0.4rem 0.75rem' }}
+              >
+                {clearLoading ? (
+                  <><i className="fas fa-spinner fa-spin" /> Clearing...</>
+                ) : (
+                  <><i className="fas fa-trash" /> Clear History</>
+                )}
+              </button>
+            )}
           </div>
           <p className="status-section-description">
             Drill down into agent activities triggered by connectors
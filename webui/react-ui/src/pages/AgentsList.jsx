@@ -1,6 +1,7 @@
 import { useState, useEffect } from 'react';
 import { Link, useOutletContext } from 'react-router-dom';
 import { agentApi } from '../utils/api';
+import { usePermissions } from '../hooks/usePermissions';
 
 function AgentsList() {
   const [agents, setAgents] = useState([]);
@@ -8,6 +9,7 @@ function AgentsList() {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
   const { showToast } = useOutletContext();
+  const { isAdmin, can } = usePermissions();
 
   // Fetch agents data
   const fetchAgents = async () => {
@@ -119,14 +121,16 @@ function AgentsList() {
     <div className="agents-container">
       <header className="page-header">
         <h1>Manage Agents</h1>
-        <div className="agent-actions">
-          <Link to="/create" className="action-btn">
-            <i className="fas fa-plus-circle"></i> Create Agent
-          </Link>
-          <Link to="/import" className="action-btn">
-            <i className="fas fa-upload"></i> Import Agent
-          </Link>
-        </div>
+        {isAdmin && (
+          <div className="agent-actions">
+            <Link to="/create" className="action-btn">
+              <i className="fas fa-plus-circle"></i> Create Agent
+            </Link>
+            <Link to="/import" className="action-btn">
+              <i className="fas fa-upload"></i> Import Agent
+            </Link>
+          </div>
+        )}
       </header>
 
       {agents.length > 0 ? (
@@ -155,38 +159,46 @@ function AgentsList() {
                   </td>
                   <td>
                     <div className="agent-table-actions">
-                      <Link to={`/talk/${name}`} className="action-btn chat-btn" title="Chat">
-                        <i className="fas fa-comment"></i> Chat
-                      </Link>
+                      {can(name, 'operator') && (
+                        <Link to={`/talk/${name}`} className="action-btn chat-btn" title="Chat">
+                          <i className="fas fa-comment"></i> Chat
+                        </Link>
+                      )}
                       <Link to={`/status/${name}`} className="action-btn status-btn" title="Status">
                         <i className="fas fa-chart-line"></i> Status
                       </Link>
-                      <Link to={`/settings/${name}`} className="action-btn settings-btn" title="Settings">
-                        <i className="fas fa-cog"></i> Settings
-                      </Link>
+                      {can(name, 'admin') && (
+                        <Link to={`/settings/${name}`} className="action-btn settings-btn" title="Settings">
+                          <i className="fas fa-cog"></i> Settings
+                        </Link>
+                      )}
                     </div>
                   </td>
                   <td>
                     <div className="agent-table-actions">
-                      <button
-                        className="action-btn toggle-btn"
-                        onClick={() => toggleAgentStatus(name, statuses[name])}
-                        title={statuses[name] ? "Pause Agent" : "Start Agent"}
-                      >
-                        {statuses[name] ? (
-                          <><i className="fas fa-pause"></i> Pause</>
-                        ) : (
-                          <><i className="fas fa-play"></i> Start</>
-                        )}
-                      </button>
-
-                      <button
-                        className="action-btn delete-btn"
-                        onClick={() => deleteAgent(name)}
-                        title="Delete Agent"
-                      >
-                        <i className="fas fa-trash-alt"></i> Delete
-                      </button>
+                      {can(name, 'operator') && (
+                        <button
+                          className="action-btn toggle-btn"
+                          onClick={() => toggleAgentStatus(name, statuses[name])}
+                          title={statuses[name] ? "Pause Agent" : "Start Agent"}
+                        >
+                          {statuses[name] ? (
+                            <><i className="fas fa-pause"></i> Pause</>
+                          ) : (
+                            <><i className="fas fa-play"></i> Start</>
+                          )}
+                        </button>
+                      )}
+
+                      {can(name, 'admin') && (
+                        <button
+                          className="action-btn delete-btn"
+                          onClick={() => deleteAgent(name)}
+                          title="Delete Agent"
+                        >
+                          <i className="fas fa-trash-alt"></i> Delete
+                        </button>
+                      )}
                     </div>
                   </td>
                 </tr>
@@ -197,10 +209,14 @@ function AgentsList() {
       ) : (
         <div className="no-agents">
           <h2>No Agents Found</h2>
-          <p>Get started by creating your first agent</p>
-          <Link to="/create" className="action-btn">
-            <i className="fas fa-plus"></i> Create Agent
-          </Link>
+          {isAdmin && (
+            <>
+              <p>Get started by creating your first agent</p>
+              <Link to="/create" className="action-btn">
+                <i className="fas fa-plus"></i> Create Agent
+              </Link>
+            </>
+          )}
         </div>
       )}
     </div>
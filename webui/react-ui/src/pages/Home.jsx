@@ -1,6 +1,7 @@
 import { useState, useEffect } from 'react';
 import { Link, useLocation } from 'react-router-dom';
 import { agentApi } from '../utils/api';
+import { usePermissions } from '../hooks/usePermissions';
 
 function Home() {
   const [stats, setStats] = useState({
@@ -13,6 +14,7 @@ function Home() {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
   const location = useLocation();
+  const { isAdmin } = usePermissions();
 
   // Update document title
   useEffect(() => {
@@ -82,38 +84,41 @@ function Home() {
           </div>
         </Link>
 
-        {/* Card for Create Agent */}
-        <Link to="/create" className="card-link">
-          <div className="card">
-            <h2><i className="fas fa-plus-circle"></i> Create Agent</h2>
-            <p>Create a new intelligent agent with custom behaviors, connectors, and actions.</p>
-          </div>
-        </Link>
+        {isAdmin && (
+          <>
+            {/* Card for Create Agent */}
+            <Link to="/create" className="card-link">
+              <div className="card">
+                <h2><i className="fas fa-plus-circle"></i> Create Agent</h2>
+                <p>Create a new intelligent agent with custom behaviors, connectors, and actions.</p>
+              </div>
+            </Link>
 
-        {/* Card for Actions Playground */}
-        <Link to="/actions-playground" className="card-link">
-          <div className="card">
-            <h2><i className="fas fa-code"></i> Actions Playground</h2>
-            <p>Explore and test available actions for your agents.</p>
-          </div>
-        </Link>
+            {/* Card for Actions Playground */}
+            <Link to="/actions-playground" className="card-link">
+              <div className="card">
+                <h2><i className="fas fa-code"></i> Actions Playground</h2>
+                <p>Explore and test available actions for your agents.</p>
+              </div>
+            </Link>
 
-        {/* Card for Group Create */}
-        <Link to="/group-create" className="card-link">
-          <div className="card">
-            <h2><i className="fas fa-users"></i> Create Group</h2>
-            <p>Create a group of agents with shared configurations and behaviors.</p>
-          </div>
-        </Link>
-
-        {/* Card for Import Agent */}
-        <Link to="/import" className="card-link">
-          <div className="card">
-            <h2><i className="fas fa-upload"></i> Import Agent</h2>
-            <p>Import an existing agent configuration from a file.</p>
-          </div>
-        </Link>
+            {/* Card for Group Create */}
+            <Link to="/group-create" className="card-link">
+              <div className="card">
+                <h2><i className="fas fa-users"></i> Create Group</h2>
+                <p>Create a group of agents with shared configurations and behaviors.</p>
+              </div>
+            </Link>
 
+            {/* Card for Import Agent */}
+            <Link to="/import" className="card-link">
+              <div className="card">
+                <h2><i className="fas fa-upload"></i> Import Agent</h2>
+                <p>Import an existing agent configuration from a file.</p>
+              </div>
+            </Link>
+          </>
+        )}
       </div>
 
     </div>
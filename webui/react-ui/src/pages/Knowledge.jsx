@@ -1,6 +1,7 @@
 import { useState, useEffect } from 'react';
 import { useOutletContext } from 'react-router-dom';
 import { collectionsApi } from '../utils/api';
+import { usePermissions } from '../hooks/usePermissions';
 
 const TABS = [
   { id: 'search', label: 'Search', icon: 'fa-search' },
@@ -15,6 +16,9 @@ function Knowledge() {
   const [tab, setTab] = useState('search');
   const [collections, setCollections] = useState([]);
   const [loadingCollections, setLoadingCollections] = useState(true);
+  // The knowledge base of an agent is the collection named after it
+  const { hasRole, can } = usePermissions();
+  const canWrite = (collection) => can(collection, 'operator');
 
   const fetchCollections = async () => {
     setLoadingCollections(true);
@@ -75,11 +79,13 @@ function Knowledge() {
             loadingCollections={loadingCollections}
             onRefresh={fetchCollections}
             showToast={showToast}
+            canCreate={hasRole('operator')}
+            canWrite={canWrite}
           />
         )}
         {tab === 'upload' && (
           <UploadTab
-            collections={collections}
+            collections={collections.filter(canWrite)}
             loadingCollections={loadingCollections}
             onRefreshCollections={fetchCollections}
             showToast={showToast}
@@ -90,6 +96,7 @@ function Knowledge() {
             collections={collections}
             loadingCollections={loadingCollections}
             showToast={showToast}
+            canWrite={canWrite}
           />
         )}
         {tab === 'entries' && (
@@ -98,6 +105,7 @@ function Knowledge() {
             loadingCollections={loadingCollections}
             onRefreshCollections={fetchCollections}
             showToast={showToast}
+            canWrite={canWrite}
           />
         )}
       </div>
@@ -196,7 +204,7 @@ function SearchTab({ collections, loadingCollections, onRefreshCollections, show
   );
 }
 
-function CollectionsTab({ collections, loadingCollections, onRefresh, showToast }) {
+function CollectionsTab({ collections, loadingCollections, onRefresh, showToast, canCreate, canWrite }) {
   const [newName, setNewName] = useState('');
   const [creating, setCreating] = useState(false);
   const [resetting, setResetting] = useState(null);
@@ -235,21 +243,25 @@ function CollectionsTab({ collections, loadingCollections, onRefresh, showToast
 
   return (
     <section className="knowledge-card">
-      <h2 className="knowledge-card-title">Create collection</h2>
-      <div className="form-row">
-        <input
-          type="text"
-          value={newName}
-          onChange={(e) => setNewName(e.target.value)}
-          onKeyDown={(e) => e.key === 'Enter' && handleCreate()}
-          placeholder="Collection name..."
-          className="flex-1"
-        />
-        <button type="button" className="btn btn-primary" onClick={handleCreate} disabled={creating}>
-          {creating ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-plus" />}
-          <span>{creating ? 'Creating...' : 'Create'}</span>
-        </button>
-      </div>
+      {canCreate && (
+        <>
+          <h2 className="knowledge-card-title">Create collection</h2>
+          <div className="form-row">
+            <input
+              type="text"
+              value={newName}
+              onChange={(e) => setNewName(e.target.value)}
+              onKeyDown={(e) => e.key === 'Enter' && handleCreate()}
+              placeholder="Collection name..."
+              className="flex-1"
+            />
+            <button type="button" className="btn btn-primary" onClick={handleCreate} disabled={creating}>
+              {creating ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-plus" />}
+              <span>{creating ? 'Creating...' : 'Create'}</span>
+            </button>
+          </div>
+        </>
+      )}
       <div className="form-row" style={{ alignItems: 'center', marginBottom: '0.75rem' }}>
         <h2 className="knowledge-card-title" style={{ margin: 0 }}>Your collections</h2>
         <button type="button" className="btn btn-ghost icon-only" onClick={onRefresh} disabled={loadingCollections} title="Refresh">
@@ -266,15 +278,17 @@ function CollectionsTab({ collections, loadingCollections, onRefresh, showToast
             <li key={c} className="knowledge-list-item">
               <i className="fas fa-folder" />
               <span>{c}</span>
-              <button
-                type="button"
-                className="btn btn-ghost danger"
-                onClick={() => handleReset(c)}
-                disabled={resetting === c}
-                title="Reset collection"
-              >
-                {resetting === c ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-redo-alt" />}
-              </button>
+              {canWrite(c) && (
+                <button
+                  type="button"
+                  className="btn btn-ghost danger"
+                  onClick={() => handleReset(c)}
+                  disabled={resetting === c}
+                  title="Reset collection"
+                >
+                  {resetting === c ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-redo-alt" />}
+                </button>
+              )}
             </li>
           ))}
         </ul>
@@ -342,7 +356,7 @@ function UploadTab({ collections, loadingCollections, onRefreshCollections, show
   );
 }
 
-function SourcesTab({ collections, loadingCollections, showToast }) {
+function SourcesTab({ collections, loadingCollections, showToast, canWrite }) {
   const [selectedCollection, setSelectedCollection] = useState('');
   const [url, setUrl] = useState('');
   const [intervalMin, setIntervalMin] = useState(60);
@@ -414,30 +428,34 @@ function SourcesTab({ collections, loadingCollections, showToast }) {
           ))}
         </select>
       </div>
-      <div className="form-row">
-        <div className="form-group flex-1">
-          <label>URL</label>
-          <input
-            type="text"
-            value={url}
-            onChange={(e) => setUrl(e.target.value)}
-            placeholder="https://example.com"
-          />
-        </div>
-        <div className="form-group">
-          <label>Interval (min)</label>
-          <input
-            type="number"
-            min={1}
-            value={intervalMin}
-            onChange={(e) => setIntervalMin(Number(e.target.value) || 60)}
-          />
-        </div>
-      </div>
-      <button type="button" className="btn btn-primary" onClick={handleAdd} disabled={adding}>
-        {adding ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-plus" />}
-        <span>{adding ? 'Adding...' : 'Add source'}</span>
-      </button>
+      {(!selectedCollection || canWrite(selectedCollection)) && (
+        <>
+          <div className="form-row">
+            <div className="form-group flex-1">
+              <label>URL</label>
+              <input
+                type="text"
+                value={url}
+                onChange={(e) => setUrl(e.target.value)}
+                placeholder="https://example.com"
+              />
+            </div>
+            <div className="form-group">
+              <label>Interval (min)</label>
+              <input
+                type="number"
+                min={1}
+                value={intervalMin}
+                onChange={(e) => setIntervalMin(Number(e.target.value) || 60)}
+              />
+            </div>
+          </div>
+          <button type="button" className="btn btn-primary" onClick={handleAdd} disabled={adding}>
+            {adding ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-plus" />}
+            <span>{adding ? 'Adding...' : 'Add source'}</span>
+          </button>
+        </>
+      )}
       <h3 className="knowledge-card-title">Registered sources</h3>
       {loadingSources ? (
         <p className="muted">Loading...</p>
@@ -452,15 +470,17 @@ function SourcesTab({ collections, loadingCollections, showToast }) {
                 <span>{s.url}</span>
                 <span className="muted">Every {s.update_interval ?? 60} min</span>
               </div>
-              <button
-                type="button"
-                className="btn btn-ghost danger"
-                onClick={() => handleRemove(s.url)}
-                disabled={removing === s.url}
-                title="Remove"
-              >
-                {removing === s.url ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-trash" />}
-              </button>
+              {canWrite(selectedCollection) && (
+                <button
+                  type="button"
+                  className="btn btn-ghost danger"
+                  onClick={() => handleRemove(s.url)}
+                  disabled={removing === s.url}
+                  title="Remove"
+                >
+                  {removing === s.url ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-trash" />}
+                </button>
+              )}
             </li>
           ))}
         </ul>
@@ -469,7 +489,7 @@ function SourcesTab({ collections, loadingCollections, showToast }) {
   );
 }
 
-function EntriesTab({ collections, loadingCollections, onRefreshCollections, showToast }) {
+function EntriesTab({ collections, loadingCollections, onRefreshCollections, showToast, canWrite }) {
   const [selectedCollection, setSelectedCollection] = useState('');
   const [entries, setEntries] = useState([]);
   const [loadingEntries, setLoadingEntries] = useState(false);
@@ -552,17 +572,19 @@ function EntriesTab({ collections, loadingCollections, onRefreshCollections, sho
           ))}
         </select>
       </div>
-      <div className="form-row">
-        <button
-          type="button"
-          className="btn btn-ghost danger"
-          onClick={handleReset}
-          disabled={!selectedCollection || resetting === selectedCollection}
-        >
-          {resetting === selectedCollection ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-redo-alt" />}
-          <span>Reset collection</span>
-        </button>
-      </div>
+      {(!selectedCollection || canWrite(selectedCollection)) && (
+        <div className="form-row">
+          <button
+            type="button"
+            className="btn btn-ghost danger"
+            onClick={handleReset}
+            disabled={!selectedCollection || resetting === selectedCollection}
+          >
+            {resetting === selectedCollection ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-redo-alt" />}
+            <span>Reset collection</span>
+          </button>
+        </div>
+      )}
       {loadingEntries ? (
         <p className="muted">Loading entries...</p>
       ) : entries.length === 0 ? (
@@ -583,15 +605,17 @@ function EntriesTab({ collections, loadingCollections, onRefreshCollections, sho
                 >
                   {loadingContent === entry ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-eye" />}
                 </button>
-                <button
-                  type="button"
-                  className="btn btn-ghost danger"
-                  onClick={() => handleDelete(entry)}
-                  disabled={deleting === entry}
-                  title="Delete"
-                >
-                  {deleting === entry ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-trash" />}
-                </button>
+                {canWrite(selectedCollection) && (
+                  <button
+                    type="button"
+                    className="btn btn-ghost danger"
+                    onClick={() => handleDelete(entry)}
+                    disabled={deleting === entry}
+                    title="Delete"
+                  >
+                    {deleting === entry ? <i className="fas fa-spinner fa-spin" /> : <i className="fas fa-trash" />}
+                  </button>
+                )}
               </div>
             </li>
           ))}
@@ -1,6 +1,7 @@
 import { useState, useEffect } from 'react';
 import { Link, useOutletContext } from 'react-router-dom';
 import { skillsApi } from '../utils/api';
+import { usePermissions } from '../hooks/usePermissions';
 
 function Skills() {
   const [skills, setSkills] = useState([]);
@@ -14,6 +15,8 @@ function Skills() {
   const [gitReposLoading, setGitReposLoading] = useState(false);
   const [gitReposAction, setGitReposAction] = useState(null);
   const { showToast } = useOutletContext();
+  // Skills and git repos are shared by every agent, only admins change them
+  const { isAdmin: canEdit } = usePermissions();
 
   const fetchSkills = async () => {
     setLoading(true);
@@ -201,20 +204,24 @@ function Skills() {
             onChange={(e) => setSearchQuery(e.target.value)}
             style={{ width: '220px' }}
           />
-          <Link to="/skills/new" className="action-btn success">
-            <i className="fas fa-plus" /> New skill
-          </Link>
-          <label className="action-btn" style={{ margin: 0, cursor: 'pointer' }}>
-            <input type="file" accept=".tar.gz" onChange={handleImport} disabled={importing} style={{ display: 'none' }} />
-            {importing ? 'Importing...' : <><i className="fas fa-file-import" /> Import</>}
-          </label>
-          <button type="button" className="action-btn" onClick={() => setShowGitRepos((v) => !v)}>
-            <i className="fas fa-code-branch" /> Git Repos
-          </button>
+          {canEdit && (
+            <>
+              <Link to="/skills/new" className="action-btn success">
+                <i className="fas fa-plus" /> New skill
+              </Link>
+              <label className="action-btn" style={{ margin: 0, cursor: 'pointer' }}>
+                <input type="file" accept=".tar.gz" onChange={handleImport} disabled={importing} style={{ display: 'none' }} />
+                {importing ? 'Importing...' : <><i className="fas fa-file-import" /> Import</>}
+              </label>
+              <button type="button" className="action-btn" onClick={() => setShowGitRepos((v) => !v)}>
+                <i className="fas fa-code-branch" /> Git Repos
+              </button>
+            </>
+          )}
         </div>
       </header>
 
-      {showGitRepos && (
+      {canEdit && showGitRepos && (
         <div className="section-box" style={{ marginBottom: '1.5rem' }}>
           <h2 className="section-title" style={{ marginTop: 0 }}>
             <i className="fas fa-code-branch" /> Git repositories
@@ -270,8 +277,10 @@ function Skills() {
         <p>Loading skills...</p>
       ) : skills.length === 0 ? (
         <div className="card">
-          <p>No skills found. Create a skill or import one.</p>
-          <Link to="/skills/new" className="action-btn success" style={{ marginTop: '0.5rem' }}>Create skill</Link>
+          <p>No skills found.{canEdit && ' Create a skill or import one.'}</p>
+          {canEdit && (
+            <Link to="/skills/new" className="action-btn success" style={{ marginTop: '0.5rem' }}>Create skill</Link>
+          )}
         </div>
       ) : (
         <div className="skills-grid" style={{ display: 'grid', gridTemplateColumns: 'repeat(auto-fill, minmax(280px, 1fr))', gap: '1rem' }}>
@@ -285,12 +294,12 @@ function Skills() {
                 {s.description || 'No description'}
               </p>
               <div className="agent-table-actions" style={{ display: 'flex', gap: '0.5rem', flexWrap: 'wrap' }}>
-                {!s.readOnly && (
+                {canEdit && !s.readOnly && (
                   <Link to={`/skills/edit/${encodeURIComponent(s.name)}`} className="action-btn" title="Edit skill">
                     <i className="fas fa-edit" /> Edit
                   </Link>
                 )}
-                {!s.readOnly && (
+                {canEdit && !s.readOnly && (
                   <button type="button" className="action-btn delete-btn" onClick={() => deleteSkill(s.name)} title="Delete skill">
                     <i className="fas fa-trash" /> Delete
                   </button>
@@ -8,6 +8,7 @@ import Chat from './pages/Chat';
 import ActionsPlayground from './pages/ActionsPlayground';
 import GroupCreate from './pages/GroupCreate';
 import AgentStatus from './pages/AgentStatus';
+import AgentHistory from './pages/AgentHistory';
 import ImportAgent from './pages/ImportAgent';
 import Skills from './pages/Skills';
 import SkillEdit from './pages/SkillEdit';
@@ -58,6 +59,10 @@ export const router = createBrowserRouter([
         path: 'status/:name',
         element: <AgentStatus />
       },
+      {
+        path: 'history/:name',
+        element: <AgentHistory />
+      },
       {
         path: 'skills',
         element: <Skills />
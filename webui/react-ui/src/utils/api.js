@@ -304,6 +304,35 @@ export const statusApi = {
   },
 };
 
+// Observable history API calls
+export const historyApi = {
+  // Search the stored observables, newest first. filters holds from, to
+  // (ISO dates), status, action, conversation_id and limit; empty ones are skipped.
+  search: async (name, filters = {}) => {
+    const params = new URLSearchParams();
+    Object.entries(filters).forEach(([key, value]) => {
+      if (value !== undefined && value !== null && value !== '') {
+        params.append(key, value);
+      }
+    });
+    const query = params.toString();
+    const endpoint = API_CONFIG.endpoints.observablesSearch(name);
+    const response = await fetch(buildUrl(query ? `${endpoint}?${query}` : endpoint), {
+      headers: API_CONFIG.headers
+    });
+    const data = await handleResponse(response);
+    return data.History || [];
+  },
+  // Get the timeline of the job an observable belongs to, in order
+  replay: async (name, id) => {
+    const response = await fetch(buildUrl(API_CONFIG.endpoints.observableReplay(name, id)), {
+      headers: API_CONFIG.headers
+    });
+    const data = await handleResponse(response);
+    return data.Timeline || [];
+  },
+};
+
 // Skills API (skills are stored under state dir / skills, not configurable)
 export const skillsApi = {
   getConfig: async () => {
@@ -522,3 +551,60 @@ export const collectionsApi = {
     return handleCollectionsResponse(response);
   },
 };
+
+// Users and permissions API
+export const userApi = {
+  // Get the current user and its roles
+  getMe: async () => {
+    const response = await fetch(buildUrl(API_CONFIG.endpoints.me), {
+      headers: API_CONFIG.headers
+    });
+    return handleResponse(response);
+  },
+  list: async () => {
+    const response = await fetch(buildUrl(API_CONFIG.endpoints.users), {
+      headers: API_CONFIG.headers
+    });
+    const data = await handleResponse(response);
+    return data.Users || [];
+  },
+  create: async (user) => {
+    const response = await fetch(buildUrl(API_CONFIG.endpoints.users), {
+      method: 'POST',
+      headers: API_CONFIG.headers,
+      body: JSON.stringify(user),
+    });
+    return handleResponse(response);
+  },
+  update: async (name, user) => {
+    const response = await fetch(buildUrl(API_CONFIG.endpoints.user(name)), {
+      method: 'PUT',
+      headers: API_CONFIG.headers,
+      body: JSON.stringify(user),
+    });
+    return handleResponse(response);
+  },
+  delete: async (name) => {
+    const response = await fetch(buildUrl(API_CONFIG.endpoints.user(name)), {
+      method: 'DELETE',
+      headers: API_CONFIG.headers,
+    });
+    return handleResponse(response);
+  },
+  // The key is only returned here
+  createKey: async (name, keyName = '') => {
+    const response = await fetch(buildUrl(API_CONFIG.endpoints.userKeys(name)), {
+      method: 'POST',
+      headers: API_CONFIG.headers,
+      body: JSON.stringify({ name: keyName }),
+    });
+    return handleResponse(response);
+  },
+  deleteKey: async (name, id) => {
+    const response = await fetch(buildUrl(API_CONFIG.endpoints.userKey(name, id)), {
+      method: 'DELETE',
+      headers: API_CONFIG.headers,
+    });
+    return handleResponse(response);
+  },
+};
@@ -49,6 +49,10 @@ export const API_CONFIG = {
     // Status endpoint
     status: (name) => `/status/${name}`,
 
+    // Observable history endpoints
+    observablesSearch: (name) => `/api/agent/${name}/observables/search`,
+    observableReplay: (name, id) => `/api/agent/${name}/observables/${id}/replay`,
+
     // Skills endpoints
     skillsConfig: '/api/skills/config',
     skillsList: '/api/skills',
@@ -72,5 +76,12 @@ export const API_CONFIG = {
     collectionReset: (name) => `/api/collections/${encodeURIComponent(name)}/reset`,
     collectionDeleteEntry: (name) => `/api/collections/${encodeURIComponent(name)}/entry/delete`,
     collectionSources: (name) => `/api/collections/${encodeURIComponent(name)}/sources`,
+
+    // Users and permissions
+    me: '/api/me',
+    users: '/api/users',
+    user: (name) => `/api/users/${encodeURIComponent(name)}`,
+    userKeys: (name) => `/api/users/${encodeURIComponent(name)}/keys`,
+    userKey: (name, id) => `/api/users/${encodeURIComponent(name)}/keys/${encodeURIComponent(id)}`,
   }
 };
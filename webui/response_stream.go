@@ -0,0 +1,312 @@
+package webui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mudler/cogito"
+
+	"github.com/mudler/LocalAGI/webui/types"
+)
+
+// responseStream turns the stream events of a job into the server-sent
+// events of the Responses API. Reasoning is sent as a reasoning item, the
+// actions the agent runs as mcp_call items and the answer as a message
+// item, each opened with response.output_item.added and closed with
+// response.output_item.done.
+type responseStream struct {
+	send      func(types.ResponseStreamEvent) error
+	agentName string
+	msgID     string
+
+	// output holds the items sent so far, by output index
+	output []interface{}
+
+	reasoningIndex int
+	reasoning      strings.Builder
+
+	messageIndex int
+	message      strings.Builder
+
+	// calls are the output indexes of the actions, by tool call ID
+	calls map[string]int
+}
+
+func newResponseStream(agentName, msgID string, send func(types.ResponseStreamEvent) error) *responseStream {
+	return &responseStream{
+		send:           send,
+		agentName:      agentName,
+		msgID:          msgID,
+		reasoningIndex: -1,
+		messageIndex:   -1,
+		calls:          map[string]int{},
+	}
+}
+
+func newItemID(prefix string) string {
+	return fmt.Sprintf("%s_%d", prefix, time.Now().UnixNano())
+}
+
+// add sends a new item of the output and returns its index
+func (s *responseStream) add(item interface{}) (int, error) {
+	s.output = append(s.output, item)
+	index := len(s.output) - 1
+	return index, s.send(types.ResponseStreamEvent{
+		Type:        "response.output_item.added",
+		OutputIndex: index,
+		Item:        item,
+	})
+}
+
+// event sends the events a stream event of the job maps to
+func (s *responseStream) event(ev cogito.StreamEvent) error {
+	switch ev.Type {
+	case cogito.StreamEventReasoning:
+		if ev.Content == "" {
+			return nil
+		}
+		return s.reasoningDelta(ev.Content)
+	case cogito.StreamEventContent:
+		if ev.Content == "" {
+			return nil
+		}
+		return s.textDelta(ev.Content)
+	case cogito.StreamEventToolCall:
+		return s.toolCall(ev)
+	case cogito.StreamEventToolResult:
+		return s.toolResult(ev)
+	}
+	return nil
+}
+
+func (s *responseStream) reasoningDelta(delta string) error {
+	if s.reasoningIndex < 0 {
+		index, err := s.add(types.ReasoningItem{
+			Type:    "reasoning",
+			ID:      newItemID("rs"),
+			Status:  "in_progress",
+			Summary: []types.MessageContentItem{},
+			Content: []types.MessageContentItem{},
+		})
+		s.reasoningIndex = index
+		if err != nil {
+			return err
+		}
+	}
+	s.reasoning.WriteString(delta)
+	return s.send(types.ResponseStreamEvent{
+		Type:        "response.reasoning_text.delta",
+		ItemID:      s.output[s.reasoningIndex].(types.ReasoningItem).ID,
+		OutputIndex: s.reasoningIndex,
+		Delta:       delta,
+	})
+}
+
+// openMessage sends the message item of the answer and its text part
+func (s *responseStream) openMessage(id string) (int, error) {
+	index, err := s.add(types.ResponseMessage{
+		Type:    "message",
+		ID:      id,
+		Status:  "in_progress",
+		Role:    "assistant",
+		Content: []types.MessageContentItem{},
+	})
+	if err != nil {
+		return index, err
+	}
+	return index, s.send(types.ResponseStreamEvent{
+		Type:        "response.content_part.added",
+		ItemID:      id,
+		OutputIndex: index,
+		Part:        &types.MessageContentItem{Type: "output_text", Annotations: []interface{}{}},
+	})
+}
+
+func (s *responseStream) textDelta(delta string) error {
+	if s.messageIndex < 0 {
+		index, err := s.openMessage(s.msgID)
+		s.messageIndex = index
+		if err != nil {
+			return err
+		}
+	}
+	s.message.WriteString(delta)
+	return s.send(types.ResponseStreamEvent{
+		Type:        "response.output_text.delta",
+		ItemID:      s.msgID,
+		OutputIndex: s.messageIndex,
+		Delta:       delta,
+	})
+}
+
+// closeMessage sends the final text of a message item
+func (s *responseStream) closeMessage(index int, id, text string) error {
+	part := types.MessageContentItem{Type: "output_text", Text: text, Annotations: []interface{}{}}
+	item := types.ResponseMessage{
+		Type:    "message",
+		ID:      id,
+		Status:  "completed",
+		Role:    "assistant",
+		Content: []types.MessageContentItem{part},
+	}
+	s.output[index] = item
+
+	events := []types.ResponseStreamEvent{
+		{Type: "response.output_text.done", ItemID: id, OutputIndex: index, Text: &text},
+		{Type: "response.content_part.done", ItemID: id, OutputIndex: index, Part: &part},
+		{Type: "response.output_item.done", OutputIndex: index, Item: item},
+	}
+	for _, event := range events {
+		if err := s.send(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *responseStream) toolCall(ev cogito.StreamEvent) error {
+	if ev.ToolName == "" {
+		return nil
+	}
+	id := newItemID("mcp")
+	index, err := s.add(types.MCPCall{
+		Type:        "mcp_call",
+		ID:          id,
+		Status:      "in_progress",
+		ServerLabel: s.agentName,
+		Name:        ev.ToolName,
+	})
+	if err != nil {
+		return err
+	}
+	if ev.ToolCallID != "" {
+		s.calls[ev.ToolCallID] = index
+	}
+
+	call := s.output[index].(types.MCPCall)
+	call.Arguments = ev.ToolArgs
+	s.output[index] = call
+
+	events := []types.ResponseStreamEvent{
+		{Type: "response.mcp_call_arguments.delta", ItemID: id, OutputIndex: index, Delta: ev.ToolArgs},
+		{Type: "response.mcp_call_arguments.done", ItemID: id, OutputIndex: index, Arguments: &call.Arguments},
+		{Type: "response.mcp_call.in_progress", ItemID: id, OutputIndex: index},
+	}
+	for _, event := range events {
+		if err := s.send(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *responseStream) toolResult(ev cogito.StreamEvent) error {
+	index, ok := s.calls[ev.ToolCallID]
+	if !ok {
+		return nil
+	}
+	delete(s.calls, ev.ToolCallID)
+
+	call := s.output[index].(types.MCPCall)
+	result := ev.ToolResult
+	call.Output = &result
+	call.Status = "completed"
+	s.output[index] = call
+
+	if err := s.send(types.ResponseStreamEvent{Type: "response.mcp_call.completed", ItemID: call.ID, OutputIndex: index}); err != nil {
+		return err
+	}
+	return s.send(types.ResponseStreamEvent{Type: "response.output_item.done", OutputIndex: index, Item: call})
+}
+
+// finish closes the items still open and sends the items of the final
+// response that were not streamed, such as the calls of the client tools.
+// It returns the whole output, in the order it was sent.
+func (s *responseStream) finish(final []interface{}) ([]interface{}, error) {
+	if s.reasoningIndex >= 0 {
+		text := s.reasoning.String()
+		item := s.output[s.reasoningIndex].(types.ReasoningItem)
+		item.Status = "completed"
+		item.Content = []types.MessageContentItem{{Type: "reasoning_text", Text: text}}
+		s.output[s.reasoningIndex] = item
+		if err := s.send(types.ResponseStreamEvent{Type: "response.reasoning_text.done", ItemID: item.ID, OutputIndex: s.reasoningIndex, Text: &text}); err != nil {
+			return nil, err
+		}
+		if err := s.send(types.ResponseStreamEvent{Type: "response.output_item.done", OutputIndex: s.reasoningIndex, Item: item}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Actions that never returned were stopped, e.g. denied by an approval
+	for _, index := range s.calls {
+		call := s.output[index].(types.MCPCall)
+		call.Status = "incomplete"
+		s.output[index] = call
+		if err := s.send(types.ResponseStreamEvent{Type: "response.mcp_call.failed", ItemID: call.ID, OutputIndex: index}); err != nil {
+			return nil, err
+		}
+		if err := s.send(types.ResponseStreamEvent{Type: "response.output_item.done", OutputIndex: index, Item: call}); err != nil {
+			return nil, err
+		}
+	}
+	s.calls = map[string]int{}
+
+	messageClosed := false
+	for _, item := range final {
+		switch output := item.(type) {
+		case types.ResponseMessage:
+			text := ""
+			if len(output.Content) > 0 {
+				text = output.Content[0].Text
+			}
+			if output.ID == s.msgID && s.messageIndex >= 0 {
+				// The answer streamed so far is replaced by the final one
+				if err := s.closeMessage(s.messageIndex, output.ID, text); err != nil {
+					return nil, err
+				}
+				messageClosed = true
+				continue
+			}
+			// Backends that do not stream still get the text delivered as a single delta
+			index, err := s.openMessage(output.ID)
+			if err != nil {
+				return nil, err
+			}
+			if text != "" {
+				if err := s.send(types.ResponseStreamEvent{Type: "response.output_text.delta", ItemID: output.ID, OutputIndex: index, Delta: text}); err != nil {
+					return nil, err
+				}
+			}
+			if err := s.closeMessage(index, output.ID, text); err != nil {
+				return nil, err
+			}
+		case types.FunctionToolCall:
+			output.Status = "in_progress"
+			index, err := s.add(output)
+			if err != nil {
+				return nil, err
+			}
+			output.Status = "completed"
+			s.output[index] = output
+			events := []types.ResponseStreamEvent{
+				{Type: "response.function_call_arguments.delta", ItemID: output.ID, OutputIndex: index, Delta: output.Arguments},
+				{Type: "response.function_call_arguments.done", ItemID: output.ID, OutputIndex: index, Arguments: &output.Arguments},
+				{Type: "response.output_item.done", OutputIndex: index, Item: output},
+			}
+			for _, event := range events {
+				if err := s.send(event); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Text streamed before the agent handed a tool call to the client
+	if s.messageIndex >= 0 && !messageClosed {
+		if err := s.closeMessage(s.messageIndex, s.msgID, s.message.String()); err != nil {
+			return nil, err
+		}
+	}
+	return s.output, nil
+}
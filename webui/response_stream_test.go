@@ -0,0 +1,114 @@
+package webui
+
+import (
+	"github.com/mudler/cogito"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/mudler/LocalAGI/webui/types"
+)
+
+var _ = Describe("responseStream", func() {
+	var (
+		events []types.ResponseStreamEvent
+		stream *responseStream
+	)
+
+	eventTypes := func() []string {
+		var names []string
+		for _, e := range events {
+			names = append(names, e.Type)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		events = nil
+		stream = newResponseStream("support", "msg_1", func(e types.ResponseStreamEvent) error {
+			events = append(events, e)
+			return nil
+		})
+	})
+
+	It("sends reasoning, actions and the answer as output items", func() {
+		Expect(stream.event(cogito.StreamEvent{Type: cogito.StreamEventReasoning, Content: "Looking up"})).To(Succeed())
+		Expect(stream.event(cogito.StreamEvent{Type: cogito.StreamEventToolCall, ToolName: "search", ToolArgs: `{"query":"x"}`, ToolCallID: "call_1"})).To(Succeed())
+		Expect(stream.event(cogito.StreamEvent{Type: cogito.StreamEventToolResult, ToolName: "search", ToolCallID: "call_1", ToolResult: "found"})).To(Succeed())
+		Expect(stream.event(cogito.StreamEvent{Type: cogito.StreamEventContent, Content: "Hel"})).To(Succeed())
+		Expect(stream.event(cogito.StreamEvent{Type: cogito.StreamEventContent, Content: "lo"})).To(Succeed())
+
+		output, err := stream.finish([]interface{}{
+			types.ResponseMessage{Type: "message", ID: "msg_1", Content: []types.MessageContentItem{{Type: "output_text", Text: "Hello"}}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(eventTypes()).To(Equal([]string{
+			"response.output_item.added",
+			"response.reasoning_text.delta",
+			"response.output_item.added",
+			"response.mcp_call_arguments.delta",
+			"response.mcp_call_arguments.done",
+			"response.mcp_call.in_progress",
+			"response.mcp_call.completed",
+			"response.output_item.done",
+			"response.output_item.added",
+			"response.content_part.added",
+			"response.output_text.delta",
+			"response.output_text.delta",
+			"response.reasoning_text.done",
+			"response.output_item.done",
+			"response.output_text.done",
+			"response.content_part.done",
+			"response.output_item.done",
+		}))
+
+		Expect(output).To(HaveLen(3))
+		Expect(output[0].(types.ReasoningItem).Content[0].Text).To(Equal("Looking up"))
+		call := output[1].(types.MCPCall)
+		Expect(call.Name).To(Equal("search"))
+		Expect(call.ServerLabel).To(Equal("support"))
+		Expect(call.Status).To(Equal("completed"))
+		Expect(*call.Output).To(Equal("found"))
+		message := output[2].(types.ResponseMessage)
+		Expect(message.Status).To(Equal("completed"))
+		Expect(message.Content[0].Text).To(Equal("Hello"))
+
+		done := events[len(events)-3]
+		Expect(*done.Text).To(Equal("Hello"))
+		Expect(done.OutputIndex).To(Equal(2))
+	})
+
+	It("delivers the answer of backends that do not stream", func() {
+		output, err := stream.finish([]interface{}{
+			types.ResponseMessage{Type: "message", ID: "msg_1", Content: []types.MessageContentItem{{Type: "output_text", Text: "Hello"}}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(eventTypes()).To(Equal([]string{
+			"response.output_item.added",
+			"response.content_part.added",
+			"response.output_text.delta",
+			"response.output_text.done",
+			"response.content_part.done",
+			"response.output_item.done",
+		}))
+		Expect(events[2].Delta).To(Equal("Hello"))
+		Expect(output).To(HaveLen(1))
+	})
+
+	It("sends the calls of client tools and closes the actions that did not run", func() {
+		Expect(stream.event(cogito.StreamEvent{Type: cogito.StreamEventToolCall, ToolName: "shell", ToolArgs: "{}", ToolCallID: "call_1"})).To(Succeed())
+
+		output, err := stream.finish([]interface{}{
+			types.FunctionToolCall{Type: "function_call", ID: "tool_1", CallID: "call_2", Name: "get_weather", Arguments: `{"city":"Rome"}`},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(HaveLen(2))
+		Expect(output[0].(types.MCPCall).Status).To(Equal("incomplete"))
+		Expect(output[1].(types.FunctionToolCall).Status).To(Equal("completed"))
+		Expect(eventTypes()).To(ContainElements(
+			"response.mcp_call.failed",
+			"response.function_call_arguments.delta",
+			"response.function_call_arguments.done",
+		))
+	})
+})
@@ -0,0 +1,127 @@
+package webui_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/webui"
+	"github.com/mudler/LocalAGI/webui/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/sashabaranov/go-openai"
+)
+
+var _ = Describe("Responses", func() {
+	var (
+		mu       sync.Mutex
+		arrivals int
+		holding  bool
+		release  chan struct{}
+		pool     *state.AgentPool
+		app      *webui.App
+		key      string
+	)
+
+	BeforeEach(func() {
+		arrivals = 0
+		holding = false
+		release = make(chan struct{})
+		// While holding, the LLM answers once released, so that the requests
+		// overlap
+		llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hold := holding
+			if hold {
+				arrivals++
+			}
+			mu.Unlock()
+			if hold {
+				select {
+				case <-release:
+				case <-r.Context().Done():
+					return
+				}
+			}
+			json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{
+					Message:      openai.ChatCompletionMessage{Role: "assistant", Content: "done"},
+					FinishReason: openai.FinishReasonStop,
+				}},
+			})
+		}))
+		DeferCleanup(llm.Close)
+
+		dir := GinkgoT().TempDir()
+		pool = newTestPoolWithLLM(llm.URL)
+		Expect(pool.CreateAgent("responses", &state.AgentConfig{Name: "responses"}, "")).To(Succeed())
+
+		users, err := auth.NewJSONStore(filepath.Join(dir, "users.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(users.Create(auth.User{Name: "operator", Role: auth.RoleOperator})).To(Succeed())
+		key, _, err = users.CreateAPIKey("operator", "test")
+		Expect(err).NotTo(HaveOccurred())
+
+		app = webui.NewApp(
+			webui.WithPool(pool),
+			webui.WithUsers(users),
+			webui.WithStateDir(dir),
+			webui.WithLocalRAGURL("http://127.0.0.1:0"),
+		)
+	})
+
+	respond := func(previousResponseID string) (int, types.ResponseBody) {
+		body := `{"model": "responses", "input": "hello"}`
+		if previousResponseID != "" {
+			body = fmt.Sprintf(`{"model": "responses", "input": "hello", "previous_response_id": %q}`, previousResponseID)
+		}
+		req := httptest.NewRequest("POST", "/v1/responses", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := app.Test(req, -1)
+		Expect(err).NotTo(HaveOccurred())
+		var response types.ResponseBody
+		Expect(json.NewDecoder(resp.Body).Decode(&response)).To(Succeed())
+		return resp.StatusCode, response
+	}
+
+	It("completes the responses requested at once in a thread", func() {
+		status, root := respond("")
+		Expect(status).To(Equal(http.StatusOK))
+		mu.Lock()
+		holding = true
+		mu.Unlock()
+
+		var wg sync.WaitGroup
+		statuses := make([]int, 2)
+		responses := make([]types.ResponseBody, 2)
+		ask := func(i int) {
+			defer GinkgoRecover()
+			defer wg.Done()
+			statuses[i], responses[i] = respond(root.ID)
+		}
+		wg.Add(2)
+		go ask(0)
+		Eventually(func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return arrivals
+		}).Should(Equal(1))
+		go ask(1)
+		Eventually(pool.GetAgent("responses").QueueDepth).Should(Equal(1))
+		close(release)
+		wg.Wait()
+
+		for i := range responses {
+			Expect(statuses[i]).To(Equal(http.StatusOK))
+			Expect(responses[i].Status).To(Equal("completed"))
+			Expect(responses[i].Output).To(HaveLen(1))
+		}
+	})
+})
@@ -7,11 +7,13 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"path/filepath"
 
 	"github.com/dave-gray101/v2keyauth"
 	fiber "github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/gofiber/fiber/v2/middleware/keyauth"
+	"github.com/mudler/LocalAGI/core/auth"
 	"github.com/mudler/LocalAGI/core/conversations"
 	"github.com/mudler/LocalAGI/core/sse"
 
@@ -27,13 +29,28 @@ var reactUI embed.FS
 
 func (app *App) registerRoutes(pool *state.AgentPool, webapp *fiber.App) {
 
-	if len(app.config.ApiKeys) > 0 {
-		kaConfig, err := GetKeyAuthConfig(app.config.ApiKeys)
-		if err != nil || kaConfig == nil {
-			panic(err)
-		}
-		webapp.Use(v2keyauth.New(*kaConfig))
+	// The login with the identity provider happens before having a session
+	if app.config.OIDC != nil {
+		webapp.Get("/auth/login", app.OIDCLogin())
+		webapp.Get("/auth/callback", app.OIDCCallback())
+		webapp.Get("/auth/logout", app.OIDCLogout())
+	}
+
+	// Installed even without API keys, since users can be added while the
+	// server runs
+	kaConfig, err := app.authConfig()
+	if err != nil || kaConfig == nil {
+		panic(err)
 	}
+	webapp.Use(v2keyauth.New(*kaConfig))
+
+	// Permission checks, on the agent of the :name parameter or on every
+	// agent
+	agentViewer := requireAgentRole(auth.RoleViewer)
+	agentOperator := requireAgentRole(auth.RoleOperator)
+	agentAdmin := requireAgentRole(auth.RoleAdmin)
+	viewer := requireRole(auth.RoleViewer)
+	admin := requireRole(auth.RoleAdmin)
 
 	webapp.Get("/", func(c *fiber.Ctx) error {
 		return c.Redirect("/app")
@@ -55,7 +72,7 @@ func (app *App) registerRoutes(pool *state.AgentPool, webapp *fiber.App) {
 	})
 
 	// Define a route for the GET method on the root path '/'
-	webapp.Get("/sse/:name", func(c *fiber.Ctx) error {
+	webapp.Get("/sse/:name", agentViewer, func(c *fiber.Ctx) error {
 		m := pool.GetManager(c.Params("name"))
 		if m == nil {
 			return c.SendStatus(404)
@@ -65,26 +82,51 @@ func (app *App) registerRoutes(pool *state.AgentPool, webapp *fiber.App) {
 		return nil
 	})
 
-	webapp.Get("/api/notify/:name", app.Notify(pool))
+	webapp.Get("/api/notify/:name", agentOperator, app.rateLimit, app.Notify(pool))
 
-	webapp.Post("/api/agent/create", app.Create(pool))
-	webapp.Delete("/api/agent/:name", app.Delete(pool))
-	webapp.Put("/api/agent/:name/pause", app.Pause(pool))
-	webapp.Put("/api/agent/:name/start", app.Start(pool))
+	webapp.Post("/api/agent/create", admin, app.Create(pool))
+	webapp.Delete("/api/agent/:name", agentAdmin, app.Delete(pool))
+	webapp.Put("/api/agent/:name/pause", agentOperator, app.Pause(pool))
+	webapp.Put("/api/agent/:name/start", agentOperator, app.Start(pool))
 
-	webapp.Post("/api/chat/:name", app.Chat(pool))
+	webapp.Post("/api/chat/:name", agentOperator, app.rateLimit, app.Chat(pool))
 
 	webapp.Get("/login", func(c *fiber.Ctx) error {
 		return c.Status(401).Redirect("/app") // After login, just redirect to index
 	})
 
-	conversationTracker := conversations.NewConversationTracker[string](app.config.ConversationStoreDuration)
+	trackerOpts := []conversations.TrackerOption[string]{
+		conversations.WithRetention[string](conversations.RetentionPolicy{
+			MaxMessages:      app.config.ConversationMaxMessages,
+			MaxConversations: app.config.ConversationMaxCount,
+		}),
+	}
+	if app.config.StateDir != "" {
+		store, err := conversations.NewJSONFileStore[string](filepath.Join(app.config.StateDir, "responses-conversations.json"))
+		if err != nil {
+			xlog.Error("Failed to open conversation store, conversations will not be persisted", "error", err)
+		} else {
+			trackerOpts = append(trackerOpts, conversations.WithStore[string](store))
+		}
+	}
+	conversationTracker := conversations.NewConversationTracker[string](app.config.ConversationStoreDuration, trackerOpts...)
 
+	// The agent is the model of the request, so its permissions are checked
+	// by the handler
 	webapp.Post("/v1/responses", app.Responses(pool, conversationTracker))
 
 	// New API endpoints for getting and updating agent configuration
-	webapp.Get("/api/agent/:name/config", app.GetAgentConfig(pool))
-	webapp.Put("/api/agent/:name/config", app.UpdateAgentConfig(pool))
+	webapp.Get("/api/agent/:name/config", agentViewer, app.GetAgentConfig(pool))
+	webapp.Put("/api/agent/:name/config", agentAdmin, app.UpdateAgentConfig(pool))
+	webapp.Get("/api/agent/:name/config/versions", agentViewer, app.ListConfigVersions(pool))
+	webapp.Get("/api/agent/:name/config/versions/diff", agentViewer, app.DiffConfigVersions(pool))
+	webapp.Get("/api/agent/:name/config/versions/:version", agentViewer, app.GetConfigVersion(pool))
+	webapp.Post("/api/agent/:name/config/versions/:version/rollback", agentAdmin, app.RollbackConfigVersion(pool))
+
+	// Encrypted secrets, referred to as secret://<name> in agent configurations
+	webapp.Get("/api/secrets", admin, app.ListSecrets(pool))
+	webapp.Put("/api/secrets/:name", admin, app.SetSecret(pool))
+	webapp.Delete("/api/secrets/:name", admin, app.DeleteSecret(pool))
 
 	// Metadata endpoint for agent configuration fields
 	webapp.Get("/api/agent/config/metadata", app.GetAgentConfigMeta(app.config.CustomActionsDir))
@@ -92,17 +134,49 @@ func (app *App) registerRoutes(pool *state.AgentPool, webapp *fiber.App) {
 	// Add endpoint for getting agent config metadata
 	webapp.Get("/api/meta/agent/config", app.GetAgentConfigMeta(app.config.CustomActionsDir))
 
-	webapp.Post("/api/action/:name/definition", app.GetActionDefinition(pool))
-	webapp.Post("/api/action/:name/run", app.ExecuteAction(pool))
+	webapp.Post("/api/action/:name/definition", viewer, app.GetActionDefinition(pool))
+	webapp.Post("/api/action/:name/run", admin, app.ExecuteAction(pool))
 	webapp.Get("/api/actions", app.ListActions())
 
-	webapp.Post("/api/agent/group/generateProfiles", app.GenerateGroupProfiles(pool))
-	webapp.Post("/api/agent/group/create", app.CreateGroup(pool))
+	// Human-in-the-loop approvals for actions that require them
+	// Approvals are filtered and checked by agent in the handlers
+	webapp.Get("/api/approvals", app.ListApprovals(pool))
+	webapp.Get("/api/approvals/:id", app.GetApproval(pool))
+	webapp.Post("/api/approvals/:id", app.ResolveApproval(pool))
+	webapp.Get("/api/agent/:name/approvals", agentViewer, app.ListApprovals(pool))
+
+	// Scheduled tasks
+	webapp.Get("/api/agent/:name/tasks", agentViewer, app.ListTasks(pool))
+	webapp.Post("/api/agent/:name/tasks", agentOperator, app.CreateTask(pool))
+	webapp.Get("/api/agent/:name/tasks/:id", agentViewer, app.GetTask(pool))
+	webapp.Put("/api/agent/:name/tasks/:id", agentOperator, app.UpdateTask(pool))
+	webapp.Delete("/api/agent/:name/tasks/:id", agentOperator, app.DeleteTask(pool))
+	webapp.Get("/api/agent/:name/tasks/:id/runs", agentViewer, app.GetTaskRuns(pool))
+	webapp.Get("/api/agent/:name/tasks/:id/chain", agentViewer, app.GetTaskChain(pool))
+	webapp.Post("/api/agent/:name/tasks/:id/run", agentOperator, app.RunTask(pool))
+	webapp.Post("/api/agent/:name/tasks/:id/cancel", agentOperator, app.CancelTask(pool))
+	webapp.Put("/api/agent/:name/tasks/:id/pause", agentOperator, app.PauseTask(pool))
+	webapp.Put("/api/agent/:name/tasks/:id/resume", agentOperator, app.ResumeTask(pool))
+
+	// Token usage and cost accounting
+	webapp.Get("/api/agent/:name/usage", agentViewer, app.GetUsage(pool))
+
+	// Prometheus metrics
+	webapp.Get("/metrics", viewer, app.Metrics(pool))
+
+	webapp.Post("/api/agent/group/generateProfiles", admin, app.GenerateGroupProfiles(pool))
+	webapp.Post("/api/agent/group/create", admin, app.CreateGroup(pool))
 
 	// Dashboard API endpoint for React UI
 	webapp.Get("/api/agents", func(c *fiber.Ctx) error {
 		statuses := map[string]bool{}
-		agents := pool.List()
+		user := currentUser(c)
+		agents := []string{}
+		for _, a := range pool.List() {
+			if user.Can(a, auth.RoleViewer) {
+				agents = append(agents, a)
+			}
+		}
 		for _, a := range agents {
 			agent := pool.GetAgent(a)
 			if agent == nil {
@@ -122,7 +196,7 @@ func (app *App) registerRoutes(pool *state.AgentPool, webapp *fiber.App) {
 	})
 
 	// API endpoint for getting a specific agent's details
-	webapp.Get("/api/agent/:name", func(c *fiber.Ctx) error {
+	webapp.Get("/api/agent/:name", agentViewer, func(c *fiber.Ctx) error {
 		name := c.Params("name")
 		agent := pool.GetAgent(name)
 		if agent == nil {
@@ -138,7 +212,7 @@ func (app *App) registerRoutes(pool *state.AgentPool, webapp *fiber.App) {
 	})
 
 	// API endpoint for agent status history
-	webapp.Get("/api/agent/:name/status", func(c *fiber.Ctx) error {
+	webapp.Get("/api/agent/:name/status", agentViewer, func(c *fiber.Ctx) error {
 		history := pool.GetStatusHistory(c.Params("name"))
 		if history == nil {
 			history = &state.Status{ActionResults: []types.ActionState{}}
@@ -163,7 +237,7 @@ func (app *App) registerRoutes(pool *state.AgentPool, webapp *fiber.App) {
 	})
 
 	// API endpoint to retrieve agent observables
-	webapp.Get("/api/agent/:name/observables", func(c *fiber.Ctx) error {
+	webapp.Get("/api/agent/:name/observables", agentViewer, func(c *fiber.Ctx) error {
 		name := c.Params("name")
 		agent := pool.GetAgent(name)
 		if agent == nil {
@@ -179,7 +253,7 @@ func (app *App) registerRoutes(pool *state.AgentPool, webapp *fiber.App) {
 	})
 
 	// API endpoint to clear agent observables
-	webapp.Delete("/api/agent/:name/observables", func(c *fiber.Ctx) error {
+	webapp.Delete("/api/agent/:name/observables", agentOperator, func(c *fiber.Ctx) error {
 		name := c.Params("name")
 		agent := pool.GetAgent(name)
 		if agent == nil {
@@ -189,8 +263,30 @@ func (app *App) registerRoutes(pool *state.AgentPool, webapp *fiber.App) {
 		return c.JSON(fiber.Map{"Name": name, "cleared": true})
 	})
 
-	webapp.Post("/settings/import", app.ImportAgent(pool))
-	webapp.Get("/settings/export/:name", app.ExportAgent(pool))
+	// Persisted observables: search and job timeline replay
+	webapp.Get("/api/agent/:name/observables/search", agentViewer, app.SearchObservables(pool))
+	webapp.Get("/api/agent/:name/observables/:id/replay", agentViewer, app.ReplayObservable(pool))
+
+	webapp.Post("/settings/import", admin, app.ImportAgent(pool))
+	webapp.Get("/settings/export/:name", agentViewer, app.ExportAgent(pool))
+
+	// Skills and git repositories are shared by the agents: everyone reads
+	// them, admins change them
+	webapp.Use("/api/skills", requireWriteRole(auth.RoleAdmin))
+	webapp.Use("/api/git-repos", requireWriteRole(auth.RoleAdmin))
+
+	// Users, their API keys and their roles
+	webapp.Get("/api/me", app.GetCurrentUser())
+	webapp.Get("/api/users", admin, app.ListUsers())
+	webapp.Post("/api/users", admin, app.CreateUser())
+	webapp.Put("/api/users/:user", admin, app.UpdateUser())
+	webapp.Delete("/api/users/:user", admin, app.DeleteUser())
+	webapp.Post("/api/users/:user/keys", admin, app.CreateUserAPIKey())
+	webapp.Delete("/api/users/:user/keys/:id", admin, app.DeleteUserAPIKey())
+
+	// Audit log of the changes to agents and of the actions run
+	webapp.Get("/api/audit", admin, app.QueryAuditLog(pool))
+	webapp.Get("/api/audit/export", admin, app.ExportAuditLog(pool))
 
 	// Skills API (when app.config.SkillsService is set)
 	webapp.Get("/api/skills/config", app.GetSkillsConfig)
@@ -259,15 +355,15 @@ func GetKeyAuthConfig(apiKeys []string) (*v2keyauth.Config, error) {
 		CustomKeyLookup: customLookup,
 		Next:            func(c *fiber.Ctx) bool { return false },
 		Validator:       getApiKeyValidationFunction(apiKeys),
-		ErrorHandler:    getApiKeyErrorHandler(false, apiKeys),
+		ErrorHandler:    getApiKeyErrorHandler(false, func() bool { return len(apiKeys) > 0 }, false),
 		AuthScheme:      "Bearer",
 	}, nil
 }
 
-func getApiKeyErrorHandler(opaqueErrors bool, apiKeys []string) fiber.ErrorHandler {
+func getApiKeyErrorHandler(opaqueErrors bool, enabled func() bool, sso bool) fiber.ErrorHandler {
 	return func(ctx *fiber.Ctx, err error) error {
 		if errors.Is(err, v2keyauth.ErrMissingOrMalformedAPIKey) {
-			if len(apiKeys) == 0 {
+			if !enabled() {
 				return ctx.Next() // if no keys are set up, any error we get here is not an error.
 			}
 			ctx.Set("WWW-Authenticate", "Bearer")
@@ -276,6 +372,7 @@ func getApiKeyErrorHandler(opaqueErrors bool, apiKeys []string) fiber.ErrorHandl
 			}
 			return ctx.Status(401).Render("public/views/login", fiber.Map{
 				"Title": "Login Required",
+				"SSO":   sso,
 			})
 		}
 		if opaqueErrors {
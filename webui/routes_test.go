@@ -0,0 +1,83 @@
+package webui_test
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/webui"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// roles are the roles of the users the routes are requested as, RoleNone
+// being a user without any
+var roles = []auth.Role{auth.RoleNone, auth.RoleViewer, auth.RoleOperator, auth.RoleAdmin}
+
+var _ = Describe("Route permissions", func() {
+	var (
+		app  *webui.App
+		keys map[auth.Role]string
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		pool := newTestPool()
+		Expect(pool.CreateAgent("routes", &state.AgentConfig{Name: "routes"}, "")).To(Succeed())
+
+		users, err := auth.NewJSONStore(filepath.Join(dir, "users.json"))
+		Expect(err).NotTo(HaveOccurred())
+		keys = map[auth.Role]string{}
+		for _, role := range roles {
+			name := "user-" + string(role)
+			Expect(users.Create(auth.User{Name: name, Role: role})).To(Succeed())
+			keys[role], _, err = users.CreateAPIKey(name, "test")
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		app = webui.NewApp(
+			webui.WithPool(pool),
+			webui.WithUsers(users),
+			webui.WithStateDir(dir),
+			webui.WithLocalRAGURL("http://127.0.0.1:0"),
+		)
+	})
+
+	request := func(method, path, key string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, err := app.Test(req, -1)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode
+	}
+
+	DescribeTable("lets only the users with the required role through",
+		func(method, path string, required auth.Role) {
+			Expect(request(method, path, "")).To(Equal(fiber.StatusUnauthorized))
+			for _, role := range roles {
+				status := request(method, path, keys[role])
+				if role.Allows(required) {
+					Expect(status).NotTo(Or(Equal(fiber.StatusForbidden), Equal(fiber.StatusUnauthorized)), "%s %s as %q", method, path, role)
+				} else {
+					Expect(status).To(Equal(fiber.StatusForbidden), "%s %s as %q", method, path, role)
+				}
+			}
+		},
+		Entry("agent configuration", "GET", "/api/agent/routes/config", auth.RoleViewer),
+		Entry("agent usage", "GET", "/api/agent/routes/usage", auth.RoleViewer),
+		Entry("action definition", "POST", "/api/action/search/definition", auth.RoleViewer),
+		Entry("metrics", "GET", "/metrics", auth.RoleViewer),
+		Entry("pause an agent", "PUT", "/api/agent/routes/pause", auth.RoleOperator),
+		Entry("clear observables", "DELETE", "/api/agent/routes/observables", auth.RoleOperator),
+		Entry("update an agent", "PUT", "/api/agent/routes/config", auth.RoleAdmin),
+		Entry("secrets", "GET", "/api/secrets", auth.RoleAdmin),
+		Entry("users", "GET", "/api/users", auth.RoleAdmin),
+		Entry("audit log", "GET", "/api/audit", auth.RoleAdmin),
+	)
+})
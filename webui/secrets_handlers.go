@@ -0,0 +1,85 @@
+package webui
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/secrets"
+	"github.com/mudler/LocalAGI/core/state"
+)
+
+// secretStore returns the secret store of the pool, or answers that secrets
+// are disabled
+func secretStore(c *fiber.Ctx, pool *state.AgentPool) (secrets.Store, bool) {
+	store := pool.SecretStore()
+	if store == nil {
+		c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "secrets are disabled, set LOCALAGI_SECRETS_KEY to enable them"})
+		return nil, false
+	}
+	return store, true
+}
+
+// ListSecrets returns the names of the secrets, never their values
+func (a *App) ListSecrets(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store, ok := secretStore(c, pool)
+		if !ok {
+			return nil
+		}
+		list, err := store.List()
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"Secrets": list})
+	}
+}
+
+// SetSecret creates or replaces a secret, its value is the "value" field of
+// the body
+func (a *App) SetSecret(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store, ok := secretStore(c, pool)
+		if !ok {
+			return nil
+		}
+		name := c.Params("name")
+		if err := secrets.ValidateName(name); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		var body struct {
+			Value string `json:"value"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if body.Value == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "value is required"})
+		}
+		if err := store.Set(name, body.Value); err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		recordAudit(pool, c, audit.Entry{Operation: audit.OperationSecretSet, Details: map[string]string{"secret": name}})
+		return c.JSON(fiber.Map{"Name": name, "Reference": secrets.Ref(name)})
+	}
+}
+
+// DeleteSecret removes a secret. Agents referring to it fail to start.
+func (a *App) DeleteSecret(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store, ok := secretStore(c, pool)
+		if !ok {
+			return nil
+		}
+		err := store.Delete(c.Params("name"))
+		if errors.Is(err, secrets.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		recordAudit(pool, c, audit.Entry{Operation: audit.OperationSecretDelete, Details: map[string]string{"secret": c.Params("name")}})
+		return statusJSONMessage(c, "ok")
+	}
+}
@@ -0,0 +1,371 @@
+package webui
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mudler/LocalAGI/core/scheduler"
+	"github.com/mudler/LocalAGI/core/state"
+)
+
+const (
+	defaultTaskRunsLimit = 20
+	maxTaskRunsLimit     = 100
+)
+
+// taskRequest is the payload used to create or update a scheduled task.
+// Either a schedule (schedule_type and schedule_value) or a trigger is set.
+type taskRequest struct {
+	Prompt        string                   `json:"prompt"`
+	ScheduleType  scheduler.ScheduleType   `json:"schedule_type"`
+	ScheduleValue string                   `json:"schedule_value"`
+	Trigger       *scheduler.TaskTrigger   `json:"trigger"`
+	Retry         *scheduler.RetryPolicy   `json:"retry"`
+	Timezone      *string                  `json:"timezone"`
+	Misfire       *scheduler.MisfirePolicy `json:"misfire"`
+	Metadata      map[string]any           `json:"metadata"`
+}
+
+// agentScheduler returns the task scheduler of the agent named in the request
+func agentScheduler(c *fiber.Ctx, pool *state.AgentPool) (*scheduler.Scheduler, error) {
+	agent := pool.GetAgent(c.Params("name"))
+	if agent == nil || agent.TaskScheduler() == nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Agent not found"})
+	}
+	return agent.TaskScheduler(), nil
+}
+
+// agentTask returns the task of the request, making sure it belongs to the agent
+func agentTask(c *fiber.Ctx, sched *scheduler.Scheduler) (*scheduler.Task, error) {
+	task, err := sched.GetTask(c.Params("id"))
+	if err != nil || task.AgentName != c.Params("name") {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Task not found"})
+	}
+	return task, nil
+}
+
+// ListTasks returns the scheduled tasks of an agent
+func (a *App) ListTasks(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		tasks, err := sched.GetTasksByAgent(c.Params("name"))
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"tasks": tasks})
+	}
+}
+
+// CreateTask schedules a new task for an agent
+func (a *App) CreateTask(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		var payload taskRequest
+		if err := c.BodyParser(&payload); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if payload.Prompt == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "prompt is required"})
+		}
+
+		var task *scheduler.Task
+		if payload.Trigger != nil {
+			task, err = scheduler.NewTriggeredTask(c.Params("name"), payload.Prompt, *payload.Trigger)
+		} else {
+			task, err = scheduler.NewTask(c.Params("name"), payload.Prompt, payload.ScheduleType, payload.ScheduleValue)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if payload.Timezone != nil {
+			if err := task.SetTimezone(*payload.Timezone); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+		if payload.Retry != nil && payload.Retry.MaxRetries > 0 {
+			task.Retry = payload.Retry
+		}
+		if payload.Misfire != nil {
+			if err := payload.Misfire.Validate(); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			task.Misfire = payload.Misfire
+		}
+		for k, v := range payload.Metadata {
+			task.Metadata[k] = v
+		}
+
+		if err := sched.CreateTask(task); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusCreated).JSON(task)
+	}
+}
+
+// GetTask returns a single scheduled task
+func (a *App) GetTask(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		task, err := agentTask(c, sched)
+		if task == nil {
+			return err
+		}
+		return c.JSON(task)
+	}
+}
+
+// UpdateTask changes the prompt, schedule, trigger, timezone, retry and
+// misfire policies or metadata of a task. Fields left empty in the payload are kept as they are.
+func (a *App) UpdateTask(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		task, err := agentTask(c, sched)
+		if task == nil {
+			return err
+		}
+
+		var payload taskRequest
+		if err := c.BodyParser(&payload); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		if payload.Prompt != "" {
+			task.Prompt = payload.Prompt
+		}
+
+		if payload.Timezone != nil {
+			task.Timezone = *payload.Timezone
+		}
+
+		switch {
+		case payload.Trigger != nil:
+			if err := payload.Trigger.Validate(); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			task.Trigger = payload.Trigger
+			task.ScheduleType = scheduler.ScheduleTypeTrigger
+			task.ScheduleValue = string(payload.Trigger.On)
+			if err := task.CalculateNextRun(); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+		case payload.ScheduleType != "":
+			task.Trigger = nil
+			task.ScheduleType = payload.ScheduleType
+			task.ScheduleValue = payload.ScheduleValue
+			if err := task.CalculateNextRun(); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+		case payload.Timezone != nil:
+			if err := task.SetTimezone(task.Timezone); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+
+		if payload.Retry != nil {
+			task.Retry = payload.Retry
+			if payload.Retry.MaxRetries <= 0 {
+				task.Retry = nil
+			}
+		}
+		if payload.Misfire != nil {
+			if err := payload.Misfire.Validate(); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			task.Misfire = payload.Misfire
+		}
+		if payload.Metadata != nil {
+			task.Metadata = payload.Metadata
+		}
+
+		if err := sched.UpdateTask(task); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(task)
+	}
+}
+
+// DeleteTask removes a scheduled task
+func (a *App) DeleteTask(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		task, err := agentTask(c, sched)
+		if task == nil {
+			return err
+		}
+
+		if err := sched.DeleteTask(task.ID); err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return statusJSONMessage(c, "ok")
+	}
+}
+
+// GetTaskRuns returns the run history of a task, most recent first,
+// paginated with ?limit= and ?offset=
+func (a *App) GetTaskRuns(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		task, err := agentTask(c, sched)
+		if task == nil {
+			return err
+		}
+
+		limit := c.QueryInt("limit", defaultTaskRunsLimit)
+		if limit <= 0 || limit > maxTaskRunsLimit {
+			limit = defaultTaskRunsLimit
+		}
+		offset := c.QueryInt("offset", 0)
+		if offset < 0 {
+			offset = 0
+		}
+
+		// Fetch one more run than requested to know whether there is a next page
+		runs, err := sched.GetTaskRuns(task.ID, offset+limit+1)
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+
+		hasMore := len(runs) > offset+limit
+		if offset > len(runs) {
+			offset = len(runs)
+		}
+		runs = runs[offset:min(len(runs), offset+limit)]
+
+		return c.JSON(fiber.Map{
+			"runs":     runs,
+			"offset":   offset,
+			"limit":    limit,
+			"has_more": hasMore,
+		})
+	}
+}
+
+// RunTask starts a task immediately, outside of its schedule
+func (a *App) RunTask(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		task, err := agentTask(c, sched)
+		if task == nil {
+			return err
+		}
+
+		if err := sched.RunTaskNow(task.ID); err != nil {
+			if errors.Is(err, scheduler.ErrTaskRunning) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+			}
+			return errorJSONMessage(c, err.Error())
+		}
+		return statusJSONMessage(c, "ok")
+	}
+}
+
+// CancelTask cancels the current run of a task
+func (a *App) CancelTask(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		task, err := agentTask(c, sched)
+		if task == nil {
+			return err
+		}
+
+		if err := sched.CancelRunningTask(task.ID); err != nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return statusJSONMessage(c, "ok")
+	}
+}
+
+// PauseTask stops a task from being scheduled until it is resumed
+func (a *App) PauseTask(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		task, err := agentTask(c, sched)
+		if task == nil {
+			return err
+		}
+
+		if err := sched.PauseTask(task.ID); err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return statusJSONMessage(c, "ok")
+	}
+}
+
+// ResumeTask schedules again a paused or dead lettered task
+func (a *App) ResumeTask(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		task, err := agentTask(c, sched)
+		if task == nil {
+			return err
+		}
+
+		if err := sched.ResumeTask(task.ID); err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return statusJSONMessage(c, "ok")
+	}
+}
+
+// GetTaskChain returns the chain of triggered tasks a task belongs to,
+// starting from the root task
+func (a *App) GetTaskChain(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sched, err := agentScheduler(c, pool)
+		if sched == nil {
+			return err
+		}
+
+		task, err := agentTask(c, sched)
+		if task == nil {
+			return err
+		}
+
+		chain, err := sched.GetTaskChain(task.ID)
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return c.JSON(chain)
+	}
+}
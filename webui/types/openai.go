@@ -319,6 +319,15 @@ type UsageInfo struct {
 	TotalTokens         int          `json:"total_tokens"`
 }
 
+// NewUsageInfo converts the token usage of a job to the Responses API format
+func NewUsageInfo(usage coreTypes.Usage) UsageInfo {
+	return UsageInfo{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+}
+
 // TokenDetails represents details about token usage
 type TokenDetails struct {
 	CachedTokens    int `json:"cached_tokens"`
@@ -352,6 +361,43 @@ type ResponseBody struct {
 	Metadata           map[string]interface{} `json:"metadata"`
 }
 
+// ReasoningItem is the reasoning of the agent, as an item of the output
+type ReasoningItem struct {
+	Type    string               `json:"type"`
+	ID      string               `json:"id"`
+	Status  string               `json:"status"`
+	Summary []MessageContentItem `json:"summary"`
+	Content []MessageContentItem `json:"content"`
+}
+
+// MCPCall is an action run by the agent itself, as an item of the output.
+// Unlike FunctionToolCall, the client has nothing to run.
+type MCPCall struct {
+	Type        string  `json:"type"`
+	ID          string  `json:"id"`
+	Status      string  `json:"status"`
+	ServerLabel string  `json:"server_label"`
+	Name        string  `json:"name"`
+	Arguments   string  `json:"arguments"`
+	Output      *string `json:"output"`
+	Error       *string `json:"error"`
+}
+
+// ResponseStreamEvent represents a server-sent event emitted while streaming a response
+type ResponseStreamEvent struct {
+	Type           string              `json:"type"`
+	SequenceNumber int                 `json:"sequence_number"`
+	Response       *ResponseBody       `json:"response,omitempty"`
+	ItemID         string              `json:"item_id,omitempty"`
+	OutputIndex    int                 `json:"output_index"`
+	ContentIndex   int                 `json:"content_index"`
+	Delta          string              `json:"delta,omitempty"`
+	Item           interface{}         `json:"item,omitempty"`
+	Part           *MessageContentItem `json:"part,omitempty"`
+	Text           *string             `json:"text,omitempty"`
+	Arguments      *string             `json:"arguments,omitempty"`
+}
+
 // Content represents either a string or a slice of ContentItem
 type Content struct {
 	Text  *string        `json:"-"`
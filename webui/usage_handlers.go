@@ -0,0 +1,54 @@
+package webui
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/core/usage"
+)
+
+// GetUsage returns the token usage of an agent, broken down in hourly or
+// daily buckets with ?granularity=hour|day. The range is selected with
+// ?from= and ?to= (RFC3339) and defaults to the last day for hourly buckets
+// and to the last 30 days for daily buckets.
+func (a *App) GetUsage(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		agent := pool.GetAgent(c.Params("name"))
+		if agent == nil || agent.UsageStore() == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Agent not found"})
+		}
+
+		granularity, err := usage.ParseGranularity(c.Query("granularity"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		to := time.Now()
+		if v := c.Query("to"); v != "" {
+			if to, err = time.Parse(time.RFC3339, v); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to: " + err.Error()})
+			}
+		}
+		from := to.Add(-24 * time.Hour)
+		if granularity == usage.GranularityDay {
+			from = to.AddDate(0, 0, -30)
+		}
+		if v := c.Query("from"); v != "" {
+			if from, err = time.Parse(time.RFC3339, v); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from: " + err.Error()})
+			}
+		}
+
+		report, err := agent.UsageStore().Report(usage.Query{
+			From:        from,
+			To:          to,
+			Granularity: granularity,
+		})
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return c.JSON(report)
+	}
+}
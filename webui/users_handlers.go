@@ -0,0 +1,184 @@
+package webui
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/auth"
+)
+
+// userStore returns the users, or answers that users are disabled
+func (app *App) userStore(c *fiber.Ctx) (auth.Store, bool) {
+	if app.config.Users == nil {
+		c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "users are disabled, a state directory is required"})
+		return nil, false
+	}
+	return app.config.Users, true
+}
+
+// userError answers with the status matching an error of the user store
+func userError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, auth.ErrExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return errorJSONMessage(c, err.Error())
+	}
+}
+
+// GetCurrentUser returns the user of the request and its roles, so that
+// clients can hide what the user can't do
+func (app *App) GetCurrentUser() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		_, session := c.Locals(sessionLocal).(*auth.Session)
+		return c.JSON(fiber.Map{
+			"Name":        user.Name,
+			"DisplayName": user.DisplayName,
+			"Role":        user.Role,
+			"Agents":      user.Agents,
+			"Admin":       user.IsAdmin(),
+			"AuthEnabled": app.authEnabled(),
+			"Session":     session,
+		})
+	}
+}
+
+// ListUsers returns the users, without the hashes of their API keys
+func (app *App) ListUsers() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store, ok := app.userStore(c)
+		if !ok {
+			return nil
+		}
+		users, err := store.List()
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		for i := range users {
+			users[i] = users[i].Redacted()
+		}
+		return c.JSON(fiber.Map{"Users": users})
+	}
+}
+
+// CreateUser adds a user. The body holds its name, role and grants on
+// agents.
+func (app *App) CreateUser() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store, ok := app.userStore(c)
+		if !ok {
+			return nil
+		}
+		var user auth.User
+		if err := c.BodyParser(&user); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := user.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := store.Create(user); err != nil {
+			return userError(c, err)
+		}
+		recordAudit(app.config.Pool, c, audit.Entry{Operation: audit.OperationUserCreate, Details: userDetails(user)})
+		return statusJSONMessage(c, "ok")
+	}
+}
+
+// UpdateUser replaces the role and grants of a user
+func (app *App) UpdateUser() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store, ok := app.userStore(c)
+		if !ok {
+			return nil
+		}
+		var user auth.User
+		if err := c.BodyParser(&user); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		user.Name = c.Params("user")
+		if err := user.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := store.Update(user); err != nil {
+			return userError(c, err)
+		}
+		recordAudit(app.config.Pool, c, audit.Entry{Operation: audit.OperationUserUpdate, Details: userDetails(user)})
+		return statusJSONMessage(c, "ok")
+	}
+}
+
+// DeleteUser removes a user and revokes its API keys
+func (app *App) DeleteUser() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store, ok := app.userStore(c)
+		if !ok {
+			return nil
+		}
+		if err := store.Delete(c.Params("user")); err != nil {
+			return userError(c, err)
+		}
+		recordAudit(app.config.Pool, c, audit.Entry{Operation: audit.OperationUserDelete, Details: map[string]string{"user": c.Params("user")}})
+		return statusJSONMessage(c, "ok")
+	}
+}
+
+// CreateUserAPIKey generates an API key for a user. The key is only
+// returned here.
+func (app *App) CreateUserAPIKey() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store, ok := app.userStore(c)
+		if !ok {
+			return nil
+		}
+		var body struct {
+			Name string `json:"name"`
+		}
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&body); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+		key, apiKey, err := store.CreateAPIKey(c.Params("user"), body.Name)
+		if err != nil {
+			return userError(c, err)
+		}
+		apiKey.Hash = ""
+		recordAudit(app.config.Pool, c, audit.Entry{
+			Operation: audit.OperationAPIKeyCreate,
+			Details:   map[string]string{"user": c.Params("user"), "key": apiKey.ID, "name": apiKey.Name},
+		})
+		return c.JSON(fiber.Map{"Key": key, "APIKey": apiKey})
+	}
+}
+
+// DeleteUserAPIKey revokes an API key of a user
+func (app *App) DeleteUserAPIKey() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		store, ok := app.userStore(c)
+		if !ok {
+			return nil
+		}
+		if err := store.DeleteAPIKey(c.Params("user"), c.Params("id")); err != nil {
+			return userError(c, err)
+		}
+		recordAudit(app.config.Pool, c, audit.Entry{
+			Operation: audit.OperationAPIKeyDelete,
+			Details:   map[string]string{"user": c.Params("user"), "key": c.Params("id")},
+		})
+		return statusJSONMessage(c, "ok")
+	}
+}
+
+// userDetails describes a created or updated user in the audit log
+func userDetails(user auth.User) map[string]string {
+	details := map[string]string{"user": user.Name, "role": string(user.Role)}
+	for agent, role := range user.Agents {
+		details["agent:"+agent] = string(role)
+	}
+	return details
+}
@@ -0,0 +1,119 @@
+package webui
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mudler/LocalAGI/core/audit"
+	"github.com/mudler/LocalAGI/core/auth"
+	"github.com/mudler/LocalAGI/core/secrets"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/core/versions"
+)
+
+// requestAuthor identifies who made a request, for the history of agent
+// configurations. API keys from the configuration are masked.
+func requestAuthor(c *fiber.Ctx) string {
+	if user, ok := c.Locals(userLocal).(*auth.User); ok {
+		return user.Name
+	}
+	return "webui"
+}
+
+// ListConfigVersions returns the history of the configuration of an agent,
+// oldest first. The configurations themselves are left out, only the
+// changes of each version are listed.
+func (a *App) ListConfigVersions(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+		if pool.GetConfig(name) == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Agent not found"})
+		}
+		history, err := pool.ConfigVersions(name)
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		for i := range history {
+			history[i].Config = nil
+		}
+		return c.JSON(fiber.Map{"Name": name, "Versions": history})
+	}
+}
+
+// GetConfigVersion returns a version of the configuration of an agent
+func (a *App) GetConfigVersion(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		version, err := strconv.Atoi(c.Params("version"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid version"})
+		}
+		v, err := pool.ConfigVersion(c.Params("name"), version)
+		if errors.Is(err, versions.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		return c.JSON(v)
+	}
+}
+
+// DiffConfigVersions returns the changes between the versions ?from= and
+// ?to= of the configuration of an agent
+func (a *App) DiffConfigVersions(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		from, err := strconv.Atoi(c.Query("from"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid from version"})
+		}
+		to, err := strconv.Atoi(c.Query("to"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid to version"})
+		}
+		changes, err := pool.DiffConfigVersions(c.Params("name"), from, to)
+		if errors.Is(err, versions.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return errorJSONMessage(c, err.Error())
+		}
+		if changes == nil {
+			changes = []versions.Change{}
+		}
+		return c.JSON(fiber.Map{"From": from, "To": to, "Changes": changes})
+	}
+}
+
+// RollbackConfigVersion restarts an agent with a previous version of its
+// configuration
+func (a *App) RollbackConfigVersion(pool *state.AgentPool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		version, err := strconv.Atoi(c.Params("version"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid version"})
+		}
+		name := c.Params("name")
+		if pool.GetConfig(name) == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Agent not found"})
+		}
+		v, err := pool.RollbackConfig(name, version, requestAuthor(c))
+		if errors.Is(err, versions.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, secrets.ErrRedacted) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return errorJSONMessage(c, "Error rolling back agent: "+err.Error())
+		}
+		recordAudit(pool, c, audit.Entry{
+			Operation: audit.OperationAgentUpdate,
+			Agent:     name,
+			Details:   map[string]string{"version": strconv.Itoa(v.Version), "rollback_to": strconv.Itoa(version)},
+		})
+		v.Config = nil
+		return c.JSON(v)
+	}
+}
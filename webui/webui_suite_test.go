@@ -0,0 +1,41 @@
+package webui_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mudler/LocalAGI/core/agent"
+	"github.com/mudler/LocalAGI/core/state"
+	"github.com/mudler/LocalAGI/core/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestWebUI(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "WebUI Suite")
+}
+
+// newTestPool returns a pool whose agents have no actions, connectors,
+// prompts or filters
+func newTestPool() *state.AgentPool {
+	return newTestPoolWithLLM("http://127.0.0.1:0")
+}
+
+// newTestPoolWithLLM is newTestPool with the agents asking the LLM API at apiURL
+func newTestPoolWithLLM(apiURL string) *state.AgentPool {
+	pool, err := state.NewAgentPool("model", "", "", "", "", apiURL, "", GinkgoT().TempDir(),
+		func(*state.AgentConfig) func(context.Context, *state.AgentPool) []types.Action {
+			return func(context.Context, *state.AgentPool) []types.Action { return nil }
+		},
+		func(*state.AgentConfig) []state.Connector { return nil },
+		func(*state.AgentConfig) func(context.Context, *state.AgentPool) []agent.DynamicPrompt {
+			return func(context.Context, *state.AgentPool) []agent.DynamicPrompt { return nil }
+		},
+		func(*state.AgentConfig) types.JobFilters { return nil },
+		"1m", false, nil,
+	)
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(pool.StopAll)
+	return pool
+}